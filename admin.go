@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requireAdmin only passes requests carrying the configured admin bearer
+// token. The admin API is disabled entirely when no token is configured.
+func requireAdmin(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "admin API disabled: no admin-token configured", http.StatusForbidden)
+			return
+		}
+		if !bearerMatches(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		limitBody(next)(w, r)
+	}
+}
+
+func bearerMatches(r *http.Request, token string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+type maintenanceMode struct {
+	active bool
+	until  time.Time
+	reason string
+	lock   sync.Mutex
+}
+
+type MaintenanceRequest struct {
+	Enabled  bool   `json:"enabled"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+type MaintenanceState struct {
+	Active bool       `json:"active"`
+	Until  *time.Time `json:"until,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+var maintenance = &maintenanceMode{}
+
+func (m *maintenanceMode) set(active bool, duration time.Duration, reason string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.active = active
+	m.reason = reason
+	m.until = time.Time{}
+	if active && duration > 0 {
+		m.until = clk.Now().Add(duration)
+	}
+}
+
+// state reports the maintenance state, expiring it if its time is up
+func (m *maintenanceMode) state(now time.Time) MaintenanceState {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.active && !m.until.IsZero() && now.After(m.until) {
+		m.active = false
+		m.reason = ""
+		m.until = time.Time{}
+	}
+
+	ret := MaintenanceState{Active: m.active, Reason: m.reason}
+	if !m.until.IsZero() {
+		until := m.until
+		ret.Until = &until
+	}
+	return ret
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// notificationsMuted reports whether alerts and webhooks should be held back.
+// State keeps updating while muted; only notifications are suppressed.
+func notificationsMuted() bool {
+	return maintenance.state(clk.Now()).Active
+}
+
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	req := MaintenanceRequest{}
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		if duration, err = time.ParseDuration(req.Duration); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	maintenance.set(req.Enabled, duration, req.Reason)
+	b, _ := json.Marshal(maintenance.state(clk.Now()))
+	w.Write(b)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// The admin API, debug endpoints and metrics only listen on loopback unless
+// admin-address says otherwise
+const DEFAULT_ADMIN_ADDRESS = "127.0.0.1:8081"
+
+var adminServer *http.Server
+
+// newAdminMux returns the mux the admin, debug and metrics endpoints are
+// registered on. An empty admin-address keeps them on the public port.
+func newAdminMux(cfg LabwatchConfig) *http.ServeMux {
+	if cfg.AdminAddress == "" {
+		return http.DefaultServeMux
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// publicHandler serves the default mux. pprof and expvar register their
+// endpoints there on import, so with an admin listener of its own every
+// /debug/ path is hidden from the public port.
+func publicHandler(cfg LabwatchConfig) http.Handler {
+	if cfg.AdminAddress == "" {
+		return http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/debug/") {
+			http.NotFound(w, r)
+			return
+		}
+		http.DefaultServeMux.ServeHTTP(w, r)
+	})
+}
+
+// loopbackAddress reports whether addr only accepts connections from this
+// host. An address without a host listens on every interface.
+func loopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// adminExposureWarning explains why the admin endpoints are reachable from
+// other hosts with nothing guarding them, or returns "" when they aren't
+func adminExposureWarning(cfg LabwatchConfig) string {
+	if cfg.AdminToken != "" {
+		return ""
+	}
+	if cfg.AdminAddress == "" {
+		return "admin-address is empty so the debug and metrics endpoints are served on the public port and no admin-token is configured"
+	}
+	if !loopbackAddress(cfg.AdminAddress) {
+		return "admin-address " + cfg.AdminAddress + " is not a loopback address and no admin-token is configured, the debug and metrics endpoints are open to the network"
+	}
+	return ""
+}
+
+// startAdminServer listens on admin-address. The listener is opened before
+// returning so a port already in use fails startup.
+func startAdminServer(cfg LabwatchConfig, mux *http.ServeMux, log *slog.Logger) error {
+	if cfg.AdminAddress == "" {
+		return nil
+	}
+	log = log.With("operation", "adminServer")
+	lis, err := net.Listen("tcp", cfg.AdminAddress)
+	if err != nil {
+		return err
+	}
+	adminServer = &http.Server{Handler: mux, MaxHeaderBytes: int(cfg.RequestLimits.MaxHeaderBytes)}
+	go func() {
+		if err := adminServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Error("admin server failed", "error", err.Error())
+		}
+	}()
+	log.Info("serving admin and debug endpoints", "address", cfg.AdminAddress)
+	return nil
+}
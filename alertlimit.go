@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var alertLimitWindow = time.Duration(1) * time.Minute
+
+// alertLimiter caps how many notifications go out per minute across every
+// alert source. Those over the cap are counted and, once the window has room
+// again, reported as a single summary notification.
+type alertLimiter struct {
+	max  int
+	lock sync.Mutex
+	sent []time.Time
+
+	suppressed int
+	severity   HealthLevel
+	flush      *time.Timer
+}
+
+func newAlertLimiter(max int) *alertLimiter {
+	return &alertLimiter{max: max}
+}
+
+// trim forgets sends that have left the window. The caller holds the lock.
+func (l *alertLimiter) trim(now time.Time) {
+	i := 0
+	for i < len(l.sent) && now.Sub(l.sent[i]) >= alertLimitWindow {
+		i++
+	}
+	l.sent = l.sent[i:]
+}
+
+// allow reports whether note may be sent now. When it may not, note is
+// counted toward the summary and summarize is called with it once the window
+// frees up.
+func (l *alertLimiter) allow(note Notification, now time.Time, summarize func(Notification)) bool {
+	if l == nil || l.max <= 0 {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.trim(now)
+	if len(l.sent) < l.max && l.suppressed == 0 {
+		l.sent = append(l.sent, now)
+		return true
+	}
+
+	l.suppressed++
+	l.severity = worst(l.severity, HealthLevel(note.Severity))
+	if l.flush == nil {
+		l.flush = time.AfterFunc(l.sent[0].Add(alertLimitWindow).Sub(now), func() { l.summarize(summarize) })
+	}
+	return false
+}
+
+// summarize sends the summary of suppressed notifications, which takes up a
+// slot in the window like any other notification
+func (l *alertLimiter) summarize(send func(Notification)) {
+	l.lock.Lock()
+	now := time.Now()
+	note := Notification{
+		Title:    "alerts suppressed",
+		Message:  fmt.Sprintf("%d more alerts suppressed, more than %d in %s", l.suppressed, l.max, alertLimitWindow),
+		Severity: string(l.severity),
+		Time:     now,
+	}
+	l.trim(now)
+	l.sent = append(l.sent, now)
+	l.suppressed = 0
+	l.severity = HEALTH_LEVEL_OK
+	l.flush = nil
+	l.lock.Unlock()
+
+	send(note)
+}
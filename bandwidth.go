@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+var quotaWindow = time.Duration(1) * time.Hour
+
+// QuotaConfig caps outbound bytes per hour. A client over its own quota, or
+// any client while the global quota is used up, only receives every
+// sample-every'th event until the hour rolls over. Status updates are never
+// cut. Zero disables a quota.
+type QuotaConfig struct {
+	ClientBytesPerHour config.ByteSize            `yaml:"client-bytes-per-hour"`
+	GlobalBytesPerHour config.ByteSize            `yaml:"global-bytes-per-hour"`
+	Clients            map[string]config.ByteSize `yaml:"clients"`
+	SampleEvery        int                        `yaml:"sample-every"`
+}
+
+var quotas QuotaConfig
+
+var clientBytes = expvar.NewMap("client_bytes_sent")
+var totalBytes = expvar.NewInt("bytes_sent")
+
+// byteWindow counts bytes within the current quota window
+type byteWindow struct {
+	start time.Time
+	bytes int64
+	lock  sync.Mutex
+}
+
+func (b *byteWindow) add(n int64, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.roll(now)
+	b.bytes += n
+}
+
+func (b *byteWindow) over(limit int64, now time.Time) bool {
+	if limit <= 0 {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.roll(now)
+	return b.bytes >= limit
+}
+
+func (b *byteWindow) roll(now time.Time) {
+	if now.Sub(b.start) >= quotaWindow {
+		b.start = now
+		b.bytes = 0
+	}
+}
+
+var globalWindow = &byteWindow{}
+
+// clientStats is shared by every copy of a ClientInfo
+type clientStats struct {
+	bytes    atomic.Int64
+	window   byteWindow
+	sampling bool
+	skipped  int
+}
+
+func (c ClientInfo) countBytes(n int) {
+	if n <= 0 || c.stats == nil {
+		return
+	}
+	now := time.Now()
+	c.stats.bytes.Add(int64(n))
+	c.stats.window.add(int64(n), now)
+	globalWindow.add(int64(n), now)
+	clientBytes.Add(c.Name, int64(n))
+	totalBytes.Add(int64(n))
+}
+
+func (c ClientInfo) quota() int64 {
+	if q, ok := quotas.Clients[c.Name]; ok {
+		return int64(q)
+	}
+	return int64(quotas.ClientBytesPerHour)
+}
+
+// sampleEvent decides whether the next event goes out under the client's
+// quota. changed is set when the client just entered or left sampled mode.
+// Only the client's own goroutine calls this.
+func (c ClientInfo) sampleEvent(now time.Time) (send bool, changed bool) {
+	if c.stats == nil {
+		return true, false
+	}
+	over := c.stats.window.over(c.quota(), now) || globalWindow.over(int64(quotas.GlobalBytesPerHour), now)
+	changed = over != c.stats.sampling
+	c.stats.sampling = over
+	if !over {
+		c.stats.skipped = 0
+		return true, changed
+	}
+
+	every := quotas.SampleEvery
+	if every < 1 {
+		every = 1
+	}
+	c.stats.skipped++
+	if c.stats.skipped >= every {
+		c.stats.skipped = 0
+		return true, changed
+	}
+	return false, changed
+}
+
+func (c ClientInfo) samplingMessage() string {
+	return fmt.Sprintf("outbound quota reached, sending 1 in %d events until the quota window resets", max(quotas.SampleEvery, 1))
+}
+
+// countingWriter counts what is written to the client at the connection, so
+// the figures match what actually crosses the wire. Websocket upgrades are
+// counted through the hijacked connection.
+type countingWriter struct {
+	http.ResponseWriter
+	client ClientInfo
+}
+
+func (w countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.client.countBytes(n)
+	return n, err
+}
+
+func (w countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return countingConn{Conn: conn, client: w.client}, brw, nil
+}
+
+type countingConn struct {
+	net.Conn
+	client ClientInfo
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.client.countBytes(n)
+	return n, err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+var broadcastStats = expvar.NewMap("broadcast")
+
+// broadcastItem is a status or an event awaiting broadcast. done channels
+// are closed once a status, or the one that superseded it, has been handed
+// to every client.
+type broadcastItem struct {
+	status *LabStatus
+	event  *loki.LogEvent
+	done   []chan struct{}
+}
+
+// broadcaster hands statuses and events to clients and outputs on a
+// goroutine of its own, so a client or output that blocks (the block slow
+// client policy, say) only holds up the broadcast and never the watch loop
+// or the watchers feeding it. Only the latest status is worth sending, so a
+// queued status is replaced by a newer one; past maxEvents queued events
+// the oldest are dropped.
+type broadcaster struct {
+	maxEvents int
+	lock      sync.Mutex
+	queue     []broadcastItem
+	events    int
+	wake      chan struct{}
+	closing   bool
+	stopped   chan struct{}
+}
+
+var broadcasts *broadcaster
+
+func init() {
+	broadcastStats.Set("queued", expvar.Func(func() any { return broadcasts.queued() }))
+}
+
+func newBroadcaster(maxEvents int) *broadcaster {
+	b := &broadcaster{maxEvents: maxEvents, wake: make(chan struct{}, 1), stopped: make(chan struct{})}
+	go b.run()
+	return b
+}
+
+// status queues a status, replacing any still waiting. done, when not nil,
+// is closed once it has been broadcast.
+func (b *broadcaster) status(s LabStatus, done chan struct{}) {
+	b.lock.Lock()
+	item := broadcastItem{status: &s}
+	if done != nil {
+		item.done = append(item.done, done)
+	}
+	for i, q := range b.queue {
+		if q.status != nil {
+			item.done = append(q.done, item.done...)
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			broadcastStats.Add("statuses_dropped", 1)
+			break
+		}
+	}
+	b.queue = append(b.queue, item)
+	b.lock.Unlock()
+	b.signal()
+}
+
+func (b *broadcaster) event(e loki.LogEvent) {
+	b.lock.Lock()
+	if b.maxEvents > 0 && b.events >= b.maxEvents {
+		for i, q := range b.queue {
+			if q.event != nil {
+				b.queue = append(b.queue[:i], b.queue[i+1:]...)
+				b.events--
+				broadcastStats.Add("events_dropped", 1)
+				break
+			}
+		}
+	}
+	b.queue = append(b.queue, broadcastItem{event: &e})
+	b.events++
+	b.lock.Unlock()
+	b.signal()
+}
+
+// stop lets the broadcaster hand out what is queued and then exit, closing
+// the returned channel. Anything queued later is never sent.
+func (b *broadcaster) stop() <-chan struct{} {
+	b.lock.Lock()
+	b.closing = true
+	b.lock.Unlock()
+	b.signal()
+	return b.stopped
+}
+
+func (b *broadcaster) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *broadcaster) queued() int {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.queue)
+}
+
+// next takes the oldest queued item, reporting false when there is none
+func (b *broadcaster) next() (broadcastItem, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.queue) == 0 {
+		return broadcastItem{}, false
+	}
+	item := b.queue[0]
+	b.queue = b.queue[1:]
+	if item.event != nil {
+		b.events--
+	}
+	return item, true
+}
+
+func (b *broadcaster) run() {
+	for range b.wake {
+		for {
+			item, ok := b.next()
+			if !ok {
+				break
+			}
+			b.deliver(item)
+		}
+		b.lock.Lock()
+		closing := b.closing
+		b.lock.Unlock()
+		if closing {
+			close(b.stopped)
+			return
+		}
+	}
+}
+
+func (b *broadcaster) deliver(item broadcastItem) {
+	if item.status != nil {
+		for _, q := range statusQueues() {
+			q.offer(*item.status, clk.Now())
+		}
+		dispatcher.Status(*item.status)
+	} else {
+		for _, q := range eventQueues() {
+			q.offer(*item.event, clk.Now())
+		}
+		dispatcher.Event(*item.event)
+	}
+	for _, done := range item.done {
+		close(done)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var brokerPublishTimeout = time.Duration(5) * time.Second
+var brokerMaxReconnectInterval = time.Duration(1) * time.Minute
+
+// BrokerConfig publishes status, and optionally events, to an MQTT broker.
+// Nothing is published unless url and topic are set.
+type BrokerConfig struct {
+	URL         string `yaml:"url"`
+	Topic       string `yaml:"topic"`
+	EventsTopic string `yaml:"events-topic"`
+	ClientID    string `yaml:"client-id"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	QoS         byte   `yaml:"qos"`
+	Retain      bool   `yaml:"retain"` // status only
+}
+
+func init() {
+	registerSchemaSection("broker", SCHEMA_OUTPUT, "Publishes status and events to an MQTT broker", "broker")
+}
+
+func validateBroker(cfg BrokerConfig) error {
+	if cfg.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if cfg.Topic == "" {
+		return fmt.Errorf("topic is required when url is set")
+	}
+	if cfg.QoS > 2 {
+		return fmt.Errorf("qos must be 0, 1 or 2")
+	}
+	return nil
+}
+
+// brokerPublisher is an output publishing to an MQTT broker
+type brokerPublisher struct {
+	cfg    BrokerConfig
+	client mqtt.Client
+	log    *slog.Logger
+}
+
+func newBrokerPublisher(cfg BrokerConfig, instance string, log *slog.Logger) *brokerPublisher {
+	log = log.With("operation", "brokerPublisher")
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(cfg.URL)
+	opts.SetClientID(cfg.ClientID)
+	if cfg.ClientID == "" {
+		opts.SetClientID("labwatch-" + instance)
+	}
+	opts.SetUsername(cfg.Username)
+	opts.SetPassword(cfg.Password)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetMaxReconnectInterval(brokerMaxReconnectInterval)
+	opts.SetOnConnectHandler(func(mqtt.Client) { log.Info("connected to broker", "url", cfg.URL) })
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Warn("lost broker connection, reconnecting", "error", err.Error())
+	})
+
+	return &brokerPublisher{
+		cfg:    cfg,
+		client: mqtt.NewClient(opts),
+		log:    log,
+	}
+}
+
+func (p *brokerPublisher) Name() string {
+	return "broker"
+}
+
+// Start connects in the background; with connect retry enabled the client
+// keeps trying until the broker is reachable
+func (p *brokerPublisher) Start(ctx context.Context) error {
+	p.client.Connect()
+	go func() {
+		<-ctx.Done()
+		p.client.Disconnect(250)
+	}()
+	return nil
+}
+
+func (p *brokerPublisher) HandleStatus(s LabStatus) {
+	p.publish(p.cfg.Topic, s, p.cfg.Retain)
+}
+
+func (p *brokerPublisher) HandleEvent(e loki.LogEvent) {
+	if p.cfg.EventsTopic != "" {
+		p.publish(p.cfg.EventsTopic, e, false)
+	}
+}
+
+func (p *brokerPublisher) Healthy() bool {
+	return p.client.IsConnectionOpen()
+}
+
+func (p *brokerPublisher) publish(topic string, v any, retain bool) {
+	b, err := encodeJSON(v)
+	if err != nil {
+		p.log.Error("failed to encode broker message", "error", err.Error())
+		return
+	}
+	token := p.client.Publish(topic, p.cfg.QoS, retain, b)
+	if !token.WaitTimeout(brokerPublishTimeout) {
+		p.log.Warn("timed out publishing to broker", "topic", topic)
+	} else if err := token.Error(); err != nil {
+		p.log.Warn("failed to publish to broker", "topic", topic, "error", err.Error())
+	}
+}
+
+// checkBroker connects with a throwaway client to prove the broker accepts
+// the configured credentials
+func checkBroker(ctx context.Context, cfg BrokerConfig) error {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(cfg.URL)
+	opts.SetClientID(fmt.Sprintf("labwatch-self-test-%d", time.Now().UnixNano()))
+	opts.SetUsername(cfg.Username)
+	opts.SetPassword(cfg.Password)
+	client := mqtt.NewClient(opts)
+
+	token := client.Connect()
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := token.Error(); err != nil {
+		return err
+	}
+	client.Disconnect(0)
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// ChurnConfig flags entities whose checks keep changing state. Transitions
+// are counted over the sliding window, and an entity averaging more than
+// threshold transitions per hour over it is churning, which the rollup
+// reports as a warning even while every check is currently good. A zero
+// threshold only counts.
+type ChurnConfig struct {
+	Window    config.Duration `yaml:"window"`
+	Threshold float64         `yaml:"threshold"`
+}
+
+func validateChurn(cfg ChurnConfig) error {
+	if cfg.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	if cfg.Threshold < 0 {
+		return fmt.Errorf("threshold must not be negative")
+	}
+	return nil
+}
+
+// ChurnStatus is the churn section of the LabStatus
+type ChurnStatus struct {
+	Window    string                 `json:"window"`
+	Threshold float64                `json:"threshold,omitempty"`
+	Watchers  map[string]int         `json:"watchers"`
+	Entities  map[string]EntityChurn `json:"entities,omitempty"`
+}
+
+// EntityChurn counts the transitions of one entity within the window
+type EntityChurn struct {
+	Watcher     string  `json:"watcher"`
+	Transitions int     `json:"transitions"`
+	PerHour     float64 `json:"per_hour"`
+	Churning    bool    `json:"churning,omitempty"`
+}
+
+// churnTracker counts the transitions found by the transition tracker.
+// Every transition observed so far is a Talos node check changing state.
+type churnTracker struct {
+	cfg      ChurnConfig
+	times    map[string][]time.Time
+	churning map[string]bool
+	lock     sync.Mutex
+}
+
+var churn *churnTracker
+
+func newChurnTracker(cfg ChurnConfig) *churnTracker {
+	return &churnTracker{cfg: cfg, times: map[string][]time.Time{}, churning: map[string]bool{}}
+}
+
+// churnChange is an entity starting or stopping churning
+type churnChange struct {
+	entity   string
+	churning bool
+	status   EntityChurn
+}
+
+// observe counts the transitions and reports the entities whose churning
+// changed as a result. The restart markers in restored history are skipped,
+// as are problems released by a recovered dependency, which didn't change.
+func (t *churnTracker) observe(trs []Transition, now time.Time) []churnChange {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	cutoff := now.Add(-time.Duration(t.cfg.Window))
+	for _, tr := range trs {
+		if tr.Check == "labwatch" || tr.ReleasedBy != "" || !tr.Time.After(cutoff) {
+			continue
+		}
+		t.times[tr.Node] = append(t.times[tr.Node], tr.Time)
+	}
+	return t.update(now)
+}
+
+// expire drops transitions that left the window, reporting the entities
+// whose churning changed. changed is set when any count did, so the status
+// is rebroadcast as counts decay.
+func (t *churnTracker) expire(now time.Time) (changes []churnChange, changed bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	cutoff := now.Add(-time.Duration(t.cfg.Window))
+	for entity, times := range t.times {
+		kept := times
+		for len(kept) > 0 && !kept[0].After(cutoff) {
+			kept = kept[1:]
+		}
+		if len(kept) != len(times) {
+			changed = true
+		}
+		if len(kept) == 0 {
+			delete(t.times, entity)
+		} else {
+			t.times[entity] = kept
+		}
+	}
+	return t.update(now), changed
+}
+
+func (t *churnTracker) entity(entity string, now time.Time) EntityChurn {
+	cutoff := now.Add(-time.Duration(t.cfg.Window))
+	n := 0
+	for _, at := range t.times[entity] {
+		if at.After(cutoff) {
+			n++
+		}
+	}
+	perHour := float64(n) / time.Duration(t.cfg.Window).Hours()
+	return EntityChurn{
+		Watcher:     WATCHER_TALOS,
+		Transitions: n,
+		PerHour:     perHour,
+		Churning:    t.cfg.Threshold > 0 && perHour > t.cfg.Threshold,
+	}
+}
+
+func (t *churnTracker) update(now time.Time) []churnChange {
+	names := []string{}
+	for entity := range t.times {
+		names = append(names, entity)
+	}
+	for entity := range t.churning {
+		if _, ok := t.times[entity]; !ok {
+			names = append(names, entity)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []churnChange{}
+	for _, entity := range names {
+		s := t.entity(entity, now)
+		if s.Churning == t.churning[entity] {
+			continue
+		}
+		if s.Churning {
+			t.churning[entity] = true
+		} else {
+			delete(t.churning, entity)
+		}
+		changes = append(changes, churnChange{entity: entity, churning: s.Churning, status: s})
+	}
+	return changes
+}
+
+func (t *churnTracker) status(now time.Time) ChurnStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := ChurnStatus{
+		Window:    time.Duration(t.cfg.Window).String(),
+		Threshold: t.cfg.Threshold,
+		Watchers:  map[string]int{WATCHER_TALOS: 0},
+		Entities:  map[string]EntityChurn{},
+	}
+	for entity := range t.times {
+		s := t.entity(entity, now)
+		if s.Transitions == 0 {
+			continue
+		}
+		ret.Entities[entity] = s
+		ret.Watchers[s.Watcher] += s.Transitions
+	}
+	return ret
+}
+
+// announceChurn emits an event and a notification as an entity starts or
+// stops churning, so alerting can follow instability that never shows in
+// the current state
+func announceChurn(c churnChange, window time.Duration, log *slog.Logger) {
+	alert := "churn/" + c.entity
+	level := "info"
+	msg := fmt.Sprintf("%s stopped churning, %d transitions in the last %s", c.entity, c.status.Transitions, window)
+	if c.churning {
+		level = "warning"
+		msg = fmt.Sprintf("%s is churning, %d transitions in the last %s", c.entity, c.status.Transitions, window)
+		log.Warn(msg)
+		notifier.send(Notification{Title: "node churning", Node: c.entity, Message: msg, Severity: string(HEALTH_LEVEL_WARN), Time: clk.Now(), Alert: alert})
+	} else {
+		log.Info(msg)
+		notifier.send(Notification{Title: "node stable", Node: c.entity, Message: msg, Severity: string(HEALTH_LEVEL_OK), Time: clk.Now(), Alert: alert, Resolved: true})
+	}
+	broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: level, Message: msg, Timestamp: clk.Now()}, log)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// A node whose Ready flips every minute for ten minutes is churning though
+// it ends up ready, and stops once its transitions leave the window
+func TestChurnReplay(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cfg := defaultConfig()
+	cfg.Churn = ChurnConfig{Window: config.Duration(time.Hour), Threshold: 5}
+	transitions := newTransitionTracker(cfg.Correlation, newEventBuffer(10, time.Hour))
+	tracker := newChurnTracker(cfg.Churn)
+
+	nodes := func(ready bool) map[string]talos.NodeStatus {
+		return map[string]talos.NodeStatus{
+			"cp1":     {Node: "cp1", WatcherState: talos.CONNECTION_OK, Ready: true},
+			"worker1": {Node: "worker1", WatcherState: talos.CONNECTION_OK, Ready: ready},
+		}
+	}
+	var changes []churnChange
+	startedAt := -1
+	for minute := range 10 {
+		now := start.Add(time.Duration(minute) * time.Minute)
+		for _, c := range tracker.observe(transitions.observe(nodes(minute%2 == 1), now), now) {
+			changes = append(changes, c)
+			startedAt = minute
+		}
+	}
+	// The sixth transition takes it over five an hour
+	if len(changes) != 1 || changes[0].entity != "worker1" || !changes[0].churning || startedAt != 6 {
+		t.Fatalf("changes %+v starting at minute %d", changes, startedAt)
+	}
+
+	end := start.Add(9 * time.Minute)
+	status := tracker.status(end)
+	got := status.Entities["worker1"]
+	if got.Transitions != 9 || got.PerHour != 9 || !got.Churning || got.Watcher != WATCHER_TALOS {
+		t.Errorf("worker1 churn %+v", got)
+	}
+	if _, ok := status.Entities["cp1"]; ok {
+		t.Error("steady cp1 counted")
+	}
+	if status.Watchers[WATCHER_TALOS] != 9 || status.Window != "1h0m0s" {
+		t.Errorf("churn status %+v", status)
+	}
+
+	// Every check is good now, yet the rollup warns about the instability
+	summary := computeSummary(LabStatus{Talos: nodes(true), Churn: status}, cfg.Summary)
+	if summary.State != HEALTH_LEVEL_WARN || len(summary.Reasons) != 1 || summary.Reasons[0] != "worker1 churning, 9 transitions in 1h0m0s" {
+		t.Errorf("summary %+v", summary)
+	}
+
+	// As the window slides past the flapping the counts decay until it stops
+	changes, changed := tracker.expire(start.Add(time.Hour + 3*time.Minute))
+	if !changed || len(changes) != 0 || tracker.status(start.Add(time.Hour + 3*time.Minute)).Entities["worker1"].Transitions != 6 {
+		t.Errorf("an hour later: changes %+v, changed %t", changes, changed)
+	}
+	changes, _ = tracker.expire(start.Add(time.Hour + 4*time.Minute))
+	if len(changes) != 1 || changes[0].churning || changes[0].status.Transitions != 5 {
+		t.Errorf("stopping: changes %+v", changes)
+	}
+	later := start.Add(2 * time.Hour)
+	changes, changed = tracker.expire(later)
+	if !changed || len(changes) != 0 || len(tracker.status(later).Entities) != 0 {
+		t.Errorf("two hours later: changes %+v, changed %t, status %+v", changes, changed, tracker.status(later))
+	}
+	if summary := computeSummary(LabStatus{Talos: nodes(true), Churn: tracker.status(later)}, cfg.Summary); summary.State != HEALTH_LEVEL_OK {
+		t.Errorf("summary once stable %+v", summary)
+	}
+}
+
+func TestChurnSkipsRestartMarkers(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newChurnTracker(ChurnConfig{Window: config.Duration(time.Hour), Threshold: 1})
+	tracker.observe([]Transition{
+		{Time: now, Node: "labwatch", Check: "labwatch"},
+		// Restored history from before the window
+		{Time: now.Add(-2 * time.Hour), Node: "worker1", Check: "ready"},
+	}, now)
+	if s := tracker.status(now); len(s.Entities) != 0 {
+		t.Errorf("counted %+v", s.Entities)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SlowClientPolicy string
+
+// SLOW_CLIENT_DROP drops updates for a client whose queue is full,
+// SLOW_CLIENT_DISCONNECT additionally disconnects it once the queue has
+// stayed full for the configured duration and SLOW_CLIENT_BLOCK waits for the
+// client, holding up everyone else, as labwatch originally did
+const SLOW_CLIENT_DROP SlowClientPolicy = "drop"
+const SLOW_CLIENT_DISCONNECT SlowClientPolicy = "disconnect-after"
+const SLOW_CLIENT_BLOCK SlowClientPolicy = "block"
+
+func (SlowClientPolicy) JSONSchema() map[string]any {
+	return enumSchema(string(SLOW_CLIENT_DROP), string(SLOW_CLIENT_DISCONNECT), string(SLOW_CLIENT_BLOCK))
+}
+
+var slowClientPolicy = SLOW_CLIENT_DROP
+var slowClientDisconnectAfter time.Duration
+var clientQueueSize = 64
+
+var droppedUpdates = expvar.NewMap("client_dropped_updates")
+var slowClientDisconnects = expvar.NewInt("slow_client_disconnects")
+
+func validateSlowClientPolicy(p SlowClientPolicy, after time.Duration) error {
+	switch p {
+	case SLOW_CLIENT_DROP, SLOW_CLIENT_BLOCK:
+	case SLOW_CLIENT_DISCONNECT:
+		if after <= 0 {
+			return fmt.Errorf("slow-client-disconnect-after must be positive with the %s policy", p)
+		}
+	default:
+		return fmt.Errorf("invalid slow-client-policy %q: must be one of drop|disconnect-after|block", p)
+	}
+	return nil
+}
+
+// clientQueue buffers updates for one client. kicked is closed when the
+// slow client policy decides to disconnect it and done when it goes away.
+type clientQueue[T any] struct {
+	ch        chan T
+	name      string
+	fullSince time.Time
+	kicked    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	kickOnce  sync.Once
+}
+
+func newClientQueue[T any](name string) *clientQueue[T] {
+	return &clientQueue[T]{
+		ch:     make(chan T, clientQueueSize),
+		name:   name,
+		kicked: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// offer applies the slow client policy to deliver v. It is only called
+// from the broadcasting goroutine.
+func (q *clientQueue[T]) offer(v T, now time.Time) {
+	if slowClientPolicy == SLOW_CLIENT_BLOCK {
+		select {
+		case q.ch <- v:
+		case <-q.done:
+		}
+		return
+	}
+
+	select {
+	case q.ch <- v:
+		q.fullSince = time.Time{}
+		return
+	default:
+	}
+
+	droppedUpdates.Add(q.name, 1)
+	if q.fullSince.IsZero() {
+		q.fullSince = now
+	}
+	if slowClientPolicy == SLOW_CLIENT_DISCONNECT && now.Sub(q.fullSince) >= slowClientDisconnectAfter {
+		q.kickOnce.Do(func() {
+			slowClientDisconnects.Add(1)
+			close(q.kicked)
+		})
+	}
+}
+
+func (q *clientQueue[T]) close() {
+	q.closeOnce.Do(func() { close(q.done) })
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ANONYMOUS_CLIENT = "anonymous"
+
+var maxClientNameLength = 32
+var clientNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// Per client metrics are keyed by the sanitized client name so a reconnecting
+// client continues its series and unnamed clients share one
+var clientMessages = expvar.NewMap("client_messages_sent")
+var clientWriteErrors = expvar.NewMap("client_write_errors_by_name")
+
+// ClientInfo identifies a streaming client. Name comes from ?client_name and
+// ID is unique among connected clients, suffixed when a name is reused.
+type ClientInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Endpoint  string    `json:"endpoint"`
+	Remote    string    `json:"remote"`
+	Connected time.Time `json:"connected"`
+	BytesSent int64     `json:"bytes_sent"`
+	Sampling  bool      `json:"sampling"`
+
+	// Subscriptions lists what a /stream client currently receives
+	Subscriptions []string `json:"subscriptions,omitempty"`
+
+	stats *clientStats
+}
+
+type clientRegistry struct {
+	clients map[string]ClientInfo
+	lock    sync.Mutex
+}
+
+var registry = &clientRegistry{clients: map[string]ClientInfo{}}
+
+func sanitizeClientName(name string) string {
+	name = strings.Trim(clientNameInvalid.ReplaceAllString(name, "-"), "-")
+	if len(name) > maxClientNameLength {
+		name = name[:maxClientNameLength]
+	}
+	if name == "" {
+		return ANONYMOUS_CLIENT
+	}
+	return name
+}
+
+func (reg *clientRegistry) register(r *http.Request, endpoint string) ClientInfo {
+	c := ClientInfo{
+		Name:      sanitizeClientName(r.URL.Query().Get("client_name")),
+		Endpoint:  endpoint,
+		Remote:    r.RemoteAddr,
+		Connected: time.Now(),
+		stats:     &clientStats{},
+	}
+
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	if c.Name == ANONYMOUS_CLIENT {
+		c.ID = ANONYMOUS_CLIENT + "-" + uuid.New().String()
+	} else {
+		c.ID = c.Name
+		for i := 2; reg.clients[c.ID].ID != ""; i++ {
+			c.ID = fmt.Sprintf("%s#%d", c.Name, i)
+		}
+	}
+	reg.clients[c.ID] = c
+	return c
+}
+
+func (reg *clientRegistry) setSubscriptions(id string, subscriptions []string) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	if c, ok := reg.clients[id]; ok {
+		c.Subscriptions = subscriptions
+		reg.clients[id] = c
+	}
+}
+
+func (reg *clientRegistry) unregister(id string) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	delete(reg.clients, id)
+}
+
+func (reg *clientRegistry) list() []ClientInfo {
+	reg.lock.Lock()
+	ret := make([]ClientInfo, 0, len(reg.clients))
+	for _, c := range reg.clients {
+		c.BytesSent = c.stats.bytes.Load()
+		c.Sampling = c.stats.window.over(c.quota(), time.Now()) || globalWindow.over(int64(quotas.GlobalBytesPerHour), time.Now())
+		ret = append(ret, c)
+	}
+	reg.lock.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// track records the outcome of a write to the client and passes err through
+func (c ClientInfo) track(err error) error {
+	if err != nil {
+		writeErrors.Add(c.Endpoint, 1)
+		clientWriteErrors.Add(c.Name, 1)
+		return err
+	}
+	clientMessages.Add(c.Name, 1)
+	return nil
+}
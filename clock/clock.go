@@ -0,0 +1,50 @@
+// Package clock lets time dependent code be driven by a fake clock in tests
+// instead of waiting on the wall clock
+package clock
+
+import "time"
+
+// Clock is the part of the time package labwatch depends on
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker is a time.Ticker behind an interface so the fake can drive it
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// OrReal returns c, or the wall clock when c is nil, so a zero value config
+// keeps working
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real{}
+	}
+	return c
+}
@@ -0,0 +1,123 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake only moves when told to. Timers and tickers fire, in order, as
+// Advance passes their deadlines.
+type Fake struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After or a ticker. Ticker channels are buffered by
+// one and, like time.Ticker, drop ticks the reader hasn't kept up with.
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := &fakeWaiter{at: f.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- f.now
+		return w.ch
+	}
+	f.waiters = append(f.waiters, w)
+	return w.ch
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clock.Fake.NewTicker")
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := &fakeWaiter{at: f.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	return &fakeTicker{clock: f, waiter: w}
+}
+
+// Waiters reports how many timers and tickers are pending, so a test can
+// wait until the code under test is blocked on the clock before advancing
+func (f *Fake) Waiters() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.waiters)
+}
+
+// Advance moves the clock forward by d, firing everything due on the way
+func (f *Fake) Advance(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	end := f.now.Add(d)
+	for {
+		next := f.nextDue(end)
+		if next == nil {
+			break
+		}
+		f.now = next.at
+		select {
+		case next.ch <- f.now:
+		default:
+		}
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			f.remove(next)
+		}
+	}
+	f.now = end
+}
+
+// nextDue is the earliest waiter due by end. The caller holds the lock.
+func (f *Fake) nextDue(end time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, w := range f.waiters {
+		if !w.at.After(end) && (next == nil || w.at.Before(next.at)) {
+			next = w
+		}
+	}
+	return next
+}
+
+// remove drops a waiter. The caller holds the lock.
+func (f *Fake) remove(w *fakeWaiter) {
+	for i, o := range f.waiters {
+		if o == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.clock.remove(t.waiter)
+}
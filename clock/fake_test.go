@@ -0,0 +1,123 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fired reports the time sent on ch, if anything is waiting on it
+func fired(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeNowOnlyMovesOnAdvance(t *testing.T) {
+	f := NewFake(epoch)
+	if !f.Now().Equal(epoch) {
+		t.Fatalf("now is %s", f.Now())
+	}
+	f.Advance(90 * time.Second)
+	if want := epoch.Add(90 * time.Second); !f.Now().Equal(want) {
+		t.Errorf("now is %s, want %s", f.Now(), want)
+	}
+}
+
+func TestFakeAfter(t *testing.T) {
+	f := NewFake(epoch)
+	ch := f.After(time.Minute)
+	if f.Waiters() != 1 {
+		t.Fatalf("%d waiters", f.Waiters())
+	}
+
+	f.Advance(59 * time.Second)
+	if _, ok := fired(ch); ok {
+		t.Fatal("fired early")
+	}
+	f.Advance(2 * time.Second)
+	at, ok := fired(ch)
+	if !ok || !at.Equal(epoch.Add(time.Minute)) {
+		t.Fatalf("fired %t at %s, want the deadline", ok, at)
+	}
+	if f.Waiters() != 0 {
+		t.Error("a fired timer is still waiting")
+	}
+
+	// A non-positive wait has already passed
+	if at, ok := fired(f.After(0)); !ok || !at.Equal(f.Now()) {
+		t.Errorf("After(0) fired %t at %s", ok, at)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(epoch)
+	tick := f.NewTicker(10 * time.Second)
+
+	for i := 1; i <= 3; i++ {
+		f.Advance(10 * time.Second)
+		at, ok := fired(tick.C())
+		if want := epoch.Add(time.Duration(i) * 10 * time.Second); !ok || !at.Equal(want) {
+			t.Fatalf("tick %d fired %t at %s, want %s", i, ok, at, want)
+		}
+	}
+
+	// Like time.Ticker, ticks the reader missed are dropped rather than
+	// queued
+	f.Advance(time.Minute)
+	if at, ok := fired(tick.C()); !ok || !at.Equal(epoch.Add(40*time.Second)) {
+		t.Errorf("missed ticks left %t at %s, want the first one only", ok, at)
+	}
+	if _, ok := fired(tick.C()); ok {
+		t.Error("more than one missed tick kept")
+	}
+
+	tick.Stop()
+	f.Advance(time.Minute)
+	if _, ok := fired(tick.C()); ok {
+		t.Error("stopped ticker fired")
+	}
+	if f.Waiters() != 0 {
+		t.Error("stopped ticker still waiting")
+	}
+}
+
+func TestFakeAdvanceFiresInOrder(t *testing.T) {
+	f := NewFake(epoch)
+	late := f.After(30 * time.Second)
+	early := f.After(10 * time.Second)
+	tick := f.NewTicker(20 * time.Second)
+
+	// Each waiter sees the clock at its own deadline, not the end of the
+	// advance
+	f.Advance(time.Minute)
+	for name, tt := range map[string]struct {
+		ch   <-chan time.Time
+		want time.Duration
+	}{
+		"early": {early, 10 * time.Second},
+		"late":  {late, 30 * time.Second},
+		"tick":  {tick.C(), 20 * time.Second},
+	} {
+		if at, ok := fired(tt.ch); !ok || !at.Equal(epoch.Add(tt.want)) {
+			t.Errorf("%s fired %t at %s, want %s", name, ok, at, epoch.Add(tt.want))
+		}
+	}
+	if !f.Now().Equal(epoch.Add(time.Minute)) {
+		t.Errorf("now is %s after the advance", f.Now())
+	}
+}
+
+func TestOrReal(t *testing.T) {
+	if _, ok := OrReal(nil).(Real); !ok {
+		t.Error("nil clock isn't the wall clock")
+	}
+	f := NewFake(epoch)
+	if OrReal(f) != f {
+		t.Error("clock replaced")
+	}
+}
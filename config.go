@@ -0,0 +1,611 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/selfupdate"
+	"github.com/DRuggeri/labwatch/watchers/journald"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"gopkg.in/yaml.v3"
+)
+
+type LabwatchConfig struct {
+	LogSource                 LogSource                 `yaml:"log-source"`
+	LokiAddress               string                    `yaml:"loki-address"`
+	LokiQuery                 string                    `yaml:"loki-query"`
+	LokiQueryFile             string                    `yaml:"loki-query-file"`
+	LokiMaxClockSkew          config.Duration           `yaml:"loki-max-clock-skew"`
+	LokiQueryDebounce         config.Duration           `yaml:"loki-query-debounce"`
+	LokiNarrowFactor          float64                   `yaml:"loki-narrow-factor"`
+	LokiTraceIDField          string                    `yaml:"loki-trace-id-field"`
+	LokiFallbackQuery         string                    `yaml:"loki-fallback-query"`
+	LokiFallbackWindow        config.Duration           `yaml:"loki-fallback-window"`
+	LokiDedupWindow           config.Duration           `yaml:"loki-dedup-window"`
+	LokiQueryName             string                    `yaml:"loki-query-name"`
+	LokiLabelsCacheTTL        config.Duration           `yaml:"loki-labels-cache-ttl"`
+	LokiStatsIncludeQuery     bool                      `yaml:"loki-stats-include-query"`
+	LokiSilenceThreshold      config.Duration           `yaml:"loki-silence-threshold"`
+	LokiSilenceExempt         []string                  `yaml:"loki-silence-exempt"`
+	LokiSilenceServices       []string                  `yaml:"loki-silence-services"`
+	LokiFieldTypes            map[string]loki.FieldType `yaml:"loki-field-types"`
+	LokiPermissionCheck       bool                      `yaml:"loki-permission-check"`
+	LokiPermissionQuery       string                    `yaml:"loki-permission-query"`
+	TalosConfigFile           string                    `yaml:"talos-config"`
+	TalosClusterName          string                    `yaml:"talos-cluster"`
+	TalosContext              string                    `yaml:"talos-context"`
+	TalosKubernetesNodes      map[string]string         `yaml:"talos-kubernetes-nodes"`
+	TalosStageEvents          bool                      `yaml:"talos-stage-events"`
+	TalosMaxSilence           config.Duration           `yaml:"talos-max-silence"`
+	TalosPollMin              config.Duration           `yaml:"talos-poll-min"`
+	TalosPollMax              config.Duration           `yaml:"talos-poll-max"`
+	TalosPollSteady           int                       `yaml:"talos-poll-steady"`
+	FileWatchInterval         config.Duration           `yaml:"file-watch-interval"`
+	ShutdownWebhook           string                    `yaml:"shutdown-webhook"`
+	ShutdownTimeout           config.Duration           `yaml:"shutdown-timeout"`
+	SnapshotFile              string                    `yaml:"snapshot-file"`
+	StateFile                 string                    `yaml:"state-file"`
+	StateInterval             config.Duration           `yaml:"state-interval"`
+	StatsResolution           config.Duration           `yaml:"stats-resolution"`
+	WarmupTimeout             config.Duration           `yaml:"warmup-timeout"`
+	AdminToken                string                    `yaml:"admin-token"`
+	AdminAddress              string                    `yaml:"admin-address"`
+	ListenAddress             string                    `yaml:"listen-address"`
+	IndexFile                 string                    `yaml:"index-file"`
+	DataDir                   string                    `yaml:"data-dir"`
+	StreamToken               string                    `yaml:"stream-token"`
+	TicketTTL                 config.Duration           `yaml:"ticket-ttl"`
+	AllowedOrigins            []string                  `yaml:"allowed-origins"`
+	DepartedGrace             config.Duration           `yaml:"departed-grace"`
+	NotifyWebhook             string                    `yaml:"notify-webhook"`
+	NotifyQueueFile           string                    `yaml:"notify-queue-file"`
+	NotifyMaxAge              config.Duration           `yaml:"notify-max-age"`
+	NotifyMaxPerMinute        int                       `yaml:"notify-max-per-minute"`
+	NotifyDestinations        []NotifyDestination       `yaml:"notify-destinations"`
+	OutputQueueSize           int                       `yaml:"output-queue-size"`
+	BroadcastQueueSize        int                       `yaml:"broadcast-queue-size"`
+	MaxConnections            int                       `yaml:"max-connections"`
+	ClientQueueSize           int                       `yaml:"client-queue-size"`
+	MaxConnectionLifetime     config.Duration           `yaml:"max-connection-lifetime"`
+	StatusResyncEvery         int                       `yaml:"status-resync-every"`
+	DefaultExcludes           []string                  `yaml:"default-excludes"`
+	StatusResyncInterval      config.Duration           `yaml:"status-resync-interval"`
+	SlowClientPolicy          SlowClientPolicy          `yaml:"slow-client-policy"`
+	SlowClientDisconnectAfter config.Duration           `yaml:"slow-client-disconnect-after"`
+	HealthExpression          string                    `yaml:"health-expression"`
+	JSONStyle                 JSONStyle                 `yaml:"json-style"`
+	GRPCHealthAddress         string                    `yaml:"grpc-health-address"`
+	EventGroupBy              []string                  `yaml:"event-group-by"`
+	EventDedup                bool                      `yaml:"event-dedup"`
+	EventAggregate            bool                      `yaml:"event-aggregate"`
+	EventAggregateWindow      config.Duration           `yaml:"event-aggregate-window"`
+	EventNodeContext          bool                      `yaml:"event-node-context"`
+	InstanceName              string                    `yaml:"instance-name"`
+	ProxyURL                  string                    `yaml:"proxy-url"`
+	NoProxy                   []string                  `yaml:"no-proxy"`
+	DebugInjection            bool                      `yaml:"debug-injection"`
+	DebugInjectionTTL         config.Duration           `yaml:"debug-injection-ttl"`
+	UpdateURL                 string                    `yaml:"update-url"`
+	UpdateCheckInterval       config.Duration           `yaml:"update-check-interval"`
+
+	Self           SelfConfig                     `yaml:"self"`
+	EtcdSnapshot   EtcdSnapshotConfig             `yaml:"etcd-snapshot"`
+	StatsD         StatsDConfig                   `yaml:"statsd"`
+	Broker         BrokerConfig                   `yaml:"broker"`
+	Journald       journald.JournaldWatcherConfig `yaml:"journald"`
+	LogAlerts      []LogAlertConfig               `yaml:"log-alerts"`
+	EventStreams   []EventStreamConfig            `yaml:"event-streams"`
+	NodeThresholds []NodeThresholdConfig          `yaml:"node-thresholds"`
+	Quotas         QuotaConfig                    `yaml:"quotas"`
+	RequestLimits  RequestLimits                  `yaml:"request-limits"`
+	Enrichment     EnrichmentConfig               `yaml:"enrichment"`
+	Correlation    CorrelationConfig              `yaml:"correlation"`
+	Identities     IdentityConfig                 `yaml:"identities"`
+	Summary        SummaryConfig                  `yaml:"summary"`
+	Incidents      IncidentConfig                 `yaml:"incidents"`
+	Churn          ChurnConfig                    `yaml:"churn"`
+	DependsOn      DependencyConfig               `yaml:"depends-on"`
+	Staleness      map[string]config.Duration     `yaml:"staleness"`
+}
+
+func defaultConfig() LabwatchConfig {
+	hostname, _ := os.Hostname()
+	return LabwatchConfig{
+		InstanceName:              hostname,
+		LogSource:                 LOG_SOURCE_LOKI,
+		LokiAddress:               "boss.local:3100",
+		AdminAddress:              DEFAULT_ADMIN_ADDRESS,
+		ListenAddress:             DEFAULT_LISTEN_ADDRESS,
+		IndexFile:                 "websockets.html",
+		LokiQuery:                 `{ host_name =~ ".+" } | json`,
+		LokiMaxClockSkew:          config.Duration(5 * time.Minute),
+		LokiQueryDebounce:         config.Duration(30 * time.Second),
+		LokiNarrowFactor:          0.5,
+		LokiFallbackWindow:        config.Duration(5 * time.Minute),
+		LokiDedupWindow:           config.Duration(10 * time.Second),
+		LokiStatsIncludeQuery:     true,
+		LokiLabelsCacheTTL:        config.Duration(1 * time.Minute),
+		LokiPermissionCheck:       true,
+		TalosConfigFile:           "/home/boss/talos/talosconfig",
+		TalosClusterName:          "koobs",
+		TalosMaxSilence:           config.Duration(60 * time.Second),
+		TalosPollMin:              config.Duration(30 * time.Second),
+		TalosPollMax:              config.Duration(5 * time.Minute),
+		TalosPollSteady:           3,
+		FileWatchInterval:         config.Duration(30 * time.Second),
+		ShutdownTimeout:           config.Duration(10 * time.Second),
+		WarmupTimeout:             config.Duration(30 * time.Second),
+		StateInterval:             config.Duration(1 * time.Minute),
+		NotifyMaxAge:              config.Duration(24 * time.Hour),
+		DepartedGrace:             config.Duration(5 * time.Minute),
+		StatsResolution:           config.Duration(1 * time.Minute),
+		TicketTTL:                 config.Duration(30 * time.Second),
+		MaxConnections:            256,
+		ClientQueueSize:           64,
+		StatusResyncEvery:         500,
+		StatusResyncInterval:      config.Duration(30 * time.Minute),
+		SlowClientPolicy:          SLOW_CLIENT_DROP,
+		SlowClientDisconnectAfter: config.Duration(30 * time.Second),
+		OutputQueueSize:           1000,
+		BroadcastQueueSize:        10000,
+		Quotas:                    QuotaConfig{SampleEvery: 10},
+		RequestLimits:             RequestLimits{MaxHeaderBytes: 64 * 1024, MaxBodyBytes: 1024 * 1024},
+		Enrichment: EnrichmentConfig{
+			DNSTimeout:  config.Duration(2 * time.Second),
+			DNSCacheTTL: config.Duration(1 * time.Hour),
+			Budget:      config.Duration(25 * time.Millisecond),
+		},
+		Self:                 SelfConfig{Interval: config.Duration(30 * time.Second), MaxFDPercent: 80, MaxRSS: 1024 * 1024 * 1024},
+		EtcdSnapshot:         EtcdSnapshotConfig{Timeout: config.Duration(5 * time.Minute), Interval: config.Duration(1 * time.Minute), MaxAge: config.Duration(7 * 24 * time.Hour)},
+		StatsD:               StatsDConfig{Prefix: "labwatch", Interval: config.Duration(10 * time.Second)},
+		DebugInjectionTTL:    config.Duration(5 * time.Minute),
+		UpdateURL:            selfupdate.DEFAULT_URL,
+		JSONStyle:            JSON_STYLE_LEGACY,
+		EventGroupBy:         []string{"host"},
+		EventAggregateWindow: config.Duration(10 * time.Second),
+		Correlation: CorrelationConfig{
+			BufferSize:   1000,
+			BufferMaxAge: config.Duration(1 * time.Hour),
+			HistorySize:  500,
+			MaxEvents:    20,
+			Window:       config.Duration(60 * time.Second),
+			MaxBytes:     16384,
+			HostAliases:  map[string][]string{},
+		},
+		Incidents: IncidentConfig{
+			Gap:         config.Duration(10 * time.Minute),
+			LinkBy:      []string{INCIDENT_LINK_NODE, INCIDENT_LINK_DEPENDENCY},
+			HistorySize: 100,
+		},
+		Churn: ChurnConfig{
+			Window:    config.Duration(1 * time.Hour),
+			Threshold: 12,
+		},
+		Staleness: map[string]config.Duration{
+			SECTION_TALOS: config.Duration(2 * time.Minute),
+			SECTION_LOGS:  config.Duration(10 * time.Minute),
+		},
+		Summary: SummaryConfig{
+			Disconnected:     HEALTH_LEVEL_CRITICAL,
+			NotReady:         HEALTH_LEVEL_WARN,
+			ServiceUnhealthy: HEALTH_LEVEL_WARN,
+			Services: map[string]HealthLevel{
+				"etcd": HEALTH_LEVEL_CRITICAL,
+			},
+		},
+	}
+}
+
+// loadConfig reads the config file over the top of the default config. The
+// defaults alone are returned when no file is given.
+func loadConfig(file string) (LabwatchConfig, error) {
+	cfg := finishConfig(defaultConfig())
+	if file == "" {
+		return cfg, validateConfig(cfg)
+	}
+
+	d, err := os.ReadFile(file)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err = config.Unmarshal(d, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if cfg.LokiQueryFile != "" {
+		if cfg.LokiQuery, err = readLokiQueryFile(cfg.LokiQueryFile); err != nil {
+			return cfg, fmt.Errorf("failed to read loki-query-file: %w", err)
+		}
+	}
+	cfg = finishConfig(cfg)
+	return cfg, validateConfig(cfg)
+}
+
+// loadConfigLenient is loadConfig for --no-config-strict. Each top level
+// section that fails to decode or validate is left at its default and its
+// error returned, so one bad value can't keep labwatch from starting.
+func loadConfigLenient(file string) (LabwatchConfig, []error) {
+	cfg := finishConfig(defaultConfig())
+	if file == "" {
+		return cfg, nil
+	}
+
+	d, err := os.ReadFile(file)
+	if err != nil {
+		return cfg, []error{fmt.Errorf("failed to read config file: %w", err)}
+	}
+	pending, err := config.Split(d)
+	if err != nil {
+		return cfg, []error{fmt.Errorf("failed to parse config file: %w", err)}
+	}
+
+	// A section may only validate alongside another, so the failures are
+	// retried until a pass accepts nothing new
+	accepted := []config.Section{}
+	failed := map[string]error{}
+	for progress := true; progress; {
+		progress = false
+		next := []config.Section{}
+		for _, s := range pending {
+			candidate, err := decodeConfigSections(append(accepted[:len(accepted):len(accepted)], s))
+			if err != nil {
+				failed[s.Key] = err
+				next = append(next, s)
+				continue
+			}
+			cfg = candidate
+			accepted = append(accepted, s)
+			progress = true
+		}
+		pending = next
+	}
+
+	errs := []error{}
+	for _, s := range pending {
+		errs = append(errs, sectionError{key: s.Key, err: failed[s.Key]})
+	}
+	return cfg, errs
+}
+
+func decodeConfigSections(sections []config.Section) (LabwatchConfig, error) {
+	cfg := defaultConfig()
+	if err := config.DecodeSections(sections, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.LokiQueryFile != "" {
+		var err error
+		if cfg.LokiQuery, err = readLokiQueryFile(cfg.LokiQueryFile); err != nil {
+			return cfg, fmt.Errorf("failed to read loki-query-file: %w", err)
+		}
+	}
+	cfg = finishConfig(cfg)
+	return cfg, validateConfig(cfg)
+}
+
+// sectionError is a config section that failed to load leniently and runs
+// on its default
+type sectionError struct {
+	key string
+	err error
+}
+
+func (e sectionError) Error() string {
+	return fmt.Sprintf("using the default %s: %v", e.key, e.err)
+}
+
+func (e sectionError) Unwrap() error {
+	return e.err
+}
+
+// configError is the problem with the config file when running on fallback
+// defaults, shown to clients as config_error
+var configError atomic.Value
+
+// configDefaulted is the sections running on defaults because they failed
+// to load, keyed "config file" when the whole file did. It is written at
+// startup and by reloads, which hold reloadLock.
+var configDefaulted = map[string]bool{}
+
+// setConfigErrors reports the sections that failed to load. A section that
+// loads on a reload keeps being reported until the reload applies it or
+// labwatch restarts, as it is still running on its default.
+func setConfigErrors(errs []error, log *slog.Logger) {
+	msgs := []string{}
+	failing := map[string]bool{}
+	for _, err := range errs {
+		log.Error("CONFIG ERROR: running with defaults in place of the broken section", "error", err.Error())
+		msgs = append(msgs, err.Error())
+		key := "config file"
+		if se := (sectionError{}); errors.As(err, &se) {
+			key = se.key
+		}
+		failing[key] = true
+		configDefaulted[key] = true
+	}
+	for _, key := range slices.Sorted(maps.Keys(configDefaulted)) {
+		if failing[key] {
+			continue
+		}
+		if reloadApplied[key] {
+			delete(configDefaulted, key)
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s loads now but runs on defaults until a restart", key))
+	}
+	configError.Store(strings.Join(msgs, "; "))
+}
+
+func currentConfigError() string {
+	s, _ := configError.Load().(string)
+	return s
+}
+
+func validateConfig(cfg LabwatchConfig) error {
+	if _, err := newIdentityMap(cfg.Identities); err != nil {
+		return fmt.Errorf("invalid identities: %w", err)
+	}
+	if tmpl, err := compileLokiQuery(cfg.LokiQuery); err != nil {
+		return fmt.Errorf("invalid loki-query template: %w", err)
+	} else if tmpl != nil {
+		if _, err := renderLokiQuery(tmpl, LokiQueryData{Nodes: []string{"node"}, ClusterName: cfg.TalosClusterName}); err != nil {
+			return fmt.Errorf("invalid loki-query template: %w", err)
+		}
+	}
+	if err := validateStatsResolution(time.Duration(cfg.StatsResolution)); err != nil {
+		return fmt.Errorf("invalid stats-resolution: %w", err)
+	}
+	if cfg.LogSource != LOG_SOURCE_LOKI && cfg.LogSource != LOG_SOURCE_JOURNALD {
+		return fmt.Errorf("invalid log-source %q: must be one of loki|journald", cfg.LogSource)
+	}
+	if _, err := newJournaldWatcher(cfg, slog.Default()); err != nil {
+		return fmt.Errorf("invalid journald: %w", err)
+	}
+	if cfg.LokiFallbackQuery != "" && cfg.LokiFallbackWindow <= 0 {
+		return fmt.Errorf("invalid loki-fallback-window: must be positive")
+	}
+	if cfg.DepartedGrace < 0 {
+		return fmt.Errorf("invalid departed-grace: must not be negative")
+	}
+	if cfg.NotifyMaxPerMinute < 0 {
+		return fmt.Errorf("invalid notify-max-per-minute: must not be negative")
+	}
+	if cfg.NotifyMaxAge < 0 {
+		return fmt.Errorf("invalid notify-max-age: must not be negative")
+	}
+	if err := validateNotifyDestinations(cfg); err != nil {
+		return fmt.Errorf("invalid notify-destinations: %w", err)
+	}
+	if err := loki.ValidateFieldTypes(cfg.LokiFieldTypes); err != nil {
+		return fmt.Errorf("invalid loki-field-types: %w", err)
+	}
+	if cfg.LokiSilenceThreshold < 0 {
+		return fmt.Errorf("invalid loki-silence-threshold: must not be negative")
+	}
+	if cfg.LokiLabelsCacheTTL < 0 {
+		return fmt.Errorf("invalid loki-labels-cache-ttl: must not be negative")
+	}
+	if cfg.LokiDedupWindow < 0 {
+		return fmt.Errorf("invalid loki-dedup-window: must not be negative")
+	}
+	if cfg.TalosPollMin <= 0 {
+		return fmt.Errorf("invalid talos-poll-min: must be positive")
+	}
+	if cfg.TalosPollMax < cfg.TalosPollMin {
+		return fmt.Errorf("invalid talos-poll-max: must not be less than talos-poll-min")
+	}
+	for node, name := range cfg.TalosKubernetesNodes {
+		if name == "" {
+			return fmt.Errorf("invalid talos-kubernetes-nodes: no kubernetes node name given for %s", node)
+		}
+	}
+	if cfg.TalosPollSteady < 1 {
+		return fmt.Errorf("invalid talos-poll-steady: must be at least 1")
+	}
+	if cfg.LokiNarrowFactor <= 0 || cfg.LokiNarrowFactor >= 1 {
+		return fmt.Errorf("invalid loki-narrow-factor %v: must be between 0 and 1", cfg.LokiNarrowFactor)
+	}
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy-url: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy-url scheme %q: must be one of http|https|socks5", u.Scheme)
+		}
+	}
+	if _, err := newLogAlerter(cfg.LogAlerts, slog.Default()); err != nil {
+		return fmt.Errorf("invalid log-alerts: %w", err)
+	}
+	if _, err := newThresholdEvaluator(cfg.NodeThresholds, slog.Default()); err != nil {
+		return fmt.Errorf("invalid node-thresholds: %w", err)
+	}
+	if err := validateEnrichment(cfg.Enrichment); err != nil {
+		return fmt.Errorf("invalid enrichment: %w", err)
+	}
+	if err := validateRequestLimits(cfg.RequestLimits); err != nil {
+		return fmt.Errorf("invalid request-limits: %w", err)
+	}
+	if err := validateBroker(cfg.Broker); err != nil {
+		return fmt.Errorf("invalid broker: %w", err)
+	}
+	if err := validateSelf(cfg.Self); err != nil {
+		return fmt.Errorf("invalid self: %w", err)
+	}
+	if err := validateEtcdSnapshot(cfg.EtcdSnapshot); err != nil {
+		return fmt.Errorf("invalid etcd-snapshot: %w", err)
+	}
+	if err := validateStatsD(cfg.StatsD); err != nil {
+		return fmt.Errorf("invalid statsd: %w", err)
+	}
+	if _, err := newEventRouter(cfg.EventStreams); err != nil {
+		return fmt.Errorf("invalid event-streams: %w", err)
+	}
+	if _, err := newOriginPolicy(cfg.AllowedOrigins); err != nil {
+		return fmt.Errorf("invalid allowed-origins: %w", err)
+	}
+	if cfg.TicketTTL <= 0 {
+		return fmt.Errorf("ticket-ttl must be positive")
+	}
+	if cfg.EventAggregate && time.Duration(cfg.EventAggregateWindow) <= 0 {
+		return fmt.Errorf("event-aggregate-window must be positive when event-aggregate is enabled")
+	}
+	if err := validateSlowClientPolicy(cfg.SlowClientPolicy, time.Duration(cfg.SlowClientDisconnectAfter)); err != nil {
+		return err
+	}
+	if cfg.MaxConnectionLifetime < 0 {
+		return fmt.Errorf("invalid max-connection-lifetime: must not be negative")
+	}
+	if cfg.AdminAddress != "" {
+		if _, _, err := net.SplitHostPort(cfg.AdminAddress); err != nil {
+			return fmt.Errorf("invalid admin-address: %w", err)
+		}
+	}
+	if _, _, err := net.SplitHostPort(cfg.ListenAddress); err != nil {
+		return fmt.Errorf("invalid listen-address: %w", err)
+	}
+	if err := validateDataPaths(cfg); err != nil {
+		return err
+	}
+	if cfg.StatusResyncEvery < 0 {
+		return fmt.Errorf("invalid status-resync-every: must not be negative")
+	}
+	if cfg.StatusResyncInterval < 0 {
+		return fmt.Errorf("invalid status-resync-interval: must not be negative")
+	}
+	if cfg.UpdateCheckInterval < 0 {
+		return fmt.Errorf("invalid update-check-interval: must not be negative")
+	}
+	if cfg.UpdateCheckInterval > 0 {
+		u, err := url.Parse(cfg.UpdateURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid update-url %q: must be an http or https URL", cfg.UpdateURL)
+		}
+	}
+	if cfg.ClientQueueSize < 1 {
+		return fmt.Errorf("client-queue-size must be at least 1")
+	}
+	if _, err := parseExcludes(cfg.DefaultExcludes); err != nil {
+		return fmt.Errorf("invalid default-excludes: %w", err)
+	}
+	if cfg.BroadcastQueueSize < 1 {
+		return fmt.Errorf("broadcast-queue-size must be at least 1")
+	}
+	if len(cfg.EventGroupBy) == 0 {
+		return fmt.Errorf("event-group-by must list at least one field")
+	}
+	if cfg.JSONStyle != JSON_STYLE_LEGACY && cfg.JSONStyle != JSON_STYLE_V2 {
+		return fmt.Errorf("invalid json-style %q: must be one of legacy|v2", cfg.JSONStyle)
+	}
+	if _, err := compileHealthExpression(cfg.HealthExpression); err != nil {
+		return fmt.Errorf("invalid health-expression: %w", err)
+	}
+	if err := validateIncidents(cfg.Incidents); err != nil {
+		return fmt.Errorf("invalid incidents: %w", err)
+	}
+	if err := validateChurn(cfg.Churn); err != nil {
+		return fmt.Errorf("invalid churn: %w", err)
+	}
+	if err := validateDependencies(cfg.DependsOn); err != nil {
+		return fmt.Errorf("invalid depends-on: %w", err)
+	}
+	levels := []HealthLevel{cfg.Summary.Disconnected, cfg.Summary.NotReady, cfg.Summary.ServiceUnhealthy}
+	for _, l := range cfg.Summary.Services {
+		levels = append(levels, l)
+	}
+	for _, l := range levels {
+		if l != HEALTH_LEVEL_OK && l != HEALTH_LEVEL_WARN && l != HEALTH_LEVEL_CRITICAL {
+			return fmt.Errorf("invalid summary level %q: must be one of ok|warn|critical", l)
+		}
+	}
+	return nil
+}
+
+// redactConfig blanks out the secrets in cfg
+func redactConfig(cfg LabwatchConfig) LabwatchConfig {
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = "REDACTED"
+	}
+	if cfg.StreamToken != "" {
+		cfg.StreamToken = "REDACTED"
+	}
+	if cfg.Broker.Password != "" {
+		cfg.Broker.Password = "REDACTED"
+	}
+	cfg.Broker.URL = redactUserinfo(cfg.Broker.URL)
+	cfg.ProxyURL = redactUserinfo(cfg.ProxyURL)
+	cfg.UpdateURL = redactUserinfo(cfg.UpdateURL)
+	cfg.NotifyWebhook = redactWebhook(cfg.NotifyWebhook)
+	cfg.ShutdownWebhook = redactWebhook(cfg.ShutdownWebhook)
+	destinations := make([]NotifyDestination, len(cfg.NotifyDestinations))
+	for i, d := range cfg.NotifyDestinations {
+		d.URL = redactWebhook(d.URL)
+		destinations[i] = d
+	}
+	cfg.NotifyDestinations = destinations
+	return cfg
+}
+
+// redactUserinfo hides the credentials in a URL. A username alone is often
+// a token, so it is hidden too when there's no password.
+func redactUserinfo(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "REDACTED"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	} else if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	return u.String()
+}
+
+// redactWebhook keeps only where a webhook goes. Services like Slack and
+// Discord put the credential in the path or query, so everything past the
+// host is hidden along with any credentials.
+func redactWebhook(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return "REDACTED"
+	}
+	ret := &url.URL{Scheme: u.Scheme, Host: u.Host}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		ret.Path = "/REDACTED"
+	}
+	return ret.String()
+}
+
+// handleConfig serves the running config, with secrets redacted, alongside
+// the values derived from it at runtime
+func handleConfig(cfg LabwatchConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := redactConfig(cfg)
+
+		// Round trip through YAML so the keys match the config file
+		y, _ := yaml.Marshal(c)
+		keyed := map[string]any{}
+		yaml.Unmarshal(y, &keyed)
+
+		b, _ := json.Marshal(map[string]any{
+			"config":               keyed,
+			"effective_loki_query": effectiveLokiQuery.Load(),
+		})
+		w.Write(b)
+	}
+}
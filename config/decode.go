@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a problem with one value in a config document. Path is written
+// the way a user would navigate to it, e.g. log-alerts[2].debounce.
+type Error struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s (line %d): %s", e.Path, e.Line, e.Msg)
+}
+
+// Errors collects every Error found while decoding a document
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// valueError is returned by the UnmarshalYAML methods in this package and
+// turned into an Error once the path of the node is known
+type valueError struct {
+	line, column int
+	msg          string
+}
+
+func (e *valueError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+func valueErrorf(n *yaml.Node, format string, args ...any) error {
+	return &valueError{line: n.Line, column: n.Column, msg: fmt.Sprintf(format, args...)}
+}
+
+var typeErrorLine = regexp.MustCompile(`^line ([0-9]+): (.*)$`)
+
+// typeErrorKind picks out the collections type errors complain of, which
+// start on the line of their first child
+var typeErrorKind = regexp.MustCompile(`^cannot unmarshal !!(seq|map) `)
+
+type position struct {
+	line, column int
+}
+
+type lineKind struct {
+	line int
+	kind yaml.Kind
+}
+
+// paths maps the positions in a document back to the path of the value there
+type paths struct {
+	exact  map[position]string
+	byLine map[int]string
+	byKind map[lineKind]string
+}
+
+func (p *paths) walk(n *yaml.Node, path string) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			p.walk(c, path)
+		}
+	case yaml.MappingNode:
+		p.add(n, path)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			p.walk(n.Content[i+1], key)
+		}
+	case yaml.SequenceNode:
+		p.add(n, path)
+		for i, c := range n.Content {
+			p.walk(c, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.ScalarNode, yaml.AliasNode:
+		p.add(n, path)
+	}
+}
+
+// add records the path of n. Collections start on the line of their first
+// child, so the innermost value claims a line.
+func (p *paths) add(n *yaml.Node, path string) {
+	p.exact[position{n.Line, n.Column}] = path
+	p.byLine[n.Line] = path
+	p.byKind[lineKind{n.Line, n.Kind}] = path
+}
+
+// wrap finds the path of the value an error is about. Type errors only
+// give a line, which a collection shares with its first child, so those
+// naming a collection are matched to one.
+func (p *paths) wrap(line, column int, msg string) *Error {
+	path, ok := p.exact[position{line, column}]
+	if !ok {
+		kind := yaml.ScalarNode
+		switch m := typeErrorKind.FindStringSubmatch(msg); {
+		case m == nil:
+		case m[1] == "seq":
+			kind = yaml.SequenceNode
+		case m[1] == "map":
+			kind = yaml.MappingNode
+		}
+		if path, ok = p.byKind[lineKind{line, kind}]; !ok {
+			path = p.byLine[line]
+		}
+	}
+	return &Error{Path: path, Line: line, Msg: msg}
+}
+
+// Unmarshal decodes data into out like yaml.Unmarshal, but reports bad values
+// as Errors naming their path in the document
+func Unmarshal(data []byte, out any) error {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if root.Kind == 0 {
+		return nil
+	}
+	return decode(&root, out)
+}
+
+// Section is one top level key of a document and its value
+type Section struct {
+	Key  string
+	Line int
+
+	key, value *yaml.Node
+}
+
+// Split returns the top level sections of a mapping document in the order
+// they appear
+func Split(data []byte) ([]Section, error) {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		return nil, nil
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, &Error{Line: doc.Line, Msg: "document must be a mapping"}
+	}
+	ret := []Section{}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		k := doc.Content[i]
+		ret = append(ret, Section{Key: k.Value, Line: k.Line, key: k, value: doc.Content[i+1]})
+	}
+	return ret, nil
+}
+
+// DecodeSections decodes just the given sections into out, reporting bad
+// values as Unmarshal does with lines from the original document
+func DecodeSections(sections []Section, out any) error {
+	m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, s := range sections {
+		m.Content = append(m.Content, s.key, s.value)
+	}
+	return decode(m, out)
+}
+
+func decode(root *yaml.Node, out any) error {
+	p := &paths{exact: map[position]string{}, byLine: map[int]string{}, byKind: map[lineKind]string{}}
+	p.walk(root, "")
+
+	err := root.Decode(out)
+	var valueErr *valueError
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &valueErr):
+		return Errors{p.wrap(valueErr.line, valueErr.column, valueErr.msg)}
+	case errors.As(err, &typeErr):
+		ret := Errors{}
+		for _, msg := range typeErr.Errors {
+			m := typeErrorLine.FindStringSubmatch(msg)
+			if m == nil {
+				ret = append(ret, &Error{Msg: msg})
+				continue
+			}
+			line, _ := strconv.Atoi(m[1])
+			ret = append(ret, p.wrap(line, 0, m[2]))
+		}
+		return ret
+	}
+	return err
+}
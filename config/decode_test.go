@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testTarget struct {
+	Name     string   `yaml:"name"`
+	Interval Duration `yaml:"interval"`
+}
+
+type testDoc struct {
+	Count    int               `yaml:"count"`
+	Timeout  Duration          `yaml:"timeout"`
+	Buffer   ByteSize          `yaml:"buffer"`
+	Limits   map[string]string `yaml:"limits"`
+	Watchers struct {
+		Ping struct {
+			Targets []testTarget `yaml:"targets"`
+		} `yaml:"ping"`
+	} `yaml:"watchers"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	doc := `
+count: 3
+timeout: 10s
+buffer: 4MiB
+watchers:
+  ping:
+    targets:
+      - name: router
+        interval: 5s
+      - name: nas
+        interval: 1m
+`
+	got := testDoc{}
+	if err := Unmarshal([]byte(doc), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 3 || got.Timeout != Duration(10*time.Second) || got.Buffer != 4<<20 {
+		t.Errorf("decoded %+v", got)
+	}
+	if targets := got.Watchers.Ping.Targets; len(targets) != 2 || targets[1].Name != "nas" || targets[1].Interval != Duration(time.Minute) {
+		t.Errorf("decoded targets %+v", targets)
+	}
+
+	// An empty document leaves the defaults alone
+	defaults := testDoc{Count: 7}
+	if err := Unmarshal([]byte("# nothing yet\n"), &defaults); err != nil || defaults.Count != 7 {
+		t.Errorf("empty document decoded as %+v, %v", defaults, err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "duration without a unit",
+			doc:  "timeout: 30\n",
+			want: []string{`timeout (line 1): duration "30" is missing a unit (e.g. 30s or 30m)`},
+		},
+		{
+			name: "nested in a list",
+			doc:  "watchers:\n  ping:\n    targets:\n      - name: a\n        interval: 5s\n      - name: b\n        interval: 30\n",
+			want: []string{`watchers.ping.targets[1].interval (line 7): duration "30" is missing a unit`},
+		},
+		{
+			name: "invalid duration",
+			doc:  "timeout: soon\n",
+			want: []string{`timeout (line 1): invalid duration "soon"`},
+		},
+		{
+			name: "duration not a scalar",
+			doc:  "timeout:\n  seconds: 30\n",
+			want: []string{"timeout (line 2): expected a duration such as 30s"},
+		},
+		{
+			name: "unknown size unit",
+			doc:  "count: 1\nbuffer: 12XB\n",
+			want: []string{`buffer (line 2): invalid size "12XB": unknown unit "XB"`},
+		},
+		{
+			name: "size not a scalar",
+			doc:  "buffer: [1]\n",
+			want: []string{"buffer (line 1): expected a size such as 512KiB"},
+		},
+		{
+			// The list starts on the line of its first item, but the error
+			// is about the list
+			name: "list for a map",
+			doc:  "limits:\n  - 1\n  - 2\n",
+			want: []string{"limits (line 2): cannot unmarshal !!seq into map[string]string"},
+		},
+		{
+			name: "map for a list",
+			doc:  "watchers:\n  ping:\n    targets:\n      name: a\n",
+			want: []string{"watchers.ping.targets (line 4): cannot unmarshal !!map into []config.testTarget"},
+		},
+		{
+			// Type errors don't stop decoding, so each is reported
+			name: "every type error",
+			doc:  "count: abc\nlimits: [1]\nwatchers:\n  ping:\n    targets: nope\n",
+			want: []string{
+				"count (line 1): cannot unmarshal !!str `abc` into int",
+				"limits (line 2): cannot unmarshal !!seq into map[string]string",
+				"watchers.ping.targets (line 5): cannot unmarshal !!str `nope`",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Unmarshal([]byte(tt.doc), &testDoc{})
+			errs := Errors{}
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want Errors", err)
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d: %v", len(errs), len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				if got := errs[i].Error(); !strings.HasPrefix(got, want) {
+					t.Errorf("error %d is %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+
+	// A document that isn't YAML at all has no paths to give
+	if err := Unmarshal([]byte("a: [\n"), &testDoc{}); err == nil || errors.As(err, new(Errors)) {
+		t.Errorf("malformed document returned %v", err)
+	}
+}
+
+func TestDecodeSections(t *testing.T) {
+	doc := "count: 3\ntimeout: 30\nbuffer: 1KiB\n"
+	sections, err := Split([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, s := range sections {
+		keys = append(keys, s.Key)
+	}
+	if strings.Join(keys, ",") != "count,timeout,buffer" || sections[2].Line != 3 {
+		t.Fatalf("split into %v", keys)
+	}
+
+	// Decoding only some sections leaves the bad one out, and errors in
+	// those decoded keep the lines of the whole document
+	got := testDoc{}
+	if err := DecodeSections([]Section{sections[0], sections[2]}, &got); err != nil || got.Count != 3 || got.Buffer != 1<<10 {
+		t.Errorf("decoded %+v, %v", got, err)
+	}
+	err = DecodeSections(sections[1:2], &testDoc{})
+	if err == nil || err.Error() != `timeout (line 2): duration "30" is missing a unit (e.g. 30s or 30m)` {
+		t.Errorf("got %v", err)
+	}
+
+	if _, err := Split([]byte("- a\n- b\n")); err == nil || !strings.Contains(err.Error(), "document must be a mapping") {
+		t.Errorf("split a list into sections: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schemer is implemented by config types whose YAML form isn't what their Go
+// type suggests, or that accept only some values, so the generated schema
+// describes what the config file may actually hold
+type Schemer interface {
+	JSONSchema() map[string]any
+}
+
+var schemerType = reflect.TypeOf((*Schemer)(nil)).Elem()
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema generates a JSON Schema for the YAML form of v from the yaml struct
+// tags, the same ones that drive decoding, so the two can't drift apart
+func Schema(v any) map[string]any {
+	return schemaFor(reflect.TypeOf(v))
+}
+
+func schemaFor(t reflect.Type) map[string]any {
+	if t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).JSONSchema()
+	}
+	if reflect.PointerTo(t).Implements(schemerType) {
+		return reflect.New(t).Interface().(Schemer).JSONSchema()
+	}
+	// Anything else decoding itself can't be described, so accept any value
+	// and leave it to the decoder
+	if t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return map[string]any{}
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		addProperties(t, properties)
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+	return map[string]any{}
+}
+
+// addProperties collects a struct's fields as yaml.v3 sees them, including
+// those of inlined structs
+func addProperties(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			addProperties(f.Type, properties)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		properties[name] = schemaFor(f.Type)
+	}
+}
+
+// durationPattern matches what ParseDuration accepts: 0, or numbers each
+// followed by a unit
+var durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+func (Duration) JSONSchema() map[string]any {
+	return map[string]any{"type": "string", "pattern": durationPattern, "description": "duration with a unit, e.g. 30s or 1h30m"}
+}
+
+func (ByteSize) JSONSchema() map[string]any {
+	return map[string]any{
+		"type":        []any{"string", "integer"},
+		"pattern":     `^[0-9]+(\.[0-9]+)?\s*([BbKkMmGgTt][Ii]?[Bb]?)?$`,
+		"description": "bytes, optionally with a unit, e.g. 512KiB or 10MB",
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that must be written with a unit. A bare
+// number is rejected so `interval: 30` can't quietly mean 30ns; only 0 may
+// be written without one.
+type Duration time.Duration
+
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return 0, fmt.Errorf("duration %q is missing a unit (e.g. %ss or %sm)", s, s, s)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return Duration(d), nil
+}
+
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode {
+		return valueErrorf(n, "expected a duration such as 30s")
+	}
+	v, err := ParseDuration(n.Value)
+	if err != nil {
+		return valueErrorf(n, "%s", err.Error())
+	}
+	*d = v
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// ByteSize is a count of bytes written either as a plain number or with a
+// decimal (KB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit
+type ByteSize int64
+
+var byteSizeFormat = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)$`)
+
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// binaryUnits are used, largest first, when writing a size back out
+var binaryUnits = []struct {
+	name string
+	size int64
+}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}}
+
+func ParseByteSize(s string) (ByteSize, error) {
+	m := byteSizeFormat.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	unit, ok := byteUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, m[2])
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	size := n * float64(unit)
+	if size > math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return ByteSize(size), nil
+}
+
+func (b *ByteSize) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode {
+		return valueErrorf(n, "expected a size such as 512KiB")
+	}
+	v, err := ParseByteSize(n.Value)
+	if err != nil {
+		return valueErrorf(n, "%s", err.Error())
+	}
+	*b = v
+	return nil
+}
+
+func (b ByteSize) MarshalYAML() (any, error) {
+	return b.String(), nil
+}
+
+func (b ByteSize) String() string {
+	for _, u := range binaryUnits {
+		if b != 0 && int64(b)%u.size == 0 {
+			return fmt.Sprintf("%d%s", int64(b)/u.size, u.name)
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Duration
+		err  string
+	}{
+		{in: "30s", want: Duration(30 * time.Second)},
+		{in: " 1h30m ", want: Duration(90 * time.Minute)},
+		{in: "250ms", want: Duration(250 * time.Millisecond)},
+		{in: "0", want: 0},
+		{in: "0s", want: 0},
+		{in: "-5m", want: Duration(-5 * time.Minute)},
+		// A bare number can't quietly mean nanoseconds
+		{in: "30", err: `duration "30" is missing a unit (e.g. 30s or 30m)`},
+		{in: "1.5", err: "is missing a unit"},
+		{in: "soon", err: `invalid duration "soon"`},
+		{in: "", err: `invalid duration ""`},
+		{in: "5 minutes", err: "invalid duration"},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseDuration(%q) = %s, %v, want an error containing %q", tt.in, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ByteSize
+		err  string
+	}{
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "512KiB", want: 512 << 10},
+		{in: "512 MiB", want: 512 << 20},
+		{in: "2GiB", want: 2 << 30},
+		{in: "1TiB", want: 1 << 40},
+		{in: "1.5KiB", want: 1536},
+		{in: "10KB", want: 10000},
+		{in: "10mb", want: 10 * 1000 * 1000},
+		{in: "3GB", want: 3 * 1000 * 1000 * 1000},
+		{in: "1TB", want: 1000 * 1000 * 1000 * 1000},
+		{in: "0", want: 0},
+		{in: "12XB", err: `invalid size "12XB": unknown unit "XB"`},
+		{in: "-1KiB", err: `invalid size "-1KiB"`},
+		{in: "KiB", err: "invalid size"},
+		{in: "", err: "invalid size"},
+		{in: "99999999TiB", err: "is too large"},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseByteSize(%q) = %d, %v, want an error containing %q", tt.in, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := map[ByteSize]string{
+		0:         "0",
+		512:       "512",
+		1 << 10:   "1KiB",
+		1536:      "1536",
+		512 << 20: "512MiB",
+		3 << 30:   "3GiB",
+		2 << 40:   "2TiB",
+		10000:     "10000",
+	}
+	for size, want := range tests {
+		if got := size.String(); got != want {
+			t.Errorf("%d written as %q, want %q", int64(size), got, want)
+		}
+	}
+}
+
+// Written back out, durations and sizes read back as the same values
+func TestTypesRoundTrip(t *testing.T) {
+	type doc struct {
+		Interval Duration `yaml:"interval"`
+		Buffer   ByteSize `yaml:"buffer"`
+	}
+	in := doc{Interval: Duration(90 * time.Second), Buffer: 384 << 20}
+	b, err := yaml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "interval: 1m30s\nbuffer: 384MiB\n" {
+		t.Errorf("written as %q", b)
+	}
+	out := doc{}
+	if err := Unmarshal(b, &out); err != nil || out != in {
+		t.Errorf("read back as %+v, %v", out, err)
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Validate checks a YAML document against a schema from Schema, reporting
+// every problem as an Error with its path and line. Unknown keys, which
+// decoding quietly ignores, are among them.
+//
+// Only the parts of JSON Schema that Schema generates are understood: type,
+// properties, additionalProperties, items, enum and pattern. As in decoding,
+// any scalar is taken as a string.
+func Validate(data []byte, schema map[string]any) error {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if root.Kind == 0 {
+		return nil
+	}
+	errs := Errors{}
+	validateNode(root.Content[0], schema, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateNode(n *yaml.Node, schema map[string]any, path string, errs *Errors) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, &Error{Path: path, Line: n.Line, Msg: fmt.Sprintf(format, args...)})
+	}
+	// A null decodes to the zero value whatever the type
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			matched = matched || nodeIs(n, t)
+		}
+		if !matched {
+			fail("expected %s", strings.Join(types, " or "))
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && n.Kind == yaml.ScalarNode {
+		found := false
+		for _, v := range enum {
+			found = found || fmt.Sprint(v) == n.Value
+		}
+		if !found {
+			allowed := []string{}
+			for _, v := range enum {
+				allowed = append(allowed, fmt.Sprint(v))
+			}
+			fail("%q is not one of %s", n.Value, strings.Join(allowed, "|"))
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok && n.Kind == yaml.ScalarNode {
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(n.Value) {
+			fail("%q does not match %s", n.Value, pattern)
+		}
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		properties, _ := schema["properties"].(map[string]any)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			childPath := joinPath(path, key.Value)
+			if s, ok := properties[key.Value].(map[string]any); ok {
+				validateNode(value, s, childPath, errs)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					known := []string{}
+					for k := range properties {
+						known = append(known, k)
+					}
+					sort.Strings(known)
+					*errs = append(*errs, &Error{Path: childPath, Line: key.Line, Msg: "unknown key" + suggestKey(key.Value, known)})
+				}
+			case map[string]any:
+				validateNode(value, extra, childPath, errs)
+			}
+		}
+	case yaml.SequenceNode:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range n.Content {
+				validateNode(item, items, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func schemaTypes(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		ret := []string{}
+		for _, s := range t {
+			ret = append(ret, fmt.Sprint(s))
+		}
+		return ret
+	}
+	return nil
+}
+
+func nodeIs(n *yaml.Node, t string) bool {
+	switch t {
+	case "object":
+		return n.Kind == yaml.MappingNode
+	case "array":
+		return n.Kind == yaml.SequenceNode
+	case "string":
+		return n.Kind == yaml.ScalarNode
+	case "integer":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!int"
+	case "number":
+		return n.Kind == yaml.ScalarNode && (n.Tag == "!!int" || n.Tag == "!!float")
+	case "boolean":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!bool"
+	}
+	return true
+}
+
+// suggestKey names the known key closest to a misspelt one, if any is close
+func suggestKey(key string, known []string) string {
+	best, bestDistance := "", len(key)/3+1
+	for _, k := range known {
+		if d := editDistance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema := Schema(testDoc{})
+	if err := Validate([]byte("count: 3\ntimeout: 10s\nwatchers:\n  ping:\n    targets:\n      - name: a\n"), schema); err != nil {
+		t.Errorf("valid document: %v", err)
+	}
+
+	doc := "count: three\ntimeot: 10s\nwatchers:\n  ping:\n    targets:\n      - name: a\n        intervall: 5s\n"
+	err := Validate([]byte(doc), schema)
+	errs := Errors{}
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want Errors", err)
+	}
+	want := []string{
+		"count (line 1): expected integer",
+		`timeot (line 2): unknown key, did you mean "timeout"?`,
+		`watchers.ping.targets[0].intervall (line 7): unknown key, did you mean "interval"?`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %v, want %d errors", err, len(want))
+	}
+	for i, w := range want {
+		if errs[i].Error() != w {
+			t.Errorf("error %d is %q, want %q", i, errs[i], w)
+		}
+	}
+
+	// Nothing close enough is suggested
+	if err := Validate([]byte("frobnicate: 1\n"), schema); err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+var connectionRetryAfterSeconds = 5
+
+// connectionLimiter accounts for every long-lived client connection across
+// all endpoints and refuses new ones beyond a global maximum
+type connectionLimiter struct {
+	max     int64
+	current atomic.Int64
+}
+
+var connections = &connectionLimiter{}
+
+const ENDPOINT_STATUS = "/status"
+const ENDPOINT_EVENTS = "/events"
+const ENDPOINT_STREAM = "/stream"
+
+// writeErrors counts failed writes to streaming clients per endpoint. A spike
+// usually means network trouble or clients that can't keep up.
+var writeErrors = expvar.NewMap("client_write_errors")
+
+func init() {
+	expvar.Publish("connections_current", expvar.Func(func() any { return connections.current.Load() }))
+	expvar.Publish("connections_max", expvar.Func(func() any { return connections.max }))
+	writeErrors.Add(ENDPOINT_STATUS, 0)
+	writeErrors.Add(ENDPOINT_EVENTS, 0)
+	writeErrors.Add(ENDPOINT_STREAM, 0)
+}
+
+func (l *connectionLimiter) acquire() bool {
+	if l.current.Add(1) > l.max && l.max > 0 {
+		l.current.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (l *connectionLimiter) release() {
+	l.current.Add(-1)
+}
+
+// admit reserves a connection slot for the request, answering with 503 and a
+// Retry-After header when none are left. Callers must release the slot once
+// the connection closes.
+func (l *connectionLimiter) admit(w http.ResponseWriter) bool {
+	if l.acquire() {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(connectionRetryAfterSeconds))
+	http.Error(w, "too many connections", http.StatusServiceUnavailable)
+	return false
+}
+
+type ClientStats struct {
+	Current       int64            `json:"connections_current"`
+	Max           int64            `json:"connections_max"`
+	StatusClients int              `json:"status_clients"`
+	EventClients  int              `json:"event_clients"`
+	WriteErrors   map[string]int64 `json:"write_errors"`
+	BytesSent     int64            `json:"bytes_sent"`
+	Clients       []ClientInfo     `json:"clients"`
+}
+
+func handleDebugClients(w http.ResponseWriter, r *http.Request) {
+	lock.Lock()
+	stats := ClientStats{
+		Current:       connections.current.Load(),
+		Max:           connections.max,
+		StatusClients: len(statusClients),
+		EventClients:  len(eventClients),
+		WriteErrors:   map[string]int64{},
+	}
+	lock.Unlock()
+
+	writeErrors.Do(func(kv expvar.KeyValue) {
+		stats.WriteErrors[kv.Key] = kv.Value.(*expvar.Int).Value()
+	})
+	stats.Clients = registry.list()
+	stats.BytesSent = totalBytes.Value()
+	b, _ := encodeJSON(stats)
+	w.Write(b)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/DRuggeri/labwatch/poll"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// currentTalos is the running Talos watcher, swapped when it is restarted.
+// It is read by the file watcher and debug handlers as well as the watch loop.
+var currentTalos atomic.Pointer[talos.TalosWatcher]
+
+type WatcherDebug struct {
+	TalosPolls map[string]poll.Status `json:"talos_polls"`
+}
+
+// handleDebugWatchers reports watcher internals that aren't part of the status,
+// such as the effective poll interval of each node
+func handleDebugWatchers(w http.ResponseWriter, r *http.Request) {
+	debug := WatcherDebug{TalosPolls: map[string]poll.Status{}}
+	if t := currentTalos.Load(); t != nil {
+		debug.TalosPolls = t.PollSchedules()
+	}
+	b, _ := encodeJSON(debug)
+	w.Write(b)
+}
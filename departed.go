@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+var departedPurgeTimeout = time.Duration(10) * time.Second
+
+// departedTracker keeps nodes the talos watcher stops reporting in the status
+// for a grace period, marked departed, so dashboards don't have them blink out
+// the moment discovery drops them. It belongs to the watch loop.
+type departedTracker struct {
+	grace    time.Duration
+	seen     map[string]talos.NodeStatus
+	departed map[string]time.Time
+	log      *slog.Logger
+}
+
+func newDepartedTracker(grace time.Duration, log *slog.Logger) *departedTracker {
+	return &departedTracker{
+		grace:    grace,
+		seen:     map[string]talos.NodeStatus{},
+		departed: map[string]time.Time{},
+		log:      log.With("operation", "departedTracker"),
+	}
+}
+
+// apply notes which nodes the watcher reported and returns them along with
+// the departed nodes still within their grace period. Nodes that have just
+// gone are announced.
+func (d *departedTracker) apply(nodes map[string]talos.NodeStatus, now time.Time) map[string]talos.NodeStatus {
+	for name, last := range d.seen {
+		if _, ok := nodes[name]; ok {
+			delete(d.departed, name)
+			continue
+		}
+		if _, ok := d.departed[name]; ok {
+			continue
+		}
+		if d.grace <= 0 {
+			d.announce(name, "departed", fmt.Sprintf("%s departed", name), last.Injected)
+			delete(d.seen, name)
+			continue
+		}
+		d.departed[name] = now
+		d.announce(name, "departed", fmt.Sprintf("%s departed, keeping it for %s", name, d.grace), last.Injected)
+	}
+	for name, n := range nodes {
+		d.seen[name] = n
+	}
+	if len(d.departed) == 0 {
+		return nodes
+	}
+
+	ret := make(map[string]talos.NodeStatus, len(nodes)+len(d.departed))
+	for name, n := range nodes {
+		ret[name] = n
+	}
+	for name, at := range d.departed {
+		n := d.seen[name]
+		n.Departed = true
+		n.DepartedAt = &at
+		ret[name] = n
+	}
+	return ret
+}
+
+// expire forgets departed nodes past the grace period, reporting whether any
+// were dropped
+func (d *departedTracker) expire(now time.Time) bool {
+	dropped := false
+	for name, at := range d.departed {
+		if now.Sub(at) < d.grace {
+			continue
+		}
+		d.log.Info("dropping departed node", "node", name, "departed", at)
+		d.forget(name)
+		dropped = true
+	}
+	return dropped
+}
+
+// purge drops a departed node before its grace period is up. Nodes the
+// watcher still reports can't be purged.
+func (d *departedTracker) purge(name string) bool {
+	if _, ok := d.departed[name]; !ok {
+		return false
+	}
+	d.announce(name, "purged", fmt.Sprintf("%s purged", name), d.seen[name].Injected)
+	d.forget(name)
+	return true
+}
+
+func (d *departedTracker) forget(name string) {
+	delete(d.departed, name)
+	delete(d.seen, name)
+}
+
+func (d *departedTracker) announce(name string, what string, msg string, injected bool) {
+	d.log.Info(msg, "node", name, "change", what)
+	broadcastEvent(loki.LogEvent{Node: name, Service: "labwatch", Level: "info", Message: msg, Timestamp: clk.Now(), Injected: injected}, d.log)
+}
+
+// departedPurges hands DELETE /status/{watcher}/{entity} requests to the
+// watch loop, which owns the departed nodes
+var departedPurges = make(chan departedPurge)
+
+type departedPurge struct {
+	node string
+	done chan bool
+}
+
+// handleDepartedPurge drops a departed entity from the status immediately.
+// Talos nodes are the only entities that depart.
+func handleDepartedPurge(w http.ResponseWriter, r *http.Request) {
+	if watcher := r.PathValue("watcher"); watcher != WATCHER_TALOS {
+		http.Error(w, "unknown watcher "+watcher, http.StatusNotFound)
+		return
+	}
+
+	req := departedPurge{node: r.PathValue("entity"), done: make(chan bool, 1)}
+	select {
+	case departedPurges <- req:
+	case <-time.After(departedPurgeTimeout):
+		http.Error(w, "timed out waiting for the watch loop", http.StatusServiceUnavailable)
+		return
+	}
+	if !<-req.done {
+		http.Error(w, "no departed entity "+req.node, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
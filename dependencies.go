@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// DependencyConfig lists, for each entity, the entities it depends on
+type DependencyConfig map[string][]string
+
+// validateDependencies rejects dependency graphs containing cycles
+func validateDependencies(deps DependencyConfig) error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+
+	var visit func(entity string, path []string) error
+	visit = func(entity string, path []string) error {
+		switch state[entity] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, entity), " -> "))
+		case done:
+			return nil
+		}
+		state[entity] = visiting
+		for _, parent := range deps[entity] {
+			if err := visit(parent, append(path, entity)); err != nil {
+				return err
+			}
+		}
+		state[entity] = done
+		return nil
+	}
+
+	entities := []string{}
+	for entity := range deps {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	for _, entity := range entities {
+		if err := visit(entity, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suppressedBy returns the nearest ancestor of entity that is down, or an
+// empty string when all of its ancestors are up
+func suppressedBy(entity string, down map[string]bool, deps DependencyConfig) string {
+	seen := map[string]bool{entity: true}
+	queue := append([]string{}, deps[entity]...)
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		if seen[parent] {
+			continue
+		}
+		seen[parent] = true
+		if down[parent] {
+			return parent
+		}
+		queue = append(queue, deps[parent]...)
+	}
+	return ""
+}
+
+// related reports whether either entity depends on the other, directly or
+// through other entities
+func related(a string, b string, deps DependencyConfig) bool {
+	return a == b || dependsOn(a, b, deps) || dependsOn(b, a, deps)
+}
+
+func dependsOn(entity string, ancestor string, deps DependencyConfig) bool {
+	return suppressedBy(entity, map[string]bool{ancestor: true}, deps) != ""
+}
+
+// applyDependencies marks nodes whose parents are down as suppressed
+func applyDependencies(nodes map[string]talos.NodeStatus, deps DependencyConfig) {
+	down := map[string]bool{}
+	for name, s := range nodes {
+		down[name] = s.WatcherState == talos.CONNECTION_DISCONNECTED
+	}
+	for name, s := range nodes {
+		s.SuppressedBy = suppressedBy(name, down, deps)
+		nodes[name] = s
+	}
+}
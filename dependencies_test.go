@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		deps DependencyConfig
+		err  string
+	}{
+		{"none", DependencyConfig{}, ""},
+		{"chain", DependencyConfig{"worker1": {"cp1"}, "cp1": {"switch"}}, ""},
+		{"diamond", DependencyConfig{"worker1": {"cp1", "cp2"}, "cp1": {"switch"}, "cp2": {"switch"}}, ""},
+		{"self", DependencyConfig{"cp1": {"cp1"}}, "dependency cycle: cp1 -> cp1"},
+		{"loop", DependencyConfig{"worker1": {"cp1"}, "cp1": {"switch"}, "switch": {"worker1"}}, "dependency cycle: cp1 -> switch -> worker1 -> cp1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDependencies(tt.deps)
+			if tt.err == "" && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("got %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSuppressedBy(t *testing.T) {
+	deps := DependencyConfig{"worker1": {"cp1"}, "cp1": {"switch"}}
+	tests := []struct {
+		down []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"worker1"}, ""},
+		{[]string{"switch"}, "switch"},
+		{[]string{"cp1", "switch"}, "cp1"},
+	}
+	for _, tt := range tests {
+		down := map[string]bool{}
+		for _, d := range tt.down {
+			down[d] = true
+		}
+		if got := suppressedBy("worker1", down, deps); got != tt.want {
+			t.Errorf("with %v down, suppressed by %q, want %q", tt.down, got, tt.want)
+		}
+	}
+}
+
+// TestDependencyChain takes down a switch, the control plane node behind it
+// and the worker behind that, and brings them back in either order
+func TestDependencyChain(t *testing.T) {
+	type step struct {
+		down []string
+		// notified lists the titles and nodes of what was notified
+		notified []string
+		// suppressed counts the nodes behind a node that's down, healthy or
+		// not
+		suppressed int
+		state      HealthLevel
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "parent recovers after the children",
+			steps: []step{
+				{down: []string{"switch"}, notified: []string{"node problem switch"}, suppressed: 2, state: HEALTH_LEVEL_CRITICAL},
+				{down: []string{"switch", "cp1", "worker1"}, suppressed: 2, state: HEALTH_LEVEL_CRITICAL},
+				{down: []string{"switch", "cp1"}, suppressed: 2, state: HEALTH_LEVEL_CRITICAL},
+				{down: []string{"switch"}, suppressed: 2, state: HEALTH_LEVEL_CRITICAL},
+				{notified: []string{"node recovered switch"}, state: HEALTH_LEVEL_OK},
+			},
+		},
+		{
+			// Each child's problem is told once nothing above it explains it
+			name: "parent recovers before the children",
+			steps: []step{
+				{down: []string{"switch"}, notified: []string{"node problem switch"}, suppressed: 2, state: HEALTH_LEVEL_CRITICAL},
+				{down: []string{"switch", "cp1", "worker1"}, suppressed: 2, state: HEALTH_LEVEL_CRITICAL},
+				{down: []string{"cp1", "worker1"}, notified: []string{"node problem cp1", "node recovered switch"}, suppressed: 1, state: HEALTH_LEVEL_CRITICAL},
+				{down: []string{"worker1"}, notified: []string{"node problem worker1", "node recovered cp1"}, state: HEALTH_LEVEL_CRITICAL},
+				{notified: []string{"node recovered worker1"}, state: HEALTH_LEVEL_OK},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{}
+			srv := httptest.NewServer(receiver)
+			defer srv.Close()
+			cfg := defaultConfig()
+			cfg.NotifyWebhook = srv.URL
+			cfg.NotifyMaxAge = 0
+			cfg.DependsOn = DependencyConfig{"worker1": {"cp1"}, "cp1": {"switch"}}
+			useTestGlobals(t, cfg)
+
+			// observe runs the nodes through what the watch loop does with
+			// every talos update
+			observe := func(down []string) map[string]talos.NodeStatus {
+				nodes := map[string]talos.NodeStatus{}
+				for _, name := range []string{"switch", "cp1", "worker1"} {
+					n := worker("v1.9.0")
+					if slices.Contains(down, name) {
+						n.WatcherState = talos.CONNECTION_DISCONNECTED
+					}
+					nodes[name] = n
+				}
+				applyDependencies(nodes, cfg.DependsOn)
+				for _, tr := range transitions.observe(nodes, clk.Now()) {
+					announceTransition(tr, discardLog)
+				}
+				return nodes
+			}
+			observe(nil)
+
+			for i, s := range tt.steps {
+				nodes := observe(s.down)
+				notified := []string{}
+				for _, n := range receiver.deliver(notifier) {
+					notified = append(notified, n.Title+" "+n.Node)
+				}
+				slices.Sort(notified)
+				if s.notified == nil {
+					s.notified = []string{}
+				}
+				if !reflect.DeepEqual(notified, s.notified) {
+					t.Errorf("step %d with %v down notified %v, want %v", i, s.down, notified, s.notified)
+				}
+				summary := computeSummary(LabStatus{Talos: nodes}, cfg.Summary)
+				if summary.Suppressed != s.suppressed || summary.State != s.state {
+					t.Errorf("step %d with %v down summarized %d suppressed and %s, want %d and %s", i, s.down, summary.Suppressed, summary.State, s.suppressed, s.state)
+				}
+			}
+
+			// The children going down is still in the history, suppressed
+			for _, tr := range transitions.getHistory() {
+				if tr.Node != "switch" && tr.Bad && tr.ReleasedBy == "" && tr.SuppressedBy == "" {
+					t.Errorf("%s went down without being suppressed", tr.Node)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+const NODE_ROLE_CONTROLPLANE = "controlplane"
+const NODE_ROLE_WORKER = "worker"
+
+// nodeContexts caches the context attached to events for each Talos node so
+// enriching an event is a map lookup. It is rebuilt whenever node status
+// changes and is only touched by the watch loop.
+type nodeContexts map[string]loki.NodeContext
+
+func newNodeContexts(nodes map[string]talos.NodeStatus) nodeContexts {
+	ret := nodeContexts{}
+	for name, n := range nodes {
+		ctx := loki.NodeContext{Up: n.WatcherState == talos.CONNECTION_OK, Ready: n.Ready, Role: nodeRole(n)}
+		ret[name] = ctx
+		if n.SourceID != "" {
+			ret[n.SourceID] = ctx
+		}
+	}
+	return ret
+}
+
+// nodeRole infers the role from the services reported, as Talos only runs
+// etcd on control plane nodes. It is empty until services are known.
+func nodeRole(n talos.NodeStatus) string {
+	if len(n.Services) == 0 {
+		return ""
+	}
+	if _, ok := n.Services["etcd"]; ok {
+		return NODE_ROLE_CONTROLPLANE
+	}
+	return NODE_ROLE_WORKER
+}
+
+// enrich attaches the context of the node the event came from, if it is a
+// known Talos node. Events aggregated across hosts are left alone.
+func (c nodeContexts) enrich(e loki.LogEvent) loki.LogEvent {
+	if c == nil || e.Count > 1 {
+		return e
+	}
+	ctx, ok := c[e.Node]
+	if !ok {
+		ctx, ok = c[e.SourceID]
+	}
+	if ok {
+		e.NodeContext = &ctx
+	}
+	return e
+}
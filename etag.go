@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// writeWithETag sends b tagged with a hash of its content, or just 304 when
+// the client already holds it. Status encoding is deterministic, so the same
+// state always produces the same tag. no-cache lets shared caches keep the
+// body but makes them revalidate each time, as the status can change at any
+// moment.
+func writeWithETag(w http.ResponseWriter, r *http.Request, b []byte) {
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimPrefix(strings.TrimSpace(match), "W/"); match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Write(b)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/outputs"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// stableStatus is the same state however it is built. names sets the order
+// every map is filled in.
+func stableStatus(names []string) LabStatus {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := LabStatus{
+		Labwatch: LabwatchStatus{State: LABWATCH_RUNNING},
+		Summary:  Summary{State: HEALTH_LEVEL_WARN, Counts: map[HealthLevel]int{}, Reasons: []string{"worker1 not ready"}},
+		Sections: map[string]SectionStatus{},
+		Outputs:  map[string]outputs.Health{},
+		Talos:    map[string]talos.NodeStatus{},
+	}
+	for _, name := range names {
+		n := talos.NodeStatus{
+			Node:        name,
+			Ready:       true,
+			Addresses:   []string{"10.0.0.1", "fd00::1"},
+			Services:    map[string]talos.ServiceStatus{},
+			Phase:       map[string]string{},
+			Metrics:     map[string]float64{},
+			LastUpdated: now,
+		}
+		for _, svc := range names {
+			n.Services[svc] = talos.ServiceStatus{State: "Running", Healthy: talos.HEALTH_OK, LastChange: now}
+			n.Phase[svc] = "done"
+			n.Metrics[svc] = float64(len(svc))
+		}
+		s.Talos[name] = n
+		s.Sections[name] = SectionStatus{LastUpdated: &now}
+		s.Outputs[name] = outputs.Health{Healthy: true, Queued: len(name)}
+		s.Summary.Counts[HealthLevel(name)] = len(name)
+	}
+	return s
+}
+
+func TestStatusEncodingStable(t *testing.T) {
+	names := []string{"cp1", "cp2", "cp3", "worker1", "worker2", "worker3", "worker4", "nas", "router", "switch"}
+	reversed := slices.Clone(names)
+	slices.Reverse(reversed)
+
+	defer func(style JSONStyle) { jsonStyle = style }(jsonStyle)
+	encodings := map[string]func(LabStatus) []byte{
+		"legacy json": func(s LabStatus) []byte {
+			jsonStyle = JSON_STYLE_LEGACY
+			b, _ := encodeJSON(s)
+			return b
+		},
+		"v2 json": func(s LabStatus) []byte {
+			jsonStyle = JSON_STYLE_V2
+			b, _ := encodeJSON(s)
+			return b
+		},
+		"protobuf": encodeStatusProto,
+	}
+	for name, encode := range encodings {
+		want := encode(stableStatus(names))
+		for i := range 20 {
+			order := names
+			if i%2 == 1 {
+				order = reversed
+			}
+			if got := encode(stableStatus(order)); !bytes.Equal(got, want) {
+				at := 0
+				for at < min(len(got), len(want)) && got[at] == want[at] {
+					at++
+				}
+				t.Fatalf("%s: encoding %d differs from byte %d: %q", name, i, at, got[at:min(len(got), at+80)])
+			}
+		}
+	}
+}
+
+func TestWriteWithETag(t *testing.T) {
+	get := func(body string, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/status", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		writeWithETag(w, r, []byte(body))
+		return w
+	}
+
+	first := get("status", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Body.String() != "status" || etag == "" {
+		t.Fatalf("first response %d %q tagged %q", first.Code, first.Body, etag)
+	}
+	if again := get("status", "").Header().Get("ETag"); again != etag {
+		t.Errorf("same body tagged %s then %s", etag, again)
+	}
+
+	for _, match := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		if w := get("status", match); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: %d %q", match, w.Code, w.Body)
+		}
+	}
+	if w := get("changed", etag); w.Code != http.StatusOK || w.Body.String() != "changed" || w.Header().Get("ETag") == etag {
+		t.Errorf("changed status answered %d %q tagged %s", w.Code, w.Body, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+func init() {
+	registerSchemaSection("etcd-snapshot", SCHEMA_WATCHER, "Takes etcd snapshots on request and watches how old the newest one is", "etcd-snapshot")
+}
+
+// ETCD_SNAPSHOT_TIME_FORMAT names the snapshots labwatch takes, so they sort
+// by when they were taken
+const ETCD_SNAPSHOT_TIME_FORMAT = "20060102T150405Z"
+
+// EtcdSnapshotConfig enables POST /actions/talos/etcd-snapshot, which saves
+// a snapshot into directory, and reports the newest snapshot there every
+// interval. It is stale once older than max-age, or when there is none; 0
+// never considers it stale. An empty directory disables both.
+type EtcdSnapshotConfig struct {
+	Directory string          `yaml:"directory"`
+	Timeout   config.Duration `yaml:"timeout"`
+	Interval  config.Duration `yaml:"interval"`
+	MaxAge    config.Duration `yaml:"max-age"`
+}
+
+func validateEtcdSnapshot(cfg EtcdSnapshotConfig) error {
+	if cfg.Directory == "" {
+		return nil
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if cfg.MaxAge < 0 {
+		return fmt.Errorf("max-age must not be negative")
+	}
+	return nil
+}
+
+// EtcdSnapshotStatus is the newest snapshot in the configured directory.
+// Taken is when it was written, for dashboards to show its age.
+type EtcdSnapshotStatus struct {
+	Directory string     `json:"directory"`
+	Newest    string     `json:"newest,omitempty"`
+	Taken     *time.Time `json:"taken,omitempty"`
+	SizeBytes int64      `json:"size_bytes,omitempty"`
+	Stale     bool       `json:"stale"`
+	Error     string     `json:"error,omitempty"`
+	Checked   time.Time  `json:"checked"`
+}
+
+// scanEtcdSnapshots finds the newest snapshot: any regular file in the
+// directory save hidden ones, which are snapshots still being written
+func scanEtcdSnapshots(cfg EtcdSnapshotConfig, now time.Time) EtcdSnapshotStatus {
+	s := EtcdSnapshotStatus{Directory: cfg.Directory, Checked: now}
+	entries, err := os.ReadDir(cfg.Directory)
+	if err != nil {
+		s.Error = err.Error()
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if taken := info.ModTime(); s.Taken == nil || taken.After(*s.Taken) {
+			s.Newest, s.Taken, s.SizeBytes = e.Name(), &taken, info.Size()
+		}
+	}
+	s.Stale = cfg.MaxAge > 0 && (s.Taken == nil || now.Sub(*s.Taken) > time.Duration(cfg.MaxAge))
+	return s
+}
+
+// etcdSnapshotRescans asks watchEtcdSnapshots to scan now, after a snapshot
+// was taken
+var etcdSnapshotRescans = make(chan struct{}, 1)
+
+// watchEtcdSnapshots sends a scan at once and then every interval, or
+// sooner when asked, until ctx is done. Going stale and back is announced.
+func watchEtcdSnapshots(ctx context.Context, cfg EtcdSnapshotConfig, out chan<- EtcdSnapshotStatus, log *slog.Logger) {
+	log = log.With("operation", "etcdSnapshots")
+	ticker := clk.NewTicker(time.Duration(cfg.Interval))
+	defer ticker.Stop()
+	stale := false
+	for {
+		s := scanEtcdSnapshots(cfg, clk.Now())
+		if s.Stale != stale {
+			stale = s.Stale
+			announceEtcdSnapshotAge(s, cfg, log)
+		}
+		select {
+		case out <- s:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-ticker.C():
+		case <-etcdSnapshotRescans:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func announceEtcdSnapshotAge(s EtcdSnapshotStatus, cfg EtcdSnapshotConfig, log *slog.Logger) {
+	level := "info"
+	msg := "etcd snapshot " + s.Newest + " is recent again"
+	if s.Stale {
+		level = "warning"
+		if s.Taken == nil {
+			msg = "no etcd snapshot in " + cfg.Directory
+		} else {
+			msg = fmt.Sprintf("newest etcd snapshot %s is older than %s", s.Newest, time.Duration(cfg.MaxAge))
+		}
+		log.Warn(msg)
+	} else {
+		log.Info(msg)
+	}
+	broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: level, Message: msg, Timestamp: s.Checked}, log)
+}
+
+type EtcdSnapshotResult struct {
+	File      string    `json:"file"`
+	Node      string    `json:"node"`
+	SizeBytes int64     `json:"size_bytes"`
+	Duration  string    `json:"duration"`
+	Taken     time.Time `json:"taken"`
+}
+
+// etcdSnapshotRunning keeps a second request from starting a snapshot while
+// one streams
+var etcdSnapshotRunning sync.Mutex
+
+// handleEtcdSnapshot streams a snapshot from a control plane node to a
+// hidden file in the directory, renamed once complete so a failed snapshot
+// never passes for the newest. Every attempt is logged with the caller,
+// node, size and duration, and announced as an event.
+func handleEtcdSnapshot(cfg EtcdSnapshotConfig, log *slog.Logger) http.HandlerFunc {
+	log = log.With("operation", "etcdSnapshot")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.Directory == "" {
+			http.Error(w, "etcd snapshots disabled: no etcd-snapshot directory configured", http.StatusNotFound)
+			return
+		}
+		t := currentTalos.Load()
+		if t == nil {
+			http.Error(w, "talos watcher not running", http.StatusServiceUnavailable)
+			return
+		}
+		if !etcdSnapshotRunning.TryLock() {
+			http.Error(w, "an etcd snapshot is already running", http.StatusConflict)
+			return
+		}
+		defer etcdSnapshotRunning.Unlock()
+
+		start := clk.Now()
+		f, err := os.CreateTemp(cfg.Directory, ".etcd-*.partial")
+		if err != nil {
+			log.Error("etcd snapshot failed", "remote", r.RemoteAddr, "error", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(f.Name())
+
+		node, size, err := t.EtcdSnapshot(r.Context(), time.Duration(cfg.Timeout), f)
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		result := EtcdSnapshotResult{
+			File:      filepath.Join(cfg.Directory, "etcd-"+start.UTC().Format(ETCD_SNAPSHOT_TIME_FORMAT)+".snapshot"),
+			Node:      node,
+			SizeBytes: size,
+			Duration:  clk.Now().Sub(start).Round(time.Millisecond).String(),
+			Taken:     start,
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), result.File)
+		}
+
+		if err != nil {
+			log.Error("etcd snapshot failed", "remote", r.RemoteAddr, "node", node, "duration", result.Duration, "error", err.Error())
+			broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: "error", Message: "etcd snapshot failed: " + err.Error(), Timestamp: clk.Now()}, log)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		log.Info("etcd snapshot taken", "remote", r.RemoteAddr, "file", result.File, "node", node, "size", size, "duration", result.Duration)
+		msg := fmt.Sprintf("etcd snapshot of %d bytes taken from %s in %s", size, node, result.Duration)
+		broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: "info", Message: msg, Timestamp: clk.Now()}, log)
+		select {
+		case etcdSnapshotRescans <- struct{}{}:
+		default:
+		}
+
+		b, _ := json.Marshal(result)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+var eventQueryTimeout = time.Duration(30) * time.Second
+
+// eventSubscription is a client's feed of events. Without label matchers it
+// is a share of the main Loki tail; with them it is a dedicated tail of the
+// base query narrowed by the matchers.
+type eventSubscription struct {
+	events <-chan loki.LogEvent
+	kicked <-chan struct{}
+	query  string
+	close  func()
+}
+
+func subscribeEvents(r *http.Request, cfg LabwatchConfig, id string, log *slog.Logger) (*eventSubscription, error) {
+	matchers, err := parseLabelMatchers(r.URL.Query()["label"])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matchers) == 0 {
+		q := addEventClient(id)
+		return &eventSubscription{events: q.ch, kicked: q.kicked, close: func() { removeEventClient(id) }}, nil
+	}
+	if cfg.LogSource != LOG_SOURCE_LOKI {
+		return nil, fmt.Errorf("label matchers are %w", errNeedsLoki)
+	}
+
+	query, err := addLabelMatchers(effectiveLokiQuery.Load().(string), matchers)
+	if err != nil {
+		return nil, err
+	}
+	w, err := loki.NewLokiWatcher(context.Background(), loki.LokiWatcherConfig{
+		Address:      cfg.LokiAddress,
+		Query:        query,
+		MaxClockSkew: time.Duration(cfg.LokiMaxClockSkew),
+		NarrowFactor: cfg.LokiNarrowFactor,
+		TraceIDField: cfg.LokiTraceIDField,
+		DedupWindow:  time.Duration(cfg.LokiDedupWindow),
+		FieldTypes:   cfg.LokiFieldTypes,
+	}, componentLogger(log, *logLevelLoki).With("query", query))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	raw := make(chan loki.LogEvent)
+	stats := make(chan loki.LogStats)
+	go w.Watch(ctx, raw, stats)
+	// Only the main tail's stats are reported, but Watch still sends these
+	go func() {
+		for {
+			select {
+			case <-stats:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ch := make(chan loki.LogEvent)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-raw:
+				select {
+				case ch <- identities.Load().applyEvent(e):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return &eventSubscription{events: ch, query: query, close: cancel}, nil
+}
+
+// replay returns the recent events for the subscription. The buffer holds
+// the main tail, so narrowed subscriptions ask Loki for their history instead.
+func (s *eventSubscription) replay(ctx context.Context, cfg LabwatchConfig) ([]loki.LogEvent, error) {
+	now := time.Now()
+	if s.query == "" {
+		ret := []loki.LogEvent{}
+		for _, e := range recentEvents.replay(now) {
+			ret = append(ret, e.LogEvent)
+		}
+		return ret, nil
+	}
+
+	events, err := loki.Query(ctx, cfg.LokiAddress, s.query, now.Add(-time.Duration(cfg.Correlation.BufferMaxAge)), cfg.Correlation.BufferSize, cfg.LokiTraceIDField)
+	for i := range events {
+		loki.CoerceFields(&events[i], cfg.LokiFieldTypes)
+		events[i] = identities.Load().applyEvent(events[i])
+	}
+	return events, err
+}
+
+// handleEventQuery runs a one-shot query of the base query, narrowed by any
+// label matchers, over the last ?since (default 1h)
+func handleEventQuery(cfg LabwatchConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.LogSource != LOG_SOURCE_LOKI {
+			http.Error(w, "event queries are "+errNeedsLoki.Error(), http.StatusNotImplemented)
+			return
+		}
+		matchers, err := parseLabelMatchers(r.URL.Query()["label"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query, err := addLabelMatchers(effectiveLokiQuery.Load().(string), matchers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		since := time.Duration(1) * time.Hour
+		if s := r.URL.Query().Get("since"); s != "" {
+			if since, err = time.ParseDuration(s); err != nil {
+				http.Error(w, "invalid since", http.StatusBadRequest)
+				return
+			}
+		}
+		limit := 100
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if limit, err = strconv.Atoi(l); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), eventQueryTimeout)
+		defer cancel()
+		events, err := loki.Query(ctx, cfg.LokiAddress, query, time.Now().Add(-since), limit, cfg.LokiTraceIDField)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		filter := parseEventFilter(r.URL.Query())
+		ret := []loki.LogEvent{}
+		for _, e := range events {
+			loki.CoerceFields(&e, cfg.LokiFieldTypes)
+			if e = identities.Load().applyEvent(e); filter.matches(e) {
+				ret = append(ret, e)
+			}
+		}
+		b, _ := encodeJSON(ret)
+		w.Write(b)
+	}
+}
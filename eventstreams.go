@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// STREAM_NDJSON is the ?stream value asking /events for newline delimited
+// JSON over plain HTTP, so it can't name an event stream
+const STREAM_NDJSON = "ndjson"
+
+// EventStreamConfig routes the events whose fields all match to the named
+// stream, which /events?stream=name selects. Fields are looked up as
+// event-group-by does: host, service and level are the event's own, anything
+// else comes from the parsed log line. Values match exactly, or as regular
+// expressions matching the whole value with regex set. An event may belong
+// to any number of streams.
+type EventStreamConfig struct {
+	Name  string            `yaml:"name"`
+	Match map[string]string `yaml:"match"`
+	Regex bool              `yaml:"regex"`
+}
+
+type eventStream struct {
+	name   string
+	fields []string
+	values map[string]string
+	res    map[string]*regexp.Regexp
+}
+
+func (s eventStream) matches(e loki.LogEvent) bool {
+	for _, f := range s.fields {
+		v := eventGroupKey(e, []string{f})
+		if re := s.res[f]; re != nil {
+			if !re.MatchString(v) {
+				return false
+			}
+		} else if v != s.values[f] {
+			return false
+		}
+	}
+	return true
+}
+
+// eventRouter classifies events into the configured streams. It is only
+// read once built, so the watch loop and handlers share it freely.
+type eventRouter struct {
+	streams []eventStream
+}
+
+var eventStreams = &eventRouter{}
+
+func newEventRouter(cfgs []EventStreamConfig) (*eventRouter, error) {
+	ret := &eventRouter{}
+	names := map[string]bool{}
+	for _, c := range cfgs {
+		if c.Name == "" || len(c.Match) == 0 {
+			return nil, fmt.Errorf("event streams need a name and at least one match")
+		}
+		if c.Name == STREAM_NDJSON || strings.Contains(c.Name, ",") {
+			return nil, fmt.Errorf("invalid event stream name %q", c.Name)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate event stream name %q", c.Name)
+		}
+		names[c.Name] = true
+
+		s := eventStream{name: c.Name, values: c.Match, res: map[string]*regexp.Regexp{}}
+		for f, v := range c.Match {
+			s.fields = append(s.fields, f)
+			if c.Regex {
+				re, err := regexp.Compile("^(?:" + v + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("invalid match for %s in event stream %s: %w", f, c.Name, err)
+				}
+				s.res[f] = re
+			}
+		}
+		sort.Strings(s.fields)
+		ret.streams = append(ret.streams, s)
+	}
+	return ret, nil
+}
+
+// route sets the streams the event belongs to
+func (r *eventRouter) route(e loki.LogEvent) loki.LogEvent {
+	e.Streams = nil
+	for _, s := range r.streams {
+		if s.matches(e) {
+			e.Streams = append(e.Streams, s.name)
+		}
+	}
+	return e
+}
+
+// checkStreams rejects stream names no event could be routed to
+func (r *eventRouter) checkStreams(names map[string]bool) error {
+	for name := range names {
+		if !slices.ContainsFunc(r.streams, func(s eventStream) bool { return s.name == name }) {
+			return fmt.Errorf("unknown event stream %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+func TestEventRouter(t *testing.T) {
+	r, err := newEventRouter([]EventStreamConfig{
+		{Name: "storage", Match: map[string]string{"service": "etcd"}},
+		{Name: "workers", Match: map[string]string{"host": "worker[0-9]+"}, Regex: true},
+		{Name: "worker-etcd", Match: map[string]string{"host": "worker1", "service": "etcd"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		event loki.LogEvent
+		want  []string
+	}{
+		{"no match", loki.LogEvent{Node: "cp1", Service: "kubelet"}, nil},
+		{"exact", loki.LogEvent{Node: "cp1", Service: "etcd"}, []string{"storage"}},
+		{"regex matches the whole value", loki.LogEvent{Node: "worker12", Service: "kubelet"}, []string{"workers"}},
+		{"regex anchored", loki.LogEvent{Node: "oldworker1", Service: "kubelet"}, nil},
+		{"every field must match", loki.LogEvent{Node: "worker1", Service: "etcd"}, []string{"storage", "workers", "worker-etcd"}},
+		{"stale streams replaced", loki.LogEvent{Node: "cp1", Streams: []string{"workers"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.route(tt.event).Streams; !slices.Equal(got, tt.want) {
+				t.Errorf("routed to %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := r.checkStreams(map[string]bool{"storage": true}); err != nil {
+		t.Error(err)
+	}
+	if err := r.checkStreams(map[string]bool{"nope": true}); err == nil {
+		t.Error("unknown stream accepted")
+	}
+}
+
+func TestEventRouterConfigErrors(t *testing.T) {
+	for name, cfgs := range map[string][]EventStreamConfig{
+		"no name":         {{Match: map[string]string{"host": "a"}}},
+		"no match":        {{Name: "a"}},
+		"reserved name":   {{Name: STREAM_NDJSON, Match: map[string]string{"host": "a"}}},
+		"comma in name":   {{Name: "a,b", Match: map[string]string{"host": "a"}}},
+		"duplicate names": {{Name: "a", Match: map[string]string{"host": "a"}}, {Name: "a", Match: map[string]string{"host": "b"}}},
+		"bad regex":       {{Name: "a", Match: map[string]string{"host": "("}, Regex: true}},
+	} {
+		if _, err := newEventRouter(cfgs); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+// labwatch's own events, like the summary changes, are routed on the way
+// out just as watcher events are
+func TestBroadcastEventRoutes(t *testing.T) {
+	prevStreams, prevBuffer, prevBroadcasts := eventStreams, recentEvents, broadcasts
+	t.Cleanup(func() { eventStreams, recentEvents, broadcasts = prevStreams, prevBuffer, prevBroadcasts })
+
+	var err error
+	if eventStreams, err = newEventRouter([]EventStreamConfig{{Name: "self", Match: map[string]string{"service": "labwatch"}}}); err != nil {
+		t.Fatal(err)
+	}
+	recentEvents = newEventBuffer(10, time.Hour)
+	broadcasts = newBroadcaster(10)
+	defer broadcasts.stop()
+
+	broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Message: "lab state changed"}, slog.New(slog.DiscardHandler))
+	got := recentEvents.replay(clk.Now())
+	if len(got) != 1 || !slices.Equal(got[0].Streams, []string{"self"}) {
+		t.Fatalf("buffered %+v, want one event routed to self", got)
+	}
+}
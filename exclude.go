@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// statusSection is a status field a client names: a top level field, or a
+// field of it. A field of a map or list applies to every entry, so
+// talos.services names the services of every node.
+type statusSection struct {
+	top int
+	sub int
+}
+
+// statusExcludes are the sections ?exclude leaves out
+type statusExcludes []statusSection
+
+// statusFields are the sections ?fields keeps, leaving out the rest
+type statusFields []statusSection
+
+// jsonFieldNames are the names a field is known by: its JSON name and, for
+// fields without one, the snake_case name json-style v2 gives it
+func jsonFieldNames(f reflect.StructField) []string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if !f.IsExported() || name == "-" {
+		return nil
+	}
+	if name != "" {
+		return []string{name}
+	}
+	return []string{f.Name, snakeCase(f.Name)}
+}
+
+func fieldIndex(t reflect.Type, name string) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		for _, n := range jsonFieldNames(t.Field(i)) {
+			if n == name {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// entryStruct is the struct type a field holds directly or as the entries
+// of a map or list, or nil when it holds none
+func entryStruct(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer:
+		return entryStruct(t.Elem())
+	case reflect.Struct:
+		return t
+	}
+	return nil
+}
+
+func parseExcludes(names []string) (statusExcludes, error) {
+	return parseSections(names, "excluded")
+}
+
+func parseFields(names []string) (statusFields, error) {
+	ret, err := parseSections(names, "selected")
+	if err == nil && len(ret) == 0 {
+		err = fmt.Errorf("no sections selected")
+	}
+	return ret, err
+}
+
+// parseSections checks names like logs or talos.services against the
+// fields of LabStatus. Nothing deeper than the second level is accepted.
+func parseSections(names []string, verb string) ([]statusSection, error) {
+	t := reflect.TypeOf(LabStatus{})
+	ret := []statusSection{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		parts := strings.Split(name, ".")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid section %q: only top and second level sections can be %s", name, verb)
+		}
+		top, ok := fieldIndex(t, parts[0])
+		if !ok {
+			return nil, fmt.Errorf("unknown section %q", parts[0])
+		}
+		e := statusSection{top: top, sub: -1}
+		if len(parts) == 2 {
+			entry := entryStruct(t.Field(top).Type)
+			if entry == nil {
+				return nil, fmt.Errorf("unknown section %q: %s has no sections", name, parts[0])
+			}
+			if e.sub, ok = fieldIndex(entry, parts[1]); !ok {
+				return nil, fmt.Errorf("unknown section %q", name)
+			}
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+// ONLY_UNHEALTHY narrows a status to the nodes that aren't ok
+const ONLY_UNHEALTHY = "unhealthy"
+
+// statusView is how a client asked to see statuses: with ?only=unhealthy
+// just the lab state, its summary and the nodes that are down or degraded,
+// then only what ?fields selects or without what ?exclude leaves out
+type statusView struct {
+	unhealthy bool
+	summary   SummaryConfig
+	excludes  statusExcludes
+	fields    statusFields
+}
+
+// requestView reads ?only, ?fields and ?exclude. A client that doesn't pass
+// ?exclude gets default-excludes; ?exclude= excludes nothing. ?fields wins
+// over both, though a bad ?exclude is still rejected.
+func requestView(r *http.Request, cfg LabwatchConfig) (statusView, error) {
+	ret := statusView{summary: cfg.Summary}
+	switch only := r.URL.Query().Get("only"); only {
+	case "":
+	case ONLY_UNHEALTHY:
+		ret.unhealthy = true
+	default:
+		return ret, fmt.Errorf("invalid only %q: must be unhealthy", only)
+	}
+
+	var err error
+	if !r.URL.Query().Has("exclude") {
+		ret.excludes, err = parseExcludes(cfg.DefaultExcludes)
+	} else {
+		ret.excludes, err = parseExcludes(strings.Split(r.URL.Query().Get("exclude"), ","))
+	}
+	if err != nil || !r.URL.Query().Has("fields") {
+		return ret, err
+	}
+	ret.excludes = nil
+	ret.fields, err = parseFields(strings.Split(r.URL.Query().Get("fields"), ","))
+	return ret, err
+}
+
+func (v statusView) apply(s LabStatus) LabStatus {
+	if v.unhealthy {
+		s = unhealthyOnly(s, v.summary)
+	}
+	if v.fields != nil {
+		return v.fields.apply(s)
+	}
+	return v.excludes.apply(s)
+}
+
+// unhealthyOnly keeps the nodes the summary counts as warn or critical, and
+// those a failed dependency suppressed since they are down all the same.
+// Departed nodes are left out as the summary leaves them out.
+func unhealthyOnly(s LabStatus, cfg SummaryConfig) LabStatus {
+	nodes := map[string]talos.NodeStatus{}
+	for name, n := range s.Talos {
+		if n.Departed {
+			continue
+		}
+		if level, _ := nodeHealth(n, cfg); level != HEALTH_LEVEL_OK || n.SuppressedBy != "" {
+			nodes[name] = n
+		}
+	}
+	return LabStatus{Labwatch: s.Labwatch, Healthy: s.Healthy, Summary: s.Summary, Talos: nodes}
+}
+
+// apply returns the status with the excluded fields emptied, which leaves
+// them out of protobuf, and marked omitted so neither JSON style sends them.
+// Maps and lists are copied rather than changed since the status is shared
+// by every client.
+func (x statusExcludes) apply(s LabStatus) LabStatus {
+	if len(x) == 0 {
+		return s
+	}
+	v := reflect.ValueOf(&s).Elem()
+	for _, e := range x {
+		f := v.Field(e.top)
+		if e.sub < 0 {
+			f.SetZero()
+		} else {
+			f.Set(filterFields(f, func(i int) bool { return i == e.sub }))
+		}
+	}
+	s.omitted = append(statusExcludes{}, x...)
+	return s
+}
+
+// apply returns the status with only the selected fields, the rest emptied
+// and marked omitted as exclusion does
+func (x statusFields) apply(s LabStatus) LabStatus {
+	whole, subs := map[int]bool{}, map[int]map[int]bool{}
+	for _, e := range x {
+		if e.sub < 0 {
+			whole[e.top] = true
+		} else if subs[e.top] == nil {
+			subs[e.top] = map[int]bool{e.sub: true}
+		} else {
+			subs[e.top][e.sub] = true
+		}
+	}
+
+	ret := LabStatus{}
+	src, dst := reflect.ValueOf(s), reflect.ValueOf(&ret).Elem()
+	t := src.Type()
+	for top := 0; top < t.NumField(); top++ {
+		if jsonFieldNames(t.Field(top)) == nil {
+			continue
+		}
+		switch keep := subs[top]; {
+		case whole[top]:
+			dst.Field(top).Set(src.Field(top))
+		case keep != nil:
+			drop := func(i int) bool { return !keep[i] }
+			dst.Field(top).Set(filterFields(src.Field(top), drop))
+			entry := entryStruct(t.Field(top).Type)
+			for sub := 0; sub < entry.NumField(); sub++ {
+				if drop(sub) && jsonFieldNames(entry.Field(sub)) != nil {
+					ret.omitted = append(ret.omitted, statusSection{top: top, sub: sub})
+				}
+			}
+		default:
+			ret.omitted = append(ret.omitted, statusSection{top: top, sub: -1})
+		}
+	}
+	return ret
+}
+
+// filterFields copies v with the fields drop picks emptied, in every entry
+// when v is a map or list
+func filterFields(v reflect.Value, drop func(int) bool) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if drop(i) && c.Field(i).CanSet() {
+				c.Field(i).SetZero()
+			}
+		}
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(filterFields(v.Elem(), drop))
+		return p
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), filterFields(iter.Value(), drop))
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		l := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for j := 0; j < v.Len(); j++ {
+			l.Index(j).Set(filterFields(v.Index(j), drop))
+		}
+		return l
+	}
+	return v
+}
+
+// MarshalJSON leaves out what the client's view omitted rather than sending
+// it empty. The rest is encoded as LabStatus always has been.
+func (s LabStatus) MarshalJSON() ([]byte, error) {
+	type plain LabStatus
+	b, err := json.Marshal(plain(s))
+	if err != nil || len(s.omitted) == 0 {
+		return b, err
+	}
+	omitted := s.omitted.byName(func(names []string) string { return names[0] })
+	return editObject(b, func(key string, v json.RawMessage) (json.RawMessage, error) {
+		o, ok := omitted[key]
+		if !ok {
+			return v, nil
+		}
+		if o.subs == nil {
+			return nil, nil
+		}
+		drop := func(entry json.RawMessage) (json.RawMessage, error) {
+			return editObject(entry, func(key string, v json.RawMessage) (json.RawMessage, error) {
+				if o.subs[key] {
+					return nil, nil
+				}
+				return v, nil
+			})
+		}
+		switch o.kind {
+		case reflect.Map:
+			return editObject(v, func(_ string, entry json.RawMessage) (json.RawMessage, error) { return drop(entry) })
+		case reflect.Slice:
+			entries := []json.RawMessage{}
+			if err := json.Unmarshal(v, &entries); err != nil || entries == nil {
+				return v, err
+			}
+			for i := range entries {
+				if entries[i], err = drop(entries[i]); err != nil {
+					return nil, err
+				}
+			}
+			return json.Marshal(entries)
+		}
+		return drop(v)
+	})
+}
+
+// omittedField is a top level field a view left out: the whole of it when
+// subs is nil, otherwise the fields named in subs of it or its entries
+type omittedField struct {
+	kind reflect.Kind
+	subs map[string]bool
+}
+
+// byName keys the omitted fields by the name pick chooses from a field's
+// jsonFieldNames
+func (x statusExcludes) byName(pick func(names []string) string) map[string]omittedField {
+	t := reflect.TypeOf(LabStatus{})
+	ret := map[string]omittedField{}
+	for _, e := range x {
+		f := t.Field(e.top)
+		top := pick(jsonFieldNames(f))
+		o, seen := ret[top]
+		if e.sub < 0 {
+			ret[top] = omittedField{kind: f.Type.Kind()}
+			continue
+		}
+		if seen && o.subs == nil {
+			continue
+		}
+		if !seen {
+			o = omittedField{kind: f.Type.Kind(), subs: map[string]bool{}}
+		}
+		o.subs[pick(jsonFieldNames(entryStruct(f.Type).Field(e.sub)))] = true
+		ret[top] = o
+	}
+	return ret
+}
+
+// editObject rewrites the members of a JSON object in order, leaving out
+// those edit returns nil for. null is returned as it is.
+func editObject(b []byte, edit func(key string, v json.RawMessage) (json.RawMessage, error)) ([]byte, error) {
+	if bytes.Equal(b, []byte("null")) {
+		return b, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	out := bytes.Buffer{}
+	out.WriteByte('{')
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		v := json.RawMessage{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if v, err = edit(key, v); err != nil {
+			return nil, err
+		} else if v == nil {
+			continue
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		out.Write(k)
+		out.WriteByte(':')
+		out.Write(v)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestParseExcludesErrors(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"talos.services.etcd"}, "only top and second level sections can be excluded"},
+		{[]string{"nope"}, `unknown section "nope"`},
+		{[]string{"logs", "talos.nope"}, `unknown section "talos.nope"`},
+		{[]string{"healthy.x"}, "healthy has no sections"},
+		{[]string{"*"}, `unknown section "*"`},
+	}
+	for _, tt := range tests {
+		if _, err := parseExcludes(tt.names); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseExcludes(%q) = %v, want an error containing %q", tt.names, err, tt.want)
+		}
+	}
+
+	// Fields are known by their JSON names and the snake_case of untagged
+	// ones, and blanks left by a trailing comma are skipped
+	x, err := parseExcludes([]string{"talos.services", "talos.Ready", " logs", "talos_summary", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 4 {
+		t.Errorf("parsed %+v", x)
+	}
+
+	cfg := defaultConfig()
+	cfg.DefaultExcludes = []string{"talos.nope"}
+	if err := validateConfig(cfg); err == nil || !strings.Contains(err.Error(), "invalid default-excludes") {
+		t.Errorf("default-excludes validated as %v", err)
+	}
+}
+
+func excludeStatus() LabStatus {
+	return LabStatus{
+		Summary: Summary{State: HEALTH_LEVEL_CRITICAL},
+		Talos: map[string]talos.NodeStatus{
+			"cp1": {Node: "cp1", WatcherState: talos.CONNECTION_OK, Ready: true, Services: map[string]talos.ServiceStatus{
+				"etcd": {State: "Running", Healthy: talos.HEALTH_OK},
+			}},
+			"worker1": {Node: "worker1", WatcherState: talos.CONNECTION_OK, Services: map[string]talos.ServiceStatus{
+				"kubelet": {State: "Failed", Healthy: talos.HEALTH_ERR},
+			}},
+		},
+		Logs: loki.LogStats{NumMessages: 12},
+	}
+}
+
+func TestExcludeApply(t *testing.T) {
+	s := excludeStatus()
+	x, err := parseExcludes([]string{"talos.services", "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := x.apply(s)
+	if got.Logs.NumMessages != 0 {
+		t.Errorf("logs left as %+v", got.Logs)
+	}
+	if len(got.Talos) != 2 {
+		t.Fatalf("nodes left as %+v", got.Talos)
+	}
+	for name, n := range got.Talos {
+		if n.Services != nil {
+			t.Errorf("%s services left as %+v", name, n.Services)
+		}
+		if n.Node != name {
+			t.Errorf("%s node emptied along with its services", name)
+		}
+	}
+	if got.Summary.State != HEALTH_LEVEL_CRITICAL {
+		t.Errorf("summary changed to %s", got.Summary.State)
+	}
+
+	// The status is shared by every client so the original is untouched
+	if s.Logs.NumMessages != 12 || len(s.Talos["cp1"].Services) != 1 || len(s.Talos["worker1"].Services) != 1 {
+		t.Errorf("original changed to %+v", s)
+	}
+}
+
+func TestRequestView(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DefaultExcludes = []string{"logs"}
+
+	tests := []struct {
+		query string
+		// logs and services say whether each is left in the status
+		logs, services bool
+		nodes          int
+		err            string
+	}{
+		{query: "", logs: false, services: true, nodes: 2},
+		// Passing exclude replaces default-excludes rather than adding to them
+		{query: "exclude=talos.services", logs: true, services: false, nodes: 2},
+		{query: "exclude=", logs: true, services: true, nodes: 2},
+		{query: "only=unhealthy", logs: false, services: true, nodes: 1},
+		// Exclusion applies on top of only, which keeps no logs of its own
+		{query: "only=unhealthy&exclude=talos.services", logs: false, services: false, nodes: 1},
+		{query: "only=unhealthy&exclude=talos", logs: false, services: false, nodes: 0},
+		// Fields wins over exclude and default-excludes
+		{query: "fields=talos", logs: false, services: true, nodes: 2},
+		{query: "fields=logs", logs: true, nodes: 0},
+		{query: "fields=logs,talos.services&exclude=logs,talos.services", logs: true, services: true, nodes: 2},
+		{query: "fields=talos.Ready&exclude=talos", logs: false, services: false, nodes: 2},
+		{query: "only=unhealthy&fields=talos,logs", logs: false, services: true, nodes: 1},
+		{query: "only=healthy", err: `invalid only "healthy"`},
+		{query: "fields=", err: "no sections selected"},
+		{query: "fields=nope", err: `unknown section "nope"`},
+		{query: "fields=talos.services.etcd", err: "only top and second level sections can be selected"},
+		// A bad exclude is still rejected alongside fields
+		{query: "fields=logs&exclude=nope", err: `unknown section "nope"`},
+		{query: "exclude=talos.nope", err: `unknown section "talos.nope"`},
+		{query: "only=unhealthy&exclude=a.b.c", err: "only top and second level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			view, err := requestView(httptest.NewRequest("GET", "/status?"+tt.query, nil), cfg)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := view.apply(excludeStatus())
+			if logs := got.Logs.NumMessages != 0; logs != tt.logs {
+				t.Errorf("logs left in: %t, want %t", logs, tt.logs)
+			}
+			if len(got.Talos) != tt.nodes {
+				t.Errorf("%d nodes left, want %d", len(got.Talos), tt.nodes)
+			}
+			services := false
+			for _, n := range got.Talos {
+				services = services || n.Services != nil
+			}
+			if tt.nodes > 0 && services != tt.services {
+				t.Errorf("services left in: %t, want %t", services, tt.services)
+			}
+			if tt.nodes == 1 && got.Talos["worker1"].Node != "worker1" {
+				t.Errorf("only kept %+v, want the not ready worker", got.Talos)
+			}
+		})
+	}
+}
+
+// Omitted sections leave their keys out of either JSON style rather than
+// being sent empty
+func TestViewEncoding(t *testing.T) {
+	defer func(style JSONStyle) { jsonStyle = style }(jsonStyle)
+	cfg := defaultConfig()
+	tests := []struct {
+		query string
+		// top and node are the keys left in the status and in each node, in
+		// legacy then v2 names. Without node only services is checked.
+		top      [2][]string
+		node     [2][]string
+		services bool
+	}{
+		{
+			query: "fields=summary,talos.Ready,talos.Node",
+			top:   [2][]string{{"summary", "talos"}, {"summary", "talos"}},
+			node:  [2][]string{{"Node", "Ready"}, {"node", "ready"}},
+		},
+		{
+			// Whole sections win over their fields
+			query:    "fields=summary,talos.Ready,talos",
+			top:      [2][]string{{"summary", "talos"}, {"summary", "talos"}},
+			services: true,
+		},
+		{
+			query: "exclude=labwatch,healthy,maintenance,sections,outputs,talos_summary,logs,config_hash,self,churn,talos.Services",
+			top:   [2][]string{{"summary", "talos"}, {"summary", "talos"}},
+		},
+	}
+	for _, tt := range tests {
+		for i, style := range []JSONStyle{JSON_STYLE_LEGACY, JSON_STYLE_V2} {
+			t.Run(tt.query+"/"+string(style), func(t *testing.T) {
+				jsonStyle = style
+				view, err := requestView(httptest.NewRequest("GET", "/status?"+tt.query, nil), cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := encodeJSON(view.apply(excludeStatus()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := map[string]map[string]any{}
+				top := map[string]json.RawMessage{}
+				if err := json.Unmarshal(b, &top); err != nil {
+					t.Fatal(err)
+				}
+				if keys := slices.Sorted(maps.Keys(top)); !slices.Equal(keys, tt.top[i]) {
+					t.Errorf("sent %v in %s", keys, b)
+				}
+				if err := json.Unmarshal(top["talos"], &got); err != nil {
+					t.Fatal(err)
+				}
+				for name, n := range got {
+					keys := slices.Sorted(maps.Keys(n))
+					if tt.node[i] != nil && !slices.Equal(keys, tt.node[i]) {
+						t.Errorf("sent %v for %s", keys, name)
+					}
+					if services := slices.Contains(keys, "Services") || slices.Contains(keys, "services"); tt.node[i] == nil && services != tt.services {
+						t.Errorf("sent %v for %s", keys, name)
+					}
+				}
+			})
+		}
+	}
+}
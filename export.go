@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const EXPORT_NDJSON = "ndjson"
+const EXPORT_CSV = "csv"
+
+// exportFlushEvery bounds how many rows are held before being sent on
+var exportFlushEvery = 100
+
+// exportColumns is the CSV header. Append new columns at the end so scripts
+// reading by position keep working.
+var exportColumns = []string{"received", "timestamp", "node", "service", "level", "message", "source_id", "trace_id", "injected", "count", "hosts", "fields"}
+
+func exportRow(e BufferedEvent) []string {
+	fields := ""
+	if len(e.Fields) > 0 {
+		b, _ := json.Marshal(e.Fields)
+		fields = string(b)
+	}
+	ts := ""
+	if !e.Timestamp.IsZero() {
+		ts = e.Timestamp.Format(time.RFC3339Nano)
+	}
+	return []string{
+		e.Received.Format(time.RFC3339Nano),
+		ts,
+		e.Node,
+		e.Service,
+		e.Level,
+		e.Message,
+		e.SourceID,
+		e.TraceID,
+		strconv.FormatBool(e.Injected),
+		strconv.Itoa(e.Count),
+		strings.Join(e.Hosts, ","),
+		fields,
+	}
+}
+
+func parseExportTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// handleEventExport downloads the events received between ?from and ?to as
+// NDJSON or CSV, narrowed by the /events filter parameters. Events are not
+// persisted, so only what is still in the in-memory buffer can be exported;
+// the response says so in a Warning header.
+func handleEventExport(cfg LabwatchConfig, log *slog.Logger) http.HandlerFunc {
+	log = log.With("operation", "handleEventExport")
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		now := time.Now()
+		from, err := parseExportTime(q.Get("from"), time.Time{})
+		if err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseExportTime(q.Get("to"), now)
+		if err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := q.Get("format")
+		if format == "" {
+			format = EXPORT_NDJSON
+		}
+		if format != EXPORT_NDJSON && format != EXPORT_CSV {
+			http.Error(w, fmt.Sprintf("invalid format %q: must be one of ndjson|csv", format), http.StatusBadRequest)
+			return
+		}
+
+		filter := parseEventFilter(q)
+		events := recentEvents.collect(func(e BufferedEvent) bool {
+			return !e.Received.After(to) && filter.matches(e.LogEvent)
+		}, from, now, 0)
+
+		flusher, _ := w.(http.Flusher)
+		flush := func() {
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		name := fmt.Sprintf("labwatch-events-%s.%s", now.UTC().Format("20060102T150405Z"), format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		w.Header().Set("Warning", fmt.Sprintf(`199 labwatch "events are not persisted; export covers at most the last %d events within %s"`,
+			cfg.Correlation.BufferSize, time.Duration(cfg.Correlation.BufferMaxAge)))
+
+		rows := 0
+		if format == EXPORT_CSV {
+			w.Header().Set("Content-Type", "text/csv")
+			cw := csv.NewWriter(w)
+			cw.Write(exportColumns)
+			for _, e := range events {
+				if err = cw.Write(exportRow(e)); err != nil {
+					break
+				}
+				if rows++; rows%exportFlushEvery == 0 {
+					cw.Flush()
+					flush()
+				}
+			}
+			cw.Flush()
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for _, e := range events {
+				b, _ := encodeJSON(e)
+				if _, err = w.Write(append(b, '\n')); err != nil {
+					break
+				}
+				if rows++; rows%exportFlushEvery == 0 {
+					flush()
+				}
+			}
+		}
+
+		log.Info("event export", "remote", r.RemoteAddr, "format", format, "from", from, "to", to, "query", r.URL.RawQuery, "rows", rows)
+	}
+}
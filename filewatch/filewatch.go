@@ -0,0 +1,85 @@
+package filewatch
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDuration lets a burst of writes finish before the change is reported
+var settleDuration = time.Duration(500) * time.Millisecond
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func stat(path string) fileState {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+}
+
+// Watch calls onChange whenever the file at path changes until ctx is done.
+// The parent directory is watched with fsnotify so editors and tools that
+// replace the file by renaming are caught, and the file is also polled every
+// interval in case notifications aren't available (network filesystems,
+// mounted secrets).
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(), log *slog.Logger) {
+	log = log.With("operation", "filewatch", "file", path)
+	path = filepath.Clean(path)
+	last := stat(path)
+
+	var events chan fsnotify.Event
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warn("file notifications unavailable, polling only", "error", err.Error())
+	} else if err = watcher.Add(filepath.Dir(path)); err != nil {
+		log.Warn("file notifications unavailable, polling only", "error", err.Error())
+		watcher.Close()
+	} else {
+		defer watcher.Close()
+		events = watcher.Events
+		go func() {
+			for err := range watcher.Errors {
+				log.Warn("file notification error", "error", err.Error())
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+			} else if filepath.Clean(e.Name) == path {
+				settle = time.After(settleDuration)
+			}
+		case <-ticker.C:
+			if current := stat(path); current != last {
+				last = current
+				log.Info("file changed")
+				onChange()
+			}
+		case <-settle:
+			// Trust the notification even if the stat looks the same, a
+			// same-sized rewrite within the mtime granularity is invisible
+			settle = nil
+			last = stat(path)
+			log.Info("file changed")
+			onChange()
+		}
+	}
+}
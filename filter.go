@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// eventFilter selects events by the host, level, service, trace and stream
+// query parameters. Each takes a comma separated list; an absent parameter
+// matches everything. text matches a case insensitive substring of the
+// message.
+type eventFilter struct {
+	hosts    map[string]bool
+	levels   map[string]bool
+	services map[string]bool
+	traces   map[string]bool
+	streams  map[string]bool
+	text     string
+}
+
+func parseEventFilter(q url.Values) eventFilter {
+	streams, _ := parseStreamSelection(q.Get("stream"))
+	return eventFilter{
+		hosts:    filterSet(q.Get("host")),
+		levels:   filterSet(q.Get("level")),
+		services: filterSet(q.Get("service")),
+		traces:   filterSet(q.Get("trace")),
+		streams:  streams,
+		text:     strings.ToLower(q.Get("text")),
+	}
+}
+
+// parseStreamSelection splits ?stream into the event streams it names and
+// whether it asks for ndjson, so ?stream=ndjson,auth streams the auth events
+// as ndjson
+func parseStreamSelection(v string) (map[string]bool, bool) {
+	streams := filterSet(v)
+	ndjson := streams[STREAM_NDJSON]
+	delete(streams, STREAM_NDJSON)
+	if len(streams) == 0 {
+		streams = nil
+	}
+	return streams, ndjson
+}
+
+func filterSet(v string) map[string]bool {
+	if v == "" {
+		return nil
+	}
+	ret := map[string]bool{}
+	for _, s := range strings.Split(v, ",") {
+		ret[strings.TrimSpace(s)] = true
+	}
+	return ret
+}
+
+func (f eventFilter) matches(e loki.LogEvent) bool {
+	if f.hosts != nil && !f.hosts[e.Node] && !f.hosts[e.SourceID] {
+		return false
+	}
+	if f.levels != nil && !f.levels[e.Level] {
+		return false
+	}
+	if f.services != nil && !f.services[e.Service] {
+		return false
+	}
+	if f.traces != nil && !f.traces[e.TraceID] {
+		return false
+	}
+	if f.streams != nil && !slices.ContainsFunc(e.Streams, func(s string) bool { return f.streams[s] }) {
+		return false
+	}
+	if f.text != "" && !strings.Contains(strings.ToLower(e.Message), f.text) {
+		return false
+	}
+	return true
+}
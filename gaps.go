@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/gorilla/websocket"
+)
+
+var gapStats = expvar.NewMap("event_gaps")
+
+// eventSeq is the number of the last event broadcast
+var eventSeq uint64
+var eventSeqLock sync.Mutex
+
+// clientMessage is a message read from a v2 client: a subscription change or
+// a control such as report_gap, or why it couldn't be read
+type clientMessage struct {
+	subscribe *server.Subscribe
+	control   *server.ClientControl
+	err       error
+}
+
+// parseClientMessage tells controls, which name an action, from
+// subscription changes
+func parseClientMessage(b []byte) clientMessage {
+	control := server.ClientControl{}
+	if err := json.Unmarshal(b, &control); err != nil {
+		return clientMessage{err: fmt.Errorf("invalid message: %w", err)}
+	}
+	switch control.Action {
+	case "":
+	case server.ACTION_REPORT_GAP:
+		return clientMessage{control: &control}
+	default:
+		return clientMessage{err: fmt.Errorf("unknown action %q: must be %s", control.Action, server.ACTION_REPORT_GAP)}
+	}
+
+	req := server.Subscribe{}
+	if err := json.Unmarshal(b, &req); err != nil {
+		return clientMessage{err: fmt.Errorf("invalid subscribe message: %w", err)}
+	}
+	return clientMessage{subscribe: &req}
+}
+
+// readClientMessages hands what the client sends over to the goroutine
+// writing to the connection, which must be the only one to do so. The read
+// error that ends the connection is sent on failed.
+func readClientMessages(conn *websocket.Conn, done <-chan struct{}) (<-chan clientMessage, <-chan error) {
+	messages := make(chan clientMessage)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case messages <- parseClientMessage(b):
+			case <-done:
+				return
+			}
+		}
+	}()
+	return messages, failed
+}
+
+// backfill answers a reported gap with the events still buffered that match
+// the client's filter. The buffer holds a run of numbers up to the newest
+// event, so what it lacks of the gap is its start, which is reported as
+// unrecoverable.
+func backfill(conn *websocket.Conn, framer *server.Framer, client ClientInfo, filter eventFilter, req server.ClientControl) error {
+	gapStats.Add("reported", 1)
+	if req.From == 0 || req.To < req.From {
+		return client.track(writeMessage(conn, framer, server.TYPE_ERROR, server.Error{Message: "invalid gap: from must be positive and not after to"}))
+	}
+
+	buffered := recentEvents.numbered(req.From, req.To, clk.Now())
+	lostTo := req.To
+	if len(buffered) > 0 {
+		lostTo = buffered[0].Seq - 1
+	}
+
+	events := []loki.LogEvent{}
+	for _, e := range buffered {
+		if filter.matches(e.LogEvent) {
+			events = append(events, e.LogEvent)
+		}
+	}
+	if len(buffered) > 0 {
+		gapStats.Add("backfills_served", 1)
+		gapStats.Add("events_backfilled", int64(len(events)))
+		if err := client.track(writeMessage(conn, framer, server.TYPE_BACKFILL, server.Backfill{From: buffered[0].Seq, To: req.To, Events: events})); err != nil {
+			return err
+		}
+	}
+	if lostTo >= req.From {
+		gapStats.Add("unrecoverable", 1)
+		control := server.Control{
+			Action:  server.CONTROL_GAP_UNRECOVERABLE,
+			Message: fmt.Sprintf("events %d to %d are no longer buffered", req.From, lostTo),
+		}
+		return client.track(writeMessage(conn, framer, server.TYPE_CONTROL, control))
+	}
+	return nil
+}
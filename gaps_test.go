@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/gorilla/websocket"
+)
+
+// streamMessage is an envelope as a v2 client reads it
+type streamMessage struct {
+	server.Envelope
+	Data json.RawMessage `json:"data"`
+}
+
+func totalDropped() int64 {
+	total := int64(0)
+	droppedUpdates.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok {
+			total += v.Value()
+		}
+	})
+	return total
+}
+
+// dialStream connects a v2 events client to /stream, served with the test
+// globals and a client queue of queueSize. Messages after the subscription
+// is confirmed arrive on the channel, which is closed with the connection.
+func dialStream(t *testing.T, queueSize int) (*websocket.Conn, <-chan streamMessage) {
+	t.Helper()
+	cfg := defaultConfig()
+	useTestGlobals(t, cfg)
+	prevSize, prevPolicy, prevBroadcasts := clientQueueSize, slowClientPolicy, broadcasts
+	clientQueueSize, slowClientPolicy = queueSize, SLOW_CLIENT_DROP
+	broadcasts = newBroadcaster(1000)
+	t.Cleanup(func() {
+		<-broadcasts.stop()
+		clientQueueSize, slowClientPolicy, broadcasts = prevSize, prevPolicy, prevBroadcasts
+	})
+
+	origins, err := newOriginPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handleStream(cfg, &streamAuth{origins: origins}, websocket.Upgrader{}, discardLog))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?protocol=v2&subscribe=events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		conn.Close()
+	})
+	messages := make(chan streamMessage)
+	go func() {
+		defer close(messages)
+		for {
+			msg := streamMessage{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for msg := range messages {
+		if msg.Type == server.TYPE_CONTROL {
+			return conn, messages
+		}
+	}
+	t.Fatal("subscription never confirmed")
+	return nil, nil
+}
+
+func reportGap(t *testing.T, conn *websocket.Conn, from uint64, to uint64) {
+	t.Helper()
+	gap, _ := json.Marshal(server.ClientControl{Action: server.ACTION_REPORT_GAP, From: from, To: to})
+	if err := conn.WriteMessage(websocket.TextMessage, gap); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func next(t *testing.T, messages <-chan streamMessage) streamMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received")
+	}
+	return streamMessage{}
+}
+
+// Events dropped for a client with a one event buffer show up as a gap
+// between what it receives, and reporting the gap fills it in from the ring
+// buffer so the client ends up with every event
+func TestBackfillHealsDroppedEvents(t *testing.T) {
+	conn, messages := dialStream(t, 1)
+
+	// Nothing is taken off messages while big events stall the writer, so
+	// the client's queue overflows
+	eventSeqLock.Lock()
+	first := eventSeq + 1
+	eventSeqLock.Unlock()
+	payload := strings.Repeat("x", 256*1024)
+	dropped := totalDropped()
+	for sent := 0; totalDropped() == dropped; sent++ {
+		if sent == 1000 {
+			t.Fatal("no events dropped for a client that isn't reading")
+		}
+		broadcastEvent(loki.LogEvent{Node: "worker1", Service: "kubelet", Message: payload}, discardLog)
+		time.Sleep(time.Millisecond)
+	}
+
+	// Small events keep arriving once it reads again, and the stream has
+	// healed when it holds every event up to one of them
+	received := map[uint64]int{}
+	healed := func(upTo uint64) bool {
+		for seq := first; seq <= upTo; seq++ {
+			if received[seq] == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	gaps := 0
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(10 * time.Second)
+	for done := false; !done; {
+		select {
+		case <-timeout:
+			t.Fatalf("stream never healed, %d gaps reported", gaps)
+		case <-tick.C:
+			broadcastEvent(loki.LogEvent{Node: "worker1", Service: "kubelet", Message: "small"}, discardLog)
+		case msg, ok := <-messages:
+			if !ok {
+				t.Fatal("connection closed")
+			}
+			switch msg.Type {
+			case server.TYPE_EVENT:
+				e := loki.LogEvent{}
+				if err := json.Unmarshal(msg.Data, &e); err != nil {
+					t.Fatal(err)
+				}
+				if msg.Prev != 0 && e.Seq != msg.Prev+1 {
+					gaps++
+					reportGap(t, conn, msg.Prev+1, e.Seq-1)
+				}
+				received[e.Seq]++
+				done = e.Message == "small" && healed(e.Seq)
+			case server.TYPE_BACKFILL:
+				b := server.Backfill{}
+				events := []loki.LogEvent{}
+				b.Events = &events
+				if err := json.Unmarshal(msg.Data, &b); err != nil {
+					t.Fatal(err)
+				}
+				for _, e := range events {
+					received[e.Seq]++
+				}
+			default:
+				t.Fatalf("unexpected %s message: %s", msg.Type, msg.Data)
+			}
+		}
+	}
+	if gaps == 0 {
+		t.Fatal("the client saw no gap")
+	}
+	for seq, n := range received {
+		if n > 1 && seq >= first {
+			t.Errorf("event %d received %d times", seq, n)
+		}
+	}
+}
+
+func TestBackfillUnrecoverable(t *testing.T) {
+	conn, messages := dialStream(t, 8)
+	control := func(msg streamMessage) server.Control {
+		t.Helper()
+		c := server.Control{}
+		if err := json.Unmarshal(msg.Data, &c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// The ring buffer is empty, so nothing can be sent again
+	reportGap(t, conn, 5, 7)
+	msg := next(t, messages)
+	if c := control(msg); msg.Type != server.TYPE_CONTROL || c.Action != server.CONTROL_GAP_UNRECOVERABLE || c.Message != "events 5 to 7 are no longer buffered" {
+		t.Errorf("got %s %+v", msg.Type, c)
+	}
+
+	// Only the start of a gap reaching past the oldest buffered event is
+	// lost. Two numbers are skipped as if their events had aged out.
+	eventSeqLock.Lock()
+	eventSeq += 2
+	first := eventSeq + 1
+	eventSeqLock.Unlock()
+	for range 3 {
+		broadcastEvent(loki.LogEvent{Node: "worker1", Service: "kubelet", Message: "buffered"}, discardLog)
+		if msg := next(t, messages); msg.Type != server.TYPE_EVENT {
+			t.Fatalf("got %s", msg.Type)
+		}
+	}
+	reportGap(t, conn, first-2, first+1)
+	msg = next(t, messages)
+	b := server.Backfill{}
+	events := []loki.LogEvent{}
+	b.Events = &events
+	if err := json.Unmarshal(msg.Data, &b); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != server.TYPE_BACKFILL || b.From != first || b.To != first+1 || len(events) != 2 || events[0].Seq != first {
+		t.Errorf("got %s %+v with %d events", msg.Type, b, len(events))
+	}
+	msg = next(t, messages)
+	if c := control(msg); c.Action != server.CONTROL_GAP_UNRECOVERABLE || c.Message != fmt.Sprintf("events %d to %d are no longer buffered", first-2, first-1) {
+		t.Errorf("got %s %+v", msg.Type, c)
+	}
+
+	reportGap(t, conn, 9, 3)
+	if msg := next(t, messages); msg.Type != server.TYPE_ERROR {
+		t.Errorf("backwards gap answered with %s", msg.Type)
+	}
+}
+
+func TestParseClientMessage(t *testing.T) {
+	tests := []struct {
+		msg     string
+		control bool
+		sub     bool
+		err     string
+	}{
+		{msg: `{"action":"report_gap","from":3,"to":5}`, control: true},
+		{msg: `{"subscribe":["events"],"event_filter":{"host":"worker1"}}`, sub: true},
+		{msg: `{"action":"shout"}`, err: `unknown action "shout"`},
+		{msg: `not json`, err: "invalid message"},
+		{msg: `{"subscribe":"events"}`, err: "invalid subscribe message"},
+	}
+	for _, tt := range tests {
+		got := parseClientMessage([]byte(tt.msg))
+		if (got.control != nil) != tt.control || (got.subscribe != nil) != tt.sub {
+			t.Errorf("%s parsed as %+v", tt.msg, got)
+		}
+		if tt.err == "" && got.err != nil || tt.err != "" && (got.err == nil || !strings.Contains(got.err.Error(), tt.err)) {
+			t.Errorf("%s: got error %v, want %q", tt.msg, got.err, tt.err)
+		}
+	}
+	if got := parseClientMessage([]byte(`{"action":"report_gap","from":3,"to":5}`)); got.control.From != 3 || got.control.To != 5 {
+		t.Errorf("gap parsed as %+v", got.control)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+var discardLog = slog.New(slog.DiscardHandler)
+
+// useTestGlobals gives the test fresh trackers built from cfg, the way main
+// builds them, and a fake clock. Everything is put back when the test ends.
+func useTestGlobals(t *testing.T, cfg LabwatchConfig) *clock.Fake {
+	t.Helper()
+	saved := struct {
+		clk          clock.Clock
+		status       LabStatus
+		notifier     *webhookNotifier
+		recentEvents *eventBuffer
+		transitions  *transitionTracker
+		incidents    *incidentTracker
+		churn        *churnTracker
+		eventStats   *statsHistory
+		silences     *silenceList
+		maintenance  *maintenanceMode
+	}{clk, loadStatus(), notifier, recentEvents, transitions, incidents, churn, eventStats, silences, maintenance}
+	t.Cleanup(func() {
+		clk = saved.clk
+		storeStatus(saved.status)
+		notifier = saved.notifier
+		recentEvents = saved.recentEvents
+		transitions = saved.transitions
+		incidents = saved.incidents
+		churn = saved.churn
+		eventStats = saved.eventStats
+		silences = saved.silences
+		maintenance = saved.maintenance
+	})
+
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	clk = fake
+	storeStatus(LabStatus{Labwatch: LabwatchStatus{State: LABWATCH_STARTING}})
+	notifier = newWebhookNotifier(notifyDestinations(cfg), cfg.NotifyQueueFile, time.Duration(cfg.NotifyMaxAge), cfg.NotifyMaxPerMinute, discardLog)
+	recentEvents = newEventBuffer(cfg.Correlation.BufferSize, time.Duration(cfg.Correlation.BufferMaxAge))
+	transitions = newTransitionTracker(cfg.Correlation, recentEvents)
+	incidents = newIncidentTracker(cfg.Incidents, cfg.DependsOn, cfg.Summary)
+	churn = newChurnTracker(cfg.Churn)
+	eventStats = newStatsHistory(time.Duration(cfg.StatsResolution))
+	silences = &silenceList{silenced: map[string]bool{}}
+	maintenance = &maintenanceMode{}
+	return fake
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// eventGroupKey composes the identity of an event's source from the
+// configured fields. host, service and level refer to the normalized event
+// fields; anything else is looked up in the parsed log line.
+func eventGroupKey(e loki.LogEvent, fields []string) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		switch f {
+		case "host":
+			parts[i] = e.Node
+		case "service":
+			parts[i] = e.Service
+		case "level":
+			parts[i] = e.Level
+		default:
+			if v, ok := e.Fields[f]; ok {
+				parts[i] = fmt.Sprint(v)
+			}
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// eventDeduper collapses consecutive identical messages from the same source
+// the way syslog does, reporting how often a message repeated once the run
+// ends
+type eventDeduper struct {
+	fields []string
+	last   map[string]loki.LogEvent
+	counts map[string]int
+}
+
+func newEventDeduper(fields []string) *eventDeduper {
+	return &eventDeduper{
+		fields: fields,
+		last:   map[string]loki.LogEvent{},
+		counts: map[string]int{},
+	}
+}
+
+// filter returns the events to broadcast in place of e: nothing while a
+// repeat run continues, otherwise e preceded by a repeat summary if a run
+// just ended
+func (d *eventDeduper) filter(e loki.LogEvent) []loki.LogEvent {
+	key := eventGroupKey(e, d.fields)
+	last, seen := d.last[key]
+	if seen && last.Message == e.Message && last.Level == e.Level {
+		d.counts[key]++
+		return nil
+	}
+
+	ret := []loki.LogEvent{}
+	if n := d.counts[key]; n > 0 {
+		summary := last
+		summary.Message = fmt.Sprintf("last message repeated %d times", n)
+		summary.Fields = nil
+		ret = append(ret, summary)
+	}
+	d.last[key] = e
+	d.counts[key] = 0
+	return append(ret, e)
+}
+
+type TopTalker struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// topTalkers ranks event sources by how many events in the buffer they sent
+func topTalkers(events []BufferedEvent, fields []string, limit int) []TopTalker {
+	counts := map[string]int{}
+	for _, e := range events {
+		counts[eventGroupKey(e.LogEvent, fields)]++
+	}
+
+	ret := []TopTalker{}
+	for k, c := range counts {
+		ret = append(ret, TopTalker{Key: k, Count: c})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].Key < ret[j].Key
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret
+}
+
+func handleTopTalkers(fields []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 10
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		b, _ := encodeJSON(topTalkers(recentEvents.replay(time.Now()), fields, limit))
+		w.Write(b)
+	}
+}
+
+// eventAggregator collapses the same message showing up on several hosts
+// within the window. The first occurrence is passed on straight away; any
+// repeats are held and reported as one event listing the affected hosts once
+// the window closes.
+type eventAggregator struct {
+	window time.Duration
+	open   map[string]*aggregateWindow
+}
+
+type aggregateWindow struct {
+	started time.Time
+	first   loki.LogEvent
+	hosts   map[string]bool
+	count   int
+}
+
+func newEventAggregator(window time.Duration) *eventAggregator {
+	return &eventAggregator{window: window, open: map[string]*aggregateWindow{}}
+}
+
+func aggregateKey(e loki.LogEvent) string {
+	return e.Level + "\x00" + e.Message
+}
+
+func (a *eventAggregator) filter(e loki.LogEvent, now time.Time) []loki.LogEvent {
+	ret := a.flush(now)
+	key := aggregateKey(e)
+	if w, ok := a.open[key]; ok {
+		w.hosts[e.Node] = true
+		w.count++
+		return ret
+	}
+	a.open[key] = &aggregateWindow{started: now, first: e, hosts: map[string]bool{e.Node: true}, count: 1}
+	return append(ret, e)
+}
+
+// flush closes the windows that have expired, returning an aggregate for
+// each that saw more than the first event
+func (a *eventAggregator) flush(now time.Time) []loki.LogEvent {
+	ret := []loki.LogEvent{}
+	for key, w := range a.open {
+		if now.Sub(w.started) < a.window {
+			continue
+		}
+		delete(a.open, key)
+		if w.count == 1 {
+			continue
+		}
+
+		hosts := []string{}
+		for h := range w.hosts {
+			hosts = append(hosts, h)
+		}
+		sort.Strings(hosts)
+
+		agg := w.first
+		agg.Node = strings.Join(hosts, ",")
+		agg.SourceID = ""
+		agg.Fields = nil
+		agg.Timestamp = now
+		agg.Hosts = hosts
+		agg.Count = w.count
+		ret = append(ret, agg)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Message < ret[j].Message })
+	return ret
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// watcherHealth tracks whether each watcher is ready. It is the single source
+// of truth for /readyz and the gRPC health service so the two can't disagree.
+type watcherHealth struct {
+	ready     map[string]bool
+	listeners []func(name string, ready bool)
+	lock      sync.Mutex
+}
+
+var watchersHealth = newWatcherHealth(WATCHER_TALOS, WATCHER_LOKI)
+
+func newWatcherHealth(names ...string) *watcherHealth {
+	h := &watcherHealth{ready: map[string]bool{}}
+	for _, name := range names {
+		h.ready[name] = false
+	}
+	return h
+}
+
+func (h *watcherHealth) set(name string, ready bool) {
+	h.lock.Lock()
+	changed := h.ready[name] != ready
+	h.ready[name] = ready
+	listeners := h.listeners
+	h.lock.Unlock()
+
+	if changed {
+		for _, l := range listeners {
+			l(name, ready)
+		}
+	}
+}
+
+// subscribe calls fn with the current state of every watcher and again for
+// each subsequent change
+func (h *watcherHealth) subscribe(fn func(name string, ready bool)) {
+	h.lock.Lock()
+	h.listeners = append(h.listeners, fn)
+	current := map[string]bool{}
+	for k, v := range h.ready {
+		current[k] = v
+	}
+	h.lock.Unlock()
+
+	for name, ready := range current {
+		fn(name, ready)
+	}
+}
+
+func (h *watcherHealth) snapshot() (map[string]bool, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	ret := map[string]bool{}
+	all := true
+	for k, v := range h.ready {
+		ret[k] = v
+		all = all && v
+	}
+	return ret, all
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+var deepReadyTimeout = time.Duration(5) * time.Second
+
+// deepReadyTTL is how long one round of deep checks answers /readyz?deep=true
+var deepReadyTTL = time.Duration(30) * time.Second
+
+// deepReadiness runs the dependency checks at most once per ttl. Callers
+// arriving while a round runs wait for it rather than starting their own, so
+// a flood of probes costs the upstreams one round.
+type deepReadiness struct {
+	run     func(ctx context.Context) ([]CheckResult, bool)
+	ttl     time.Duration
+	at      time.Time
+	results []CheckResult
+	passed  bool
+	lock    sync.Mutex
+}
+
+func newDeepReadiness(checks []dependencyCheck, ttl time.Duration) *deepReadiness {
+	return &deepReadiness{
+		run: func(ctx context.Context) ([]CheckResult, bool) { return runChecks(ctx, checks) },
+		ttl: ttl,
+	}
+}
+
+func (d *deepReadiness) check() ([]CheckResult, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.results == nil || clk.Now().Sub(d.at) >= d.ttl {
+		// Not bound to the request so a caller hanging up doesn't cut short
+		// the round every other caller shares
+		ctx, cancel := context.WithTimeout(context.Background(), deepReadyTimeout)
+		defer cancel()
+		d.results, d.passed = d.run(ctx)
+		d.at = clk.Now()
+	}
+	return d.results, d.passed
+}
+
+// handleReadyz reports watcher readiness. With ?deep=true it also runs the
+// same dependency checks as the self-test command against every upstream,
+// which takes the admin token since it makes labwatch call out to all of
+// them.
+func handleReadyz(cfg LabwatchConfig) http.HandlerFunc {
+	deep := newDeepReadiness(dependencyChecks(cfg), deepReadyTTL)
+	handleDeep := requireAdmin(cfg.AdminToken, func(w http.ResponseWriter, r *http.Request) {
+		states, ready := watchersHealth.snapshot()
+		results, passed := deep.check()
+		if !ready || !passed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		b, _ := encodeJSON(map[string]any{"watchers": states, "dependencies": results})
+		w.Write(b)
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("deep") == "true" {
+			handleDeep(w, r)
+			return
+		}
+		states, ready := watchersHealth.snapshot()
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		b, _ := encodeJSON(states)
+		w.Write(b)
+	}
+}
+
+var grpcHealthServer *grpc.Server
+var grpcHealthStatus *health.Server
+
+// startGRPCHealth serves grpc.health.v1.Health. The empty service name
+// reflects process liveness and each watcher name reflects its readiness.
+func startGRPCHealth(addr string, log *slog.Logger) error {
+	log = log.With("operation", "grpcHealth")
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	serveGRPCHealth(lis, log)
+	log.Info("serving grpc health", "address", addr)
+	return nil
+}
+
+func serveGRPCHealth(lis net.Listener, log *slog.Logger) {
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	watchersHealth.subscribe(func(name string, ready bool) {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if ready {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		hs.SetServingStatus(name, status)
+	})
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, hs)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Error("grpc health server failed", "error", err.Error())
+		}
+	}()
+
+	grpcHealthServer = server
+	grpcHealthStatus = hs
+}
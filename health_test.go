@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestDeepReadyzRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		query  string
+		want   int
+	}{
+		{name: "shallow stays open", token: "admin-secret", query: "", want: http.StatusServiceUnavailable},
+		{name: "deep without a token configured", query: "?deep=true", want: http.StatusForbidden},
+		{name: "deep anonymous", token: "admin-secret", query: "?deep=true", want: http.StatusUnauthorized},
+		{name: "deep wrong token", token: "admin-secret", header: "Bearer guess", query: "?deep=true", want: http.StatusUnauthorized},
+		{name: "deep with the admin token", token: "admin-secret", header: "Bearer admin-secret", query: "?deep=true", want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.AdminToken = tt.token
+			h := handleReadyz(cfg)
+			r := httptest.NewRequest(http.MethodGet, "/readyz"+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			// The watchers never start here so an authorized probe reports
+			// not ready rather than OK
+			if w.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeepReadinessCached(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	saved := clk
+	clk = fake
+	t.Cleanup(func() { clk = saved })
+
+	var runs atomic.Int32
+	release := make(chan struct{})
+	d := &deepReadiness{ttl: 30 * time.Second, run: func(ctx context.Context) ([]CheckResult, bool) {
+		runs.Add(1)
+		<-release
+		return []CheckResult{{Name: "loki", OK: true}}, true
+	}}
+
+	// A burst of probes shares the one round in flight
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, passed := d.check(); !passed {
+				t.Error("cached round should pass")
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("burst ran the checks %d times, want 1", got)
+	}
+
+	fake.Advance(29 * time.Second)
+	d.check()
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("checks ran again within the ttl")
+	}
+
+	fake.Advance(time.Second)
+	d.check()
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("checks ran %d times after the ttl, want 2", got)
+	}
+}
+
+func TestGRPCHealth(t *testing.T) {
+	savedHealth, savedServer, savedStatus := watchersHealth, grpcHealthServer, grpcHealthStatus
+	watchersHealth = newWatcherHealth(WATCHER_TALOS, WATCHER_LOKI)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveGRPCHealth(lis, discardLog)
+	t.Cleanup(func() {
+		grpcHealthServer.Stop()
+		watchersHealth, grpcHealthServer, grpcHealthStatus = savedHealth, savedServer, savedStatus
+	})
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	readyz := handleReadyz(defaultConfig())
+	// agree checks every service answers want over gRPC and that /readyz
+	// says the same
+	agree := func(want map[string]healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		ready := true
+		for service, status := range want {
+			resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatalf("checking %q: %v", service, err)
+			}
+			if resp.Status != status {
+				t.Errorf("%q is %s, want %s", service, resp.Status, status)
+			}
+			ready = ready && status == healthpb.HealthCheckResponse_SERVING
+		}
+		w := httptest.NewRecorder()
+		readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if (w.Code == http.StatusOK) != ready {
+			t.Errorf("/readyz answered %d %s while gRPC says %v", w.Code, w.Body, want)
+		}
+	}
+
+	// Alive but waiting on the watchers
+	agree(map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":            healthpb.HealthCheckResponse_SERVING,
+		WATCHER_TALOS: healthpb.HealthCheckResponse_NOT_SERVING,
+		WATCHER_LOKI:  healthpb.HealthCheckResponse_NOT_SERVING,
+	})
+
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: WATCHER_LOKI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("watching loki started with %v, %v", resp, err)
+	}
+
+	watchersHealth.set(WATCHER_TALOS, true)
+	agree(map[string]healthpb.HealthCheckResponse_ServingStatus{
+		WATCHER_TALOS: healthpb.HealthCheckResponse_SERVING,
+		WATCHER_LOKI:  healthpb.HealthCheckResponse_NOT_SERVING,
+	})
+	watchersHealth.set(WATCHER_LOKI, true)
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("watching loki got %v, %v once it was ready", resp, err)
+	}
+	agree(map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":            healthpb.HealthCheckResponse_SERVING,
+		WATCHER_TALOS: healthpb.HealthCheckResponse_SERVING,
+		WATCHER_LOKI:  healthpb.HealthCheckResponse_SERVING,
+	})
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "mqtt"}); status.Code(err) != codes.NotFound {
+		t.Errorf("checking an unknown service got %v", err)
+	}
+
+	// Shutting down turns everything away, ready or not
+	grpcHealthStatus.Shutdown()
+	for _, service := range []string{"", WATCHER_TALOS} {
+		if resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service}); err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("%q answered %v, %v while shutting down", service, resp, err)
+		}
+	}
+}
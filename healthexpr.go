@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// healthExpression is a user supplied expression evaluated against the
+// LabStatus to decide the overall lab state, e.g.
+//
+//	!(Talos["worker1"].Ready == false && Talos["worker2"].Ready == false)
+//
+// The expression may return a bool (true is ok, false is critical) or one of
+// the strings ok|warn|critical.
+type healthExpression struct {
+	source  string
+	program *vm.Program
+}
+
+func compileHealthExpression(source string) (*healthExpression, error) {
+	if source == "" {
+		return nil, nil
+	}
+	program, err := expr.Compile(source, expr.Env(LabStatus{}))
+	if err != nil {
+		return nil, err
+	}
+	return &healthExpression{source: source, program: program}, nil
+}
+
+func (h *healthExpression) evaluate(status LabStatus) (HealthLevel, error) {
+	out, err := expr.Run(h.program, status)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := out.(type) {
+	case bool:
+		if v {
+			return HEALTH_LEVEL_OK, nil
+		}
+		return HEALTH_LEVEL_CRITICAL, nil
+	case string:
+		switch l := HealthLevel(v); l {
+		case HEALTH_LEVEL_OK, HEALTH_LEVEL_WARN, HEALTH_LEVEL_CRITICAL:
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("health expression returned %v: must be a bool or one of ok|warn|critical", out)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+type CorrelationConfig struct {
+	BufferSize   int                 `yaml:"buffer-size"`
+	BufferMaxAge config.Duration     `yaml:"buffer-max-age"`
+	HistorySize  int                 `yaml:"history-size"`
+	MaxEvents    int                 `yaml:"max-events"`
+	Window       config.Duration     `yaml:"window"`
+	MaxBytes     config.ByteSize     `yaml:"max-bytes"`
+	HostAliases  map[string][]string `yaml:"host-aliases"`
+}
+
+type Transition struct {
+	Time  time.Time `json:"time"`
+	Node  string    `json:"node"`
+	Check string    `json:"check"`
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Bad   bool      `json:"bad"`
+
+	SuppressedBy string          `json:"suppressed_by,omitempty"`
+	Injected     bool            `json:"injected,omitempty"`
+	Events       []BufferedEvent `json:"events,omitempty"`
+
+	// ReleasedBy names the failed dependency whose recovery left an
+	// already bad check no longer suppressed. From and To are then the same.
+	ReleasedBy string `json:"released_by,omitempty"`
+}
+
+type BufferedEvent struct {
+	Received time.Time `json:"received"`
+	loki.LogEvent
+}
+
+// eventBuffer is a fixed size ring of the most recently received events.
+// Events older than maxAge are ignored even when the ring isn't full, so it
+// holds whichever is smaller of size events or maxAge worth of them.
+type eventBuffer struct {
+	events []BufferedEvent
+	next   int
+	full   bool
+	maxAge time.Duration
+	lock   sync.Mutex
+}
+
+func newEventBuffer(size int, maxAge time.Duration) *eventBuffer {
+	return &eventBuffer{events: make([]BufferedEvent, size), maxAge: maxAge}
+}
+
+func (b *eventBuffer) add(e loki.LogEvent, now time.Time) {
+	if len(b.events) == 0 {
+		return
+	}
+	b.lock.Lock()
+	b.events[b.next] = BufferedEvent{Received: now, LogEvent: e}
+	b.next = (b.next + 1) % len(b.events)
+	if b.next == 0 {
+		b.full = true
+	}
+	b.lock.Unlock()
+}
+
+// collect returns up to max events, oldest first, received at or after since
+// (and within maxAge of now) for which keep returns true
+func (b *eventBuffer) collect(keep func(BufferedEvent) bool, since time.Time, now time.Time, max int) []BufferedEvent {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.maxAge > 0 && since.Before(now.Add(-b.maxAge)) {
+		since = now.Add(-b.maxAge)
+	}
+
+	ret := []BufferedEvent{}
+	count := b.next
+	if b.full {
+		count = len(b.events)
+	}
+	for i := 0; i < count && (max <= 0 || len(ret) < max); i++ {
+		// Walk backwards from the newest event
+		e := b.events[(b.next-1-i+len(b.events))%len(b.events)]
+		if e.Received.Before(since) {
+			break
+		}
+		if keep(e) {
+			ret = append(ret, e)
+		}
+	}
+
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return ret
+}
+
+// matching returns up to max events, oldest first, received at or after since
+// whose host is one of hosts
+func (b *eventBuffer) matching(hosts map[string]bool, since time.Time, max int) []BufferedEvent {
+	return b.collect(func(e BufferedEvent) bool {
+		return hosts[normalizeHost(e.Node)]
+	}, since, clk.Now(), max)
+}
+
+// numbered returns the buffered events numbered from to to, both included,
+// which are every one of them that is still within the size and age limits
+func (b *eventBuffer) numbered(from uint64, to uint64, now time.Time) []BufferedEvent {
+	return b.collect(func(e BufferedEvent) bool {
+		return e.Seq >= from && e.Seq <= to
+	}, time.Time{}, now, 0)
+}
+
+// replay returns every buffered event still within the age limit
+func (b *eventBuffer) replay(now time.Time) []BufferedEvent {
+	return b.collect(func(BufferedEvent) bool { return true }, time.Time{}, now, 0)
+}
+
+func normalizeHost(h string) string {
+	return strings.ToLower(strings.TrimSpace(h))
+}
+
+// transitionTracker watches Talos node states for changes and keeps a bounded
+// history of them. Transitions into a bad state carry the events that led up
+// to them.
+type transitionTracker struct {
+	cfg        CorrelationConfig
+	buffer     *eventBuffer
+	previous   map[string]map[string]string
+	suppressed map[string]string
+	history    []Transition
+	lock       sync.Mutex
+}
+
+func newTransitionTracker(cfg CorrelationConfig, buffer *eventBuffer) *transitionTracker {
+	return &transitionTracker{
+		cfg:        cfg,
+		buffer:     buffer,
+		previous:   map[string]map[string]string{},
+		suppressed: map[string]string{},
+	}
+}
+
+// checks flattens a node status into named checks and their current state
+func checks(s talos.NodeStatus) map[string]string {
+	ret := map[string]string{
+		"connection": string(s.WatcherState),
+		"ready":      fmt.Sprintf("%t", s.Ready),
+	}
+	for name, svc := range s.Services {
+		ret["service/"+name] = string(svc.Healthy)
+	}
+	return ret
+}
+
+func isBad(check string, state string) bool {
+	switch {
+	case check == "connection":
+		return state == string(talos.CONNECTION_DISCONNECTED)
+	case check == "ready":
+		return state == "false"
+	case strings.HasPrefix(check, "service/"):
+		return state == string(talos.HEALTH_ERR)
+	}
+	return false
+}
+
+func (t *transitionTracker) observe(nodes map[string]talos.NodeStatus, now time.Time) []Transition {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	names := []string{}
+	for node := range nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+
+	found := []Transition{}
+	for _, node := range names {
+		current := checks(nodes[node])
+		prev, seen := t.previous[node]
+		t.previous[node] = current
+		wasSuppressedBy := t.suppressed[node]
+		t.suppressed[node] = nodes[node].SuppressedBy
+		if !seen {
+			continue
+		}
+		released := wasSuppressedBy != "" && nodes[node].SuppressedBy == ""
+
+		checkNames := []string{}
+		for check := range current {
+			checkNames = append(checkNames, check)
+		}
+		sort.Strings(checkNames)
+
+		for _, check := range checkNames {
+			if prev[check] == "" {
+				continue
+			}
+			if prev[check] == current[check] {
+				// A problem held back while a dependency was down is a new
+				// problem once it recovers
+				if released && isBad(check, current[check]) {
+					found = append(found, Transition{Time: now, Node: node, Check: check, From: prev[check], To: current[check], Bad: true, Injected: nodes[node].Injected, ReleasedBy: wasSuppressedBy, Events: t.relatedEvents(node, now)})
+				}
+				continue
+			}
+			tr := Transition{
+				Time:  now,
+				Node:  node,
+				Check: check,
+				From:  prev[check],
+				To:    current[check],
+				Bad:   isBad(check, current[check]),
+
+				SuppressedBy: nodes[node].SuppressedBy,
+				Injected:     nodes[node].Injected,
+			}
+			if tr.Bad && !isBad(check, prev[check]) {
+				tr.Events = t.relatedEvents(node, now)
+			}
+			found = append(found, tr)
+		}
+	}
+
+	t.history = append(t.history, found...)
+	if over := len(t.history) - t.cfg.HistorySize; over > 0 {
+		t.history = t.history[over:]
+	}
+	return found
+}
+
+// relatedEvents collects recent events from the node, honoring the configured
+// aliases and the cap on the attached payload size
+func (t *transitionTracker) relatedEvents(node string, now time.Time) []BufferedEvent {
+	hosts := map[string]bool{normalizeHost(node): true}
+	for _, alias := range t.cfg.HostAliases[node] {
+		hosts[normalizeHost(alias)] = true
+	}
+
+	events := t.buffer.matching(hosts, now.Add(-time.Duration(t.cfg.Window)), t.cfg.MaxEvents)
+	for len(events) > 0 {
+		b, _ := json.Marshal(events)
+		if t.cfg.MaxBytes <= 0 || len(b) <= int(t.cfg.MaxBytes) {
+			break
+		}
+		// Drop the oldest events first
+		events = events[1:]
+	}
+	return events
+}
+
+func (t *transitionTracker) getHistory() []Transition {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := make([]Transition, len(t.history))
+	copy(ret, t.history)
+	return ret
+}
+
+// export copies the last seen checks and the history for saving
+func (t *transitionTracker) export() (map[string]map[string]string, []Transition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	checks := make(map[string]map[string]string, len(t.previous))
+	for node, c := range t.previous {
+		cpy := make(map[string]string, len(c))
+		for k, v := range c {
+			cpy[k] = v
+		}
+		checks[node] = cpy
+	}
+	history := make([]Transition, len(t.history))
+	copy(history, t.history)
+	return checks, history
+}
+
+// restore seeds the tracker from saved state so nodes still in the state
+// they were in before a restart aren't reported as transitioning. The marker
+// is appended to the history to show where the restart happened.
+func (t *transitionTracker) restore(checks map[string]map[string]string, history []Transition, marker Transition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for node, c := range checks {
+		t.previous[node] = c
+	}
+	t.history = append(append(history, t.history...), marker)
+	if over := len(t.history) - t.cfg.HistorySize; over > 0 {
+		t.history = t.history[over:]
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+const WATCHER_TALOS = "talos"
+const WATCHER_LOKI = "loki"
+
+// IdentityConfig maps canonical entity names to the raw names each watcher
+// knows them by, e.g. worker1: {talos: [worker1], loki: [worker1.lab.local]}
+type IdentityConfig map[string]map[string][]string
+
+// identityMap resolves the raw names published by watchers to their
+// canonical entity names. Unmapped names pass through unchanged.
+type identityMap struct {
+	byWatcher map[string]map[string]string
+}
+
+var identities atomic.Pointer[identityMap]
+
+func newIdentityMap(cfg IdentityConfig) (*identityMap, error) {
+	m := &identityMap{byWatcher: map[string]map[string]string{}}
+	for canonical, watchers := range cfg {
+		for watcher, aliases := range watchers {
+			if _, ok := m.byWatcher[watcher]; !ok {
+				m.byWatcher[watcher] = map[string]string{}
+			}
+			for _, raw := range aliases {
+				if existing, ok := m.byWatcher[watcher][raw]; ok && existing != canonical {
+					return nil, fmt.Errorf("%s name %s is mapped to both %s and %s", watcher, raw, existing, canonical)
+				}
+				m.byWatcher[watcher][raw] = canonical
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *identityMap) canonical(watcher string, raw string) string {
+	if m == nil {
+		return raw
+	}
+	if c, ok := m.byWatcher[watcher][raw]; ok {
+		return c
+	}
+	return raw
+}
+
+// applyTalos rekeys the node map by canonical name. When several raw nodes
+// collapse onto one canonical name, one already named that claims it, then
+// the first raw name in sort order, and the rest keep their raw names so
+// nothing is silently lost.
+func (m *identityMap) applyTalos(nodes map[string]talos.NodeStatus, log *slog.Logger) map[string]talos.NodeStatus {
+	raws := []string{}
+	for raw := range nodes {
+		raws = append(raws, raw)
+	}
+	// Nodes keeping their own names go first so a node mapped onto one of
+	// them can't take the name and leave it nowhere to go
+	sort.Slice(raws, func(i, j int) bool {
+		iSame, jSame := m.canonical(WATCHER_TALOS, raws[i]) == raws[i], m.canonical(WATCHER_TALOS, raws[j]) == raws[j]
+		if iSame != jSame {
+			return iSame
+		}
+		return raws[i] < raws[j]
+	})
+
+	owners := map[string]string{}
+	for _, raw := range raws {
+		name := m.canonical(WATCHER_TALOS, raw)
+		if _, taken := owners[name]; taken {
+			log.Warn("multiple talos nodes map to the same identity", "identity", name, "node", raw)
+			name = raw
+		}
+		// A node falling back to its own name takes it from any node mapped
+		// onto it, which falls back to its own in turn
+		for {
+			prev, taken := owners[name]
+			owners[name] = raw
+			if !taken {
+				break
+			}
+			log.Warn("multiple talos nodes map to the same identity", "identity", name, "node", prev)
+			raw, name = prev, prev
+		}
+	}
+
+	ret := map[string]talos.NodeStatus{}
+	for name, raw := range owners {
+		status := nodes[raw]
+		status.Node = name
+		status.SourceID = raw
+		ret[name] = status
+	}
+	return ret
+}
+
+func (m *identityMap) applyEvent(e loki.LogEvent) loki.LogEvent {
+	e.SourceID = e.Node
+	e.Node = m.canonical(WATCHER_LOKI, e.Node)
+	return e
+}
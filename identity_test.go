@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestNewIdentityMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   IdentityConfig
+		valid bool
+	}{
+		{"empty", IdentityConfig{}, true},
+		{"aliases per watcher", IdentityConfig{"worker1": {WATCHER_TALOS: {"worker1"}, WATCHER_LOKI: {"worker1.lab.local", "worker1-ipmi"}}}, true},
+		{"alias repeated", IdentityConfig{"worker1": {WATCHER_LOKI: {"worker1.lab.local", "worker1.lab.local"}}}, true},
+		{"same raw name in two watchers", IdentityConfig{"worker1": {WATCHER_TALOS: {"w1"}}, "plug1": {WATCHER_LOKI: {"w1"}}}, true},
+		{"raw name mapped twice", IdentityConfig{"worker1": {WATCHER_LOKI: {"w1"}}, "worker2": {WATCHER_LOKI: {"w1"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newIdentityMap(tt.cfg); (err == nil) != tt.valid {
+				t.Errorf("valid %t, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestApplyTalos(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   IdentityConfig
+		nodes []string
+		// want maps each name in the result to the raw name it came from
+		want map[string]string
+	}{
+		{
+			name:  "unmapped",
+			cfg:   IdentityConfig{},
+			nodes: []string{"cp1", "worker1"},
+			want:  map[string]string{"cp1": "cp1", "worker1": "worker1"},
+		},
+		{
+			name:  "renamed",
+			cfg:   IdentityConfig{"worker1": {WATCHER_TALOS: {"10.0.0.21"}}},
+			nodes: []string{"cp1", "10.0.0.21"},
+			want:  map[string]string{"cp1": "cp1", "worker1": "10.0.0.21"},
+		},
+		{
+			// The first in sort order claims the name, the other keeps its own
+			name:  "two raw names for one identity",
+			cfg:   IdentityConfig{"worker1": {WATCHER_TALOS: {"10.0.0.21", "worker1.lab.local"}}},
+			nodes: []string{"worker1.lab.local", "10.0.0.21"},
+			want:  map[string]string{"worker1": "10.0.0.21", "worker1.lab.local": "worker1.lab.local"},
+		},
+		{
+			// A node already named that keeps the name, however they sort
+			name:  "mapped onto another node's name",
+			cfg:   IdentityConfig{"worker1": {WATCHER_TALOS: {"10.0.0.21"}}},
+			nodes: []string{"10.0.0.21", "worker1"},
+			want:  map[string]string{"worker1": "worker1", "10.0.0.21": "10.0.0.21"},
+		},
+		{
+			// Falling back to its own name, a node takes it back from the node
+			// mapped onto it, which falls back to its own
+			name:  "fallen back onto a mapped name",
+			cfg:   IdentityConfig{"a": {WATCHER_TALOS: {"0"}}, "c": {WATCHER_TALOS: {"a"}}},
+			nodes: []string{"c", "0", "a"},
+			want:  map[string]string{"c": "c", "a": "a", "0": "0"},
+		},
+		{
+			name:  "mapped by another watcher",
+			cfg:   IdentityConfig{"worker1": {WATCHER_LOKI: {"10.0.0.21"}}},
+			nodes: []string{"10.0.0.21"},
+			want:  map[string]string{"10.0.0.21": "10.0.0.21"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newIdentityMap(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes := map[string]talos.NodeStatus{}
+			for _, n := range tt.nodes {
+				nodes[n] = talos.NodeStatus{Node: n, Stage: "stage of " + n}
+			}
+
+			got := map[string]string{}
+			for name, n := range m.applyTalos(nodes, discardLog) {
+				if n.Node != name || n.Stage != "stage of "+n.SourceID {
+					t.Errorf("%s holds %s named %s", name, n.SourceID, n.Node)
+				}
+				got[name] = n.SourceID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without a map everything passes through
+	got := (*identityMap)(nil).applyTalos(map[string]talos.NodeStatus{"cp1": {}}, discardLog)
+	if n, ok := got["cp1"]; !ok || n.Node != "cp1" || n.SourceID != "cp1" {
+		t.Errorf("nil map applied as %+v", got)
+	}
+}
+
+func TestApplyEvent(t *testing.T) {
+	m, err := newIdentityMap(IdentityConfig{"worker1": {WATCHER_LOKI: {"worker1.lab.local", "worker1-ipmi"}, WATCHER_TALOS: {"cp9"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string]string{
+		"worker1.lab.local": "worker1",
+		"worker1-ipmi":      "worker1",
+		"cp9":               "cp9",
+		"router":            "router",
+	} {
+		e := m.applyEvent(loki.LogEvent{Node: raw, Message: "link up"})
+		if e.Node != want || e.SourceID != raw || e.Message != "link up" {
+			t.Errorf("%s applied as %+v, want %s", raw, e, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+const INCIDENT_LINK_NODE = "node"
+const INCIDENT_LINK_DEPENDENCY = "dependency"
+const INCIDENT_LINK_TIME = "time"
+
+// IncidentConfig controls how transitions are grouped into incidents. An
+// incident stays open until everything in it has recovered for gap, and a
+// new problem joins an open incident when one of link-by connects them: the
+// same node, nodes related through depends-on, or simply time.
+type IncidentConfig struct {
+	Gap         config.Duration `yaml:"gap"`
+	LinkBy      []string        `yaml:"link-by"`
+	HistorySize int             `yaml:"history-size"`
+}
+
+func validateIncidents(cfg IncidentConfig) error {
+	if cfg.Gap <= 0 {
+		return fmt.Errorf("gap must be positive")
+	}
+	for _, l := range cfg.LinkBy {
+		if l != INCIDENT_LINK_NODE && l != INCIDENT_LINK_DEPENDENCY && l != INCIDENT_LINK_TIME {
+			return fmt.Errorf("invalid link-by %q, must be one of node|dependency|time", l)
+		}
+	}
+	return nil
+}
+
+// Incident is a cluster of related transitions. End is set once every check
+// involved has recovered and stayed that way for the gap.
+type Incident struct {
+	ID          int          `json:"id"`
+	Start       time.Time    `json:"start"`
+	End         *time.Time   `json:"end,omitempty"`
+	Entities    []string     `json:"entities"`
+	Checks      []string     `json:"checks"`
+	Peak        HealthLevel  `json:"peak"`
+	Transitions []Transition `json:"transitions,omitempty"`
+
+	bad  map[string]bool
+	last time.Time
+}
+
+func (i *Incident) involves(node string) bool {
+	return slices.Contains(i.Entities, node)
+}
+
+func (i *Incident) add(tr Transition, level HealthLevel) {
+	key := tr.Node + "/" + tr.Check
+	i.Transitions = append(i.Transitions, tr)
+	i.last = tr.Time
+	if !i.involves(tr.Node) {
+		i.Entities = append(i.Entities, tr.Node)
+		sort.Strings(i.Entities)
+	}
+	if !slices.Contains(i.Checks, key) {
+		i.Checks = append(i.Checks, key)
+		sort.Strings(i.Checks)
+	}
+	if tr.Bad {
+		i.bad[key] = true
+		i.Peak = worst(i.Peak, level)
+	} else {
+		delete(i.bad, key)
+	}
+}
+
+// incidentTracker groups transitions into incidents as they are observed
+type incidentTracker struct {
+	cfg       IncidentConfig
+	deps      DependencyConfig
+	summary   SummaryConfig
+	incidents []*Incident
+	nextID    int
+	lock      sync.Mutex
+}
+
+var incidents *incidentTracker
+
+func newIncidentTracker(cfg IncidentConfig, deps DependencyConfig, summary SummaryConfig) *incidentTracker {
+	return &incidentTracker{cfg: cfg, deps: deps, summary: summary, nextID: 1}
+}
+
+// severity is what the check going bad contributes to the lab summary
+func (t *incidentTracker) severity(tr Transition) HealthLevel {
+	switch {
+	case tr.Check == "connection":
+		return t.summary.Disconnected
+	case tr.Check == "ready":
+		return t.summary.NotReady
+	case strings.HasPrefix(tr.Check, "service/"):
+		if l, ok := t.summary.Services[strings.TrimPrefix(tr.Check, "service/")]; ok {
+			return l
+		}
+		return t.summary.ServiceUnhealthy
+	}
+	return HEALTH_LEVEL_WARN
+}
+
+func (t *incidentTracker) linked(i *Incident, node string) bool {
+	for _, l := range t.cfg.LinkBy {
+		switch l {
+		case INCIDENT_LINK_TIME:
+			return true
+		case INCIDENT_LINK_NODE:
+			if i.involves(node) {
+				return true
+			}
+		case INCIDENT_LINK_DEPENDENCY:
+			for _, e := range i.Entities {
+				if related(node, e, t.deps) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// observe files the transitions into incidents. A check going bad joins the
+// newest linked incident still within reach or starts a new one; a recovery
+// is recorded against the incident holding the failure.
+func (t *incidentTracker) observe(trs []Transition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, tr := range trs {
+		key := tr.Node + "/" + tr.Check
+		var target *Incident
+		for _, i := range slices.Backward(t.incidents) {
+			if tr.Bad && i.End == nil && t.linked(i, tr.Node) {
+				target = i
+				break
+			}
+			if !tr.Bad && i.bad[key] {
+				target = i
+				break
+			}
+		}
+		if target == nil && !tr.Bad {
+			continue
+		}
+		if target == nil {
+			target = &Incident{ID: t.nextID, Start: tr.Time, Peak: HEALTH_LEVEL_OK, bad: map[string]bool{}}
+			t.nextID++
+			t.incidents = append(t.incidents, target)
+			if over := len(t.incidents) - t.cfg.HistorySize; over > 0 {
+				t.incidents = t.incidents[over:]
+			}
+		}
+		target.add(tr, t.severity(tr))
+	}
+}
+
+// expire closes incidents that have been fully recovered for the gap and
+// reports whether any were closed
+func (t *incidentTracker) expire(now time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	closed := false
+	for _, i := range t.incidents {
+		if i.End == nil && len(i.bad) == 0 && now.Sub(i.last) > time.Duration(t.cfg.Gap) {
+			end := i.last
+			i.End = &end
+			closed = true
+		}
+	}
+	return closed
+}
+
+// list returns copies of the incidents overlapping from and to, oldest first.
+// Zero times leave that side unbounded.
+func (t *incidentTracker) list(from time.Time, to time.Time, openOnly bool) []Incident {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := []Incident{}
+	for _, i := range t.incidents {
+		if openOnly && i.End != nil {
+			continue
+		}
+		if !to.IsZero() && i.Start.After(to) {
+			continue
+		}
+		if !from.IsZero() && i.End != nil && i.End.Before(from) {
+			continue
+		}
+		cpy := *i
+		cpy.Entities = slices.Clone(i.Entities)
+		cpy.Checks = slices.Clone(i.Checks)
+		cpy.Transitions = slices.Clone(i.Transitions)
+		ret = append(ret, cpy)
+	}
+	return ret
+}
+
+// open returns the open incidents without their transitions, compact enough
+// to send with every status update
+func (t *incidentTracker) open() []Incident {
+	ret := t.list(time.Time{}, time.Time{}, true)
+	for i := range ret {
+		ret[i].Transitions = nil
+	}
+	return ret
+}
+
+// handleIncidents lists the incidents overlapping ?from and ?to (RFC3339),
+// only those still open with ?open=true
+func handleIncidents(w http.ResponseWriter, r *http.Request) {
+	var from, to time.Time
+	var err error
+	if s := r.URL.Query().Get("from"); s != "" {
+		if from, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	b, _ := encodeJSON(incidents.list(from, to, r.URL.Query().Get("open") == "true"))
+	w.Write(b)
+}
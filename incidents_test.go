@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+var incidentEpoch = time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+
+// recorded is a transition min minutes into a recorded sequence
+func recorded(min int, node string, check string, bad bool) Transition {
+	from, to := "bad", "ok"
+	if bad {
+		from, to = to, from
+	}
+	return Transition{Time: incidentEpoch.Add(time.Duration(min) * time.Minute), Node: node, Check: check, From: from, To: to, Bad: bad}
+}
+
+type wantIncident struct {
+	start    int
+	end      int // minutes, or -1 while open
+	entities []string
+	checks   []string
+	peak     HealthLevel
+}
+
+// replay feeds the sequence through the tracker the way the watch loop does,
+// expiring incidents as time passes, and finally expires them at the end
+func replay(tracker *incidentTracker, trs []Transition, end time.Time) {
+	for _, tr := range trs {
+		tracker.expire(tr.Time)
+		tracker.observe([]Transition{tr})
+	}
+	tracker.expire(end)
+}
+
+func TestIncidentGrouping(t *testing.T) {
+	defaults := defaultConfig()
+	tests := []struct {
+		name   string
+		linkBy []string
+		deps   DependencyConfig
+		trs    []Transition
+		want   []wantIncident
+	}{
+		{
+			name: "one node going down and coming back",
+			trs: []Transition{
+				recorded(0, "worker2", "ready", true),
+				recorded(1, "worker2", "connection", true),
+				recorded(5, "worker2", "connection", false),
+				recorded(6, "worker2", "ready", false),
+			},
+			want: []wantIncident{
+				{start: 0, end: 6, entities: []string{"worker2"}, checks: []string{"worker2/connection", "worker2/ready"}, peak: HEALTH_LEVEL_CRITICAL},
+			},
+		},
+		{
+			// The 02:13-02:40 incident: worker2 loses its NFS server, then
+			// etcd on a control plane node depending on it follows
+			name: "linked by dependency",
+			deps: DependencyConfig{"worker2": {"nfs"}, "cp1": {"nfs"}},
+			trs: []Transition{
+				recorded(13, "nfs", "connection", true),
+				recorded(14, "worker2", "ready", true),
+				recorded(16, "cp1", "service/etcd", true),
+				recorded(30, "nfs", "connection", false),
+				recorded(35, "cp1", "service/etcd", false),
+				recorded(40, "worker2", "ready", false),
+			},
+			want: []wantIncident{
+				{start: 13, end: 40, entities: []string{"cp1", "nfs", "worker2"}, checks: []string{"cp1/service/etcd", "nfs/connection", "worker2/ready"}, peak: HEALTH_LEVEL_CRITICAL},
+			},
+		},
+		{
+			name: "unrelated nodes kept apart",
+			trs: []Transition{
+				recorded(0, "worker1", "ready", true),
+				recorded(1, "worker2", "service/kubelet", true),
+				recorded(2, "worker1", "ready", false),
+				recorded(3, "worker2", "service/kubelet", false),
+			},
+			want: []wantIncident{
+				{start: 0, end: 2, entities: []string{"worker1"}, checks: []string{"worker1/ready"}, peak: HEALTH_LEVEL_WARN},
+				{start: 1, end: 3, entities: []string{"worker2"}, checks: []string{"worker2/service/kubelet"}, peak: HEALTH_LEVEL_WARN},
+			},
+		},
+		{
+			name:   "linked by time alone",
+			linkBy: []string{INCIDENT_LINK_TIME},
+			trs: []Transition{
+				recorded(0, "worker1", "ready", true),
+				recorded(1, "worker2", "connection", true),
+				recorded(2, "worker1", "ready", false),
+				recorded(3, "worker2", "connection", false),
+			},
+			want: []wantIncident{
+				{start: 0, end: 3, entities: []string{"worker1", "worker2"}, checks: []string{"worker1/ready", "worker2/connection"}, peak: HEALTH_LEVEL_CRITICAL},
+			},
+		},
+		{
+			name:   "dependencies ignored when not linked by them",
+			linkBy: []string{INCIDENT_LINK_NODE},
+			deps:   DependencyConfig{"worker2": {"nfs"}},
+			trs: []Transition{
+				recorded(0, "nfs", "connection", true),
+				recorded(1, "worker2", "ready", true),
+				recorded(2, "nfs", "connection", false),
+				recorded(3, "worker2", "ready", false),
+			},
+			want: []wantIncident{
+				{start: 0, end: 2, entities: []string{"nfs"}, checks: []string{"nfs/connection"}, peak: HEALTH_LEVEL_CRITICAL},
+				{start: 1, end: 3, entities: []string{"worker2"}, checks: []string{"worker2/ready"}, peak: HEALTH_LEVEL_WARN},
+			},
+		},
+		{
+			name: "flapping within the gap stays one incident",
+			trs: []Transition{
+				recorded(0, "worker1", "ready", true),
+				recorded(1, "worker1", "ready", false),
+				recorded(9, "worker1", "ready", true),
+				recorded(10, "worker1", "ready", false),
+			},
+			want: []wantIncident{
+				{start: 0, end: 10, entities: []string{"worker1"}, checks: []string{"worker1/ready"}, peak: HEALTH_LEVEL_WARN},
+			},
+		},
+		{
+			name: "recurring after the gap starts a new incident",
+			trs: []Transition{
+				recorded(0, "worker1", "ready", true),
+				recorded(1, "worker1", "ready", false),
+				recorded(12, "worker1", "ready", true),
+			},
+			want: []wantIncident{
+				{start: 0, end: 1, entities: []string{"worker1"}, checks: []string{"worker1/ready"}, peak: HEALTH_LEVEL_WARN},
+				{start: 12, end: -1, entities: []string{"worker1"}, checks: []string{"worker1/ready"}, peak: HEALTH_LEVEL_WARN},
+			},
+		},
+		{
+			name: "still failing keeps it open past the gap",
+			trs: []Transition{
+				recorded(0, "cp1", "connection", true),
+				recorded(1, "worker1", "ready", true),
+				recorded(2, "worker1", "ready", false),
+			},
+			want: []wantIncident{
+				{start: 0, end: -1, entities: []string{"cp1"}, checks: []string{"cp1/connection"}, peak: HEALTH_LEVEL_CRITICAL},
+				{start: 1, end: 2, entities: []string{"worker1"}, checks: []string{"worker1/ready"}, peak: HEALTH_LEVEL_WARN},
+			},
+		},
+		{
+			name: "recovery without a failure is ignored",
+			trs: []Transition{
+				recorded(0, "worker1", "ready", false),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults.Incidents
+			if tt.linkBy != nil {
+				cfg.LinkBy = tt.linkBy
+			}
+			tracker := newIncidentTracker(cfg, tt.deps, defaults.Summary)
+			last := incidentEpoch
+			if len(tt.trs) > 0 {
+				last = tt.trs[len(tt.trs)-1].Time
+			}
+			replay(tracker, tt.trs, last.Add(time.Hour))
+
+			got := tracker.list(time.Time{}, time.Time{}, false)
+			if len(got) != len(tt.want) {
+				t.Fatalf("grouped into %d incidents, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				inc := got[i]
+				if inc.ID != i+1 {
+					t.Errorf("incident %d has ID %d", i, inc.ID)
+				}
+				if !inc.Start.Equal(incidentEpoch.Add(time.Duration(want.start) * time.Minute)) {
+					t.Errorf("incident %d started %s", i, inc.Start)
+				}
+				switch {
+				case want.end < 0 && inc.End != nil:
+					t.Errorf("incident %d ended %s, want open", i, inc.End)
+				case want.end >= 0 && (inc.End == nil || !inc.End.Equal(incidentEpoch.Add(time.Duration(want.end)*time.Minute))):
+					t.Errorf("incident %d ended %v, want %d minutes in", i, inc.End, want.end)
+				}
+				if !slices.Equal(inc.Entities, want.entities) || !slices.Equal(inc.Checks, want.checks) {
+					t.Errorf("incident %d involves %v %v, want %v %v", i, inc.Entities, inc.Checks, want.entities, want.checks)
+				}
+				if inc.Peak != want.peak {
+					t.Errorf("incident %d peaked at %s, want %s", i, inc.Peak, want.peak)
+				}
+			}
+		})
+	}
+}
+
+func TestIncidentHistorySize(t *testing.T) {
+	cfg := IncidentConfig{Gap: config.Duration(time.Minute), LinkBy: []string{INCIDENT_LINK_NODE}, HistorySize: 2}
+	tracker := newIncidentTracker(cfg, nil, defaultConfig().Summary)
+	replay(tracker, []Transition{
+		recorded(0, "worker1", "ready", true),
+		recorded(1, "worker2", "ready", true),
+		recorded(2, "worker3", "ready", true),
+	}, incidentEpoch.Add(3*time.Minute))
+	got := tracker.list(time.Time{}, time.Time{}, false)
+	if len(got) != 2 || got[0].ID != 2 || got[1].ID != 3 {
+		t.Errorf("kept %+v, want the two newest", got)
+	}
+}
+
+func TestIncidentList(t *testing.T) {
+	prev := incidents
+	t.Cleanup(func() { incidents = prev })
+	incidents = newIncidentTracker(defaultConfig().Incidents, nil, defaultConfig().Summary)
+	replay(incidents, []Transition{
+		recorded(0, "worker1", "ready", true),
+		recorded(5, "worker1", "ready", false),
+		recorded(30, "worker2", "ready", true),
+	}, incidentEpoch.Add(time.Hour))
+
+	if open := incidents.open(); len(open) != 1 || open[0].ID != 2 || open[0].Transitions != nil {
+		t.Errorf("open incidents %+v, want the second without transitions", open)
+	}
+
+	tests := []struct {
+		query string
+		code  int
+		ids   []int
+	}{
+		{query: "", code: http.StatusOK, ids: []int{1, 2}},
+		{query: "?open=true", code: http.StatusOK, ids: []int{2}},
+		// The first ended five minutes in
+		{query: "?from=2026-03-01T02:10:00Z", code: http.StatusOK, ids: []int{2}},
+		{query: "?to=2026-03-01T02:10:00Z", code: http.StatusOK, ids: []int{1}},
+		{query: "?from=2026-03-01T02:03:00Z&to=2026-03-01T02:40:00Z", code: http.StatusOK, ids: []int{1, 2}},
+		{query: "?from=yesterday", code: http.StatusBadRequest},
+		{query: "?to=tomorrow", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleIncidents(rec, httptest.NewRequest(http.MethodGet, "/incidents"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q returned %d", tt.query, rec.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		got := []Incident{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, i := range got {
+			ids = append(ids, i.ID)
+		}
+		if !slices.Equal(ids, tt.ids) {
+			t.Errorf("%q listed %v, want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// InjectRequest carries a synthetic event and/or partial node statuses. Each
+// node fragment is laid over the node's real status, so {"Ready": false} is
+// enough to mark a node down. Events are one-shot; node overrides revert once
+// the TTL passes.
+type InjectRequest struct {
+	Event *loki.LogEvent             `json:"event,omitempty"`
+	Talos map[string]json.RawMessage `json:"talos,omitempty"`
+	TTL   string                     `json:"ttl,omitempty"`
+}
+
+type injectedOverride struct {
+	fragment json.RawMessage
+	until    time.Time
+}
+
+// injector holds the live node overrides. The watch loop applies them on top
+// of every talos update and is nudged through injectionChan when they change.
+type injector struct {
+	overrides map[string]injectedOverride
+	lock      sync.Mutex
+}
+
+var injections = &injector{overrides: map[string]injectedOverride{}}
+
+// injectionChan hands injected events to the watch loop and tells it to
+// re-apply node overrides
+var injectionChan = make(chan *loki.LogEvent)
+
+func (i *injector) set(node string, fragment json.RawMessage, until time.Time) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.overrides[node] = injectedOverride{fragment: fragment, until: until}
+}
+
+// expire drops overrides past their TTL and returns the nodes that reverted
+func (i *injector) expire(now time.Time) []string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	expired := []string{}
+	for node, o := range i.overrides {
+		if now.After(o.until) {
+			delete(i.overrides, node)
+			expired = append(expired, node)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+func (i *injector) active() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return len(i.overrides) > 0
+}
+
+// apply returns a copy of nodes with every override laid over the real
+// status and tagged as injected
+func (i *injector) apply(nodes map[string]talos.NodeStatus) map[string]talos.NodeStatus {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if len(i.overrides) == 0 {
+		return nodes
+	}
+
+	ret := make(map[string]talos.NodeStatus, len(nodes))
+	for name, n := range nodes {
+		ret[name] = n
+	}
+	for name, o := range i.overrides {
+		n := talos.NodeStatus{Node: name}
+		if base, ok := nodes[name]; ok {
+			// Round trip so the fragment can't write into the real status maps
+			b, _ := json.Marshal(base)
+			json.Unmarshal(b, &n)
+			n.Error = base.Error
+		}
+		json.Unmarshal(o.fragment, &n)
+		n.Injected = true
+		ret[name] = n
+	}
+	return ret
+}
+
+func handleInject(defaultTTL time.Duration, log *slog.Logger) http.HandlerFunc {
+	log = log.With("operation", "inject")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+
+		req := InjectRequest{}
+		err := json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Event == nil && len(req.Talos) == 0 {
+			http.Error(w, "nothing to inject: provide an event and/or talos node fragments", http.StatusBadRequest)
+			return
+		}
+
+		ttl := defaultTTL
+		if req.TTL != "" {
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		nodes := []string{}
+		for node, fragment := range req.Talos {
+			if err = json.Unmarshal(fragment, &talos.NodeStatus{}); err != nil {
+				http.Error(w, "invalid fragment for "+node+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+
+		log.Warn("debug injection", "remote", r.RemoteAddr, "nodes", nodes, "event", req.Event != nil, "ttl", ttl.String(), "body", string(body))
+
+		until := clk.Now().Add(ttl)
+		for _, node := range nodes {
+			injections.set(node, req.Talos[node], until)
+		}
+		if req.Event != nil {
+			req.Event.Injected = true
+			if req.Event.Timestamp.IsZero() {
+				req.Event.Timestamp = clk.Now()
+			}
+		}
+		injectionChan <- req.Event
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
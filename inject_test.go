@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// useTestInjections starts the test with no overrides
+func useTestInjections(t *testing.T) {
+	saved := injections
+	injections = &injector{overrides: map[string]injectedOverride{}}
+	t.Cleanup(func() { injections = saved })
+}
+
+// applyInjected is the part of the watch loop's applyNodes injections go
+// through
+func applyInjected(nodes map[string]talos.NodeStatus) map[string]talos.NodeStatus {
+	t := injections.apply(nodes)
+	for _, tr := range transitions.observe(t, clk.Now()) {
+		announceTransition(tr, discardLog)
+	}
+	return t
+}
+
+// inject POSTs body to the handler and stands in for the watch loop's side
+// of injectionChan, handing what arrives to loop before the handler answers
+func inject(body string, loop func(e *loki.LogEvent)) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	answered := make(chan struct{})
+	go func() {
+		handleInject(time.Minute, discardLog)(w, httptest.NewRequest("POST", "/debug/inject", strings.NewReader(body)))
+		close(answered)
+	}()
+	select {
+	case e := <-injectionChan:
+		loop(e)
+		<-answered
+	case <-answered:
+	}
+	return w
+}
+
+func TestInjectNodeDown(t *testing.T) {
+	receiver := &webhookReceiver{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	cfg := defaultConfig()
+	cfg.NotifyWebhook = srv.URL
+	cfg.NotifyMaxAge = 0
+	cfg.LogAlerts = []LogAlertConfig{{Name: "oom", Pattern: "out of memory", Severity: HEALTH_LEVEL_CRITICAL}}
+	f := useTestGlobals(t, cfg)
+	alerter, err := newLogAlerter(cfg.LogAlerts, discardLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestInjections(t)
+	nodes := map[string]talos.NodeStatus{"worker1": worker("v1.9.0")}
+	applyInjected(nodes)
+	loop := func(e *loki.LogEvent) {
+		if e != nil {
+			alerter.check(*e, clk.Now())
+		}
+		applyInjected(nodes)
+	}
+
+	// The node goes down, alerting and notifying as a real outage would but
+	// marked as injected
+	if w := inject(`{"talos":{"worker1":{"Ready":false}},"event":{"Node":"worker1","Service":"kernel","Message":"worker1 out of memory"},"ttl":"5m"}`, loop); w.Code != http.StatusAccepted {
+		t.Fatalf("injecting answered %d %q", w.Code, w.Body)
+	}
+	if got := injections.apply(nodes)["worker1"]; got.Ready || !got.Injected {
+		t.Errorf("worker1 injected as %+v", got)
+	}
+	notes := receiver.deliver(notifier)
+	titles := map[string]Notification{}
+	for _, n := range notes {
+		titles[n.Title] = n
+	}
+	if len(notes) != 2 {
+		t.Fatalf("notified %+v, want a log alert and a node problem", notes)
+	}
+	if n, ok := titles["log alert: oom"]; !ok || !n.Injected || n.Severity != string(HEALTH_LEVEL_CRITICAL) || n.Event == nil || !n.Event.Injected {
+		t.Errorf("log alert notified as %+v", n)
+	}
+	if n, ok := titles["node problem"]; !ok || !n.Injected || n.Alert != "node/worker1/ready" || n.Resolved {
+		t.Errorf("node problem notified as %+v", n)
+	}
+	if !nodes["worker1"].Ready {
+		t.Errorf("injecting changed the real status")
+	}
+
+	// Nothing reverts before the TTL
+	f.Advance(4 * time.Minute)
+	if expired := injections.expire(f.Now()); len(expired) != 0 {
+		t.Errorf("%v reverted before the TTL", expired)
+	}
+
+	// Once it passes the real status comes back and the problem is resolved
+	f.Advance(2 * time.Minute)
+	if expired := injections.expire(f.Now()); len(expired) != 1 || expired[0] != "worker1" {
+		t.Fatalf("%v reverted after the TTL, want worker1", expired)
+	}
+	if got := applyInjected(nodes)["worker1"]; !got.Ready || got.Injected {
+		t.Errorf("worker1 reverted to %+v", got)
+	}
+	notes = receiver.deliver(notifier)
+	if len(notes) != 1 || notes[0].Title != "node recovered" || !notes[0].Resolved || notes[0].Alert != "node/worker1/ready" {
+		t.Errorf("notified %+v on reverting, want worker1 recovered", notes)
+	}
+	if injections.active() {
+		t.Errorf("overrides still active after reverting")
+	}
+}
+
+func TestInjectRejected(t *testing.T) {
+	useTestGlobals(t, defaultConfig())
+	useTestInjections(t)
+
+	w := httptest.NewRecorder()
+	handleInject(time.Minute, discardLog)(w, httptest.NewRequest("GET", "/debug/inject", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET answered %d", w.Code)
+	}
+
+	for name, body := range map[string]string{
+		"not json":      `{"talos":`,
+		"nothing":       `{"ttl":"1m"}`,
+		"bad ttl":       `{"talos":{"worker1":{"Ready":false}},"ttl":"soon"}`,
+		"bad fragment":  `{"talos":{"worker1":{"Ready":"no"}}}`,
+		"not an object": `{"talos":{"worker1":false}}`,
+	} {
+		loop := func(*loki.LogEvent) { t.Errorf("%s reached the watch loop", name) }
+		if w := inject(body, loop); w.Code != http.StatusBadRequest {
+			t.Errorf("%s answered %d %q", name, w.Code, w.Body)
+		}
+	}
+	if injections.active() {
+		t.Errorf("a rejected injection left overrides behind")
+	}
+}
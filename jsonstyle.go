@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+type JSONStyle string
+
+// JSON_STYLE_LEGACY keeps the field names labwatch has always emitted
+const JSON_STYLE_LEGACY JSONStyle = "legacy"
+
+// JSON_STYLE_V2 uses snake_case field names throughout and leaves out
+// sections that have not been populated yet
+const JSON_STYLE_V2 JSONStyle = "v2"
+
+func (JSONStyle) JSONSchema() map[string]any {
+	return enumSchema(string(JSON_STYLE_LEGACY), string(JSON_STYLE_V2))
+}
+
+var jsonStyle = JSON_STYLE_LEGACY
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+var labStatusType = reflect.TypeOf(LabStatus{})
+
+// encodeJSON serializes payloads sent to clients in the configured style
+func encodeJSON(v any) ([]byte, error) {
+	if jsonStyle == JSON_STYLE_V2 {
+		return marshalV2(v)
+	}
+	return json.Marshal(v)
+}
+
+func marshalV2(v any) ([]byte, error) {
+	tree, _, err := toV2(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+// toV2 converts a value into plain maps and slices keyed by snake_case field
+// names. Map keys (node names, service names...) are left untouched. The
+// returned bool reports whether the value is absent and may be omitted.
+func toV2(v reflect.Value) (any, bool, error) {
+	if !v.IsValid() {
+		return nil, true, nil
+	}
+
+	if v.Type() == jsonNumberType {
+		if v.String() == "" {
+			return json.RawMessage("0"), false, nil
+		}
+		return json.RawMessage(v.String()), false, nil
+	}
+	if v.Type() == labStatusType {
+		return statusToV2(v.Interface().(LabStatus))
+	}
+	if v.Kind() != reflect.Interface && v.Kind() != reflect.Pointer && v.Type().Implements(marshalerType) {
+		b, err := v.Interface().(json.Marshaler).MarshalJSON()
+		return json.RawMessage(b), v.IsZero(), err
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, true, nil
+		}
+		if v.Type().Implements(errorType) {
+			return v.Interface().(error).Error(), false, nil
+		}
+		return toV2(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, true, nil
+		}
+		return toV2(v.Elem())
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil, true, nil
+		}
+		ret := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			val, _, err := toV2(iter.Value())
+			if err != nil {
+				return nil, false, err
+			}
+			ret[fmt.Sprint(iter.Key().Interface())] = val
+		}
+		return ret, false, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Len() == 0 {
+			return nil, true, nil
+		}
+		ret := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			val, _, err := toV2(v.Index(i))
+			if err != nil {
+				return nil, false, err
+			}
+			ret[i] = val
+		}
+		return ret, false, nil
+	case reflect.Struct:
+		ret := map[string]any{}
+		if err := structToV2(v, ret); err != nil {
+			return nil, false, err
+		}
+		return ret, v.IsZero(), nil
+	}
+	return v.Interface(), false, nil
+}
+
+// statusToV2 converts a status as any struct, leaving out what the client's
+// view omitted. Its MarshalJSON is for legacy JSON and isn't used.
+func statusToV2(s LabStatus) (any, bool, error) {
+	ret := map[string]any{}
+	if err := structToV2(reflect.ValueOf(s), ret); err != nil {
+		return nil, false, err
+	}
+	absent := reflect.ValueOf(s).IsZero()
+	for name, o := range s.omitted.byName(func(names []string) string { return names[len(names)-1] }) {
+		if o.subs == nil {
+			delete(ret, name)
+			continue
+		}
+		entries := []any{ret[name]}
+		switch v := ret[name].(type) {
+		case map[string]any:
+			if o.kind == reflect.Map {
+				entries = entries[:0]
+				for _, e := range v {
+					entries = append(entries, e)
+				}
+			}
+		case []any:
+			entries = v
+		}
+		for _, e := range entries {
+			if fields, ok := e.(map[string]any); ok {
+				for sub := range o.subs {
+					delete(fields, sub)
+				}
+			}
+		}
+	}
+	return ret, absent, nil
+}
+
+func structToV2(v reflect.Value, ret map[string]any) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs are flattened just as encoding/json does
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if err := structToV2(v.Field(i), ret); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		val, absent, err := toV2(v.Field(i))
+		if err != nil {
+			return err
+		}
+		if strings.Contains(opts, "omitempty") && (absent || v.Field(i).IsZero()) {
+			continue
+		}
+		if absent && f.Type.Kind() != reflect.Bool && !isNumeric(f.Type.Kind()) && f.Type.Kind() != reflect.String {
+			continue
+		}
+		ret[name] = val
+	}
+	return nil
+}
+
+func isNumeric(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+// snakeCase converts Go field names to snake_case, keeping acronyms together
+// (NumDNSQueries becomes num_dns_queries)
+func snakeCase(s string) string {
+	runes := []rune(s)
+	b := strings.Builder{}
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
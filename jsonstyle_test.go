@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/outputs"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, or rewrites the file with -update
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, rerun with -update if the change is intended\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// runningStatus is a lab with every watcher reporting, one node failing
+func runningStatus() LabStatus {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pods := 12
+	return LabStatus{
+		Labwatch: LabwatchStatus{State: LABWATCH_RUNNING},
+		Summary: Summary{
+			State:   HEALTH_LEVEL_WARN,
+			Counts:  map[HealthLevel]int{HEALTH_LEVEL_OK: 1, HEALTH_LEVEL_WARN: 1},
+			Reasons: []string{"worker1 not ready"},
+		},
+		Sections: map[string]SectionStatus{
+			"talos": {LastUpdated: &now},
+			"loki":  {LastUpdated: &now},
+		},
+		Outputs: map[string]outputs.Health{"mqtt": {Healthy: true}},
+		Talos: map[string]talos.NodeStatus{
+			"cp1": {
+				WatcherState: talos.CONNECTION_OK,
+				Node:         "cp1",
+				Services:     map[string]talos.ServiceStatus{"etcd": {State: "Running", Healthy: talos.HEALTH_OK, LastChange: now}},
+				Addresses:    []string{"10.0.0.11"},
+				Ready:        true,
+				PodCount:     &pods,
+				Version:      "v1.9.0",
+				Metrics:      map[string]float64{talos.METRIC_CPU_PERCENT: 25},
+				LastUpdated:  now,
+			},
+			"worker1": {
+				WatcherState:    talos.CONNECTION_DISCONNECTED,
+				Node:            "worker1",
+				Error:           errors.New("connection refused"),
+				UnmetConditions: []string{"Ready"},
+				SourceID:        "worker1.lab.local",
+				LastUpdated:     now,
+			},
+		},
+		TalosSummary: TalosSummary{Total: 2, Healthy: 1, ControlPlane: 1, ControlPlaneHealthy: 1, EtcdHealthy: true, Unreachable: []string{"worker1"}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_WARN},
+		Logs:         loki.LogStats{NumMessages: 40, NumErrorMessages: 2, NumInfoMessages: 38},
+		ConfigHash:   "3f2a",
+	}
+}
+
+func TestJSONStyleGolden(t *testing.T) {
+	defer func(style JSONStyle) { jsonStyle = style }(jsonStyle)
+	statuses := map[string]LabStatus{
+		// Before the watchers warm up, which v2 leaves mostly empty
+		"starting": {Labwatch: LabwatchStatus{State: LABWATCH_STARTING}},
+		"running":  runningStatus(),
+	}
+	for _, style := range []JSONStyle{JSON_STYLE_LEGACY, JSON_STYLE_V2} {
+		for name, s := range statuses {
+			jsonStyle = style
+			b, err := encodeJSON(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := bytes.Buffer{}
+			if err := json.Indent(&out, b, "", "  "); err != nil {
+				t.Fatal(err)
+			}
+			out.WriteByte('\n')
+			golden(t, "status-"+name+"-"+string(style)+".golden", out.Bytes())
+		}
+	}
+}
+
+// Large IDs logged as numbers reach clients intact in either style
+func TestJSONStyleNumbers(t *testing.T) {
+	defer func(style JSONStyle) { jsonStyle = style }(jsonStyle)
+	const id = "1234567890123456789"
+	e := loki.LogEvent{Node: "worker1", Fields: map[string]any{"request_id": json.Number(id), "ratio": json.Number("0.000001")}}
+	for _, style := range []JSONStyle{JSON_STYLE_LEGACY, JSON_STYLE_V2} {
+		jsonStyle = style
+		b, err := encodeJSON(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(b, []byte(`"request_id":`+id)) || !bytes.Contains(b, []byte(`"ratio":0.000001`)) {
+			t.Errorf("%s encoded %s", style, b)
+		}
+	}
+}
@@ -2,87 +2,301 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/DRuggeri/labwatch/browserhandler"
+	"github.com/DRuggeri/labwatch/clock"
+	"github.com/DRuggeri/labwatch/filewatch"
+	"github.com/DRuggeri/labwatch/outputs"
+	"github.com/DRuggeri/labwatch/poll"
+	"github.com/DRuggeri/labwatch/proxy"
+	"github.com/DRuggeri/labwatch/render"
+	"github.com/DRuggeri/labwatch/sdnotify"
+	"github.com/DRuggeri/labwatch/server"
 	"github.com/DRuggeri/labwatch/watchers/loki"
 	"github.com/DRuggeri/labwatch/watchers/talos"
 	"github.com/alecthomas/kingpin/v2"
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"gopkg.in/yaml.v3"
 
 	_ "net/http/pprof"
 )
 
 var (
-	Version  = "testing"
-	logLevel = kingpin.Flag("log-level", "Log Level (one of debug|info|warn|error)").Short('l').Envar("LABWATCH_LOGLEVEL").String()
-	config   = kingpin.Flag("config", "Configuration file path").Short('c').Envar("LABWATCH_CONFIG").ExistingFile()
+	Version       = "testing"
+	logLevel      = kingpin.Flag("log-level", "Log Level (one of debug|info|warn|error)").Short('l').Envar("LABWATCH_LOGLEVEL").String()
+	logLevelTalos = kingpin.Flag("log-level-talos", "Log Level for the Talos watcher, overriding --log-level").Envar("LABWATCH_LOGLEVEL_TALOS").Enum("debug", "info", "warn", "error")
+	logLevelLoki  = kingpin.Flag("log-level-loki", "Log Level for the log watcher (Loki or journald), overriding --log-level").Envar("LABWATCH_LOGLEVEL_LOKI").Enum("debug", "info", "warn", "error")
+	configFile    = kingpin.Flag("config", "Configuration file path").Short('c').Envar("LABWATCH_CONFIG").String()
+	configStrict  = kingpin.Flag("config-strict", "Exit if the config file is unreadable or invalid rather than using defaults for the broken sections").Default("true").Envar("LABWATCH_CONFIG_STRICT").Bool()
+	restartHint   = kingpin.Flag("restart-hint", "Estimated downtime announced to clients when shutting down (e.g. 30s)").Envar("LABWATCH_RESTART_HINT").Duration()
+
+	listenAddressOverride     = kingpin.Flag("listen-address", "Address the websocket and HTTP server listens on, overriding listen-address").Envar("LABWATCH_LISTEN_ADDRESS").String()
+	adminAddressOverride      = kingpin.Flag("admin-address", "Address the admin server listens on, overriding admin-address").Envar("LABWATCH_ADMIN_ADDRESS").String()
+	grpcHealthAddressOverride = kingpin.Flag("grpc-health-address", "Address the gRPC health server listens on, overriding grpc-health-address").Envar("LABWATCH_GRPC_HEALTH_ADDRESS").String()
+	dataDirOverride           = kingpin.Flag("data-dir", "Directory every file labwatch writes must be in, overriding data-dir").Envar("LABWATCH_DATA_DIR").String()
+
+	serveCmd        = kingpin.Command("serve", "Run the labwatch server").Default()
+	checkConfigCmd  = kingpin.Command("check-config", "Validate the configuration file and exit")
+	selfTestCmd     = kingpin.Command("self-test", "Check connectivity to every configured dependency and exit")
+	selfTestTimeout = selfTestCmd.Flag("timeout", "Bound on the whole self-test run").Default("30s").Duration()
+	selfTestFormat  = selfTestCmd.Flag("format", "Output format (one of json|table|wide)").Default(string(render.FORMAT_TABLE)).Enum(render.Formats...)
+
+	selfUpdateCmd       = kingpin.Command("self-update", "Replace this binary with the latest signed release")
+	selfUpdateURL       = selfUpdateCmd.Flag("url", "Releases API URL, overriding update-url").String()
+	selfUpdateCheckOnly = selfUpdateCmd.Flag("check-only", "Only report whether an update is available").Bool()
+	selfUpdateForce     = selfUpdateCmd.Flag("force", "Install the latest release even if it isn't newer").Bool()
+	selfUpdateRestart   = selfUpdateCmd.Flag("restart", "Restart after installing (one of none|systemd)").Default(RESTART_NONE).Enum(RESTART_NONE, RESTART_SYSTEMD)
+	selfUpdateUnit      = selfUpdateCmd.Flag("unit", "systemd unit restarted by --restart=systemd").Default("labwatch.service").String()
+
+	tailCmd     = kingpin.Command("tail", "Print events streamed from a running labwatch instance")
+	tailServer  = tailCmd.Flag("server", "Base URL of the labwatch instance").Default("http://localhost:8080").Envar("LABWATCH_SERVER").String()
+	tailToken   = tailCmd.Flag("token", "Bearer token sent with the request").Envar("LABWATCH_TOKEN").String()
+	tailReplay  = tailCmd.Flag("replay", "Print recent events before following").Bool()
+	tailHost    = tailCmd.Flag("host", "Only events from these hosts (comma separated)").String()
+	tailLevel   = tailCmd.Flag("level", "Only events at these levels (comma separated)").String()
+	tailService = tailCmd.Flag("service", "Only events from these services (comma separated)").String()
+	tailTrace   = tailCmd.Flag("trace", "Only events with these trace IDs (comma separated)").String()
+	tailText    = tailCmd.Flag("text", "Only events whose message contains this text").String()
+	tailLabels  = tailCmd.Flag("label", "Loki label matcher name=value, may be repeated").Strings()
+	tailFormat  = tailCmd.Flag("format", "Output format (one of json|table|wide)").Default(string(render.FORMAT_TABLE)).Enum(render.Formats...)
 )
 
-type LabwatchConfig struct {
-	LokiAddress      string `yaml:"loki-address"`
-	LokiQuery        string `yaml:"loki-query"`
-	TalosConfigFile  string `yaml:"talos-config"`
-	TalosClusterName string `yaml:"talos-cluster"`
-}
 type LabStatus struct {
-	Talos map[string]talos.NodeStatus `json:"talos"`
-	Logs  loki.LogStats               `json:"logs"`
+	Labwatch         LabwatchStatus              `json:"labwatch"`
+	Healthy          bool                        `json:"healthy"`
+	Summary          Summary                     `json:"summary"`
+	Maintenance      bool                        `json:"maintenance"`
+	MaintenanceUntil *time.Time                  `json:"maintenance_until,omitempty"`
+	Sections         map[string]SectionStatus    `json:"sections"`
+	Outputs          map[string]outputs.Health   `json:"outputs"`
+	Talos            map[string]talos.NodeStatus `json:"talos"`
+	TalosSummary     TalosSummary                `json:"talos_summary"`
+	TalosEndpoints   []talos.EndpointStatus      `json:"talos_endpoints,omitempty"`
+	Logs             loki.LogStats               `json:"logs"`
+	ConfigError      string                      `json:"config_error,omitempty"`
+	ConfigHash       string                      `json:"config_hash"`
+	FromCache        bool                        `json:"from_cache,omitempty"`
+	CachedAt         *time.Time                  `json:"cached_at,omitempty"`
+	Self             SelfStatus                  `json:"self"`
+	EtcdSnapshot     *EtcdSnapshotStatus         `json:"etcd_snapshot,omitempty"`
+	Incidents        []Incident                  `json:"incidents,omitempty"`
+	Churn            ChurnStatus                 `json:"churn"`
+
+	// omitted is what the client's view left out, which neither JSON style
+	// sends
+	omitted statusExcludes
+}
+
+type LabwatchStatus struct {
+	State             LabwatchState `json:"state"`
+	EstimatedDowntime string        `json:"estimated_downtime,omitempty"`
+}
+
+type LabwatchState string
+
+const LABWATCH_STARTING LabwatchState = "starting"
+const LABWATCH_RUNNING LabwatchState = "running"
+const LABWATCH_SHUTTING_DOWN LabwatchState = "shutting_down"
+
+// currentStatus is the last status built by the watch loop. The loop
+// replaces it while handlers, shutdown and the state saver read it, so it is
+// only touched through loadStatus and storeStatus. Sections and the other
+// maps are copied before they change, so the copy handed out is safe to
+// read without the lock.
+var currentStatus = LabStatus{Labwatch: LabwatchStatus{State: LABWATCH_STARTING}}
+var currentStatusLock = &sync.Mutex{}
+
+func loadStatus() LabStatus {
+	currentStatusLock.Lock()
+	defer currentStatusLock.Unlock()
+	return currentStatus
 }
 
-var currentStatus = LabStatus{}
-var statusClients = map[string]chan<- LabStatus{}
-var eventClients = map[string]chan<- loki.LogEvent{}
+func storeStatus(s LabStatus) {
+	currentStatusLock.Lock()
+	currentStatus = s
+	currentStatusLock.Unlock()
+}
+
+// layerNodes lays departed nodes, injections, silences, dependencies and
+// thresholds over a copy of the raw node statuses. The layers write into the
+// map they're given, and the raw map may be the one last published
+func layerNodes(raw map[string]talos.NodeStatus, departures *departedTracker, thresholds *thresholdEvaluator, deps DependencyConfig, now time.Time) map[string]talos.NodeStatus {
+	t := maps.Clone(raw)
+	t = departures.apply(t, now)
+	t = injections.apply(t)
+	silences.apply(t, now)
+	applyDependencies(t, deps)
+	thresholds.apply(t)
+	return t
+}
+
+var statusClients = map[string]*clientQueue[LabStatus]{}
+var eventClients = map[string]*clientQueue[loki.LogEvent]{}
 var lock = &sync.Mutex{}
+var recentEvents *eventBuffer
+
+// clk drives the watch loop and the watchers it starts. Tests swap in a
+// clock.Fake to step through debounce, staleness and expiry deterministically.
+var clk clock.Clock = clock.Real{}
+var transitions *transitionTracker
+
+// labwatchStateChan feeds lifecycle changes into the watch loop so they are
+// broadcast in order with regular status updates. The done channel is closed
+// once the broadcaster has handed every status client the update.
+var labwatchStateChan = make(chan labwatchStateChange)
+
+type labwatchStateChange struct {
+	status LabwatchStatus
+	done   chan struct{}
+}
+
+// stopping is closed when shutdown begins so websocket handlers can send a
+// close frame to their clients.
+var stopping = make(chan struct{})
+var clientsWG = &sync.WaitGroup{}
 
 func main() {
 	kingpin.Version(Version)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
+	if *configFile == "" {
+		*configFile = defaultConfigFile()
+	}
 
-	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
-	switch *logLevel {
-	case "error":
-		opts.Level = slog.LevelError
-	case "warn":
-		opts.Level = slog.LevelWarn
-	case "info":
-		opts.Level = slog.LevelInfo
-	case "debug":
-		opts.Level = slog.LevelDebug
+	opts := &slog.HandlerOptions{Level: parseLogLevel(*logLevel)}
+
+	if command == checkConfigCmd.FullCommand() {
+		// The schema is checked first since it reports every problem at once,
+		// unknown keys included, where loading stops at the first bad value
+		if d, err := os.ReadFile(*configFile); err == nil {
+			if err = validateConfigSchema(d); err != nil {
+				fmt.Fprintln(os.Stderr, "config does not match the schema: "+err.Error())
+				os.Exit(1)
+			}
+		}
+		cfg, err := loadConfig(*configFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		for _, warning := range checkConfigWarnings(cfg) {
+			fmt.Printf("warning: %s\n", warning)
+		}
+		fmt.Println("config OK")
+		return
+	}
+
+	if command == tailCmd.FullCommand() {
+		filters := url.Values{"label": *tailLabels}
+		for k, v := range map[string]string{"host": *tailHost, "level": *tailLevel, "service": *tailService, "trace": *tailTrace, "text": *tailText} {
+			if v != "" {
+				filters.Set(k, v)
+			}
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		log := slog.New(slog.NewTextHandler(os.Stderr, opts))
+		err := tailEvents(ctx, tailOptions{Server: *tailServer, Token: *tailToken, Replay: *tailReplay, Filters: filters, Format: render.Format(*tailFormat)}, os.Stdout, log)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
 	}
 
 	log := slog.New(slog.NewTextHandler(os.Stdout, opts)).With("operation", "main")
 	log.Info("starting up labwatch", "version", Version)
 
-	cfg := LabwatchConfig{
-		LokiAddress:      "boss.local:3100",
-		LokiQuery:        `{ host_name =~ ".+" } | json`,
-		TalosConfigFile:  "/home/boss/talos/talosconfig",
-		TalosClusterName: "koobs",
+	var cfg LabwatchConfig
+	var err error
+	if *configStrict {
+		if cfg, err = loadConfig(*configFile); err != nil {
+			log.Error("failed to load config", "error", err.Error())
+			os.Exit(1)
+		}
+	} else {
+		var errs []error
+		cfg, errs = loadConfigLenient(*configFile)
+		setConfigErrors(errs, log)
 	}
+	setReloadBase(cfg)
 
-	if *config != "" {
-		d, err := os.ReadFile(*config)
-		if err != nil {
-			log.Error("failed to read provided config file", "error", err.Error())
+	if err = proxy.Configure(cfg.ProxyURL, cfg.NoProxy); err != nil {
+		log.Error("failed to configure proxy", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if command == selfTestCmd.FullCommand() {
+		if !selfTest(cfg, *selfTestTimeout, render.Format(*selfTestFormat), os.Stdout) {
 			os.Exit(1)
 		}
-		err = yaml.Unmarshal(d, &cfg)
-		if err != nil {
-			log.Error("failed to parse provided config file", "error", err.Error())
+		return
+	}
+
+	if command == selfUpdateCmd.FullCommand() {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		opts := selfUpdateOptions{URL: cfg.UpdateURL, CheckOnly: *selfUpdateCheckOnly, Force: *selfUpdateForce, Restart: *selfUpdateRestart, Unit: *selfUpdateUnit}
+		if *selfUpdateURL != "" {
+			opts.URL = *selfUpdateURL
+		}
+		if err = runSelfUpdate(ctx, opts, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
+		return
 	}
 
-	err := startWatchers(cfg, log)
+	idMap, _ := newIdentityMap(cfg.Identities)
+	identities.Store(idMap)
+	if r, err := newEventRouter(cfg.EventStreams); err == nil {
+		eventStreams = r
+	}
+	notifier = newWebhookNotifier(notifyDestinations(cfg), cfg.NotifyQueueFile, time.Duration(cfg.NotifyMaxAge), cfg.NotifyMaxPerMinute, log)
+	go notifier.run(context.Background())
+	if err = startOutputs(cfg, log); err != nil {
+		log.Error("failed to start outputs", "error", err.Error())
+		os.Exit(1)
+	}
+	connections.max = int64(cfg.MaxConnections)
+	jsonStyle = cfg.JSONStyle
+	slowClientPolicy = cfg.SlowClientPolicy
+	slowClientDisconnectAfter = time.Duration(cfg.SlowClientDisconnectAfter)
+	clientQueueSize = cfg.ClientQueueSize
+	quotas = cfg.Quotas
+	requestLimits = cfg.RequestLimits
+
+	recentEvents = newEventBuffer(cfg.Correlation.BufferSize, time.Duration(cfg.Correlation.BufferMaxAge))
+	broadcasts = newBroadcaster(cfg.BroadcastQueueSize)
+	transitions = newTransitionTracker(cfg.Correlation, recentEvents)
+	incidents = newIncidentTracker(cfg.Incidents, cfg.DependsOn, cfg.Summary)
+	churn = newChurnTracker(cfg.Churn)
+	eventStats = newStatsHistory(time.Duration(cfg.StatsResolution))
+
+	var restored *RuntimeState
+	if cfg.StateFile != "" {
+		restored = loadRuntimeState(cfg.StateFile, time.Now(), log)
+		if time.Duration(cfg.StateInterval) > 0 {
+			go saveRuntimeStatePeriodically(cfg, log)
+		}
+	}
+
+	if time.Duration(cfg.UpdateCheckInterval) > 0 {
+		updates = newUpdateChecker()
+		go updates.run(context.Background(), cfg.UpdateURL, time.Duration(cfg.UpdateCheckInterval), log)
+	}
+
+	err = startWatchers(cfg, restored, log)
 	if err != nil {
 		log.Error("failed to start watchers", "error", err.Error())
 		os.Exit(1)
@@ -90,115 +304,540 @@ func main() {
 
 	log.Info("watchers initialized")
 
+	origins, _ := newOriginPolicy(cfg.AllowedOrigins)
+	auth := &streamAuth{origins: origins, token: cfg.StreamToken, tickets: newTicketIssuer(time.Duration(cfg.TicketTTL))}
 	u := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return true },
+		CheckOrigin:     origins.check,
 	}
 
+	http.HandleFunc("/auth/ticket", handleTicket(auth))
 	http.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.allow(w, r) {
+			return
+		}
+		encoding, err := statusEncoding(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		view, err := requestView(r, cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if r.Header.Get("Upgrade") == "" {
-			b, _ := json.Marshal(currentStatus)
-			w.Write(b)
+			if encoding == ENCODING_PROTOBUF {
+				w.Header().Set("Content-Type", "application/x-protobuf")
+				writeWithETag(w, r, encodeStatusProto(view.apply(loadStatus())))
+				return
+			}
+			b, _ := encodeJSON(view.apply(loadStatus()))
+			writeWithETag(w, r, b)
 			return
 		}
 
-		conn, err := u.Upgrade(w, r, nil)
+		delta, err := statusDeltaRequested(r, encoding)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if view, err = websocketView(r, view, encoding); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !connections.admit(w) {
+			return
+		}
+		defer connections.release()
+
+		client := registry.register(r, ENDPOINT_STATUS)
+		defer registry.unregister(client.ID)
+
+		conn, err := u.Upgrade(countingWriter{ResponseWriter: w, client: client}, r, nil)
 		if err != nil {
 			slog.Info("upgrade failed", "error", err.Error())
 			return
 		}
 
-		thisChan := make(chan LabStatus)
-		uuid := uuid.New().String()
+		defer conn.Close()
+		clientsWG.Add(1)
+		defer clientsWG.Done()
+
+		log.Info("status client connected", "client", client.ID, "remote", client.Remote)
+		defer log.Info("status client disconnected", "client", client.ID)
+
+		queue := addStatusClient(client.ID)
+		defer removeStatusClient(client.ID)
 
-		addStatusClient(uuid, thisChan)
-		defer removeStatusClient(uuid)
+		// Protobuf clients only ever receive statuses, so they get no envelope
+		framer := newClientFramer(r)
+		if encoding == ENCODING_PROTOBUF {
+			framer = nil
+		}
+		if err := client.track(sendHello(conn, framer, cfg)); err != nil {
+			return
+		}
 
-		data, _ := json.Marshal(currentStatus)
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			slog.Info("write failed", "error", err.Error())
+		stream := newStatusStream(delta, cfg)
+		if err := client.track(stream.write(conn, framer, encoding, view.apply(loadStatus()), time.Now())); err != nil {
+			log.Info("write failed", "client", client.ID, "error", err.Error())
 			return
 		}
 
+		lifetime := connectionLifetime(cfg)
 		for {
 			var status LabStatus
 			select {
 			case <-r.Context().Done():
 				return
-			case status = <-thisChan:
+			case <-stopping:
+				closeClient(conn)
+				return
+			case <-lifetime:
+				log.Info("disconnecting status client at max lifetime", "client", client.ID)
+				closeExpiredClient(conn)
+				return
+			case <-queue.kicked:
+				log.Warn("disconnecting slow status client", "client", client.ID)
+				closeSlowClient(conn)
+				return
+			case status = <-queue.ch:
 			}
-			data, _ := json.Marshal(status)
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			if err := client.track(stream.write(conn, framer, encoding, view.apply(status), time.Now())); err != nil {
 				return
 			}
 		}
 	})
 
 	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
-		conn, err := u.Upgrade(w, r, nil)
+		if !auth.allow(w, r) {
+			return
+		}
+		if !connections.admit(w) {
+			return
+		}
+		defer connections.release()
+
+		client := registry.register(r, ENDPOINT_EVENTS)
+		defer registry.unregister(client.ID)
+
+		streams, ndjson := parseStreamSelection(r.URL.Query().Get("stream"))
+		if err := eventStreams.checkStreams(streams); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !ndjson {
+			if err := checkV1Params(r, eventV2Params); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		sub, err := subscribeEvents(r, cfg, client.ID, log)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer sub.close()
+
+		log.Info("event client connected", "client", client.ID, "remote", client.Remote)
+		defer log.Info("event client disconnected", "client", client.ID)
+
+		if ndjson {
+			streamEventsNDJSON(countingWriter{ResponseWriter: w, client: client}, r, sub, client, cfg)
+			return
+		}
+
+		conn, err := u.Upgrade(countingWriter{ResponseWriter: w, client: client}, r, nil)
 		if err != nil {
 			slog.Info("upgrade failed", "error", err.Error())
 			return
 		}
 
-		thisChan := make(chan loki.LogEvent)
-		uuid := uuid.New().String()
+		defer conn.Close()
+		clientsWG.Add(1)
+		defer clientsWG.Done()
 
-		addEventClient(uuid, thisChan)
-		defer removeEventClient(uuid)
+		framer := newClientFramer(r)
+		if err := client.track(sendHello(conn, framer, cfg)); err != nil {
+			return
+		}
 
+		// Filtering, stream selection, replay and gap reports are v2
+		// features; v1 clients get every event and are never read from
+		filter := eventFilter{}
+		var messages <-chan clientMessage
+		var readFailed <-chan error
+		if framer != nil {
+			filter = parseEventFilter(r.URL.Query())
+			done := make(chan struct{})
+			defer close(done)
+			conn.SetReadLimit(requestLimits.bodyLimit(r))
+			messages, readFailed = readClientMessages(conn, done)
+		}
+
+		if r.URL.Query().Get("replay") != "" {
+			events, err := sub.replay(r.Context(), cfg)
+			if err != nil {
+				log.Warn("replay failed", "error", err.Error())
+			}
+			for _, e := range events {
+				if !filter.matches(e) {
+					continue
+				}
+				if err := client.track(writeMessage(conn, framer, server.TYPE_EVENT, e)); err != nil {
+					return
+				}
+			}
+		}
+
+		lifetime := connectionLifetime(cfg)
 		for {
 			select {
 			case <-r.Context().Done():
 				return
-			case e := <-thisChan:
-				data, _ := json.Marshal(e)
-				if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			case <-stopping:
+				closeClient(conn)
+				return
+			case <-lifetime:
+				log.Info("disconnecting event client at max lifetime", "client", client.ID)
+				closeExpiredClient(conn)
+				return
+			case <-sub.kicked:
+				log.Warn("disconnecting slow event client", "client", client.ID)
+				closeSlowClient(conn)
+				return
+			case err := <-readFailed:
+				log.Debug("event client read failed", "client", client.ID, "error", err.Error())
+				return
+			case msg := <-messages:
+				if msg.control != nil {
+					if err := backfill(conn, framer, client, filter, *msg.control); err != nil {
+						return
+					}
+					continue
+				}
+				if msg.err == nil {
+					msg.err = fmt.Errorf("subscriptions are only accepted on /stream")
+				}
+				if err := client.track(writeMessage(conn, framer, server.TYPE_ERROR, server.Error{Message: msg.err.Error()})); err != nil {
+					return
+				}
+			case e := <-sub.events:
+				if !filter.matches(e) {
+					continue
+				}
+				if err := writeEvent(conn, framer, client, e, log); err != nil {
 					return
 				}
 			}
 		}
 	})
 
+	http.HandleFunc("/stream", handleStream(cfg, auth, u, log))
+
+	http.HandleFunc("GET /schema", handleSchema)
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz(cfg))
+
+	// Everything showing lab state or log lines takes the stream token
+	http.HandleFunc("/events/top", auth.guard(handleTopTalkers(cfg.EventGroupBy)))
+	http.HandleFunc("/events/query", auth.guard(handleEventQuery(cfg)))
+	lokiLabels := handleLokiLabels(cfg, auth)
+	http.HandleFunc("GET /loki/labels", lokiLabels)
+	http.HandleFunc("GET /loki/labels/{name}/values", lokiLabels)
+
+	http.HandleFunc("/stats/history", auth.guard(handleStatsHistory))
+	http.HandleFunc("/incidents", auth.guard(handleIncidents))
+	http.HandleFunc("GET /watchers", handleWatchers(cfg))
+	http.HandleFunc("GET /version", handleVersion)
+	http.HandleFunc("/history", auth.guard(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := encodeJSON(transitions.getHistory())
+		w.Write(b)
+	}))
+
+	admin := newAdminMux(cfg)
+	admin.HandleFunc("/config", handleConfig(cfg))
+	admin.HandleFunc("/debug/runtime", handleRuntimeStats)
+	admin.HandleFunc("/debug/clients", handleDebugClients)
+	admin.HandleFunc("/debug/watchers", handleDebugWatchers)
+	admin.HandleFunc("/events/export", requireAdmin(cfg.AdminToken, handleEventExport(cfg, log)))
+	admin.HandleFunc("/admin/maintenance", requireAdmin(cfg.AdminToken, handleMaintenance))
+	admin.HandleFunc("POST /admin/reload", requireAdmin(cfg.AdminToken, handleReload(log)))
+	admin.HandleFunc("/admin/silence", requireAdmin(cfg.AdminToken, handleSilence))
+	admin.HandleFunc("DELETE /admin/silence/{id}", requireAdmin(cfg.AdminToken, handleSilenceDelete))
+	admin.HandleFunc("POST /admin/watcher/{name}/restart", requireAdmin(cfg.AdminToken, handleWatcherRestart))
+	admin.HandleFunc("DELETE /status/{watcher}/{entity}", requireAdmin(cfg.AdminToken, handleDepartedPurge))
+	admin.HandleFunc("POST /actions/talos/etcd-snapshot", requireAdmin(cfg.AdminToken, handleEtcdSnapshot(cfg.EtcdSnapshot, log)))
+	if cfg.DebugInjection {
+		log.Warn("debug injection enabled")
+		admin.HandleFunc("/debug/inject", requireAdmin(cfg.AdminToken, handleInject(time.Duration(cfg.DebugInjectionTTL), log)))
+	}
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "websockets.html")
+		http.ServeFile(w, r, cfg.IndexFile)
 	})
 
 	browserHandler, _ := browserhandler.NewBrowserHandler(log)
-	http.Handle("/navigate", browserHandler)
+	http.Handle("/navigate", limitBody(browserHandler))
+
+	if cfg.GRPCHealthAddress != "" {
+		if err := startGRPCHealth(cfg.GRPCHealthAddress, log); err != nil {
+			log.Error("failed to start grpc health server", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if warning := adminExposureWarning(cfg); warning != "" {
+		log.Warn("SECURITY: " + warning + "; set admin-token or bind admin-address to 127.0.0.1")
+	}
+	if err := startAdminServer(cfg, admin, log); err != nil {
+		log.Error("failed to start admin server", "error", err.Error())
+		os.Exit(1)
+	}
+
+	server := &http.Server{Addr: cfg.ListenAddress, Handler: publicHandler(cfg), MaxHeaderBytes: int(cfg.RequestLimits.MaxHeaderBytes)}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Error("http server failed", "error", err.Error())
+			os.Exit(1)
+		}
+	}()
+
+	if interval := sdnotify.WatchdogInterval(); interval > 0 {
+		go runWatchdog(interval, localURL(cfg.ListenAddress, "/healthz"), log)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	sig := <-sigChan
+	for sig == syscall.SIGHUP {
+		reload(log)
+		sig = <-sigChan
+	}
 
-	err = http.ListenAndServe(":8080", nil)
-	log.With("operation", "main", "error", err.Error()).Info("shutting down")
+	log.Info("shutting down", "signal", sig.String())
+	shutdown(cfg, server, sig.String(), log)
 }
 
-func startWatchers(cfg LabwatchConfig, log *slog.Logger) error {
+func startWatchers(cfg LabwatchConfig, restored *RuntimeState, log *slog.Logger) error {
 	log = log.With("operation", "startWatchers")
-	status := LabStatus{}
-
-	tWatcher, err := talos.NewTalosWatcher(context.Background(), cfg.TalosConfigFile, cfg.TalosClusterName, log)
+	status := cachedStatus(restored, log)
+	storeStatus(status)
+	// announced is the last summary state notified about. Resuming it from
+	// saved state keeps an unchanged lab from being re-announced.
+	var announced HealthLevel
+	if restored != nil {
+		announced = restored.Summary
+	}
+	startTime := clk.Now()
+	warmupDeadline := startTime.Add(time.Duration(cfg.WarmupTimeout))
+	warmed := map[string]bool{}
+	healthExpr, err := compileHealthExpression(cfg.HealthExpression)
 	if err != nil {
 		return err
 	}
+
+	// Each watcher runs under its own context so it can be restarted alone
 	tInfo := make(chan map[string]talos.NodeStatus)
-	go tWatcher.Watch(context.Background(), tInfo)
+	// The stop functions cancel a watcher and return a channel closed once
+	// its Watch has returned
+	stopped := make(chan struct{})
+	close(stopped)
+	stopTalos := func() <-chan struct{} { return stopped }
+	startTalos := func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		w, err := talos.NewTalosWatcher(ctx, cfg.TalosConfigFile, cfg.TalosContext, cfg.TalosClusterName, componentLogger(log, *logLevelTalos))
+		if err != nil {
+			cancel()
+			return err
+		}
+		w.SetMaxSilence(time.Duration(cfg.TalosMaxSilence))
+		w.SetKubernetesNodes(cfg.TalosKubernetesNodes)
+		w.SetPollConfig(poll.Config{
+			Min:    time.Duration(cfg.TalosPollMin),
+			Max:    time.Duration(cfg.TalosPollMax),
+			Steady: cfg.TalosPollSteady,
+		})
+		w.SetClock(clk)
+		stopTalos()
+		done := make(chan struct{})
+		stopTalos = func() <-chan struct{} { cancel(); return done }
+		currentTalos.Store(w)
+		go func() {
+			w.Watch(ctx, tInfo)
+			close(done)
+		}()
+		return nil
+	}
+	if err = startTalos(); err != nil {
+		return err
+	}
+	tWatcher := currentTalos.Load()
 
-	lWatcher, err := loki.NewLokiWatcher(context.Background(), cfg.LokiAddress, cfg.LokiQuery, log)
+	credentialChanges := make(chan credentialChange)
+	go filewatch.Watch(context.Background(), cfg.TalosConfigFile, time.Duration(cfg.FileWatchInterval), func() {
+		credentialChanges <- credentialChange{section: SECTION_TALOS, file: cfg.TalosConfigFile, err: currentTalos.Load().ReloadConfig()}
+	}, log)
+
+	lokiQuery := cfg.LokiQuery
+	queryTmpl, err := compileLokiQuery(cfg.LokiQuery)
 	if err != nil {
 		return err
 	}
+	var queryTracker *lokiQueryTracker
+	if queryTmpl != nil && cfg.LogSource == LOG_SOURCE_LOKI {
+		nodes := []string{}
+		for _, n := range tWatcher.Nodes() {
+			nodes = append(nodes, identities.Load().canonical(WATCHER_TALOS, n))
+		}
+		if queryTracker, lokiQuery, err = newLokiQueryTracker(queryTmpl, cfg.TalosClusterName, time.Duration(cfg.LokiQueryDebounce), nodes); err != nil {
+			return err
+		}
+		log.Debug("rendered loki query", "query", lokiQuery)
+	}
+	effectiveLokiQuery.Store(lokiQuery)
+
 	events := make(chan loki.LogEvent)
 	stats := make(chan loki.LogStats)
-	go lWatcher.Watch(context.Background(), events, stats)
+	var lWatcher *loki.LokiWatcher
+	stopLoki := func() <-chan struct{} { return stopped }
+	startLoki := func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		w, err := newLogWatcher(ctx, cfg, effectiveLokiQuery.Load().(string), componentLogger(log, *logLevelLoki))
+		if err != nil {
+			cancel()
+			return err
+		}
+		stopLoki()
+		done := make(chan struct{})
+		stopLoki = func() <-chan struct{} { cancel(); return done }
+		lWatcher, _ = w.(*loki.LokiWatcher)
+		go func() {
+			w.Watch(ctx, events, stats)
+			close(done)
+		}()
+		return nil
+	}
+	if err = startLoki(); err != nil {
+		return err
+	}
+
+	selfInfo := make(chan SelfStatus)
+	go watchSelf(context.Background(), cfg, selfInfo)
+	etcdSnapshots := make(chan EtcdSnapshotStatus)
+	if cfg.EtcdSnapshot.Directory != "" {
+		go watchEtcdSnapshots(context.Background(), cfg.EtcdSnapshot, etcdSnapshots, log)
+	}
 
 	log = log.With("operation", "watchloop")
+	staleTicker := clk.NewTicker(stalenessCheckInterval)
+	limits := newSelfLimits(cfg.Self, log)
+	alerter, err := newLogAlerter(cfg.LogAlerts, log)
+	if err != nil {
+		return err
+	}
+	thresholds, err := newThresholdEvaluator(cfg.NodeThresholds, log)
+	if err != nil {
+		return err
+	}
+	enricher, err := newNetworkEnricher(cfg.Enrichment, log)
+	if err != nil {
+		return err
+	}
+	var deduper *eventDeduper
+	if cfg.EventDedup {
+		deduper = newEventDeduper(cfg.EventGroupBy)
+	}
+	var aggregator *eventAggregator
+	if cfg.EventAggregate {
+		aggregator = newEventAggregator(time.Duration(cfg.EventAggregateWindow))
+	}
+	// emit runs deduplicated events through cross host aggregation before
+	// they are broadcast
+	emit := func(e loki.LogEvent) {
+		if aggregator == nil {
+			broadcastEvent(e, log)
+			return
+		}
+		for _, a := range aggregator.filter(e, clk.Now()) {
+			broadcastEvent(a, log)
+		}
+	}
+	// rawTalos keeps the last real node statuses so injected overrides can be
+	// laid over and reverted without waiting for the next talos update
+	var rawTalos map[string]talos.NodeStatus
+	var contexts nodeContexts
+	departures := newDepartedTracker(time.Duration(cfg.DepartedGrace), log)
+	applyNodes := func(t map[string]talos.NodeStatus) {
+		t = layerNodes(t, departures, thresholds, cfg.DependsOn, clk.Now())
+		announceMaintenanceChanges(status.Talos, t, log)
+		if cfg.TalosStageEvents {
+			announceStageChanges(status.Talos, t, log)
+		}
+		status.Talos = t
+		if cfg.EventNodeContext {
+			contexts = newNodeContexts(t)
+		}
+		if queryTracker != nil {
+			nodes := []string{}
+			for n := range t {
+				if !t[n].Injected {
+					nodes = append(nodes, n)
+				}
+			}
+			queryTracker.observe(nodes, clk.Now())
+		}
+		observed := t
+		if status.Labwatch.State == LABWATCH_STARTING {
+			// Node watchers report disconnected until they first connect, which
+			// isn't a transition worth comparing against restored state
+			observed = map[string]talos.NodeStatus{}
+			for name, n := range t {
+				if n.WatcherState != talos.CONNECTION_DISCONNECTED {
+					observed[name] = n
+				}
+			}
+		}
+		found := transitions.observe(observed, clk.Now())
+		for _, tr := range found {
+			announceTransition(tr, log)
+		}
+		incidents.observe(found)
+		for _, c := range churn.observe(found, clk.Now()) {
+			announceChurn(c, time.Duration(cfg.Churn.Window), log)
+		}
+	}
 	go func() {
 		for {
+			beatLoop(clk.Now())
 			broadcastStatusUpdate := false
+			var stateChange *labwatchStateChange
 			select {
+			case c := <-labwatchStateChan:
+				status.Labwatch = c.status
+				stateChange = &c
+				broadcastStatusUpdate = true
+			case <-staleTicker.C():
+				if aggregator != nil {
+					for _, a := range aggregator.flush(clk.Now()) {
+						broadcastEvent(a, log)
+					}
+				}
+				if updateStaleness(&status, cfg.Staleness, startTime, clk.Now()) {
+					log.Info("section staleness changed", "sections", status.Sections)
+					broadcastStatusUpdate = true
+				}
 			case t, ok := <-tInfo:
 				if ok {
-					status.Talos = t
+					rawTalos = identities.Load().applyTalos(t, log)
+					applyNodes(rawTalos)
+					markUpdated(&status, SECTION_TALOS, clk.Now())
+					warmed["talos"] = true
 					broadcastStatusUpdate = true
 				} else {
 					log.Error("error encountered reading talos states")
@@ -206,30 +845,174 @@ func startWatchers(cfg LabwatchConfig, log *slog.Logger) error {
 			case s, ok := <-stats:
 				if ok {
 					status.Logs = s
+					markUpdated(&status, SECTION_LOGS, clk.Now())
+					warmed["logs"] = true
 					broadcastStatusUpdate = true
 				} else {
 					log.Error("error encountered reading log stats")
 				}
 			case e, ok := <-events:
 				if ok {
-					log.Debug("broadcasting event", "clients", len(eventClients))
-					for _, ch := range eventClients {
-						ch <- e
+					e = enricher.enrich(contexts.enrich(identities.Load().applyEvent(e)))
+					alerter.check(e, clk.Now())
+					eventStats.record(e, clk.Now())
+					if deduper == nil {
+						emit(e)
+					} else {
+						for _, d := range deduper.filter(e) {
+							emit(d)
+						}
 					}
 				} else {
 					log.Error("error encountered reading ")
 				}
+			case req := <-watcherRestarts:
+				log.Info("restarting watcher", "watcher", req.name)
+				section, start := SECTION_TALOS, startTalos
+				if req.name == WATCHER_LOKI {
+					section, start = SECTION_LOGS, startLoki
+				}
+				err := start()
+				if err != nil {
+					log.Error("failed to restart watcher, keeping the old one", "watcher", req.name, "error", err.Error())
+				} else {
+					delete(warmed, section)
+					watchersHealth.set(req.name, false)
+					broadcastStatusUpdate = true
+				}
+				req.done <- err
+			case req := <-watcherStops:
+				stop := stopTalos
+				if req.name == WATCHER_LOKI {
+					stop = stopLoki
+				}
+				req.stopped <- stop()
+			case req := <-departedPurges:
+				purged := departures.purge(req.node)
+				if purged {
+					applyNodes(rawTalos)
+					broadcastStatusUpdate = true
+				}
+				req.done <- purged
+			case s := <-selfInfo:
+				status.Self = s
+				limits.check(s)
+				broadcastStatusUpdate = true
+			case s := <-etcdSnapshots:
+				status.EtcdSnapshot = &s
+				broadcastStatusUpdate = true
+			case c := <-credentialChanges:
+				if announceCredentialChange(&status, c, log) {
+					broadcastStatusUpdate = true
+				}
+			case e := <-injectionChan:
+				if e != nil {
+					injected := enricher.enrich(contexts.enrich(identities.Load().applyEvent(*e)))
+					alerter.check(injected, clk.Now())
+					broadcastEvent(injected, log)
+				}
+				applyNodes(rawTalos)
+				broadcastStatusUpdate = true
 			default:
 				time.Sleep(time.Millisecond * 100)
-				continue
+			}
+
+			if expired := injections.expire(clk.Now()); len(expired) > 0 {
+				log.Info("injected overrides expired, reverting", "nodes", expired)
+				applyNodes(rawTalos)
+				broadcastStatusUpdate = true
+			}
+
+			if queryTracker != nil {
+				if query, ok, err := queryTracker.due(clk.Now()); err != nil {
+					log.Error("failed to render loki query, keeping the current one", "error", err.Error())
+				} else if ok {
+					log.Info("node set changed, updating loki query", "query", query)
+					effectiveLokiQuery.Store(query)
+					lWatcher.SetQuery(query)
+				}
+			}
+
+			if silences.expire(clk.Now()) {
+				applyNodes(rawTalos)
+				broadcastStatusUpdate = true
+			}
+
+			if departures.expire(clk.Now()) {
+				applyNodes(rawTalos)
+				broadcastStatusUpdate = true
+			}
+
+			if incidents.expire(clk.Now()) {
+				broadcastStatusUpdate = true
+			}
+
+			if changes, changed := churn.expire(clk.Now()); changed {
+				for _, c := range changes {
+					announceChurn(c, time.Duration(cfg.Churn.Window), log)
+				}
+				broadcastStatusUpdate = true
+			}
+
+			if e := currentConfigError(); e != status.ConfigError {
+				status.ConfigError = e
+				broadcastStatusUpdate = true
+			}
+
+			if h := currentConfigHash(); h != status.ConfigHash {
+				status.ConfigHash = h
+				broadcastStatusUpdate = true
+			}
+
+			if m := maintenance.state(clk.Now()); m.Active != status.Maintenance || !sameTime(m.Until, status.MaintenanceUntil) {
+				log.Info("maintenance mode changed", "active", m.Active, "reason", m.Reason)
+				status.Maintenance = m.Active
+				status.MaintenanceUntil = m.Until
+				broadcastStatusUpdate = true
+			}
+
+			if status.Labwatch.State == LABWATCH_STARTING && (len(warmed) == 2 || clk.Now().After(warmupDeadline)) {
+				log.Info("warmup complete", "warmed", len(warmed))
+				notifySystemd(sdnotify.READY, log)
+				status.Labwatch.State = LABWATCH_RUNNING
+				status.FromCache, status.CachedAt = false, nil
+				broadcastStatusUpdate = true
 			}
 
 			if broadcastStatusUpdate {
-				currentStatus = status
-				log.Debug("broadcasting status", "clients", len(statusClients))
-				for _, ch := range statusClients {
-					ch <- status
+				updateStaleness(&status, cfg.Staleness, startTime, clk.Now())
+				watchersHealth.set(WATCHER_TALOS, warmed["talos"] && !status.Sections[SECTION_TALOS].Stale)
+				watchersHealth.set(WATCHER_LOKI, warmed["logs"] && !status.Sections[SECTION_LOGS].Stale && status.Logs.Connection != loki.CONNECTION_UNAUTHORIZED)
+				status.TalosEndpoints = currentTalos.Load().Endpoints()
+				status.Churn = churn.status(clk.Now())
+				status.Summary = computeSummary(status, cfg.Summary)
+				status.TalosSummary = computeTalosSummary(status.Talos, cfg.Summary)
+				status.TalosSummary.EndpointsDown = endpointsDown(status.TalosEndpoints)
+				status.Incidents = incidents.open()
+				if healthExpr != nil {
+					if level, err := healthExpr.evaluate(status); err != nil {
+						log.Warn("health expression failed, using built in rollup", "error", err.Error())
+					} else {
+						status.Summary.State = level
+					}
+				}
+				status.Healthy = status.Summary.State == HEALTH_LEVEL_OK
+				status.Outputs = dispatcher.Health()
+				healthMetric.Set(int64(status.Summary.State.rank()))
+				if status.Labwatch.State != LABWATCH_STARTING {
+					if announced != "" && announced != status.Summary.State {
+						announceSummaryChange(status.Summary, log)
+					}
+					announced = status.Summary.State
 				}
+
+				storeStatus(status)
+				log.Debug("broadcasting status", "clients", len(statusQueues()))
+				var done chan struct{}
+				if stateChange != nil {
+					done = stateChange.done
+				}
+				broadcasts.status(status, done)
 			}
 		}
 	}()
@@ -237,26 +1020,178 @@ func startWatchers(cfg LabwatchConfig, log *slog.Logger) error {
 	return nil
 }
 
-func addStatusClient(id string, ch chan<- LabStatus) {
+// broadcastEvent routes the event to its streams, numbers it and queues it
+// for every client. Every event goes through here, labwatch's own included.
+// The number is taken under eventSeqLock so events are queued in the order
+// they were numbered.
+func broadcastEvent(e loki.LogEvent, log *slog.Logger) {
+	e = eventStreams.route(e)
+	eventSeqLock.Lock()
+	defer eventSeqLock.Unlock()
+	eventSeq++
+	e.Seq = eventSeq
+	recentEvents.add(e, clk.Now())
+	log.Debug("broadcasting event", "clients", len(eventQueues()))
+	broadcasts.event(e)
+}
+
+// announceTransition logs a node transition and notifies about new problems
+// unless a failed dependency already explains them, and about those that
+// cleared
+func announceTransition(tr Transition, log *slog.Logger) {
+	log.Info("node transition", "node", tr.Node, "check", tr.Check, "from", tr.From, "to", tr.To, "events", len(tr.Events), "suppressedBy", tr.SuppressedBy)
+	alert := "node/" + tr.Node + "/" + tr.Check
+	if !tr.Bad {
+		msg := fmt.Sprintf("%s %s recovered, changed from %s to %s", tr.Node, tr.Check, tr.From, tr.To)
+		notifier.send(Notification{Title: "node recovered", Node: tr.Node, Message: msg, Severity: string(HEALTH_LEVEL_OK), Time: tr.Time, Injected: tr.Injected, Alert: alert, Resolved: true})
+		return
+	}
+	if tr.SuppressedBy != "" {
+		return
+	}
+
+	msg := fmt.Sprintf("%s %s changed from %s to %s", tr.Node, tr.Check, tr.From, tr.To)
+	if tr.ReleasedBy != "" {
+		msg = fmt.Sprintf("%s %s still %s after %s recovered", tr.Node, tr.Check, tr.To, tr.ReleasedBy)
+	}
+	if len(tr.Events) > 0 {
+		msg += fmt.Sprintf(" (%d related events, latest: %s)", len(tr.Events), tr.Events[len(tr.Events)-1].Message)
+	}
+	notifier.send(Notification{Title: "node problem", Node: tr.Node, Message: msg, Severity: string(HEALTH_LEVEL_WARN), Time: tr.Time, Injected: tr.Injected, Alert: alert})
+}
+
+// announceSummaryChange emits an event and a notification when the overall
+// lab state changes
+func announceSummaryChange(s Summary, log *slog.Logger) {
+	msg := summaryChangeMessage(s)
+	log.Info(msg)
+
+	level := "info"
+	switch s.State {
+	case HEALTH_LEVEL_WARN:
+		level = "warning"
+	case HEALTH_LEVEL_CRITICAL:
+		level = "critical"
+	}
+	injected := injections.active()
+	broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: level, Message: msg, Timestamp: clk.Now(), Injected: injected}, log)
+	notifier.send(Notification{Title: "lab state changed", Message: msg, Severity: string(s.State), Time: clk.Now(), Injected: injected, Alert: "lab-state", Resolved: s.State == HEALTH_LEVEL_OK})
+}
+
+// credentialChange reports the outcome of reloading a watcher's credential
+// or config file after it changed on disk
+type credentialChange struct {
+	section string
+	file    string
+	err     error
+}
+
+// announceMaintenanceChanges emits an event for every node that entered or
+// left Talos maintenance mode
+func announceMaintenanceChanges(prev map[string]talos.NodeStatus, next map[string]talos.NodeStatus, log *slog.Logger) {
+	for name, n := range next {
+		was := prev[name].WatcherState == talos.CONNECTION_MAINTENANCE
+		is := n.WatcherState == talos.CONNECTION_MAINTENANCE
+		if was == is {
+			continue
+		}
+		msg := "node left maintenance mode"
+		if is {
+			msg = "node entered maintenance mode"
+			if n.Maintenance != nil && n.Maintenance.Version != "" {
+				msg += fmt.Sprintf(" (Talos %s)", n.Maintenance.Version)
+			}
+		}
+		broadcastEvent(loki.LogEvent{Node: name, Service: "talos", Level: "info", Message: msg, Timestamp: clk.Now(), Injected: n.Injected}, log)
+	}
+}
+
+// announceStageChanges emits an event for every stage transition recorded
+// since the previous status, so a node can be watched booting
+func announceStageChanges(prev map[string]talos.NodeStatus, next map[string]talos.NodeStatus, log *slog.Logger) {
+	for name, n := range next {
+		var seen time.Time
+		if h := prev[name].StageHistory; len(h) > 0 {
+			seen = h[len(h)-1].Time
+		}
+		for _, tr := range n.StageHistory {
+			if !tr.Time.After(seen) {
+				continue
+			}
+			msg := fmt.Sprintf("node stage changed from %s to %s", tr.From, tr.To)
+			broadcastEvent(loki.LogEvent{Node: name, Service: "talos", Level: "info", Message: msg, Timestamp: tr.Time, Injected: n.Injected}, log)
+		}
+	}
+}
+
+// announceCredentialChange emits an event for the reload and flags the
+// section degraded while it is stuck on the previous credentials. Reports
+// whether the status changed.
+func announceCredentialChange(status *LabStatus, c credentialChange, log *slog.Logger) bool {
+	e := loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: "info", Message: fmt.Sprintf("reloaded %s", c.file)}
+	reason := ""
+	if c.err != nil {
+		reason = fmt.Sprintf("failed to reload %s, using previous version: %s", c.file, c.err.Error())
+		e.Level, e.Message = "error", reason
+		log.Error("failed to reload credentials", "section", c.section, "file", c.file, "error", c.err.Error())
+	}
+	broadcastEvent(e, log)
+	return markDegraded(status, c.section, reason)
+}
+
+func addStatusClient(id string) *clientQueue[LabStatus] {
+	q := newClientQueue[LabStatus](ENDPOINT_STATUS)
 	lock.Lock()
-	statusClients[id] = ch
+	statusClients[id] = q
 	lock.Unlock()
+	return q
 }
 
 func removeStatusClient(id string) {
 	lock.Lock()
-	delete(statusClients, id)
+	if q, ok := statusClients[id]; ok {
+		q.close()
+		delete(statusClients, id)
+	}
 	lock.Unlock()
 }
 
-func addEventClient(id string, ch chan<- loki.LogEvent) {
+func addEventClient(id string) *clientQueue[loki.LogEvent] {
+	q := newClientQueue[loki.LogEvent](ENDPOINT_EVENTS)
 	lock.Lock()
-	eventClients[id] = ch
+	eventClients[id] = q
 	lock.Unlock()
+	return q
 }
 
 func removeEventClient(id string) {
 	lock.Lock()
-	delete(eventClients, id)
+	if q, ok := eventClients[id]; ok {
+		q.close()
+		delete(eventClients, id)
+	}
 	lock.Unlock()
 }
+
+// statusQueues and eventQueues snapshot the clients so the broadcast can run
+// without holding the lock, which a blocking policy would otherwise hold
+// against clients trying to leave
+func statusQueues() []*clientQueue[LabStatus] {
+	lock.Lock()
+	defer lock.Unlock()
+	ret := make([]*clientQueue[LabStatus], 0, len(statusClients))
+	for _, q := range statusClients {
+		ret = append(ret, q)
+	}
+	return ret
+}
+
+func eventQueues() []*clientQueue[loki.LogEvent] {
+	lock.Lock()
+	defer lock.Unlock()
+	ret := make([]*clientQueue[loki.LogEvent], 0, len(eventClients))
+	for _, q := range eventClients {
+		ret = append(ret, q)
+	}
+	return ret
+}
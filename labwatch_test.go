@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestAnnounceSummaryChangeTimestamp(t *testing.T) {
+	fake := useTestGlobals(t, defaultConfig())
+	prevBroadcasts := broadcasts
+	t.Cleanup(func() { broadcasts = prevBroadcasts })
+	broadcasts = newBroadcaster(10)
+	defer broadcasts.stop()
+
+	fake.Advance(time.Minute)
+	announceSummaryChange(Summary{State: HEALTH_LEVEL_WARN}, discardLog)
+	got := recentEvents.replay(fake.Now())
+	if len(got) != 1 {
+		t.Fatalf("buffered %d events, want 1", len(got))
+	}
+	if !got[0].Timestamp.Equal(fake.Now()) {
+		t.Errorf("event stamped %s, want %s", got[0].Timestamp, fake.Now())
+	}
+	if got[0].Level != "warning" {
+		t.Errorf("event level %q", got[0].Level)
+	}
+}
+
+// Layering the nodes again while a handler reads the last status published
+// must not write into it. Run with -race
+func TestLayerNodesCopies(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DependsOn = DependencyConfig{"worker1": {"cp1"}}
+	useTestGlobals(t, cfg)
+	useTestInjections(t)
+	prevBroadcasts := broadcasts
+	t.Cleanup(func() { broadcasts = prevBroadcasts })
+	broadcasts = newBroadcaster(10)
+	defer broadcasts.stop()
+	defer storeStatus(loadStatus())
+	departures := newDepartedTracker(time.Hour, discardLog)
+	thresholds, err := newThresholdEvaluator([]NodeThresholdConfig{{Metric: talos.METRIC_CPU_PERCENT, Above: 90}}, discardLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	silences.add(Silence{ID: "maint", Node: "worker1", Until: clk.Now().Add(time.Hour)})
+	raw := map[string]talos.NodeStatus{
+		"cp1":     controlPlane("v1.9.0", talos.HEALTH_OK),
+		"worker1": with(worker("v1.9.0"), func(n *talos.NodeStatus) { n.Metrics = map[string]float64{talos.METRIC_CPU_PERCENT: 95} }),
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, n := range loadStatus().Talos {
+				_ = n.Silenced || n.Degraded != nil
+			}
+		}
+	}()
+	for range 200 {
+		storeStatus(LabStatus{Talos: layerNodes(raw, departures, thresholds, cfg.DependsOn, clk.Now())})
+	}
+	close(stop)
+	<-done
+
+	if got := loadStatus().Talos["worker1"]; !got.Silenced || got.Degraded == nil {
+		t.Errorf("worker1 layered as %+v", got)
+	}
+	if n := raw["worker1"]; n.Silenced || n.Degraded != nil {
+		t.Errorf("layering wrote into the raw statuses: %+v", n)
+	}
+}
+
+// Maintenance windows and departure announcements follow the clock the
+// watch loop runs on
+func TestMaintenanceAndDepartedClock(t *testing.T) {
+	fake := useTestGlobals(t, defaultConfig())
+	prevBroadcasts := broadcasts
+	t.Cleanup(func() { broadcasts = prevBroadcasts })
+	broadcasts = newBroadcaster(10)
+	defer broadcasts.stop()
+
+	maintenance.set(true, time.Hour, "upgrade")
+	if !notificationsMuted() {
+		t.Error("not muted during maintenance")
+	}
+	fake.Advance(2 * time.Hour)
+	if notificationsMuted() {
+		t.Error("still muted once the maintenance window passed")
+	}
+
+	departures := newDepartedTracker(0, discardLog)
+	departures.apply(map[string]talos.NodeStatus{"worker1": worker("v1.9.0")}, fake.Now())
+	fake.Advance(time.Minute)
+	departures.apply(map[string]talos.NodeStatus{}, fake.Now())
+	got := recentEvents.replay(fake.Now())
+	if len(got) != 1 || got[0].Message != "worker1 departed" || !got[0].Timestamp.Equal(fake.Now()) {
+		t.Errorf("announced %+v at %s", got, fake.Now())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+// RequestLimits caps what a client may send. MaxHeaderBytes applies to every
+// request; MaxBodyBytes to the endpoints that read a body, unless Paths sets
+// a limit for the endpoint's route path such as /debug/inject.
+type RequestLimits struct {
+	MaxHeaderBytes config.ByteSize            `yaml:"max-header-bytes"`
+	MaxBodyBytes   config.ByteSize            `yaml:"max-body-bytes"`
+	Paths          map[string]config.ByteSize `yaml:"paths"`
+}
+
+var requestLimits RequestLimits
+
+func validateRequestLimits(cfg RequestLimits) error {
+	if cfg.MaxHeaderBytes <= 0 {
+		return fmt.Errorf("max-header-bytes must be positive")
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		return fmt.Errorf("max-body-bytes must be positive")
+	}
+	for path, limit := range cfg.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path %q must start with /", path)
+		}
+		if limit <= 0 {
+			return fmt.Errorf("limit for %s must be positive", path)
+		}
+	}
+	return nil
+}
+
+// bodyLimit looks the request up by the pattern it was routed by, so
+// /admin/silence/{id} is limited as a whole rather than per ID
+func (l RequestLimits) bodyLimit(r *http.Request) int64 {
+	path := r.Pattern
+	if _, p, ok := strings.Cut(path, " "); ok {
+		path = p
+	}
+	if limit, ok := l.Paths[path]; ok {
+		return int64(limit)
+	}
+	return int64(l.MaxBodyBytes)
+}
+
+// limitBody caps the request body before next reads it
+func limitBody(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, requestLimits.bodyLimit(r))
+		next.ServeHTTP(w, r)
+	}
+}
+
+// readBody reads a body capped by limitBody, answering 413 when it was too
+// large. It reports false once it has answered the request.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("request body over %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return nil, false
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return body, true
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+var defaultLogAlertDebounce = time.Duration(5) * time.Minute
+
+// LogAlertConfig fires a notification when an event's message matches the
+// pattern, at most once per debounce period
+type LogAlertConfig struct {
+	Name     string          `yaml:"name"`
+	Pattern  string          `yaml:"pattern"`
+	Regex    bool            `yaml:"regex"`
+	Severity HealthLevel     `yaml:"severity"`
+	Debounce config.Duration `yaml:"debounce"`
+}
+
+type logAlert struct {
+	cfg       LogAlertConfig
+	re        *regexp.Regexp
+	lastFired time.Time
+}
+
+func (a *logAlert) matches(e loki.LogEvent) bool {
+	if a.re != nil {
+		return a.re.MatchString(e.Message)
+	}
+	return strings.Contains(e.Message, a.cfg.Pattern)
+}
+
+// logAlerter is only used from the watch loop so it needs no locking
+type logAlerter struct {
+	alerts []*logAlert
+	log    *slog.Logger
+}
+
+func newLogAlerter(cfgs []LogAlertConfig, log *slog.Logger) (*logAlerter, error) {
+	ret := &logAlerter{log: log.With("operation", "logAlerter")}
+	names := map[string]bool{}
+	for _, c := range cfgs {
+		if c.Name == "" || c.Pattern == "" {
+			return nil, fmt.Errorf("log alerts need a name and a pattern")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate log alert name %q", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Severity == "" {
+			c.Severity = HEALTH_LEVEL_WARN
+		}
+		if c.Severity != HEALTH_LEVEL_OK && c.Severity != HEALTH_LEVEL_WARN && c.Severity != HEALTH_LEVEL_CRITICAL {
+			return nil, fmt.Errorf("invalid severity %q for log alert %s", c.Severity, c.Name)
+		}
+		if c.Debounce == 0 {
+			c.Debounce = config.Duration(defaultLogAlertDebounce)
+		}
+
+		a := &logAlert{cfg: c}
+		if c.Regex {
+			re, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for log alert %s: %w", c.Name, err)
+			}
+			a.re = re
+		}
+		ret.alerts = append(ret.alerts, a)
+	}
+	return ret, nil
+}
+
+// check notifies for every alert the event matches that isn't debounced
+func (l *logAlerter) check(e loki.LogEvent, now time.Time) {
+	for _, a := range l.alerts {
+		if !a.matches(e) {
+			continue
+		}
+		if now.Sub(a.lastFired) < time.Duration(a.cfg.Debounce) {
+			l.log.Debug("log alert debounced", "alert", a.cfg.Name)
+			continue
+		}
+		a.lastFired = now
+
+		l.log.Info("log alert fired", "alert", a.cfg.Name, "host", e.Node, "service", e.Service)
+		event := e
+		notifier.send(Notification{
+			Title:    "log alert: " + a.cfg.Name,
+			Node:     e.Node,
+			Message:  fmt.Sprintf("%s %s: %s", e.Node, e.Service, e.Message),
+			Severity: string(a.cfg.Severity),
+			Time:     now,
+			Injected: e.Injected,
+			Event:    &event,
+		})
+	}
+}
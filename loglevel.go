@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+func parseLogLevel(level string) slog.Level {
+	switch level {
+	case "error":
+		return slog.LevelError
+	case "warn":
+		return slog.LevelWarn
+	case "debug":
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// levelHandler filters records at its own level before passing them on, so
+// a component can log more or less than the rest of labwatch
+type levelHandler struct {
+	level slog.Leveler
+	slog.Handler
+}
+
+func (h levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{level: h.level, Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{level: h.level, Handler: h.Handler.WithGroup(name)}
+}
+
+// componentLogger returns log filtered at level, or log itself when no level
+// was given for the component
+func componentLogger(log *slog.Logger, level string) *slog.Logger {
+	if level == "" {
+		return log
+	}
+	return slog.New(levelHandler{level: parseLogLevel(level), Handler: log.Handler()})
+}
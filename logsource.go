@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/journald"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+type LogSource string
+
+// LOG_SOURCE_LOKI tails the configured Loki query
+const LOG_SOURCE_LOKI LogSource = "loki"
+
+// LOG_SOURCE_JOURNALD follows the systemd journal directly with journalctl,
+// for labs without Loki. Label matchers and event queries need Loki and are
+// refused. The watcher keeps the loki name in health and admin endpoints.
+const LOG_SOURCE_JOURNALD LogSource = "journald"
+
+func (LogSource) JSONSchema() map[string]any {
+	return enumSchema(string(LOG_SOURCE_LOKI), string(LOG_SOURCE_JOURNALD))
+}
+
+func init() {
+	registerSchemaSection("loki", SCHEMA_WATCHER, "Tails a Loki query for events and log stats", "log-source", "loki-")
+	registerSchemaSection("journald", SCHEMA_WATCHER, "Follows the systemd journal of local or ssh hosts when log-source is journald", "journald")
+}
+
+var errNeedsLoki = errors.New("not supported by the journald log source")
+
+// logWatcher is the log source feeding the watch loop
+type logWatcher interface {
+	Watch(ctx context.Context, eventChan chan<- loki.LogEvent, statChan chan<- loki.LogStats)
+}
+
+func newLogWatcher(ctx context.Context, cfg LabwatchConfig, query string, log *slog.Logger) (logWatcher, error) {
+	if cfg.LogSource == LOG_SOURCE_JOURNALD {
+		return newJournaldWatcher(cfg, log)
+	}
+	return loki.NewLokiWatcher(ctx, loki.LokiWatcherConfig{
+		Address:          cfg.LokiAddress,
+		Query:            query,
+		MaxClockSkew:     time.Duration(cfg.LokiMaxClockSkew),
+		NarrowFactor:     cfg.LokiNarrowFactor,
+		TraceIDField:     cfg.LokiTraceIDField,
+		FallbackQuery:    cfg.LokiFallbackQuery,
+		FallbackWindow:   time.Duration(cfg.LokiFallbackWindow),
+		DedupWindow:      time.Duration(cfg.LokiDedupWindow),
+		Clock:            clk,
+		ReportQuery:      cfg.LokiStatsIncludeQuery,
+		Name:             cfg.LokiQueryName,
+		SilenceThreshold: time.Duration(cfg.LokiSilenceThreshold),
+		SilenceExempt:    cfg.LokiSilenceExempt,
+		SilenceServices:  cfg.LokiSilenceServices,
+		FieldTypes:       cfg.LokiFieldTypes,
+		PermissionCheck:  cfg.LokiPermissionCheck,
+		PermissionQuery:  cfg.LokiPermissionQuery,
+	}, log)
+}
+
+func newJournaldWatcher(cfg LabwatchConfig, log *slog.Logger) (*journald.JournaldWatcher, error) {
+	jcfg := cfg.Journald
+	jcfg.TraceIDField = cfg.LokiTraceIDField
+	return journald.NewJournaldWatcher(jcfg, log)
+}
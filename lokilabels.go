@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"golang.org/x/sync/singleflight"
+)
+
+var lokiLabelsTimeout = time.Duration(15) * time.Second
+
+// labelCache holds Loki's label answers for ttl so dashboards refreshing
+// together cost Loki one request. Concurrent misses for the same key share a
+// single fetch. Errors are never cached.
+type labelCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]labelCacheEntry
+	fetches singleflight.Group
+}
+
+type labelCacheEntry struct {
+	values  []string
+	fetched time.Time
+}
+
+func newLabelCache(ttl time.Duration) *labelCache {
+	return &labelCache{ttl: ttl, entries: map[string]labelCacheEntry{}}
+}
+
+func (c *labelCache) get(key string, fetch func() ([]string, error)) ([]string, error) {
+	c.lock.Lock()
+	e, ok := c.entries[key]
+	c.lock.Unlock()
+	if ok && time.Since(e.fetched) < c.ttl {
+		return e.values, nil
+	}
+
+	v, err, _ := c.fetches.Do(key, func() (any, error) {
+		values, err := fetch()
+		if err == nil && c.ttl > 0 {
+			c.lock.Lock()
+			c.entries[key] = labelCacheEntry{values: values, fetched: time.Now()}
+			c.lock.Unlock()
+		}
+		return values, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+// writeLokiError passes a rejection from Loki through with its status, so a
+// bad label name reads as a 400 rather than an empty list. Loki failing or
+// being unreachable is a bad gateway.
+func writeLokiError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	lokiErr := loki.StatusError{}
+	if errors.As(err, &lokiErr) && lokiErr.Status >= 400 && lokiErr.Status < 500 {
+		status = lokiErr.Status
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// handleLokiLabels serves GET /loki/labels and, given a name in the path,
+// GET /loki/labels/{name}/values
+func handleLokiLabels(cfg LabwatchConfig, auth *streamAuth) http.HandlerFunc {
+	cache := newLabelCache(time.Duration(cfg.LokiLabelsCacheTTL))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.allow(w, r) {
+			return
+		}
+		if cfg.LogSource != LOG_SOURCE_LOKI {
+			http.Error(w, "label discovery is "+errNeedsLoki.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), lokiLabelsTimeout)
+		defer cancel()
+		name := r.PathValue("name")
+		values, err := cache.get(name, func() ([]string, error) {
+			if name == "" {
+				return loki.Labels(ctx, cfg.LokiAddress)
+			}
+			return loki.LabelValues(ctx, cfg.LokiAddress, name)
+		})
+		if err != nil {
+			writeLokiError(w, err)
+			return
+		}
+		b, _ := encodeJSON(values)
+		w.Write(b)
+	}
+}
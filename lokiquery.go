@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// LokiQueryData is available to templated Loki queries such as
+// { host_name =~ "{{ .Nodes | join "|" }}" } | json
+type LokiQueryData struct {
+	Nodes       []string
+	ClusterName string
+}
+
+var effectiveLokiQuery atomic.Value
+
+var lokiQueryFuncs = template.FuncMap{
+	"join": func(sep string, s []string) string { return strings.Join(s, sep) },
+}
+
+// readLokiQueryFile loads a query kept in its own file. Lines starting with
+// # are comments and dropped, as is the whitespace around the query.
+func readLokiQueryFile(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	query := strings.TrimSpace(strings.Join(lines, "\n"))
+	if query == "" {
+		return "", fmt.Errorf("%s contains no query", file)
+	}
+	return query, nil
+}
+
+// compileLokiQuery parses the query as a template. Plain queries without any
+// template syntax return nil and are used as-is.
+func compileLokiQuery(query string) (*template.Template, error) {
+	if !strings.Contains(query, "{{") {
+		return nil, nil
+	}
+	return template.New("loki-query").Funcs(lokiQueryFuncs).Option("missingkey=error").Parse(query)
+}
+
+func renderLokiQuery(tmpl *template.Template, data LokiQueryData) (string, error) {
+	b := strings.Builder{}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// lokiQueryTracker re-renders a templated query when the set of nodes
+// changes, waiting for the set to hold still for the debounce period so a
+// flapping discovery doesn't thrash the Loki connection
+type lokiQueryTracker struct {
+	tmpl         *template.Template
+	cluster      string
+	debounce     time.Duration
+	current      string
+	pending      []string
+	pendingSince time.Time
+}
+
+func newLokiQueryTracker(tmpl *template.Template, cluster string, debounce time.Duration, nodes []string) (*lokiQueryTracker, string, error) {
+	t := &lokiQueryTracker{tmpl: tmpl, cluster: cluster, debounce: debounce}
+	sort.Strings(nodes)
+	query, err := renderLokiQuery(tmpl, LokiQueryData{Nodes: nodes, ClusterName: cluster})
+	if err != nil {
+		return nil, "", err
+	}
+	t.current = strings.Join(nodes, "\x00")
+	return t, query, nil
+}
+
+func (t *lokiQueryTracker) observe(nodes []string, now time.Time) {
+	sort.Strings(nodes)
+	key := strings.Join(nodes, "\x00")
+	if key == t.current {
+		t.pending = nil
+		return
+	}
+	if t.pending == nil || strings.Join(t.pending, "\x00") != key {
+		t.pending = nodes
+		t.pendingSince = now
+	}
+}
+
+// due returns a freshly rendered query once a node set change has settled
+func (t *lokiQueryTracker) due(now time.Time) (string, bool, error) {
+	if t.pending == nil || now.Sub(t.pendingSince) < t.debounce {
+		return "", false, nil
+	}
+	nodes := t.pending
+	t.pending = nil
+	t.current = strings.Join(nodes, "\x00")
+
+	query, err := renderLokiQuery(t.tmpl, LokiQueryData{Nodes: nodes, ClusterName: t.cluster})
+	return query, err == nil, err
+}
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseLabelMatchers reads repeated label=name=value query parameters. Only
+// plain label names are accepted and values are quoted when rendered, so a
+// matcher can narrow the stream selector but never reach the pipeline.
+func parseLabelMatchers(values []string) (map[string]string, error) {
+	ret := map[string]string{}
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("label matcher %q must be of the form name=value", v)
+		}
+		if !labelNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		ret[name] = value
+	}
+	return ret, nil
+}
+
+// addLabelMatchers appends equality matchers to the stream selector that
+// opens the query, leaving the rest of the query untouched
+func addLabelMatchers(query string, matchers map[string]string) (string, error) {
+	if len(matchers) == 0 {
+		return query, nil
+	}
+
+	start := strings.IndexByte(query, '{')
+	if start < 0 || strings.TrimSpace(query[:start]) != "" {
+		return "", fmt.Errorf("query does not start with a stream selector")
+	}
+	end := -1
+	inString := false
+	for i := start + 1; i < len(query) && end < 0; i++ {
+		switch {
+		case inString && query[i] == '\\':
+			i++
+		case query[i] == '"':
+			inString = !inString
+		case !inString && query[i] == '}':
+			end = i
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("unterminated stream selector")
+	}
+
+	names := []string{}
+	for name := range matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	selector := strings.TrimSpace(query[start+1 : end])
+	for _, name := range names {
+		if selector != "" {
+			selector += ", "
+		}
+		selector += name + "=" + strconv.Quote(matchers[name])
+	}
+	return query[:start] + "{ " + selector + " }" + query[end+1:], nil
+}
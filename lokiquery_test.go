@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+func TestLokiQueryDebounce(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	tmpl, err := compileLokiQuery(`{ host_name =~ "{{ .Nodes | join "|" }}" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, query, err := newLokiQueryTracker(tmpl, "lab", 10*time.Second, []string{"cp1", "worker1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != `{ host_name =~ "cp1|worker1" }` {
+		t.Fatalf("initial query %s", query)
+	}
+	due := func() (string, bool) {
+		t.Helper()
+		q, ok, err := tracker.due(fake.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q, ok
+	}
+
+	// The same set in another order is no change
+	tracker.observe([]string{"worker1", "cp1"}, fake.Now())
+	fake.Advance(time.Minute)
+	if _, ok := due(); ok {
+		t.Fatal("re-rendered an unchanged set")
+	}
+
+	// A new node waits for the set to hold still
+	tracker.observe([]string{"cp1", "worker1", "worker2"}, fake.Now())
+	fake.Advance(9 * time.Second)
+	if _, ok := due(); ok {
+		t.Fatal("re-rendered before the debounce")
+	}
+
+	// Flapping restarts the wait
+	tracker.observe([]string{"cp1", "worker1", "worker3"}, fake.Now())
+	fake.Advance(9 * time.Second)
+	tracker.observe([]string{"cp1", "worker1", "worker3"}, fake.Now())
+	if _, ok := due(); ok {
+		t.Fatal("re-rendered while the set was flapping")
+	}
+	fake.Advance(time.Second)
+	q, ok := due()
+	if !ok || q != `{ host_name =~ "cp1|worker1|worker3" }` {
+		t.Fatalf("settled set rendered %t as %s", ok, q)
+	}
+	if _, ok := due(); ok {
+		t.Error("rendered twice for one change")
+	}
+
+	// Going back to the current set before the debounce drops the change
+	tracker.observe([]string{"cp1"}, fake.Now())
+	fake.Advance(5 * time.Second)
+	tracker.observe([]string{"cp1", "worker1", "worker3"}, fake.Now())
+	fake.Advance(time.Minute)
+	if _, ok := due(); ok {
+		t.Error("re-rendered a change that was reverted")
+	}
+}
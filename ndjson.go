@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// streamEventsNDJSON holds a plain HTTP response open and writes one JSON
+// event per line for clients that can't speak websockets or SSE
+func streamEventsNDJSON(w http.ResponseWriter, r *http.Request, sub *eventSubscription, client ClientInfo, cfg LabwatchConfig) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	clientsWG.Add(1)
+	defer clientsWG.Done()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	filter := parseEventFilter(r.URL.Query())
+	write := func(e loki.LogEvent) error {
+		if !filter.matches(e) {
+			return nil
+		}
+		data, err := encodeJSON(e)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(append(data, '\n')); err != nil {
+			return client.track(err)
+		}
+		flusher.Flush()
+		return client.track(nil)
+	}
+
+	if r.URL.Query().Get("replay") != "" {
+		events, _ := sub.replay(r.Context(), cfg)
+		for _, e := range events {
+			if err := write(e); err != nil {
+				return
+			}
+		}
+	}
+
+	lifetime := connectionLifetime(cfg)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stopping:
+			return
+		case <-lifetime:
+			return
+		case <-sub.kicked:
+			return
+		case e := <-sub.events:
+			if !filter.matches(e) {
+				continue
+			}
+			if send, _ := client.sampleEvent(time.Now()); !send {
+				continue
+			}
+			if err := write(e); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+//go:embed oui.txt
+var embeddedOUIs string
+
+// maxEnrichedAddresses bounds the work done for one event
+var maxEnrichedAddresses = 8
+var maxDNSCacheEntries = 10000
+
+// Failed lookups are retried sooner than answered ones are refreshed
+var dnsNegativeTTL = time.Duration(1) * time.Minute
+
+var enrichmentStats = expvar.NewMap("enrichment")
+
+// EnrichmentConfig adds what is known about the MAC and IP addresses in an
+// event. Each lookup is enabled on its own. Enrichment gives up once it has
+// taken budget and the event goes out with whatever was found by then; a
+// reverse lookup still running finishes into the cache for later events.
+type EnrichmentConfig struct {
+	OUI         bool            `yaml:"oui"`
+	OUIFile     string          `yaml:"oui-file"`
+	ReverseDNS  bool            `yaml:"reverse-dns"`
+	DNSTimeout  config.Duration `yaml:"dns-timeout"`
+	DNSCacheTTL config.Duration `yaml:"dns-cache-ttl"`
+	GeoIPFile   string          `yaml:"geoip-file"`
+	Budget      config.Duration `yaml:"budget"`
+}
+
+func (cfg EnrichmentConfig) enabled() bool {
+	return cfg.OUI || cfg.ReverseDNS || cfg.GeoIPFile != ""
+}
+
+func validateEnrichment(cfg EnrichmentConfig) error {
+	if !cfg.enabled() {
+		return nil
+	}
+	if cfg.Budget <= 0 {
+		return fmt.Errorf("budget must be positive")
+	}
+	if cfg.ReverseDNS && (cfg.DNSTimeout <= 0 || cfg.DNSCacheTTL <= 0) {
+		return fmt.Errorf("dns-timeout and dns-cache-ttl must be positive when reverse-dns is enabled")
+	}
+	return nil
+}
+
+// networkEnricher is the enrichment stage of the event pipeline. A nil
+// enricher passes events through.
+type networkEnricher struct {
+	budget time.Duration
+	ouis   map[string]string
+	geo    *maxminddb.Reader
+	dns    *reverseDNSCache
+}
+
+func newNetworkEnricher(cfg EnrichmentConfig, log *slog.Logger) (*networkEnricher, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	log = log.With("operation", "networkEnricher")
+	n := &networkEnricher{budget: time.Duration(cfg.Budget)}
+
+	if cfg.OUI {
+		var r io.Reader = strings.NewReader(embeddedOUIs)
+		if cfg.OUIFile != "" {
+			f, err := os.Open(cfg.OUIFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		ouis, err := parseOUIs(r)
+		if err != nil {
+			return nil, err
+		}
+		n.ouis = ouis
+		log.Info("loaded mac vendors", "vendors", len(ouis))
+	}
+	if cfg.GeoIPFile != "" {
+		geo, err := maxminddb.Open(cfg.GeoIPFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open geoip-file: %w", err)
+		}
+		n.geo = geo
+		log.Info("loaded geoip database", "type", geo.Metadata.DatabaseType)
+	}
+	if cfg.ReverseDNS {
+		n.dns = newReverseDNSCache(time.Duration(cfg.DNSTimeout), time.Duration(cfg.DNSCacheTTL))
+	}
+	return n, nil
+}
+
+// parseOUIs reads lines starting with an OUI such as B8:27:EB, B8-27-EB or
+// B827EB followed by the vendor. The "(hex)" and "(base 16)" markers of the
+// IEEE format are skipped and lines without an OUI ignored.
+func parseOUIs(r io.Reader) (map[string]string, error) {
+	ret := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix := strings.Fields(line)[0]
+		key := strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(prefix))
+		if _, err := hex.DecodeString(key); err != nil || len(key) != 6 {
+			continue
+		}
+		vendor := strings.TrimSpace(line[len(prefix):])
+		for _, marker := range []string{"(hex)", "(base 16)"} {
+			vendor = strings.TrimSpace(strings.TrimPrefix(vendor, marker))
+		}
+		if vendor != "" {
+			ret[key] = vendor
+		}
+	}
+	return ret, scanner.Err()
+}
+
+// eventAddresses finds the MAC and IP addresses among the event's string
+// fields and the words of its message
+func eventAddresses(e loki.LogEvent) map[string]any {
+	ret := map[string]any{}
+	add := func(s string) bool {
+		if _, ok := ret[s]; ok {
+			return true
+		}
+		if len(ret) >= maxEnrichedAddresses {
+			return false
+		}
+		if mac, err := net.ParseMAC(s); err == nil && len(mac) == 6 {
+			ret[s] = mac
+		} else if addr, err := netip.ParseAddr(s); err == nil {
+			ret[s] = addr.Unmap()
+		} else if ap, err := netip.ParseAddrPort(s); err == nil {
+			ret[s] = ap.Addr().Unmap()
+		}
+		return true
+	}
+
+	for _, v := range e.Fields {
+		if s, ok := v.(string); ok && !add(s) {
+			return ret
+		}
+	}
+	words := strings.FieldsFunc(e.Message, func(r rune) bool {
+		return strings.ContainsRune(" \t=,;\"'()[]<>", r)
+	})
+	for _, w := range words {
+		if !add(strings.TrimSuffix(w, ".")) {
+			break
+		}
+	}
+	return ret
+}
+
+func publicAddr(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// enrich returns the event with an entry for every address something was
+// found for. The original fields are left as they are.
+func (n *networkEnricher) enrich(e loki.LogEvent) loki.LogEvent {
+	if n == nil {
+		return e
+	}
+	found := map[string]loki.Enrichment{}
+	pending := map[string]*dnsEntry{}
+	for raw, parsed := range eventAddresses(e) {
+		info := loki.Enrichment{}
+		switch a := parsed.(type) {
+		case net.HardwareAddr:
+			if n.ouis != nil {
+				info.Vendor = n.ouis[strings.ToUpper(hex.EncodeToString(a[:3]))]
+			}
+		case netip.Addr:
+			if n.geo != nil && publicAddr(a) {
+				var rec struct {
+					Country struct {
+						ISOCode string `maxminddb:"iso_code"`
+					} `maxminddb:"country"`
+				}
+				if err := n.geo.Lookup(net.IP(a.AsSlice()), &rec); err == nil {
+					info.Country = rec.Country.ISOCode
+				}
+			}
+			if n.dns != nil && !a.IsLoopback() && !a.IsUnspecified() {
+				pending[raw] = n.dns.lookup(a, time.Now())
+			}
+		}
+		if info != (loki.Enrichment{}) {
+			found[raw] = info
+		}
+	}
+
+	if len(pending) > 0 {
+		timer := time.NewTimer(n.budget)
+		defer timer.Stop()
+		expired := false
+		for raw, entry := range pending {
+			if !expired {
+				select {
+				case <-entry.done:
+				case <-timer.C:
+					expired = true
+				}
+			}
+			select {
+			case <-entry.done:
+				if entry.name != "" {
+					info := found[raw]
+					info.Hostname = entry.name
+					found[raw] = info
+				}
+			default:
+				enrichmentStats.Add("dns_over_budget", 1)
+			}
+		}
+	}
+
+	if len(found) > 0 {
+		e.Enrichment = found
+	}
+	return e
+}
+
+// reverseDNSCache remembers PTR lookups. A miss starts the lookup in the
+// background so a caller can stop waiting without losing the answer.
+type reverseDNSCache struct {
+	timeout  time.Duration
+	ttl      time.Duration
+	resolver *net.Resolver
+	entries  map[netip.Addr]*dnsEntry
+	lock     sync.Mutex
+}
+
+// dnsEntry is one cached lookup. name may only be read once done is closed.
+type dnsEntry struct {
+	name    string
+	expires time.Time
+	done    chan struct{}
+}
+
+func newReverseDNSCache(timeout time.Duration, ttl time.Duration) *reverseDNSCache {
+	return &reverseDNSCache{
+		timeout:  timeout,
+		ttl:      ttl,
+		resolver: net.DefaultResolver,
+		entries:  map[netip.Addr]*dnsEntry{},
+	}
+}
+
+// lookup returns the entry for addr, which may still be resolving
+func (c *reverseDNSCache) lookup(addr netip.Addr, now time.Time) *dnsEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.entries[addr]; ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		enrichmentStats.Add("dns_cache_hits", 1)
+		return e
+	}
+	enrichmentStats.Add("dns_cache_misses", 1)
+
+	if len(c.entries) >= maxDNSCacheEntries {
+		c.prune(now)
+	}
+	e := &dnsEntry{done: make(chan struct{})}
+	c.entries[addr] = e
+	go c.resolve(addr, e)
+	return e
+}
+
+func (c *reverseDNSCache) resolve(addr netip.Addr, e *dnsEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	names, err := c.resolver.LookupAddr(ctx, addr.String())
+
+	ttl := c.ttl
+	if err != nil || len(names) == 0 {
+		enrichmentStats.Add("dns_errors", 1)
+		ttl = min(ttl, dnsNegativeTTL)
+	} else {
+		e.name = strings.TrimSuffix(names[0], ".")
+	}
+	c.lock.Lock()
+	e.expires = time.Now().Add(ttl)
+	c.lock.Unlock()
+	close(e.done)
+}
+
+// prune drops expired entries, and failing that arbitrary ones, to make room.
+// Called with the lock held.
+func (c *reverseDNSCache) prune(now time.Time) {
+	for addr, e := range c.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(c.entries, addr)
+		}
+	}
+	for addr, e := range c.entries {
+		if len(c.entries) < maxDNSCacheEntries {
+			return
+		}
+		if !e.expires.IsZero() {
+			delete(c.entries, addr)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// testEnricher looks up vendors in the embedded table and has the reverse
+// lookup of 192.168.1.10 cached and that of 192.168.1.11 never finishing, so
+// nothing reaches the network
+func testEnricher(tb testing.TB) *networkEnricher {
+	tb.Helper()
+	n, err := newNetworkEnricher(EnrichmentConfig{
+		OUI:         true,
+		ReverseDNS:  true,
+		DNSTimeout:  config.Duration(time.Second),
+		DNSCacheTTL: config.Duration(time.Hour),
+		Budget:      config.Duration(5 * time.Millisecond),
+	}, discardLog)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	answered := &dnsEntry{name: "nas.lab", done: make(chan struct{})}
+	close(answered.done)
+	n.dns.entries[netip.MustParseAddr("192.168.1.10")] = answered
+	n.dns.entries[netip.MustParseAddr("192.168.1.11")] = &dnsEntry{done: make(chan struct{})}
+	return n
+}
+
+func TestEnrich(t *testing.T) {
+	n := testEnricher(t)
+	e := loki.LogEvent{
+		Message: "DHCPACK on 192.168.1.10 to b8:27:eb:12:34:56 via eth0",
+		Fields:  map[string]any{"src": "192.168.1.11:443"},
+	}
+	start := time.Now()
+	got := n.enrich(e)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("enriching waited %s on a lookup that never finishes", took)
+	}
+
+	want := map[string]loki.Enrichment{
+		"192.168.1.10":      {Hostname: "nas.lab"},
+		"b8:27:eb:12:34:56": {Vendor: "Raspberry Pi"},
+	}
+	if len(got.Enrichment) != len(want) {
+		t.Errorf("enriched %+v, want %+v", got.Enrichment, want)
+	}
+	for raw, info := range want {
+		if got.Enrichment[raw] != info {
+			t.Errorf("%s enriched as %+v, want %+v", raw, got.Enrichment[raw], info)
+		}
+	}
+	if got.Message != e.Message || got.Fields["src"] != "192.168.1.11:443" {
+		t.Errorf("original fields changed to %+v", got)
+	}
+
+	if got := (*networkEnricher)(nil).enrich(e); got.Enrichment != nil {
+		t.Errorf("a nil enricher enriched %+v", got.Enrichment)
+	}
+}
+
+// enrich runs on every event, most of which have no addresses at all
+func BenchmarkEnrich(b *testing.B) {
+	n := testEnricher(b)
+	n.budget = time.Hour
+	events := map[string]loki.LogEvent{
+		"no addresses": {Message: "kubelet: Started container etcd in pod etcd-cp1", Fields: map[string]any{"unit": "kubelet", "level": "info"}},
+		"mac":          {Message: "DHCPDISCOVER from b8:27:eb:12:34:56 via eth0"},
+		"cached dns":   {Message: "accepted connection from 192.168.1.10:51514"},
+		"mixed":        {Message: "DHCPACK on 192.168.1.10 to dc:a6:32:01:02:03 via eth0", Fields: map[string]any{"client": "192.168.1.10"}},
+	}
+	for name, e := range events {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				n.enrich(e)
+			}
+		})
+	}
+	b.Run("disabled", func(b *testing.B) {
+		var n *networkEnricher
+		e := events["mixed"]
+		b.ReportAllocs()
+		for b.Loop() {
+			n.enrich(e)
+		}
+	})
+}
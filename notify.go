@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/proxy"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/google/uuid"
+)
+
+var notifyTimeout = time.Duration(10) * time.Second
+var notifyMinBackoff = time.Duration(5) * time.Second
+var notifyMaxBackoff = time.Duration(10) * time.Minute
+
+// Notification is delivered at least once. ID is the same on every attempt
+// so receivers can drop the duplicates a retry or restart may cause. Alert
+// identifies the problem across its notifications, Resolved marks the one
+// saying it cleared and Reminder counts the reminders sent while it fires.
+type Notification struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Node     string    `json:"node,omitempty"`
+	Message  string    `json:"message"`
+	Severity string    `json:"severity"`
+	Time     time.Time `json:"time"`
+	Injected bool      `json:"injected,omitempty"`
+	Alert    string    `json:"alert,omitempty"`
+	Resolved bool      `json:"resolved,omitempty"`
+	Reminder int       `json:"reminder,omitempty"`
+
+	Event *loki.LogEvent `json:"event,omitempty"`
+}
+
+// queuedNotification is a notification awaiting delivery to one destination
+// along with its retry schedule. Entries queued before destinations existed
+// have no URL and go to notify-webhook.
+type queuedNotification struct {
+	Notification Notification `json:"notification"`
+	Destination  string       `json:"destination,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	Attempts     int          `json:"attempts"`
+	NextAttempt  time.Time    `json:"next_attempt"`
+}
+
+// webhookNotifier POSTs notifications as JSON to the configured destinations,
+// retrying with backoff until delivered or older than maxAge. With a queue
+// file, the queue is written before each delivery attempt so a restart
+// resumes the retries rather than losing them.
+type webhookNotifier struct {
+	legacyURL string
+	file      string
+	maxAge    time.Duration
+	limiter   *alertLimiter
+	alerts    *alertTracker
+	log       *slog.Logger
+
+	lock  sync.Mutex
+	queue []queuedNotification
+	wake  chan struct{}
+}
+
+var notifier *webhookNotifier
+
+func init() {
+	expvar.Publish("notifications_pending", expvar.Func(func() any { return notifier.pending() }))
+}
+
+func newWebhookNotifier(destinations []NotifyDestination, file string, maxAge time.Duration, maxPerMinute int, log *slog.Logger) *webhookNotifier {
+	n := &webhookNotifier{
+		file:    file,
+		maxAge:  maxAge,
+		limiter: newAlertLimiter(maxPerMinute),
+		alerts:  newAlertTracker(destinations),
+		log:     log.With("operation", "webhookNotifier"),
+		wake:    make(chan struct{}, 1),
+	}
+	for _, d := range destinations {
+		if d.Name == LEGACY_DESTINATION {
+			n.legacyURL = d.URL
+		}
+	}
+	if file != "" {
+		n.load()
+	}
+	return n
+}
+
+// load restores the queue left by the previous run. An unreadable file is
+// logged and ignored, the same as the state file.
+func (n *webhookNotifier) load() {
+	b, err := os.ReadFile(n.file)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &n.queue)
+	}
+	if err != nil {
+		n.log.Warn("ignoring unreadable notification queue", "file", n.file, "error", err.Error())
+		n.queue = nil
+		return
+	}
+	if len(n.queue) > 0 {
+		n.log.Info("restored pending notifications", "file", n.file, "pending", len(n.queue))
+	}
+}
+
+// persist writes the queue out. The caller holds the lock.
+func (n *webhookNotifier) persist() {
+	if n.file == "" {
+		return
+	}
+	b, err := json.Marshal(n.queue)
+	if err == nil {
+		err = writeFileAtomic(n.file, b)
+	}
+	if err != nil {
+		n.log.Error("failed to save notification queue", "file", n.file, "error", err.Error())
+	}
+}
+
+// send queues the notification for delivery in the background so slow
+// receivers never hold up the watch loop. Which destinations it goes to is
+// up to their policies. Nothing is sent while notifications are muted or
+// about a silenced node, and those over the rate limit are summarized.
+func (n *webhookNotifier) send(note Notification) {
+	if n == nil || len(n.alerts.destinations) == 0 {
+		return
+	}
+	n.deliver(n.alerts.observe(note, n.suppressed(note), clk.Now()))
+}
+
+// suppressed reports whether note is held back by a mute or silence
+func (n *webhookNotifier) suppressed(note Notification) bool {
+	if notificationsMuted() {
+		n.log.Debug("notification muted", "title", note.Title)
+		return true
+	}
+	if silences.covers(note.Node) {
+		n.log.Debug("notification silenced", "title", note.Title, "node", note.Node)
+		return true
+	}
+	return false
+}
+
+// deliver queues one notification's deliveries, which count once toward the
+// rate limit. The summary of those over it goes to every destination.
+func (n *webhookNotifier) deliver(deliveries []delivery) {
+	if len(deliveries) == 0 {
+		return
+	}
+	if !n.limiter.allow(deliveries[0].note, time.Now(), n.broadcast) {
+		n.log.Debug("notification rate limited", "title", deliveries[0].note.Title)
+		return
+	}
+	for _, d := range deliveries {
+		n.enqueue(d.dest, d.note)
+	}
+}
+
+func (n *webhookNotifier) broadcast(note Notification) {
+	for _, d := range n.alerts.all(note) {
+		n.enqueue(d.dest, d.note)
+	}
+}
+
+func (n *webhookNotifier) enqueue(dest NotifyDestination, note Notification) {
+	note.ID = uuid.New().String()
+	n.lock.Lock()
+	n.queue = append(n.queue, queuedNotification{Notification: note, Destination: dest.Name, URL: dest.URL, NextAttempt: time.Now()})
+	n.persist()
+	n.lock.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pending reports how many notifications are awaiting delivery
+func (n *webhookNotifier) pending() int {
+	if n == nil {
+		return 0
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return len(n.queue)
+}
+
+// run delivers queued notifications, and reminders as they come due, until
+// ctx is done
+func (n *webhookNotifier) run(ctx context.Context) {
+	if n == nil || len(n.alerts.destinations) == 0 {
+		return
+	}
+	go n.runReminders(ctx)
+	for {
+		wait := n.deliverDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliverDue attempts every notification whose retry time has come, drops
+// those past maxAge and returns how long until the next one is due
+func (n *webhookNotifier) deliverDue(ctx context.Context, now time.Time) time.Duration {
+	n.lock.Lock()
+	due := []queuedNotification{}
+	kept := []queuedNotification{}
+	for _, q := range n.queue {
+		if n.maxAge > 0 && now.Sub(q.Notification.Time) > n.maxAge {
+			n.log.Warn("dropping undelivered notification past the max age", "id", q.Notification.ID, "title", q.Notification.Title, "attempts", q.Attempts)
+			continue
+		}
+		if !q.NextAttempt.After(now) {
+			due = append(due, q)
+		}
+		kept = append(kept, q)
+	}
+	if len(kept) != len(n.queue) {
+		n.queue = kept
+		n.persist()
+	}
+	n.lock.Unlock()
+
+	// Attempts are made without the lock so send is never held up by a slow
+	// receiver. Due notifications stay queued, and saved, until they succeed.
+	delivered := map[string]bool{}
+	retries := map[string]queuedNotification{}
+	for _, q := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		url := q.URL
+		if url == "" {
+			url = n.legacyURL
+		}
+		if url == "" {
+			n.log.Warn("dropping notification for a destination no longer configured", "id", q.Notification.ID, "title", q.Notification.Title, "destination", q.Destination)
+			delivered[q.Notification.ID] = true
+			continue
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err := postJSON(attemptCtx, url, q.Notification)
+		cancel()
+		if err != nil {
+			q.Attempts++
+			backoff := min(notifyMinBackoff<<min(q.Attempts-1, 16), notifyMaxBackoff)
+			q.NextAttempt = time.Now().Add(backoff)
+			n.log.Error("failed to deliver notification, will retry", "error", err.Error(), "id", q.Notification.ID, "title", q.Notification.Title, "destination", q.Destination, "attempts", q.Attempts, "retry", backoff.String())
+			retries[q.Notification.ID] = q
+		} else {
+			delivered[q.Notification.ID] = true
+		}
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if len(delivered)+len(retries) > 0 {
+		remaining := []queuedNotification{}
+		for _, q := range n.queue {
+			if delivered[q.Notification.ID] {
+				continue
+			}
+			if retry, ok := retries[q.Notification.ID]; ok {
+				q = retry
+			}
+			remaining = append(remaining, q)
+		}
+		n.queue = remaining
+		n.persist()
+	}
+
+	wait := notifyMaxBackoff
+	for _, q := range n.queue {
+		wait = min(wait, time.Until(q.NextAttempt))
+	}
+	return max(wait, 0)
+}
+
+func postJSON(ctx context.Context, url string, v any) error {
+	b, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := proxy.HTTPClient(0).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
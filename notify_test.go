@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the notifications POSTed to it and fails them
+// while down is set, standing in for a receiver that is away
+type webhookReceiver struct {
+	lock     sync.Mutex
+	down     bool
+	received []Notification
+	taken    int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	note := Notification{}
+	json.NewDecoder(req.Body).Decode(&note)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.received = append(r.received, note)
+	if r.down {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+func (r *webhookReceiver) setDown(down bool) {
+	r.lock.Lock()
+	r.down = down
+	r.lock.Unlock()
+}
+
+func (r *webhookReceiver) ids() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ret := []string{}
+	for _, note := range r.received {
+		ret = append(ret, note.ID)
+	}
+	return ret
+}
+
+// deliver has n deliver what is due and returns what arrived since the last
+// call
+func (r *webhookReceiver) deliver(n *webhookNotifier) []Notification {
+	n.deliverDue(context.Background(), time.Now())
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ret := append([]Notification{}, r.received[r.taken:]...)
+	r.taken = len(r.received)
+	return ret
+}
+
+func queuedIn(t *testing.T, file string) []queuedNotification {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := []queuedNotification{}
+	if err := json.Unmarshal(b, &ret); err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestNotificationQueueSurvivesRestart(t *testing.T) {
+	receiver := &webhookReceiver{down: true}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	dest := NotifyDestination{Name: "pager", URL: srv.URL, Policy: NOTIFY_EDGE}
+	file := filepath.Join(t.TempDir(), "queue.json")
+	ctx := context.Background()
+
+	// The notification is saved as soon as it's queued, before any attempt
+	before := newWebhookNotifier([]NotifyDestination{dest}, file, time.Hour, 0, discardLog)
+	before.enqueue(dest, Notification{Title: "node down", Node: "worker1", Time: time.Now()})
+	saved := queuedIn(t, file)
+	if len(saved) != 1 || saved[0].Attempts != 0 || saved[0].Notification.ID == "" || saved[0].Destination != "pager" {
+		t.Fatalf("queued %+v", saved)
+	}
+	id := saved[0].Notification.ID
+
+	// The receiver is away for the first attempt, which is saved with its
+	// retry time
+	if wait := before.deliverDue(ctx, time.Now()); wait <= 0 || wait > notifyMinBackoff {
+		t.Errorf("next attempt in %s, want within %s", wait, notifyMinBackoff)
+	}
+	saved = queuedIn(t, file)
+	if len(saved) != 1 || saved[0].Attempts != 1 || !saved[0].NextAttempt.After(time.Now()) {
+		t.Fatalf("after a failed attempt queued %+v", saved)
+	}
+
+	// labwatch restarts and the receiver comes back. The retry resumes
+	// where it left off rather than being lost or starting over.
+	receiver.setDown(false)
+	after := newWebhookNotifier([]NotifyDestination{dest}, file, time.Hour, 0, discardLog)
+	if after.pending() != 1 {
+		t.Fatalf("restored %d pending notifications", after.pending())
+	}
+	if after.deliverDue(ctx, time.Now()); len(receiver.ids()) != 1 {
+		t.Errorf("retried before the saved retry time")
+	}
+	after.deliverDue(ctx, saved[0].NextAttempt)
+	if after.pending() != 0 || len(queuedIn(t, file)) != 0 {
+		t.Errorf("%d still pending after delivery", after.pending())
+	}
+
+	// Every attempt carried the same ID so the receiver can drop duplicates
+	ids := receiver.ids()
+	if len(ids) != 2 || ids[0] != id || ids[1] != id {
+		t.Errorf("receiver got %v, want %s twice", ids, id)
+	}
+
+	// Nothing is left to deliver after another restart
+	if again := newWebhookNotifier([]NotifyDestination{dest}, file, time.Hour, 0, discardLog); again.pending() != 0 {
+		t.Errorf("restored %d delivered notifications", again.pending())
+	}
+}
+
+func TestNotificationQueueRestoredPastMaxAge(t *testing.T) {
+	receiver := &webhookReceiver{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	dest := NotifyDestination{Name: "pager", URL: srv.URL, Policy: NOTIFY_EDGE}
+	file := filepath.Join(t.TempDir(), "queue.json")
+
+	// Queued during a restart that went on longer than the max age
+	before := newWebhookNotifier([]NotifyDestination{dest}, file, time.Hour, 0, discardLog)
+	before.enqueue(dest, Notification{Title: "stale", Time: time.Now().Add(-2 * time.Hour)})
+	before.enqueue(dest, Notification{Title: "fresh", Time: time.Now()})
+	fresh := queuedIn(t, file)[1].Notification.ID
+
+	after := newWebhookNotifier([]NotifyDestination{dest}, file, time.Hour, 0, discardLog)
+	if after.pending() != 2 {
+		t.Fatalf("restored %d pending notifications", after.pending())
+	}
+	// The stale one is dropped rather than delivered late
+	after.deliverDue(context.Background(), time.Now())
+	if ids := receiver.ids(); len(ids) != 1 || ids[0] != fresh {
+		t.Errorf("receiver got %v, want only %s", ids, fresh)
+	}
+	if after.pending() != 0 || len(queuedIn(t, file)) != 0 {
+		t.Errorf("%d still pending", after.pending())
+	}
+}
+
+func TestNotificationQueueUnreadable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queue.json")
+	if err := os.WriteFile(file, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n := newWebhookNotifier([]NotifyDestination{{Name: "pager", URL: "http://127.0.0.1:1/hook"}}, file, time.Hour, 0, discardLog)
+	if n.pending() != 0 {
+		t.Errorf("restored %d notifications from an unreadable queue", n.pending())
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+// Reminders for level destinations are checked this often, so they go out up
+// to this late
+var notifyRemindCheck = time.Duration(30) * time.Second
+
+// NotifyPolicy is when a destination hears about an alert
+type NotifyPolicy string
+
+const NOTIFY_EDGE NotifyPolicy = "edge"
+const NOTIFY_LEVEL NotifyPolicy = "level"
+
+func (NotifyPolicy) JSONSchema() map[string]any {
+	return map[string]any{"type": "string", "enum": []any{string(NOTIFY_EDGE), string(NOTIFY_LEVEL)}}
+}
+
+// LEGACY_DESTINATION names the destination notify-webhook configures
+const LEGACY_DESTINATION = "webhook"
+
+// NotifyDestination is a webhook notifications are delivered to. An edge
+// destination hears about each transition once; a level one is reminded
+// every remind-every while the alert keeps firing, one severity higher per
+// reminder with escalate. notify-resolve also tells it when the alert clears.
+type NotifyDestination struct {
+	Name          string          `yaml:"name"`
+	URL           string          `yaml:"url"`
+	Policy        NotifyPolicy    `yaml:"policy"`
+	RemindEvery   config.Duration `yaml:"remind-every"`
+	Escalate      bool            `yaml:"escalate"`
+	NotifyResolve bool            `yaml:"notify-resolve"`
+}
+
+// notifyDestinations is every configured destination. notify-webhook is an
+// edge destination told about resolutions, which is what it always got for
+// the lab state.
+func notifyDestinations(cfg LabwatchConfig) []NotifyDestination {
+	ret := []NotifyDestination{}
+	if cfg.NotifyWebhook != "" {
+		ret = append(ret, NotifyDestination{Name: LEGACY_DESTINATION, URL: cfg.NotifyWebhook, Policy: NOTIFY_EDGE, NotifyResolve: true})
+	}
+	for _, d := range cfg.NotifyDestinations {
+		if d.Policy == "" {
+			d.Policy = NOTIFY_EDGE
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+func validateNotifyDestinations(cfg LabwatchConfig) error {
+	names := map[string]bool{}
+	for i, d := range notifyDestinations(cfg) {
+		if d.Name == "" {
+			return fmt.Errorf("destination %d: name is required", i)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("destination %d: duplicate name %q", i, d.Name)
+		}
+		names[d.Name] = true
+		if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("destination %s: invalid url %q, must be http or https", d.Name, d.URL)
+		}
+		switch d.Policy {
+		case NOTIFY_EDGE:
+			if d.RemindEvery != 0 || d.Escalate {
+				return fmt.Errorf("destination %s: remind-every and escalate need policy level", d.Name)
+			}
+		case NOTIFY_LEVEL:
+			if d.RemindEvery <= 0 {
+				return fmt.Errorf("destination %s: remind-every must be positive with policy level", d.Name)
+			}
+		default:
+			return fmt.Errorf("destination %s: invalid policy %q, must be one of edge|level", d.Name, d.Policy)
+		}
+	}
+	return nil
+}
+
+// AlertState is a firing alert: the notification that last reported it and
+// when each destination was last told, which is saved with the runtime state
+// so reminders keep their cadence over a restart
+type AlertState struct {
+	Note      Notification         `json:"note"`
+	Notified  map[string]time.Time `json:"notified,omitempty"`
+	Reminders map[string]int       `json:"reminders,omitempty"`
+}
+
+// delivery is a notification bound for one destination
+type delivery struct {
+	dest NotifyDestination
+	note Notification
+}
+
+// alertTracker routes notifications to destinations by their policy.
+// Notifications with an Alert key are tracked while they fire; each one from
+// the source is a transition every destination hears about, and the resolve
+// clearing it stops any reminders at once. Those without a key are one-offs
+// delivered to everyone.
+type alertTracker struct {
+	destinations []NotifyDestination
+	lock         sync.Mutex
+	firing       map[string]*AlertState
+}
+
+func newAlertTracker(destinations []NotifyDestination) *alertTracker {
+	return &alertTracker{destinations: destinations, firing: map[string]*AlertState{}}
+}
+
+func (t *alertTracker) all(note Notification) []delivery {
+	ret := []delivery{}
+	for _, d := range t.destinations {
+		ret = append(ret, delivery{dest: d, note: note})
+	}
+	return ret
+}
+
+// observe records note and returns who it goes to. A suppressed note, one
+// muted or silenced, still updates what is firing but goes to nobody, and
+// the destinations it would have reached don't count as told.
+func (t *alertTracker) observe(note Notification, suppressed bool, now time.Time) []delivery {
+	if note.Alert == "" {
+		if suppressed {
+			return nil
+		}
+		return t.all(note)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if note.Resolved {
+		a, ok := t.firing[note.Alert]
+		delete(t.firing, note.Alert)
+		if !ok || suppressed {
+			return nil
+		}
+		ret := []delivery{}
+		for _, d := range t.destinations {
+			if _, told := a.Notified[d.Name]; told && d.NotifyResolve {
+				ret = append(ret, delivery{dest: d, note: note})
+			}
+		}
+		return ret
+	}
+
+	a := &AlertState{Note: note, Notified: map[string]time.Time{}, Reminders: map[string]int{}}
+	t.firing[note.Alert] = a
+	if suppressed {
+		return nil
+	}
+	for _, d := range t.destinations {
+		a.Notified[d.Name] = now
+	}
+	return t.all(note)
+}
+
+// remind returns the reminders due to level destinations. An alert that is
+// suppressed isn't reminded about; once that lifts, a destination never told
+// about it is reminded straight away and the others at their usual cadence.
+func (t *alertTracker) remind(suppressed func(Notification) bool, now time.Time) []delivery {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := []delivery{}
+	for _, a := range t.firing {
+		if suppressed(a.Note) {
+			continue
+		}
+		for _, d := range t.destinations {
+			if d.Policy != NOTIFY_LEVEL {
+				continue
+			}
+			if last, ok := a.Notified[d.Name]; ok && now.Sub(last) < time.Duration(d.RemindEvery) {
+				continue
+			}
+			a.Notified[d.Name] = now
+			a.Reminders[d.Name]++
+			note := a.Note
+			note.Reminder = a.Reminders[d.Name]
+			note.Time = now
+			if d.Escalate {
+				note.Severity = string(escalate(HealthLevel(note.Severity), note.Reminder))
+			}
+			ret = append(ret, delivery{dest: d, note: note})
+		}
+	}
+	return ret
+}
+
+// escalate raises a severity by steps, stopping at critical
+func escalate(l HealthLevel, steps int) HealthLevel {
+	levels := []HealthLevel{HEALTH_LEVEL_OK, HEALTH_LEVEL_WARN, HEALTH_LEVEL_CRITICAL}
+	return levels[min(l.rank()+steps, len(levels)-1)]
+}
+
+// isFiring reports whether the alert is being tracked as firing
+func (n *webhookNotifier) isFiring(alert string) bool {
+	if n == nil {
+		return false
+	}
+	return n.alerts.isFiring(alert)
+}
+
+func (t *alertTracker) isFiring(alert string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	_, ok := t.firing[alert]
+	return ok
+}
+
+func (n *webhookNotifier) firingAlerts() map[string]AlertState {
+	if n == nil {
+		return nil
+	}
+	return n.alerts.export()
+}
+
+func (n *webhookNotifier) restoreAlerts(saved map[string]AlertState) {
+	if n == nil {
+		return
+	}
+	n.alerts.restore(saved)
+}
+
+func (t *alertTracker) export() map[string]AlertState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := make(map[string]AlertState, len(t.firing))
+	for k, a := range t.firing {
+		ret[k] = AlertState{Note: a.Note, Notified: maps.Clone(a.Notified), Reminders: maps.Clone(a.Reminders)}
+	}
+	return ret
+}
+
+// restore resumes the saved alerts. Destinations removed from the config are
+// forgotten and new ones treated as never told.
+func (t *alertTracker) restore(saved map[string]AlertState) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for k, a := range saved {
+		s := &AlertState{Note: a.Note, Notified: map[string]time.Time{}, Reminders: map[string]int{}}
+		for _, d := range t.destinations {
+			if when, ok := a.Notified[d.Name]; ok {
+				s.Notified[d.Name] = when
+				s.Reminders[d.Name] = a.Reminders[d.Name]
+			}
+		}
+		t.firing[k] = s
+	}
+}
+
+// runReminders sends the reminders for level destinations until ctx is done
+func (n *webhookNotifier) runReminders(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(notifyRemindCheck):
+		}
+		for _, d := range n.alerts.remind(n.suppressed, clk.Now()) {
+			n.log.Debug("reminding about firing alert", "alert", d.note.Alert, "destination", d.dest.Name, "reminder", d.note.Reminder)
+			n.deliver([]delivery{d})
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+var policyDestinations = []NotifyDestination{
+	{Name: "phone", URL: "http://127.0.0.1:1/phone", Policy: NOTIFY_EDGE},
+	{Name: "room", URL: "http://127.0.0.1:1/room", Policy: NOTIFY_LEVEL, RemindEvery: config.Duration(5 * time.Minute), Escalate: true, NotifyResolve: true},
+	{Name: "log", URL: "http://127.0.0.1:1/log", Policy: NOTIFY_LEVEL, RemindEvery: config.Duration(2 * time.Minute)},
+}
+
+var nodeDown = Notification{Title: "node down", Node: "worker1", Severity: string(HEALTH_LEVEL_WARN), Alert: "node/worker1/ready"}
+
+func destinationNames(deliveries []delivery) []string {
+	ret := []string{}
+	for _, d := range deliveries {
+		ret = append(ret, d.dest.Name)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestReminderCadence(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newAlertTracker(policyDestinations)
+	never := func(Notification) bool { return false }
+
+	// The transition itself goes everywhere
+	if got := destinationNames(tracker.observe(nodeDown, false, start)); !slices.Equal(got, []string{"log", "phone", "room"}) {
+		t.Fatalf("firing went to %v", got)
+	}
+
+	// Reminders are checked every notifyRemindCheck for twelve minutes
+	reminded := map[string][]time.Duration{}
+	severities := map[string][]string{}
+	for at := notifyRemindCheck; at <= 12*time.Minute; at += notifyRemindCheck {
+		for _, d := range tracker.remind(never, start.Add(at)) {
+			reminded[d.dest.Name] = append(reminded[d.dest.Name], at)
+			severities[d.dest.Name] = append(severities[d.dest.Name], d.note.Severity)
+			if d.note.Reminder != len(reminded[d.dest.Name]) || !d.note.Time.Equal(start.Add(at)) {
+				t.Errorf("reminder %d to %s numbered %d at %s", len(reminded[d.dest.Name]), d.dest.Name, d.note.Reminder, d.note.Time)
+			}
+		}
+	}
+	want := map[string][]time.Duration{
+		"room": {5 * time.Minute, 10 * time.Minute},
+		"log":  {2 * time.Minute, 4 * time.Minute, 6 * time.Minute, 8 * time.Minute, 10 * time.Minute, 12 * time.Minute},
+	}
+	if !maps.EqualFunc(reminded, want, slices.Equal) {
+		t.Errorf("reminded %v, want %v", reminded, want)
+	}
+	// Only room escalates, one level per reminder up to critical
+	if got := severities["room"]; !slices.Equal(got, []string{string(HEALTH_LEVEL_CRITICAL), string(HEALTH_LEVEL_CRITICAL)}) {
+		t.Errorf("room reminded at %v", got)
+	}
+	if got := severities["log"]; got[0] != nodeDown.Severity || got[5] != nodeDown.Severity {
+		t.Errorf("log reminded at %v", got)
+	}
+
+	// Resolving reaches those told about it that want resolutions, and stops
+	// reminders at once even where one was about to fall due
+	resolved := nodeDown
+	resolved.Resolved = true
+	now := start.Add(12*time.Minute + 30*time.Second)
+	if got := destinationNames(tracker.observe(resolved, false, now)); !slices.Equal(got, []string{"room"}) {
+		t.Errorf("resolution went to %v", got)
+	}
+	if tracker.isFiring(nodeDown.Alert) {
+		t.Error("still firing after the resolution")
+	}
+	for at := notifyRemindCheck; at <= time.Hour; at += notifyRemindCheck {
+		if got := tracker.remind(never, now.Add(at)); len(got) != 0 {
+			t.Fatalf("reminded %v after the resolution", destinationNames(got))
+		}
+	}
+}
+
+func TestRemindersWhileSuppressed(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newAlertTracker(policyDestinations)
+	silenced := true
+	suppressed := func(Notification) bool { return silenced }
+
+	// Fired during a silence, so nobody has been told
+	if got := tracker.observe(nodeDown, true, start); len(got) != 0 {
+		t.Fatalf("suppressed firing went to %v", destinationNames(got))
+	}
+	if got := tracker.remind(suppressed, start.Add(time.Hour)); len(got) != 0 {
+		t.Fatalf("reminded %v during the silence", destinationNames(got))
+	}
+
+	// Once lifted, the level destinations are told straight away and then
+	// at their cadence
+	silenced = false
+	lifted := start.Add(time.Hour)
+	if got := destinationNames(tracker.remind(suppressed, lifted)); !slices.Equal(got, []string{"log", "room"}) {
+		t.Fatalf("reminded %v once the silence lifted", got)
+	}
+	if got := tracker.remind(suppressed, lifted.Add(time.Minute)); len(got) != 0 {
+		t.Errorf("reminded %v a minute later", destinationNames(got))
+	}
+	if got := destinationNames(tracker.remind(suppressed, lifted.Add(2*time.Minute))); !slices.Equal(got, []string{"log"}) {
+		t.Errorf("reminded %v two minutes later", got)
+	}
+
+	// Resolutions go only to destinations that heard about the alert
+	tracker.observe(nodeDown, true, lifted)
+	resolved := nodeDown
+	resolved.Resolved = true
+	if got := tracker.observe(resolved, false, lifted); len(got) != 0 {
+		t.Errorf("resolution of an alert fired in silence went to %v", destinationNames(got))
+	}
+}
+
+func TestRemindersSurviveRestart(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	before := newAlertTracker(policyDestinations)
+	before.observe(nodeDown, false, start)
+	before.remind(func(Notification) bool { return false }, start.Add(5*time.Minute))
+
+	// The restarted instance dropped the log destination
+	after := newAlertTracker(policyDestinations[:2])
+	after.restore(before.export())
+	saved := after.export()[nodeDown.Alert]
+	if _, ok := saved.Notified["log"]; ok {
+		t.Error("kept a removed destination")
+	}
+	if saved.Reminders["room"] != 1 {
+		t.Errorf("room reminder count %d", saved.Reminders["room"])
+	}
+	// The cadence carries on from the last reminder before the restart
+	if got := after.remind(func(Notification) bool { return false }, start.Add(9*time.Minute)); len(got) != 0 {
+		t.Errorf("reminded %v early", destinationNames(got))
+	}
+	got := after.remind(func(Notification) bool { return false }, start.Add(10*time.Minute))
+	if len(got) != 1 || got[0].dest.Name != "room" || got[0].note.Reminder != 2 {
+		t.Errorf("reminded %+v, want room's second reminder", got)
+	}
+}
+
+// runReminders waits on the package clock, so a fake clock drives the real
+// loop and the reminders land in the delivery queue
+func TestRunReminders(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.NotifyDestinations = []NotifyDestination{{Name: "room", URL: "http://127.0.0.1:1/room", Policy: NOTIFY_LEVEL, RemindEvery: config.Duration(time.Minute), NotifyResolve: true}}
+	fake := useTestGlobals(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		notifier.runReminders(ctx)
+		close(stopped)
+	}()
+	// The globals are put back once the loop has stopped using them
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	waitPending := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for notifier.pending() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("%d notifications queued, want %d", notifier.pending(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// idle waits until the loop is waiting for the next reminder check
+	idle := func() {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for fake.Waiters() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("reminder loop never waited on the clock")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	step := func() {
+		t.Helper()
+		idle()
+		fake.Advance(notifyRemindCheck)
+	}
+
+	notifier.send(nodeDown)
+	waitPending(1)
+	for range 2 {
+		step()
+	}
+	waitPending(2)
+	for range 2 {
+		step()
+	}
+	waitPending(3)
+
+	resolved := nodeDown
+	resolved.Resolved = true
+	notifier.send(resolved)
+	waitPending(4)
+	for range 6 {
+		step()
+	}
+	idle()
+	if n := notifier.pending(); n != 4 {
+		t.Errorf("%d notifications queued after the resolution, want no more reminders", n)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/DRuggeri/labwatch/outputs"
+)
+
+var dispatcher *outputs.Dispatcher[LabStatus]
+var stopOutputs = func() {}
+
+// startOutputs starts every configured output behind the dispatcher
+func startOutputs(cfg LabwatchConfig, log *slog.Logger) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopOutputs = cancel
+	dispatcher = outputs.NewDispatcher[LabStatus](cfg.OutputQueueSize, log)
+
+	if cfg.Broker.URL != "" {
+		if err := dispatcher.Start(ctx, newBrokerPublisher(cfg.Broker, cfg.InstanceName, log)); err != nil {
+			return err
+		}
+	}
+	if cfg.SnapshotFile != "" {
+		if err := dispatcher.Start(ctx, newSnapshotWriter(cfg.SnapshotFile, log)); err != nil {
+			return err
+		}
+	}
+	if cfg.StatsD.Address != "" {
+		if err := dispatcher.Start(ctx, newStatsDPublisher(cfg.StatsD, log)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
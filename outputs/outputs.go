@@ -0,0 +1,137 @@
+package outputs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// Output is a sink for status updates and events. S is the status type so
+// outputs can live beside it without this package depending on it.
+//
+// HandleStatus and HandleEvent are called from a single goroutine per output
+// and may block; the dispatcher buffers in front of them.
+type Output[S any] interface {
+	Name() string
+	Start(ctx context.Context) error
+	HandleStatus(status S)
+	HandleEvent(event loki.LogEvent)
+	Healthy() bool
+}
+
+type Health struct {
+	Healthy bool  `json:"healthy"`
+	Queued  int   `json:"queued"`
+	Dropped int64 `json:"dropped"`
+}
+
+type item[S any] struct {
+	status *S
+	event  *loki.LogEvent
+}
+
+type queuedOutput[S any] struct {
+	output  Output[S]
+	queue   chan item[S]
+	dropped atomic.Int64
+}
+
+// Dispatcher fans broadcasts out to every output through its own bounded
+// queue. A full queue drops its oldest entry, so a slow or stuck output only
+// loses its own backlog and never holds up the caller or other outputs.
+type Dispatcher[S any] struct {
+	queueSize int
+	outputs   []*queuedOutput[S]
+	log       *slog.Logger
+	lock      sync.Mutex
+}
+
+func NewDispatcher[S any](queueSize int, log *slog.Logger) *Dispatcher[S] {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &Dispatcher[S]{queueSize: queueSize, log: log.With("operation", "Dispatcher")}
+}
+
+// Start starts the output and begins draining its queue until ctx is done
+func (d *Dispatcher[S]) Start(ctx context.Context, o Output[S]) error {
+	if err := o.Start(ctx); err != nil {
+		return err
+	}
+	q := &queuedOutput[S]{output: o, queue: make(chan item[S], d.queueSize)}
+
+	d.lock.Lock()
+	d.outputs = append(d.outputs, q)
+	d.lock.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case i := <-q.queue:
+				if i.status != nil {
+					o.HandleStatus(*i.status)
+				} else {
+					o.HandleEvent(*i.event)
+				}
+			}
+		}
+	}()
+	d.log.Info("started output", "output", o.Name())
+	return nil
+}
+
+func (d *Dispatcher[S]) Status(s S) {
+	d.dispatch(item[S]{status: &s})
+}
+
+func (d *Dispatcher[S]) Event(e loki.LogEvent) {
+	d.dispatch(item[S]{event: &e})
+}
+
+func (d *Dispatcher[S]) dispatch(i item[S]) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, q := range d.outputs {
+		for {
+			select {
+			case q.queue <- i:
+			default:
+				select {
+				case <-q.queue:
+					if q.dropped.Add(1) == 1 {
+						d.log.Warn("output queue full, dropping oldest", "output", q.output.Name())
+					}
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// Health reports each output's own health alongside its queue
+func (d *Dispatcher[S]) Health() map[string]Health {
+	if d == nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ret := make(map[string]Health, len(d.outputs))
+	for _, q := range d.outputs {
+		ret[q.output.Name()] = Health{
+			Healthy: q.output.Healthy(),
+			Queued:  len(q.queue),
+			Dropped: q.dropped.Load(),
+		}
+	}
+	return ret
+}
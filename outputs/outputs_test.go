@@ -0,0 +1,136 @@
+package outputs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// fakeOutput records what it handles. With block set every call waits on it
+// first, standing in for an output whose backend hangs.
+type fakeOutput struct {
+	name     string
+	block    chan struct{}
+	statuses []int
+	events   []string
+	lock     sync.Mutex
+}
+
+func (o *fakeOutput) Name() string                    { return o.name }
+func (o *fakeOutput) Start(ctx context.Context) error { return nil }
+func (o *fakeOutput) Healthy() bool                   { return o.block == nil }
+
+func (o *fakeOutput) HandleStatus(s int) {
+	if o.block != nil {
+		<-o.block
+	}
+	o.lock.Lock()
+	o.statuses = append(o.statuses, s)
+	o.lock.Unlock()
+}
+
+func (o *fakeOutput) HandleEvent(e loki.LogEvent) {
+	if o.block != nil {
+		<-o.block
+	}
+	o.lock.Lock()
+	o.events = append(o.events, e.Message)
+	o.lock.Unlock()
+}
+
+func (o *fakeOutput) handled() ([]int, []string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return append([]int{}, o.statuses...), append([]string{}, o.events...)
+}
+
+func TestSlowOutputDoesNotBlockOthers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := NewDispatcher[int](4, slog.New(slog.DiscardHandler))
+	slow := &fakeOutput{name: "slow", block: make(chan struct{})}
+	fast := &fakeOutput{name: "fast"}
+	for _, o := range []*fakeOutput{slow, fast} {
+		if err := d.Start(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// handled polls until the output has handled n statuses and events
+	handled := func(o *fakeOutput, n int) bool {
+		deadline := time.Now().Add(time.Second)
+		for {
+			statuses, events := o.handled()
+			if len(statuses)+len(events) >= n {
+				return true
+			}
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Every broadcast reaches the fast output while the slow one is stuck,
+	// and the caller never waits on the slow one
+	sent := make(chan bool)
+	go func() {
+		for i := range 100 {
+			d.Status(i)
+			if !handled(fast, i+1) {
+				sent <- false
+				return
+			}
+		}
+		d.Event(loki.LogEvent{Message: "last"})
+		sent <- true
+	}()
+	select {
+	case ok := <-sent:
+		if !ok {
+			t.Fatal("fast output fell behind while the slow one was stuck")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatching blocked on the slow output")
+	}
+	if !handled(fast, 101) {
+		t.Fatal("fast output never handled the event")
+	}
+	if statuses, _ := fast.handled(); statuses[99] != 99 {
+		t.Errorf("fast output handled %v", statuses)
+	}
+
+	health := d.Health()
+	if h := health["slow"]; h.Healthy || h.Queued != 4 || h.Dropped == 0 {
+		t.Errorf("slow output health %+v, want a full queue with drops", h)
+	}
+	if h := health["fast"]; !h.Healthy || h.Dropped != 0 {
+		t.Errorf("fast output health %+v, want healthy without drops", h)
+	}
+
+	// Once unstuck the slow output only sees what was left in its queue, the
+	// newest broadcasts
+	close(slow.block)
+	if !handled(slow, 5) {
+		t.Fatal("slow output never drained its queue")
+	}
+	statuses, _ := slow.handled()
+	// One status was already in hand when it hung, then the three newest
+	// that fit in the queue beside the event
+	if len(statuses) != 4 || statuses[0] != 0 || statuses[3] != 99 {
+		t.Errorf("slow output handled %v", statuses)
+	}
+}
+
+func TestDispatcherNil(t *testing.T) {
+	var d *Dispatcher[int]
+	d.Status(1)
+	d.Event(loki.LogEvent{})
+	if d.Health() != nil {
+		t.Error("a nil dispatcher reports health")
+	}
+}
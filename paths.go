@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DEFAULT_CONFIG_FILE is read when --config isn't given and it exists, which
+// is where the container image keeps its config
+const DEFAULT_CONFIG_FILE = "/etc/labwatch/labwatch.yaml"
+
+const DEFAULT_LISTEN_ADDRESS = ":8080"
+
+// defaultConfigFile is the config file to read when none was given
+func defaultConfigFile() string {
+	if _, err := os.Stat(DEFAULT_CONFIG_FILE); err == nil {
+		return DEFAULT_CONFIG_FILE
+	}
+	return ""
+}
+
+// finishConfig lays the address and data-dir flags, or their LABWATCH_
+// environment variables, over the config file, then resolves the files
+// labwatch writes against data-dir. It runs before validation so both
+// config loaders and reload see the same result.
+func finishConfig(cfg LabwatchConfig) LabwatchConfig {
+	for _, o := range []struct {
+		flag  *string
+		value *string
+	}{
+		{listenAddressOverride, &cfg.ListenAddress},
+		{adminAddressOverride, &cfg.AdminAddress},
+		{grpcHealthAddressOverride, &cfg.GRPCHealthAddress},
+		{dataDirOverride, &cfg.DataDir},
+	} {
+		if o.flag != nil && *o.flag != "" {
+			*o.value = *o.flag
+		}
+	}
+
+	if cfg.DataDir == "" {
+		return cfg
+	}
+	for _, p := range dataPaths(&cfg) {
+		if *p.path != "" && !filepath.IsAbs(*p.path) {
+			*p.path = filepath.Join(cfg.DataDir, *p.path)
+		}
+	}
+	return cfg
+}
+
+type dataPath struct {
+	key  string
+	path *string
+}
+
+// dataPaths are the files and directories labwatch writes to
+func dataPaths(cfg *LabwatchConfig) []dataPath {
+	return []dataPath{
+		{"state-file", &cfg.StateFile},
+		{"snapshot-file", &cfg.SnapshotFile},
+		{"notify-queue-file", &cfg.NotifyQueueFile},
+		{"etcd-snapshot directory", &cfg.EtcdSnapshot.Directory},
+	}
+}
+
+// validateDataPaths keeps every write inside data-dir, when one is set, so
+// labwatch runs with the rest of the filesystem read-only
+func validateDataPaths(cfg LabwatchConfig) error {
+	if cfg.DataDir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(cfg.DataDir) {
+		return fmt.Errorf("invalid data-dir: must be an absolute path")
+	}
+	for _, p := range dataPaths(&cfg) {
+		if *p.path == "" {
+			continue
+		}
+		rel, err := filepath.Rel(cfg.DataDir, *p.path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid %s: %s is outside data-dir %s", p.key, *p.path, cfg.DataDir)
+		}
+	}
+	return nil
+}
+
+// localURL is the URL labwatch reaches its own listener on at path, over
+// loopback when the listener is on every interface
+func localURL(addr string, path string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr + path
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + path
+}
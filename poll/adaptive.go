@@ -0,0 +1,116 @@
+// Package poll paces repeated polls of a target, backing off while it stays
+// healthy and unchanged and snapping back as soon as anything happens
+package poll
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+// Config bounds an adaptive schedule. Once Steady consecutive polls have
+// found nothing changed, each quiet poll doubles the interval until it
+// reaches Max. A Max at or below Min polls at a fixed Min.
+type Config struct {
+	Min    time.Duration
+	Max    time.Duration
+	Steady int
+}
+
+// Status is a snapshot of a schedule for debug output
+type Status struct {
+	Interval  time.Duration `json:"interval_ns"`
+	Unchanged int           `json:"unchanged_polls"`
+}
+
+// Adaptive is the schedule for one target. It is safe for concurrent use so
+// an event stream can Reset it while the polling goroutine Waits.
+type Adaptive struct {
+	cfg       Config
+	clock     clock.Clock
+	interval  time.Duration
+	unchanged int
+	kick      chan struct{}
+	lock      sync.Mutex
+}
+
+func NewAdaptive(cfg Config, c clock.Clock) *Adaptive {
+	return &Adaptive{
+		cfg:      cfg,
+		clock:    clock.OrReal(c),
+		interval: cfg.Min,
+		kick:     make(chan struct{}, 1),
+	}
+}
+
+// Configure replaces the bounds and starts the schedule over at the minimum
+func (a *Adaptive) Configure(cfg Config) {
+	a.lock.Lock()
+	a.cfg = cfg
+	a.lock.Unlock()
+	a.Reset()
+}
+
+// Observe records the outcome of a poll. A change, or a poll that failed or
+// found the target unhealthy, snaps the interval back to the minimum.
+func (a *Adaptive) Observe(changed bool) time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if changed {
+		a.interval, a.unchanged = a.cfg.Min, 0
+		return a.interval
+	}
+
+	a.unchanged++
+	if a.unchanged >= a.cfg.Steady && a.interval < a.cfg.Max {
+		a.interval = min(2*a.interval, a.cfg.Max)
+	}
+	return a.interval
+}
+
+// Reset drops back to the minimum interval, shortening a pending Wait to
+// match, for when something outside the polls says the target is changing
+func (a *Adaptive) Reset() {
+	a.lock.Lock()
+	a.interval, a.unchanged = a.cfg.Min, 0
+	a.lock.Unlock()
+
+	select {
+	case a.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Adaptive) Interval() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.interval
+}
+
+func (a *Adaptive) Status() Status {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return Status{Interval: a.interval, Unchanged: a.unchanged}
+}
+
+// Wait blocks until the next poll is due. It reports false once ctx is done.
+func (a *Adaptive) Wait(ctx context.Context) bool {
+	start := a.clock.Now()
+	due := a.clock.After(a.Interval())
+	for {
+		select {
+		case <-due:
+			return true
+		case <-a.kick:
+			remaining := a.Interval() - a.clock.Now().Sub(start)
+			if remaining <= 0 {
+				return true
+			}
+			due = a.clock.After(remaining)
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
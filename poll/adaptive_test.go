@@ -0,0 +1,186 @@
+package poll
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+var epoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var backoff = Config{Min: time.Second, Max: 8 * time.Second, Steady: 3}
+
+// waiting blocks until n timers are pending on the fake clock, which is how
+// far a Wait gets before it blocks
+func waiting(t *testing.T, f *clock.Fake, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for f.Waiters() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want %d", f.Waiters(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// startWait runs Wait in the background, handing back its result
+func startWait(ctx context.Context, a *Adaptive) <-chan bool {
+	done := make(chan bool, 1)
+	go func() { done <- a.Wait(ctx) }()
+	return done
+}
+
+func result(done <-chan bool) (bool, bool) {
+	select {
+	case ok := <-done:
+		return ok, true
+	case <-time.After(10 * time.Millisecond):
+		return false, false
+	}
+}
+
+func TestBackoffAndSnapBack(t *testing.T) {
+	a := NewAdaptive(backoff, clock.NewFake(epoch))
+	if a.Interval() != time.Second {
+		t.Fatalf("starts at %s", a.Interval())
+	}
+
+	// Steady quiet polls at the minimum, then doubling up to the maximum
+	want := []time.Duration{1, 1, 2, 4, 8, 8, 8}
+	for i, w := range want {
+		if got := a.Observe(false); got != w*time.Second {
+			t.Errorf("quiet poll %d: interval %s, want %s", i+1, got, w*time.Second)
+		}
+	}
+	if s := a.Status(); s.Interval != 8*time.Second || s.Unchanged != len(want) {
+		t.Errorf("status %+v", s)
+	}
+
+	// A change goes straight back to the minimum and the count starts over
+	if got := a.Observe(true); got != time.Second {
+		t.Errorf("interval %s after a change", got)
+	}
+	if got := a.Observe(false); got != time.Second {
+		t.Errorf("interval %s after one quiet poll following a change", got)
+	}
+}
+
+func TestFixedInterval(t *testing.T) {
+	a := NewAdaptive(Config{Min: 5 * time.Second, Steady: 1}, clock.NewFake(epoch))
+	for range 5 {
+		if got := a.Observe(false); got != 5*time.Second {
+			t.Fatalf("interval %s without a max", got)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	f := clock.NewFake(epoch)
+	a := NewAdaptive(backoff, f)
+	for range 4 {
+		a.Observe(false)
+	}
+	if a.Interval() != 4*time.Second {
+		t.Fatalf("backed off to %s", a.Interval())
+	}
+
+	done := startWait(context.Background(), a)
+	waiting(t, f, 1)
+	f.Advance(4*time.Second - time.Millisecond)
+	if _, ok := result(done); ok {
+		t.Fatal("returned before the interval")
+	}
+	f.Advance(time.Millisecond)
+	if ok, returned := result(done); !returned || !ok {
+		t.Fatalf("returned %t, %t at the interval", returned, ok)
+	}
+}
+
+func TestResetShortensWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		// after is how much longer the Wait should take after the Reset
+		after time.Duration
+	}{
+		{name: "minimum already passed", elapsed: 3 * time.Second},
+		{name: "part way to the minimum", elapsed: 400 * time.Millisecond, after: 600 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := clock.NewFake(epoch)
+			a := NewAdaptive(backoff, f)
+			for range 5 {
+				a.Observe(false)
+			}
+
+			done := startWait(context.Background(), a)
+			waiting(t, f, 1)
+			f.Advance(tt.elapsed)
+			a.Reset()
+			if a.Interval() != time.Second || a.Status().Unchanged != 0 {
+				t.Errorf("reset to %+v", a.Status())
+			}
+			if tt.after > 0 {
+				// The shortened wait is pending beside the original
+				waiting(t, f, 2)
+				if _, ok := result(done); ok {
+					t.Fatal("returned before the minimum")
+				}
+				f.Advance(tt.after)
+			}
+			if ok, returned := result(done); !returned || !ok {
+				t.Fatalf("returned %t, %t after the reset", returned, ok)
+			}
+		})
+	}
+}
+
+func TestResetWithoutWait(t *testing.T) {
+	f := clock.NewFake(epoch)
+	a := NewAdaptive(backoff, f)
+	for range 5 {
+		a.Observe(false)
+	}
+	// A Reset while nothing waits carries over to the next Wait without
+	// cutting it short of the minimum
+	a.Reset()
+	a.Reset()
+	done := startWait(context.Background(), a)
+	waiting(t, f, 1)
+	if _, ok := result(done); ok {
+		t.Fatal("returned at once")
+	}
+	f.Advance(time.Second)
+	if ok, returned := result(done); !returned || !ok {
+		t.Fatalf("returned %t, %t at the minimum", returned, ok)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	a := NewAdaptive(backoff, clock.NewFake(epoch))
+	for range 5 {
+		a.Observe(false)
+	}
+	a.Configure(Config{Min: 2 * time.Second, Max: 4 * time.Second, Steady: 1})
+	if a.Interval() != 2*time.Second {
+		t.Errorf("interval %s after configure", a.Interval())
+	}
+	if got := a.Observe(false); got != 4*time.Second {
+		t.Errorf("interval %s, want the new max", got)
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	f := clock.NewFake(epoch)
+	a := NewAdaptive(backoff, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startWait(ctx, a)
+	waiting(t, f, 1)
+	cancel()
+	if ok, returned := result(done); !returned || ok {
+		t.Fatalf("returned %t, %t once cancelled", returned, ok)
+	}
+}
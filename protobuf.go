@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/outputs"
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const ENCODING_JSON = "json"
+const ENCODING_PROTOBUF = "protobuf"
+
+// statusEncoding reads ?encoding, defaulting to JSON
+func statusEncoding(r *http.Request) (string, error) {
+	switch e := r.URL.Query().Get("encoding"); e {
+	case "", ENCODING_JSON:
+		return ENCODING_JSON, nil
+	case ENCODING_PROTOBUF:
+		return ENCODING_PROTOBUF, nil
+	default:
+		return "", fmt.Errorf("invalid encoding %q: must be one of json|protobuf", e)
+	}
+}
+
+// writeStatus sends a status to a websocket client. Protobuf clients get a
+// bare length-prefixed message with no envelope.
+func writeStatus(conn *websocket.Conn, framer *server.Framer, encoding string, s LabStatus) error {
+	if encoding == ENCODING_PROTOBUF {
+		return conn.WriteMessage(websocket.BinaryMessage, encodeStatusProto(s))
+	}
+	return writeMessage(conn, framer, server.TYPE_STATUS, s)
+}
+
+// protoMarshal sorts map entries so a status always encodes the same, which
+// its ETag relies on
+var protoMarshal = proto.MarshalOptions{Deterministic: true}
+
+// encodeStatusProto encodes the status as the server.LabStatus generated
+// from server/labstatus.proto, preceded by its length
+func encodeStatusProto(s LabStatus) []byte {
+	m := statusProto(s)
+	b, err := protoMarshal.Marshal(m)
+	if err != nil {
+		// proto3 refuses strings that aren't UTF-8, such as a log line cut
+		// mid rune. JSON replaces the bad bytes, so do the same here.
+		validUTF8(m.ProtoReflect())
+		b, _ = protoMarshal.Marshal(m)
+	}
+	return protowire.AppendBytes(nil, b)
+}
+
+func statusProto(s LabStatus) *server.LabStatus {
+	ret := &server.LabStatus{
+		Labwatch: &server.LabwatchStatus{State: string(s.Labwatch.State), EstimatedDowntime: s.Labwatch.EstimatedDowntime},
+		Healthy:  s.Healthy,
+		Summary: &server.Summary{
+			State:      string(s.Summary.State),
+			Counts:     protoMap(s.Summary.Counts, func(v int) int64 { return int64(v) }),
+			Suppressed: int64(s.Summary.Suppressed),
+			Reasons:    s.Summary.Reasons,
+		},
+		Maintenance:      s.Maintenance,
+		MaintenanceUntil: protoTime(s.MaintenanceUntil),
+		Sections:         protoMap(s.Sections, sectionProto),
+		Talos:            protoMap(s.Talos, nodeProto),
+		Logs:             logStatsProto(s.Logs),
+		Outputs:          protoMap(s.Outputs, outputProto),
+		TalosSummary:     talosSummaryProto(s.TalosSummary),
+		ConfigError:      s.ConfigError,
+		TalosEndpoints:   protoList(s.TalosEndpoints, endpointProto),
+		ConfigHash:       s.ConfigHash,
+		Self:             selfProto(s.Self),
+		FromCache:        s.FromCache,
+		CachedAt:         protoTime(s.CachedAt),
+		Churn:            churnProto(s.Churn),
+		Incidents:        protoList(s.Incidents, incidentProto),
+	}
+	if s.EtcdSnapshot != nil {
+		ret.EtcdSnapshot = etcdSnapshotProto(*s.EtcdSnapshot)
+	}
+	return ret
+}
+
+func incidentProto(i Incident) *server.Incident {
+	return &server.Incident{
+		Id:       int64(i.ID),
+		Start:    protoTime(&i.Start),
+		End:      protoTime(i.End),
+		Entities: i.Entities,
+		Checks:   i.Checks,
+		Peak:     string(i.Peak),
+	}
+}
+
+func churnProto(c ChurnStatus) *server.ChurnStatus {
+	return &server.ChurnStatus{
+		Window:    c.Window,
+		Threshold: c.Threshold,
+		Watchers:  protoMap(c.Watchers, func(v int) int64 { return int64(v) }),
+		Entities: protoMap(c.Entities, func(e EntityChurn) *server.EntityChurn {
+			return &server.EntityChurn{Watcher: e.Watcher, Transitions: int64(e.Transitions), PerHour: e.PerHour, Churning: e.Churning}
+		}),
+	}
+}
+
+func talosSummaryProto(t TalosSummary) *server.TalosSummary {
+	return &server.TalosSummary{
+		Total:               int64(t.Total),
+		Healthy:             int64(t.Healthy),
+		ControlPlane:        int64(t.ControlPlane),
+		ControlPlaneHealthy: int64(t.ControlPlaneHealthy),
+		EtcdHealthy:         t.EtcdHealthy,
+		Unreachable:         t.Unreachable,
+		Versions:            t.Versions,
+		VersionsConsistent:  t.VersionsConsistent,
+		Worst:               string(t.Worst),
+		EndpointsDown:       t.EndpointsDown,
+	}
+}
+
+func endpointProto(e talos.EndpointStatus) *server.EndpointStatus {
+	return &server.EndpointStatus{
+		Endpoint:  e.Endpoint,
+		Healthy:   e.Healthy,
+		Active:    e.Active,
+		LastUsed:  protoTime(&e.LastUsed),
+		LastError: e.LastError,
+	}
+}
+
+func selfProto(s SelfStatus) *server.SelfStatus {
+	return &server.SelfStatus{
+		Hostname:             s.Hostname,
+		LoadAverage:          s.LoadAverage,
+		MemoryTotalBytes:     int64(s.MemoryTotal),
+		MemoryAvailableBytes: int64(s.MemoryAvailable),
+		Disks: protoList(s.Disks, func(d DiskUsage) *server.DiskUsage {
+			return &server.DiskUsage{Path: d.Path, TotalBytes: int64(d.TotalBytes), FreeBytes: int64(d.FreeBytes), UsedPercent: d.UsedPercent}
+		}),
+		OpenFds:    int64(s.OpenFDs),
+		FdLimit:    int64(s.FDLimit),
+		Goroutines: int64(s.Goroutines),
+		RssBytes:   int64(s.RSS),
+		Sampled:    protoTime(&s.Sampled),
+	}
+}
+
+func etcdSnapshotProto(s EtcdSnapshotStatus) *server.EtcdSnapshotStatus {
+	return &server.EtcdSnapshotStatus{
+		Directory: s.Directory,
+		Newest:    s.Newest,
+		Taken:     protoTime(s.Taken),
+		SizeBytes: s.SizeBytes,
+		Stale:     s.Stale,
+		Error:     s.Error,
+		Checked:   protoTime(&s.Checked),
+	}
+}
+
+func sectionProto(s SectionStatus) *server.SectionStatus {
+	return &server.SectionStatus{LastUpdated: protoTime(s.LastUpdated), Stale: s.Stale, Degraded: s.Degraded, Cached: s.Cached}
+}
+
+func outputProto(h outputs.Health) *server.OutputHealth {
+	return &server.OutputHealth{Healthy: h.Healthy, Queued: int64(h.Queued), Dropped: h.Dropped}
+}
+
+func nodeProto(n talos.NodeStatus) *server.NodeStatus {
+	ret := &server.NodeStatus{
+		Node:         n.Node,
+		WatcherState: string(n.WatcherState),
+		Stage:        n.Stage,
+		Ready:        n.Ready,
+		Addresses:    n.Addresses,
+		Services: protoMap(n.Services, func(svc talos.ServiceStatus) *server.ServiceStatus {
+			return &server.ServiceStatus{State: svc.State, Message: svc.Message, Healthy: string(svc.Healthy), LastChange: protoTime(&svc.LastChange)}
+		}),
+		SuppressedBy:    n.SuppressedBy,
+		UnmetConditions: n.UnmetConditions,
+		LastUpdated:     protoTime(&n.LastUpdated),
+		Injected:        n.Injected,
+		Version:         n.Version,
+		Metrics:         n.Metrics,
+		Degraded:        n.Degraded,
+		Departed:        n.Departed,
+		DepartedAt:      protoTime(n.DepartedAt),
+		Silenced:        n.Silenced,
+		StageHistory: protoList(n.StageHistory, func(tr talos.StageTransition) *server.StageTransition {
+			return &server.StageTransition{From: tr.From, To: tr.To, Time: protoTime(&tr.Time)}
+		}),
+		KubernetesNode: n.KubernetesNode,
+		Phase:          n.Phase,
+		Tasks:          n.Tasks,
+		Sequences:      n.Sequences,
+		SourceId:       n.SourceID,
+		Stale:          n.Stale,
+	}
+	if n.Error != nil {
+		ret.Error = n.Error.Error()
+	}
+	if n.PodCount != nil {
+		ret.PodCount = int64(*n.PodCount)
+	}
+	if n.PodCapacity != nil {
+		ret.PodCapacity = int64(*n.PodCapacity)
+	}
+	if n.Maintenance != nil {
+		ret.Maintenance = &server.MaintenanceInfo{Version: n.Maintenance.Version, Arch: n.Maintenance.Arch, Platform: n.Maintenance.Platform}
+	}
+	return ret
+}
+
+func logStatsProto(s loki.LogStats) *server.LogStats {
+	return &server.LogStats{
+		NumMessages:            int64(s.NumMessages),
+		NumEmergencyMessages:   int64(s.NumEmergencyMessages),
+		NumAlertMessages:       int64(s.NumAlertMessages),
+		NumCriticalMessages:    int64(s.NumCriticalMessages),
+		NumErrorMessages:       int64(s.NumErrorMessages),
+		NumWarnMessages:        int64(s.NumWarnMessages),
+		NumNoticeMessages:      int64(s.NumNoticeMessages),
+		NumInfoMessages:        int64(s.NumInfoMessages),
+		NumDebugMessages:       int64(s.NumDebugMessages),
+		NumDnsQueries:          int64(s.NumDNSQueries),
+		NumDnsLocal:            int64(s.NumDNSLocal),
+		NumDnsRecursions:       int64(s.NumDNSRecursions),
+		NumDnsCached:           int64(s.NumDNSCached),
+		NumCertChecks:          int64(s.NumCertChecks),
+		NumCertOk:              int64(s.NumCertOK),
+		NumCertSigned:          int64(s.NumCertSigned),
+		NumFirewallWanInDrops:  int64(s.NumFirewallWanInDrops),
+		NumFirewallWanOutDrops: int64(s.NumFirewallWanOutDrops),
+		NumFirewallLanInDrops:  int64(s.NumFirewallLanInDrops),
+		NumFirewallLanOutDrops: int64(s.NumFirewallLanOutDrops),
+		RateLimited:            s.RateLimited,
+		PrimaryMatchedNothing:  s.PrimaryMatchedNothing,
+		Query:                  s.Query,
+		Sources: protoMap(s.Sources, func(src loki.SourceState) *server.LogSourceState {
+			return &server.LogSourceState{LastSeen: protoTime(&src.LastSeen), LogSilent: src.LogSilent}
+		}),
+		Connection: s.Connection,
+	}
+}
+
+// validUTF8 replaces the invalid UTF-8 in every string of m
+func validUTF8(m protoreflect.Message) {
+	fix := func(v protoreflect.Value) protoreflect.Value {
+		return protoreflect.ValueOfString(strings.ToValidUTF8(v.String(), "\uFFFD"))
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			keys := []protoreflect.MapKey{}
+			fixed := map[string]protoreflect.Value{}
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				switch fd.MapValue().Kind() {
+				case protoreflect.StringKind:
+					v = fix(v)
+				case protoreflect.MessageKind:
+					validUTF8(v.Message())
+				}
+				keys = append(keys, k)
+				fixed[strings.ToValidUTF8(k.String(), "\uFFFD")] = v
+				return true
+			})
+			for _, k := range keys {
+				v.Map().Clear(k)
+			}
+			for k, e := range fixed {
+				v.Map().Set(protoreflect.ValueOfString(k).MapKey(), e)
+			}
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				switch fd.Kind() {
+				case protoreflect.StringKind:
+					v.List().Set(i, fix(v.List().Get(i)))
+				case protoreflect.MessageKind:
+					validUTF8(v.List().Get(i).Message())
+				}
+			}
+		case fd.Kind() == protoreflect.StringKind:
+			m.Set(fd, fix(v))
+		case fd.Kind() == protoreflect.MessageKind:
+			validUTF8(v.Message())
+		}
+		return true
+	})
+}
+
+// protoTime is a time as unix milliseconds, 0 when unset
+func protoTime(t *time.Time) int64 {
+	if t == nil || t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func protoMap[K ~string, V any, P any](m map[K]V, conv func(V) P) map[string]P {
+	if m == nil {
+		return nil
+	}
+	ret := make(map[string]P, len(m))
+	for k, v := range m {
+		ret[string(k)] = conv(v)
+	}
+	return ret
+}
+
+func protoList[V any, P any](l []V, conv func(V) P) []P {
+	if l == nil {
+		return nil
+	}
+	ret := make([]P, len(l))
+	for i, v := range l {
+		ret[i] = conv(v)
+	}
+	return ret
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	ret := make([]K, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type protoField struct {
+	num protowire.Number
+	// typ is the field's type, or the value type of a map
+	typ   string
+	isMap bool
+}
+
+var protoMessageLine = regexp.MustCompile(`^message (\w+) \{$`)
+var protoFieldLine = regexp.MustCompile(`^(?:repeated )?(?:map<string, (\w+)>|(\w+)) (\w+) = (\d+);$`)
+
+// parseProto reads the messages and their fields out of labstatus.proto
+func parseProto(t *testing.T) map[string]map[string]protoField {
+	t.Helper()
+	b, err := os.ReadFile("server/labstatus.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := map[string]map[string]protoField{}
+	var current map[string]protoField
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if m := protoMessageLine.FindStringSubmatch(line); m != nil {
+			current = map[string]protoField{}
+			messages[m[1]] = current
+			continue
+		}
+		if line == "}" {
+			current = nil
+			continue
+		}
+		if m := protoFieldLine.FindStringSubmatch(line); m != nil && current != nil {
+			num, _ := strconv.Atoi(m[4])
+			f := protoField{num: protowire.Number(num), typ: m[2], isMap: m[1] != ""}
+			if f.isMap {
+				f.typ = m[1]
+			}
+			current[m[3]] = f
+		}
+	}
+	if len(messages["LabStatus"]) == 0 {
+		t.Fatal("no LabStatus message parsed")
+	}
+	return messages
+}
+
+// protoOmitted are the JSON fields the wire schema leaves out on purpose
+var protoOmitted = map[string]string{
+	"Incident.transitions": "open incidents in the status never carry their transitions",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// messageType is the struct a field holds, through pointers, slices and maps
+func messageType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil
+	}
+	return t
+}
+
+// protoName is the proto field name for a JSON key. Untagged fields keep
+// their Go name in JSON, which the schema spells in snake case.
+func protoName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name != "" {
+		return name
+	}
+	b := strings.Builder{}
+	runes := []rune(f.Name)
+	for i, r := range runes {
+		upper := unicode.IsUpper(r)
+		// A capital starts a word unless it continues an acronym, as in
+		// NumDNSQueries
+		if upper && i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// The JSON and protobuf encodings of the status are one schema, so every
+// JSON field needs a proto field of the same name and the reverse
+func TestProtoMatchesJSON(t *testing.T) {
+	messages := parseProto(t)
+	seen := map[string]bool{}
+	var walk func(goType reflect.Type, message string)
+	walk = func(goType reflect.Type, message string) {
+		if seen[message] {
+			return
+		}
+		seen[message] = true
+		fields, ok := messages[message]
+		if !ok {
+			t.Errorf("%s has no message %s in labstatus.proto", goType, message)
+			return
+		}
+
+		named := map[string]bool{}
+		for i := range goType.NumField() {
+			f := goType.Field(i)
+			name := protoName(f)
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			named[name] = true
+			if _, ok := protoOmitted[message+"."+name]; ok {
+				continue
+			}
+			pf, ok := fields[name]
+			if !ok {
+				t.Errorf("%s.%s (json %q) is missing from message %s", goType.Name(), f.Name, name, message)
+				continue
+			}
+			if sub := messageType(f.Type); sub != nil {
+				walk(sub, pf.typ)
+			}
+		}
+		for name := range fields {
+			if !named[name] {
+				t.Errorf("%s.%s has no JSON field in %s", message, name, goType)
+			}
+		}
+	}
+	walk(reflect.TypeOf(LabStatus{}), "LabStatus")
+
+	for name := range messages {
+		if !seen[name] {
+			t.Errorf("message %s isn't reachable from LabStatus", name)
+		}
+	}
+}
+
+// fill sets every exported field in v to a non-zero value so the encoder
+// writes all of them
+func fill(v reflect.Value, depth int) {
+	if depth > 8 || !v.CanSet() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.Interface:
+		if v.Type() == reflect.TypeOf((*error)(nil)).Elem() {
+			v.Set(reflect.ValueOf(errors.New("x")))
+		}
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		fill(p.Elem(), depth+1)
+		v.Set(p)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		fill(s.Index(0), depth+1)
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		k := reflect.New(v.Type().Key()).Elem()
+		fill(k, depth+1)
+		e := reflect.New(v.Type().Elem()).Elem()
+		fill(e, depth+1)
+		m.SetMapIndex(k, e)
+		v.Set(m)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(time.Unix(1700000000, 0)))
+			return
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), depth+1)
+			}
+		}
+	}
+}
+
+// The generated types must be regenerated whenever labstatus.proto changes
+func TestGeneratedMatchesProto(t *testing.T) {
+	messages := parseProto(t)
+	files := (&server.LabStatus{}).ProtoReflect().Descriptor().ParentFile()
+	for name, fields := range messages {
+		md := files.Messages().ByName(protoreflect.Name(name))
+		if md == nil {
+			t.Errorf("labstatus.pb.go has no %s, run make generate", name)
+			continue
+		}
+		if md.Fields().Len() != len(fields) {
+			t.Errorf("labstatus.pb.go has %d fields in %s, labstatus.proto %d", md.Fields().Len(), name, len(fields))
+		}
+		for field, f := range fields {
+			fd := md.Fields().ByName(protoreflect.Name(field))
+			if fd == nil || fd.Number() != f.num || fd.IsMap() != f.isMap {
+				t.Errorf("labstatus.pb.go doesn't match %s.%s = %d, run make generate", name, field, f.num)
+				continue
+			}
+			if fd.IsMap() {
+				fd = fd.MapValue()
+			}
+			typ := fd.Kind().String()
+			if fd.Message() != nil {
+				typ = string(fd.Message().Name())
+			}
+			if typ != f.typ {
+				t.Errorf("labstatus.pb.go has %s.%s as %s, labstatus.proto %s", name, field, typ, f.typ)
+			}
+		}
+	}
+}
+
+// With every field of the status set, every field of the schema is
+// written, as the generated types read it back
+func TestProtoEncodesEveryField(t *testing.T) {
+	s := LabStatus{}
+	fill(reflect.ValueOf(&s).Elem(), 0)
+	b, n := protowire.ConsumeBytes(encodeStatusProto(s))
+	if n < 0 {
+		t.Fatal("status isn't length prefixed")
+	}
+	decoded := &server.LabStatus{}
+	if err := proto.Unmarshal(b, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(m protoreflect.Message)
+	walk = func(m protoreflect.Message) {
+		fields := m.Descriptor().Fields()
+		for i := range fields.Len() {
+			fd := fields.Get(i)
+			if !m.Has(fd) {
+				t.Errorf("%s is never written", fd.FullName())
+				continue
+			}
+			v := m.Get(fd)
+			switch {
+			case fd.IsMap() && fd.MapValue().Message() != nil:
+				v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+					walk(v.Message())
+					return true
+				})
+			case fd.IsList() && fd.Message() != nil:
+				for j := range v.List().Len() {
+					walk(v.List().Get(j).Message())
+				}
+			case !fd.IsMap() && !fd.IsList() && fd.Message() != nil:
+				walk(v.Message())
+			}
+		}
+	}
+	walk(decoded.ProtoReflect())
+
+	node := decoded.Talos["x"]
+	if node.GetPodCount() != 1 || node.GetError() != "x" || node.GetLastUpdated() != 1700000000000 || node.GetServices()["x"].GetLastChange() != 1700000000000 {
+		t.Errorf("node decoded as %v", node)
+	}
+}
+
+// A string cut mid rune is sent with the bad bytes replaced, as JSON does,
+// rather than failing the whole status
+func TestProtoInvalidUTF8(t *testing.T) {
+	s := LabStatus{Talos: map[string]talos.NodeStatus{"cp1\xff": {Node: "cp1", Error: errors.New("reset by peer \xe2\x82")}}, ConfigError: "bad \xff"}
+	b, _ := protowire.ConsumeBytes(encodeStatusProto(s))
+	decoded := &server.LabStatus{}
+	if err := proto.Unmarshal(b, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ConfigError != "bad \uFFFD" || decoded.Talos["cp1\uFFFD"].GetError() != "reset by peer \uFFFD" || decoded.Talos["cp1\uFFFD"].GetNode() != "cp1" {
+		t.Errorf("decoded %v", decoded)
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/http/httpproxy"
+)
+
+type proxyFunc func(*url.URL) (*url.URL, error)
+
+var current atomic.Pointer[proxyFunc]
+
+func init() {
+	fn := httpproxy.FromEnvironment().ProxyFunc()
+	current.Store((*proxyFunc)(&fn))
+}
+
+// Configure sets the proxy used by every outbound HTTP and websocket client.
+// An empty proxyURL falls back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables. socks5:// URLs are supported.
+func Configure(proxyURL string, noProxy []string) error {
+	cfg := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		if _, err := url.Parse(proxyURL); err != nil {
+			return err
+		}
+		cfg.HTTPProxy = proxyURL
+		cfg.HTTPSProxy = proxyURL
+		cfg.NoProxy = strings.Join(noProxy, ",")
+	} else if len(noProxy) > 0 {
+		cfg.NoProxy = strings.Join(noProxy, ",")
+	}
+
+	fn := cfg.ProxyFunc()
+	current.Store((*proxyFunc)(&fn))
+	return nil
+}
+
+// ForRequest picks the proxy for a request, suitable for http.Transport and
+// websocket.Dialer Proxy fields
+func ForRequest(req *http.Request) (*url.URL, error) {
+	return (*current.Load())(req.URL)
+}
+
+// transport is shared by every client so their connections are pooled.
+// Proxy is looked up per request, so it follows Configure.
+var transport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = ForRequest
+	return t
+}()
+
+// HTTPClient returns a client that honors the configured proxy
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Dialer returns a websocket dialer that honors the configured proxy. The
+// gorilla dialer needs its Proxy set explicitly to pick up proxy-url.
+func Dialer() *websocket.Dialer {
+	d := *websocket.DefaultDialer
+	d.Proxy = ForRequest
+	return &d
+}
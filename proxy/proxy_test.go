@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProxy answers every request itself, recording the URL it was asked
+// for the way a forward proxy sees it
+type testProxy struct {
+	lock      sync.Mutex
+	requested []string
+}
+
+func (p *testProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	p.requested = append(p.requested, r.URL.String())
+	p.lock.Unlock()
+	io.WriteString(w, "proxied")
+}
+
+func (p *testProxy) urls() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]string{}, p.requested...)
+}
+
+// useProxy routes clients through a fresh test proxy until the test ends
+func useProxy(t *testing.T, noProxy []string) *testProxy {
+	t.Helper()
+	p := &testProxy{}
+	srv := httptest.NewServer(p)
+	t.Cleanup(func() {
+		transport.CloseIdleConnections()
+		srv.Close()
+		Configure("", nil)
+	})
+	if err := Configure(srv.URL, noProxy); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestHTTPClient(t *testing.T) {
+	p := useProxy(t, nil)
+
+	for _, timeout := range []time.Duration{0, time.Second} {
+		resp, err := HTTPClient(timeout).Get("http://loki.lab:3100/ready")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "proxied" {
+			t.Errorf("answered %q without the proxy", b)
+		}
+	}
+	if got := p.urls(); len(got) != 2 || got[0] != "http://loki.lab:3100/ready" {
+		t.Errorf("proxy asked for %v", got)
+	}
+
+	if HTTPClient(0).Transport != HTTPClient(time.Minute).Transport {
+		t.Errorf("clients don't share a transport")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	useProxy(t, []string{"nas.lab"})
+	proxied := func(raw string) bool {
+		t.Helper()
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		via, err := ForRequest(&http.Request{URL: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return via != nil
+	}
+
+	if !proxied("https://loki.lab/loki/api/v1/tail") {
+		t.Errorf("https not proxied")
+	}
+	if proxied("http://nas.lab/status") {
+		t.Errorf("no-proxy host proxied")
+	}
+
+	// A new proxy applies to clients already handed out
+	client := HTTPClient(0)
+	p := useProxy(t, nil)
+	resp, err := client.Get("http://nas.lab/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := p.urls(); len(got) != 1 {
+		t.Errorf("reconfigured proxy asked for %v", got)
+	}
+
+	if err := Configure("http://%zz", nil); err == nil {
+		t.Errorf("configured an invalid proxy url")
+	}
+}
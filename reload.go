@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reloadApplied lists the config keys a reload puts into effect. The rest
+// are only read at startup.
+var reloadApplied = map[string]bool{"identities": true}
+
+// ReloadResult reports which config keys differ from what is running. Keys
+// needing a restart keep being reported until labwatch is restarted.
+type ReloadResult struct {
+	Reloaded        time.Time `json:"reloaded"`
+	Applied         []string  `json:"applied"`
+	RestartRequired []string  `json:"restart_required"`
+	Errors          []string  `json:"errors,omitempty"`
+}
+
+// reloadBase is the config the running instance reflects: the startup
+// config with every reload's applied keys laid over it
+var reloadBase LabwatchConfig
+var reloadLock sync.Mutex
+
+// configHash identifies the running config, shown to clients as config_hash
+var configHash atomic.Value
+
+func setReloadBase(cfg LabwatchConfig) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	reloadBase = cfg
+	configHash.Store(hashConfig(cfg))
+}
+
+func currentConfigHash() string {
+	s, _ := configHash.Load().(string)
+	return s
+}
+
+// hashConfig hashes the config as /admin/config shows it, secrets redacted,
+// so instances running the same config report the same hash whatever their
+// tokens. Keys are sorted by the JSON encoding, which keeps it stable.
+func hashConfig(cfg LabwatchConfig) string {
+	b, _ := json.Marshal(configKeys(redactConfig(cfg)))
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// configKeys renders the config through YAML so keys match the config file
+func configKeys(cfg LabwatchConfig) map[string]any {
+	y, _ := yaml.Marshal(cfg)
+	ret := map[string]any{}
+	yaml.Unmarshal(y, &ret)
+	return ret
+}
+
+// diffConfig splits the top level keys that differ between the configs into
+// those a reload applies and those that need a restart
+func diffConfig(running LabwatchConfig, next LabwatchConfig) ([]string, []string) {
+	before, after := configKeys(running), configKeys(next)
+	applied, restart := []string{}, []string{}
+	for key, v := range after {
+		if reflect.DeepEqual(before[key], v) {
+			continue
+		}
+		if reloadApplied[key] {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	sort.Strings(applied)
+	sort.Strings(restart)
+	return applied, restart
+}
+
+// reload re-reads the config file and applies what can change at runtime.
+// A config that fails to load strictly is rejected whole and nothing changes.
+// In lenient mode the sections that load are applied, as at startup.
+func reload(log *slog.Logger) (ReloadResult, error) {
+	log = log.With("operation", "reload")
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	result := ReloadResult{Reloaded: time.Now(), Applied: []string{}, RestartRequired: []string{}}
+	var cfg LabwatchConfig
+	if *configStrict {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			log.Error("not reloading invalid config", "error", err.Error())
+			return result, err
+		}
+	} else {
+		// Retries a config that fell back to defaults. Only the parts that
+		// reload applies take effect; the rest need a restart.
+		var errs []error
+		cfg, errs = loadConfigLenient(*configFile)
+		setConfigErrors(errs, log)
+		if len(errs) == 0 && currentConfigError() != "" {
+			log.Warn("config now loads cleanly, restart to apply the sections that were running on defaults")
+		}
+		for _, err := range errs {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+
+	result.Applied, result.RestartRequired = diffConfig(reloadBase, cfg)
+	idMap, _ := newIdentityMap(cfg.Identities)
+	identities.Store(idMap)
+	reloadBase.Identities = cfg.Identities
+	configHash.Store(hashConfig(reloadBase))
+
+	log.Info("reloaded config", "applied", result.Applied, "restartRequired", result.RestartRequired)
+	return result, nil
+}
+
+// handleReload does what SIGHUP does and reports what changed. A config that
+// doesn't load is reported with 422 and the running instance is untouched.
+func handleReload(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := reload(log)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		b, _ := encodeJSON(result)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useLenientConfig starts labwatch leniently on the config file holding
+// body, as main does
+func useLenientConfig(t *testing.T, body string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "labwatch.yaml")
+	if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	savedFile, savedStrict := *configFile, *configStrict
+	savedBase, savedDefaulted, savedIdentities := reloadBase, configDefaulted, identities.Load()
+	t.Cleanup(func() {
+		*configFile, *configStrict = savedFile, savedStrict
+		configDefaulted = savedDefaulted
+		identities.Store(savedIdentities)
+		setConfigErrors(nil, discardLog)
+		setReloadBase(savedBase)
+	})
+	*configFile, *configStrict = file, false
+	configDefaulted = map[string]bool{}
+
+	cfg, errs := loadConfigLenient(file)
+	setConfigErrors(errs, discardLog)
+	setReloadBase(cfg)
+	return file
+}
+
+const brokenIdentities = `
+identities:
+  cp1: {talos: [10.0.0.11]}
+  cp2: {talos: [10.0.0.11]}
+`
+
+const fixedIdentities = `
+identities:
+  cp1: {talos: [10.0.0.11]}
+  cp2: {talos: [10.0.0.12]}
+`
+
+func TestReloadLenientPending(t *testing.T) {
+	file := useLenientConfig(t, "log-source: syslog\n"+brokenIdentities)
+	if e := currentConfigError(); e == "" {
+		t.Fatal("started without a config error")
+	}
+
+	// Both load now, but only identities are applied by a reload
+	if err := os.WriteFile(file, []byte("log-source: journald\n"+fixedIdentities), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := reload(discardLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Applied, []string{"identities"}) || !reflect.DeepEqual(result.RestartRequired, []string{"log-source"}) || result.Errors != nil {
+		t.Errorf("reload reported %+v", result)
+	}
+	if e := currentConfigError(); e != "log-source loads now but runs on defaults until a restart" {
+		t.Errorf("config error %q after reloading", e)
+	}
+
+	// It stays pending over later reloads
+	if _, err := reload(discardLog); err != nil {
+		t.Fatal(err)
+	}
+	if e := currentConfigError(); e != "log-source loads now but runs on defaults until a restart" {
+		t.Errorf("config error %q after reloading again", e)
+	}
+	if got := identities.Load().canonical("talos", "10.0.0.12"); got != "cp2" {
+		t.Errorf("identities not applied, 10.0.0.12 is %q", got)
+	}
+
+	// A section broken again is reported as failing, not pending
+	if err := os.WriteFile(file, []byte("log-source: syslog\n"+fixedIdentities), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reload(discardLog); err != nil {
+		t.Fatal(err)
+	}
+	if e := currentConfigError(); e == "" || e == "log-source loads now but runs on defaults until a restart" {
+		t.Errorf("config error %q with log-source broken again", e)
+	}
+}
+
+// Once the only broken section is one a reload applies, nothing is left
+// running on defaults
+func TestReloadLenientApplied(t *testing.T) {
+	file := useLenientConfig(t, brokenIdentities)
+	if e := currentConfigError(); e == "" {
+		t.Fatal("started without a config error")
+	}
+	if err := os.WriteFile(file, []byte(fixedIdentities), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reload(discardLog); err != nil {
+		t.Fatal(err)
+	}
+	if e := currentConfigError(); e != "" {
+		t.Errorf("config error %q once identities were applied", e)
+	}
+}
@@ -0,0 +1,61 @@
+package render
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+const ANSI_RESET = "\x1b[0m"
+const ANSI_BOLD_RED = "\x1b[1;31m"
+const ANSI_RED = "\x1b[31m"
+const ANSI_GREEN = "\x1b[32m"
+const ANSI_YELLOW = "\x1b[33m"
+const ANSI_CYAN = "\x1b[36m"
+const ANSI_DIM = "\x1b[2m"
+
+// severityColors covers both syslog levels from events and the health
+// levels labwatch reports
+var severityColors = map[string]string{
+	"emergency": ANSI_BOLD_RED,
+	"alert":     ANSI_BOLD_RED,
+	"critical":  ANSI_BOLD_RED,
+	"error":     ANSI_RED,
+	"warning":   ANSI_YELLOW,
+	"warn":      ANSI_YELLOW,
+	"notice":    ANSI_CYAN,
+	"debug":     ANSI_DIM,
+	"ok":        ANSI_GREEN,
+}
+
+// Colorizer wraps text in ANSI colors, or leaves it alone when disabled
+type Colorizer struct {
+	enabled bool
+}
+
+// NewColorizer enables colors only when out is a terminal and NO_COLOR is
+// unset or empty, see https://no-color.org
+func NewColorizer(out io.Writer) *Colorizer {
+	return &Colorizer{enabled: os.Getenv("NO_COLOR") == "" && IsTerminal(out)}
+}
+
+// NewColorizerEnabled forces colors on or off, e.g. for --color=always
+func NewColorizerEnabled(enabled bool) *Colorizer {
+	return &Colorizer{enabled: enabled}
+}
+
+func (c *Colorizer) wrap(color string, s string) string {
+	if c == nil || !c.enabled || color == "" {
+		return s
+	}
+	return color + s + ANSI_RESET
+}
+
+// Severity colors s by the severity or health level named by level
+func (c *Colorizer) Severity(level string, s string) string {
+	return c.wrap(severityColors[strings.ToLower(level)], s)
+}
+
+func (c *Colorizer) Dim(s string) string {
+	return c.wrap(ANSI_DIM, s)
+}
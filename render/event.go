@@ -0,0 +1,60 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// Relative renders how long before now t was in its largest whole unit,
+// e.g. 42s, 5m, 3h or 2d
+func Relative(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Second:
+		return "now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// pad left aligns s in width columns before it is colored, so escapes don't
+// throw out the alignment
+func pad(s string, width int) string {
+	if n := visibleWidth(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// EventLine renders an event on one line: when it happened relative to now,
+// level, host, service and message. Wide uses the absolute local time and
+// adds the trace ID.
+func EventLine(e loki.LogEvent, now time.Time, c *Colorizer, wide bool) string {
+	ts := e.Timestamp
+	if ts.IsZero() {
+		ts = now
+	}
+	when := pad(Relative(ts, now), 4)
+	if wide {
+		when = ts.Local().Format(time.DateTime)
+	}
+	host := e.Node
+	if e.Count > 1 {
+		host = fmt.Sprintf("%s (x%d)", strings.Join(e.Hosts, ","), e.Count)
+	}
+
+	level := c.Severity(e.Level, pad(strings.ToUpper(e.Level), 7))
+	line := fmt.Sprintf("%s %s %s %s %s", c.Dim(when), level, pad(host, 16), pad(e.Service, 16), e.Message)
+	if wide && e.TraceID != "" {
+		line += " " + c.Dim("trace="+e.TraceID)
+	}
+	return line
+}
@@ -0,0 +1,23 @@
+package render
+
+import "fmt"
+
+// Format is the --format every CLI command accepts
+type Format string
+
+const FORMAT_JSON Format = "json"
+const FORMAT_TABLE Format = "table"
+const FORMAT_WIDE Format = "wide"
+
+// Formats lists the formats for flag help and enum validation
+var Formats = []string{string(FORMAT_JSON), string(FORMAT_TABLE), string(FORMAT_WIDE)}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FORMAT_JSON, FORMAT_TABLE, FORMAT_WIDE:
+		return f, nil
+	case "":
+		return FORMAT_TABLE, nil
+	}
+	return "", fmt.Errorf("invalid format %q, must be one of json|table|wide", s)
+}
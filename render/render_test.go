@@ -0,0 +1,177 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenWidth is the terminal width every golden file is rendered at
+const goldenWidth = 60
+
+// golden compares got with testdata/name, or rewrites the file with -update
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, rerun with -update if the change is intended\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// colorizers are the two ways a CLI command ends up rendering: NO_COLOR set,
+// and colors forced on
+func colorizers(t *testing.T) map[string]*Colorizer {
+	t.Setenv("NO_COLOR", "1")
+	return map[string]*Colorizer{
+		"nocolor": NewColorizer(os.Stdout),
+		"color":   NewColorizerEnabled(true),
+	}
+}
+
+func nodeTable(width int, c *Colorizer) *Table {
+	table := NewTable(width, "NODE", "STATE", "STAGE", "MESSAGE")
+	table.Row("cp1", c.Severity("ok", "OK"), "running", "")
+	table.Row("worker1", c.Severity("warning", "WARN"), "booting", "kubelet is not healthy: waiting for the API server to answer")
+	table.Row("worker-two-long-name", c.Severity("critical", "CRITICAL"), "unknown", "connection refused")
+	return table
+}
+
+func TestTableGolden(t *testing.T) {
+	for name, c := range colorizers(t) {
+		formats := map[Format]int{FORMAT_TABLE: goldenWidth, FORMAT_WIDE: 0}
+		for format, width := range formats {
+			t.Run(fmt.Sprintf("%s-%s", format, name), func(t *testing.T) {
+				out := bytes.Buffer{}
+				if err := nodeTable(width, c).Render(&out); err != nil {
+					t.Fatal(err)
+				}
+				if width > 0 {
+					for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+						if n := visibleWidth(line); n > width {
+							t.Errorf("line is %d wide: %q", n, line)
+						}
+					}
+				}
+				golden(t, fmt.Sprintf("table-%s-%s.golden", format, name), out.Bytes())
+			})
+		}
+	}
+}
+
+func TestEventLineGolden(t *testing.T) {
+	// Wide lines show the local time
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []loki.LogEvent{
+		{Node: "cp1", Service: "etcd", Level: "error", Message: "failed to send out heartbeat on time", Timestamp: now.Add(-42 * time.Second), TraceID: "4bf92f3577b34da6"},
+		{Node: "worker1", Service: "kubelet", Level: "warning", Message: "eviction manager: attempting to reclaim ephemeral-storage", Timestamp: now.Add(-5 * time.Minute)},
+		{Service: "dnsmasq", Level: "info", Message: "query[A] example.com from 10.0.0.5", Timestamp: now.Add(-3 * time.Hour), Hosts: []string{"worker1", "worker2"}, Count: 7},
+		{Node: "labwatch", Service: "labwatch", Level: "critical", Message: "lab state changed to CRITICAL", Timestamp: now.Add(-49 * time.Hour)},
+		{Node: "cp2", Service: "apid", Level: "debug", Message: "no timestamp, shown as now"},
+	}
+	for name, c := range colorizers(t) {
+		for _, wide := range []bool{false, true} {
+			format := FORMAT_TABLE
+			if wide {
+				format = FORMAT_WIDE
+			}
+			t.Run(fmt.Sprintf("%s-%s", format, name), func(t *testing.T) {
+				out := bytes.Buffer{}
+				for _, e := range events {
+					fmt.Fprintln(&out, EventLine(e, now, c, wide))
+				}
+				golden(t, fmt.Sprintf("events-%s-%s.golden", format, name), out.Bytes())
+			})
+		}
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if got := NewColorizer(os.Stdout).Severity("error", "FAIL"); got != "FAIL" {
+		t.Errorf("colored with NO_COLOR set: %q", got)
+	}
+	// Whatever the environment, output that isn't a terminal is left alone
+	t.Setenv("NO_COLOR", "")
+	if got := NewColorizer(&bytes.Buffer{}).Severity("error", "FAIL"); got != "FAIL" {
+		t.Errorf("colored output to a buffer: %q", got)
+	}
+	if got := NewColorizerEnabled(true).Severity("ERROR", "FAIL"); got != ANSI_RED+"FAIL"+ANSI_RESET {
+		t.Errorf("forced colors gave %q", got)
+	}
+	if got := NewColorizerEnabled(true).Severity("unheard-of", "FAIL"); got != "FAIL" {
+		t.Errorf("unknown level colored: %q", got)
+	}
+}
+
+func TestRelative(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		500 * time.Millisecond:       "now",
+		-time.Minute:                 "now",
+		59 * time.Second:             "59s",
+		time.Minute:                  "1m",
+		59*time.Minute + time.Second: "59m",
+		23 * time.Hour:               "23h",
+		49 * time.Hour:               "2d",
+	}
+	for ago, want := range tests {
+		if got := Relative(now.Add(-ago), now); got != want {
+			t.Errorf("Relative(%s ago) = %q, want %q", ago, got, want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"too long", 5, "too …"},
+		{ANSI_RED + "colored" + ANSI_RESET, 7, ANSI_RED + "colored" + ANSI_RESET},
+		{ANSI_RED + "colored" + ANSI_RESET, 4, "col…"},
+		{"réseau", 3, "ré…"},
+		{"ab", 1, "a"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": FORMAT_TABLE, "json": FORMAT_JSON, "table": FORMAT_TABLE, "wide": FORMAT_WIDE} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("yaml accepted")
+	}
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+var columnGap = "  "
+var minColumnWidth = 3
+
+// visibleWidth is the number of runes s takes up on screen, ignoring colors
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis. Colors
+// are dropped from a truncated cell since the cut may land inside one.
+func truncate(s string, width int) string {
+	if visibleWidth(s) <= width {
+		return s
+	}
+	runes := []rune(ansiEscape.ReplaceAllString(s, ""))
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// Table lays out rows in aligned columns. When the columns don't fit in
+// Width, the widest are narrowed first and their cells truncated. A Width of
+// zero never truncates.
+type Table struct {
+	Width   int
+	headers []string
+	rows    [][]string
+}
+
+func NewTable(width int, headers ...string) *Table {
+	return &Table{Width: width, headers: headers}
+}
+
+// Row adds a row. Missing cells are left blank and extra ones dropped.
+func (t *Table) Row(cells ...string) {
+	row := make([]string, len(t.headers))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+func (t *Table) widths() []int {
+	widths := make([]int, len(t.headers))
+	for _, row := range append([][]string{t.headers}, t.rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
+	}
+	if t.Width <= 0 {
+		return widths
+	}
+
+	total := len(columnGap) * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	for total > t.Width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+	return widths
+}
+
+func (t *Table) Render(w io.Writer) error {
+	widths := t.widths()
+	for _, row := range append([][]string{t.headers}, t.rows...) {
+		line := strings.Builder{}
+		for i, cell := range row {
+			cell = truncate(cell, widths[i])
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-visibleWidth(cell)))
+				line.WriteString(columnGap)
+			}
+		}
+		line.WriteByte('\n')
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package render
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+var defaultWidth = 80
+
+// IsTerminal reports whether w is a character device such as a terminal,
+// as opposed to a pipe or file
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Width is the width of the terminal behind w. COLUMNS is used when w isn't
+// a terminal, then 80.
+func Width(w io.Writer) int {
+	if f, ok := w.(*os.File); ok && IsTerminal(w) {
+		if width := terminalWidth(f); width > 0 {
+			return width
+		}
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultWidth
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package render
+
+import "os"
+
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+var watcherRestartTimeout = time.Duration(10) * time.Second
+
+// watcherRestart asks the watch loop to replace one watcher. The loop owns
+// the watchers and their channels, so the swap happens there between updates.
+type watcherRestart struct {
+	name string
+	done chan error
+}
+
+var watcherRestarts = make(chan watcherRestart)
+
+type WatcherRestartResult struct {
+	Watcher   string    `json:"watcher"`
+	Restarted time.Time `json:"restarted"`
+	Ready     bool      `json:"ready"`
+}
+
+// handleWatcherRestart stops and re-creates the watcher named in the path
+// from the running config. Clients stay connected throughout; the watcher
+// reports not ready until it delivers data again.
+func handleWatcherRestart(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name != WATCHER_TALOS && name != WATCHER_LOKI {
+		http.Error(w, "unknown watcher "+name, http.StatusNotFound)
+		return
+	}
+
+	req := watcherRestart{name: name, done: make(chan error, 1)}
+	select {
+	case watcherRestarts <- req:
+	case <-time.After(watcherRestartTimeout):
+		http.Error(w, "timed out waiting for the watch loop", http.StatusServiceUnavailable)
+		return
+	}
+	if err := <-req.done; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	states, _ := watchersHealth.snapshot()
+	b, _ := json.Marshal(WatcherRestartResult{Watcher: name, Restarted: time.Now(), Ready: states[name]})
+	w.Write(b)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+type RuntimeStats struct {
+	Goroutines     int           `json:"goroutines"`
+	HeapAlloc      uint64        `json:"heap_alloc_bytes"`
+	HeapInuse      uint64        `json:"heap_inuse_bytes"`
+	HeapSys        uint64        `json:"heap_sys_bytes"`
+	HeapObjects    uint64        `json:"heap_objects"`
+	NumGC          uint32        `json:"num_gc"`
+	LastGCPause    time.Duration `json:"last_gc_pause_ns"`
+	TotalGCPause   time.Duration `json:"total_gc_pause_ns"`
+	LastGC         time.Time     `json:"last_gc"`
+	GOMAXPROCS     int           `json:"gomaxprocs"`
+	UptimeSeconds  float64       `json:"uptime_seconds"`
+	ProcessStarted time.Time     `json:"process_started"`
+}
+
+var processStarted = time.Now()
+
+func init() {
+	// expvar already publishes memstats; the goroutine count is the missing
+	// leak early-warning
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+}
+
+func getRuntimeStats() RuntimeStats {
+	m := runtime.MemStats{}
+	runtime.ReadMemStats(&m)
+
+	ret := RuntimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAlloc:      m.HeapAlloc,
+		HeapInuse:      m.HeapInuse,
+		HeapSys:        m.HeapSys,
+		HeapObjects:    m.HeapObjects,
+		NumGC:          m.NumGC,
+		TotalGCPause:   time.Duration(m.PauseTotalNs),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		UptimeSeconds:  time.Since(processStarted).Seconds(),
+		ProcessStarted: processStarted,
+	}
+	if m.NumGC > 0 {
+		ret.LastGCPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+		ret.LastGC = time.Unix(0, int64(m.LastGC))
+	}
+	return ret
+}
+
+func handleRuntimeStats(w http.ResponseWriter, r *http.Request) {
+	b, _ := encodeJSON(getRuntimeStats())
+	w.Write(b)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/config"
+)
+
+const SCHEMA_WATCHER = "watcher"
+const SCHEMA_OUTPUT = "output"
+
+// SchemaSection is the part of the config one watcher or output owns,
+// registered alongside its code. Keys are top level config keys: its own
+// block, or the flat prefixed keys of the watchers that predate blocks.
+type SchemaSection struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Description string   `json:"description"`
+	Keys        []string `json:"keys"`
+}
+
+var schemaSections = []SchemaSection{}
+
+// registerSchemaSection records a section at init. Keys ending in - are
+// prefixes and claim every key starting with them. Keys that aren't in
+// LabwatchConfig panic, so a rename can't leave the registry stale.
+func registerSchemaSection(name string, kind string, description string, keys ...string) {
+	properties := config.Schema(LabwatchConfig{})["properties"].(map[string]any)
+	s := SchemaSection{Name: name, Kind: kind, Description: description, Keys: []string{}}
+	for _, key := range keys {
+		matched := false
+		for k := range properties {
+			if k == key || (strings.HasSuffix(key, "-") && strings.HasPrefix(k, key)) {
+				s.Keys = append(s.Keys, k)
+				matched = true
+			}
+		}
+		if !matched {
+			panic(fmt.Sprintf("schema section %s claims unknown config key %q", name, key))
+		}
+	}
+	sort.Strings(s.Keys)
+	schemaSections = append(schemaSections, s)
+}
+
+// enumSchema is the schema of a string config type limited to its constants
+func enumSchema(values ...string) map[string]any {
+	enum := []any{}
+	for _, v := range values {
+		enum = append(enum, v)
+	}
+	return map[string]any{"type": "string", "enum": enum}
+}
+
+// labwatchSchema is the JSON Schema of the whole config file. Each key owned
+// by a watcher or output is tagged with the section's name and description.
+func labwatchSchema() map[string]any {
+	schema := config.Schema(LabwatchConfig{})
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "labwatch configuration"
+
+	properties := schema["properties"].(map[string]any)
+	sections := append([]SchemaSection{}, schemaSections...)
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Name < sections[j].Name })
+	for _, s := range sections {
+		for _, key := range s.Keys {
+			p := properties[key].(map[string]any)
+			p["x-labwatch-section"] = s.Name
+			if _, ok := p["description"]; !ok {
+				p["description"] = s.Description
+			}
+		}
+	}
+	schema["x-labwatch-sections"] = sections
+	return schema
+}
+
+// validateConfigSchema checks a config file against the schema, catching
+// unknown and misspelt keys that decoding ignores
+func validateConfigSchema(data []byte) error {
+	return config.Validate(data, labwatchSchema())
+}
+
+func handleSchema(w http.ResponseWriter, r *http.Request) {
+	b, _ := encodeJSON(labwatchSchema())
+	w.Write(b)
+}
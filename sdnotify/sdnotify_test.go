@@ -0,0 +1,121 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeSystemd listens where NOTIFY_SOCKET points and returns what it receives
+func fakeSystemd(t *testing.T, name string) <-chan string {
+	t.Helper()
+	addr := &net.UnixAddr{Name: name, Net: "unixgram"}
+	if name[0] == '@' {
+		addr.Name = "\x00" + name[1:]
+	}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", name)
+
+	received := make(chan string, 8)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- string(buf[:n])
+		}
+	}()
+	return received
+}
+
+// socketPath is short enough for a unix socket wherever the temp dir is
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "notify")
+}
+
+func expect(t *testing.T, received <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("systemd got %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("systemd never got %q", want)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	received := fakeSystemd(t, socketPath(t))
+	for _, state := range []string{READY, WATCHDOG, STOPPING} {
+		if sent, err := Notify(state); !sent || err != nil {
+			t.Fatalf("Notify(%s) = %t, %v", state, sent, err)
+		}
+		expect(t, received, state)
+	}
+}
+
+func TestNotifyAbstractSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are linux only")
+	}
+	received := fakeSystemd(t, "@labwatch-test-"+strconv.Itoa(os.Getpid()))
+	if sent, err := Notify(READY); !sent || err != nil {
+		t.Fatalf("Notify = %t, %v", sent, err)
+	}
+	expect(t, received, READY)
+}
+
+func TestNotifyWithoutSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(READY); sent || err != nil {
+		t.Errorf("Notify without a socket = %t, %v", sent, err)
+	}
+
+	// A socket that went away is an error rather than silently ignored
+	t.Setenv("NOTIFY_SOCKET", socketPath(t))
+	if sent, err := Notify(READY); sent || err == nil {
+		t.Errorf("Notify to a missing socket = %t, %v", sent, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	self := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+	}{
+		{"30000000", "", 30 * time.Second},
+		{"30000000", self, 30 * time.Second},
+		// The watchdog is meant for another process, such as a parent
+		{"30000000", strconv.Itoa(os.Getpid() + 1), 0},
+		{"", "", 0},
+		{"0", "", 0},
+		{"-5", "", 0},
+		{"soon", "", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		if got := WatchdogInterval(); got != tt.want {
+			t.Errorf("WATCHDOG_USEC=%q WATCHDOG_PID=%q: interval %s, want %s", tt.usec, tt.pid, got, tt.want)
+		}
+	}
+}
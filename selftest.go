@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/proxy"
+	"github.com/DRuggeri/labwatch/render"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// dependencyCheck is a minimal real interaction with one upstream. Critical
+// checks fail the self-test and deep readiness; the rest are reported only.
+type dependencyCheck struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Name     string        `json:"name"`
+	Critical bool          `json:"critical"`
+	OK       bool          `json:"ok"`
+	Latency  time.Duration `json:"latency_ns"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// dependencyChecks lists a check for every upstream the config points at
+func dependencyChecks(cfg LabwatchConfig) []dependencyCheck {
+	checks := []dependencyCheck{}
+	if cfg.LogSource == LOG_SOURCE_JOURNALD {
+		checks = append(checks, journaldChecks(cfg)...)
+	} else {
+		checks = append(checks, dependencyCheck{
+			Name:     WATCHER_LOKI,
+			Critical: true,
+			Run:      func(ctx context.Context) error { return loki.CheckLabels(ctx, cfg.LokiAddress) },
+		})
+	}
+
+	nodes, err := talos.ConfiguredNodes(cfg.TalosConfigFile, cfg.TalosContext, cfg.TalosClusterName)
+	if err != nil {
+		checks = append(checks, dependencyCheck{
+			Name:     WATCHER_TALOS,
+			Critical: true,
+			Run:      func(ctx context.Context) error { return err },
+		})
+	}
+	for _, node := range nodes {
+		checks = append(checks, dependencyCheck{
+			Name:     WATCHER_TALOS + ":" + node,
+			Critical: true,
+			Run: func(ctx context.Context) error {
+				return talos.CheckNode(ctx, cfg.TalosConfigFile, cfg.TalosContext, cfg.TalosClusterName, node)
+			},
+		})
+	}
+
+	if cfg.Broker.URL != "" {
+		checks = append(checks, dependencyCheck{
+			Name: "broker",
+			Run:  func(ctx context.Context) error { return checkBroker(ctx, cfg.Broker) },
+		})
+	}
+	for _, d := range notifyDestinations(cfg) {
+		checks = append(checks, dependencyCheck{
+			Name: "notify-" + d.Name,
+			Run:  func(ctx context.Context) error { return checkWebhook(ctx, d.URL) },
+		})
+	}
+	if cfg.ShutdownWebhook != "" {
+		checks = append(checks, dependencyCheck{
+			Name: "shutdown-webhook",
+			Run:  func(ctx context.Context) error { return checkWebhook(ctx, cfg.ShutdownWebhook) },
+		})
+	}
+	return checks
+}
+
+// journaldChecks reads the journal of every followed host once
+func journaldChecks(cfg LabwatchConfig) []dependencyCheck {
+	w, err := newJournaldWatcher(cfg, nil)
+	if err != nil {
+		return []dependencyCheck{{
+			Name:     string(LOG_SOURCE_JOURNALD),
+			Critical: true,
+			Run:      func(ctx context.Context) error { return err },
+		}}
+	}
+	checks := []dependencyCheck{}
+	for _, host := range w.Hosts() {
+		checks = append(checks, dependencyCheck{
+			Name:     string(LOG_SOURCE_JOURNALD) + ":" + host,
+			Critical: true,
+			Run:      func(ctx context.Context) error { return w.CheckHost(ctx, host) },
+		})
+	}
+	return checks
+}
+
+// checkWebhook sends a HEAD rather than a POST so the receiver doesn't act on
+// it. Any answer short of a server error proves the endpoint is there.
+func checkWebhook(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := proxy.HTTPClient(0).Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runChecks executes every check concurrently, bounded by ctx, and returns
+// the results sorted by name
+func runChecks(ctx context.Context, checks []dependencyCheck) ([]CheckResult, bool) {
+	results := make([]CheckResult, len(checks))
+	wg := sync.WaitGroup{}
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.Run(ctx)
+			results[i] = CheckResult{Name: c.Name, Critical: c.Critical, OK: err == nil, Latency: time.Since(start)}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	passed := true
+	for _, r := range results {
+		if r.Critical && !r.OK {
+			passed = false
+		}
+	}
+	return results, passed
+}
+
+// selfTest prints a pass/fail line per dependency and reports whether every
+// critical dependency passed
+func selfTest(cfg LabwatchConfig, timeout time.Duration, format render.Format, out io.Writer) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	results, passed := runChecks(ctx, dependencyChecks(cfg))
+	if format == render.FORMAT_JSON {
+		b, _ := encodeJSON(results)
+		fmt.Fprintln(out, string(b))
+		return passed
+	}
+
+	// Table output fits long errors to the terminal; wide never truncates
+	width := render.Width(out)
+	if format == render.FORMAT_WIDE {
+		width = 0
+	}
+	colorizer := render.NewColorizer(out)
+	table := render.NewTable(width, "STATE", "NAME", "LATENCY", "ERROR")
+	for _, r := range results {
+		state, level := "PASS", "ok"
+		if !r.OK && r.Critical {
+			state, level = "FAIL", "error"
+		} else if !r.OK {
+			state, level = "WARN", "warning"
+		}
+		table.Row(colorizer.Severity(level, state), r.Name, r.Latency.Round(time.Millisecond).String(), r.Error)
+	}
+	table.Render(out)
+	return passed
+}
@@ -0,0 +1,279 @@
+// Package selfupdate finds, verifies and installs newer labwatch releases.
+// A release must publish a SHA256SUMS file covering its binaries and an
+// ed25519 signature, SHA256SUMS.sig, made with the key whose public half is
+// built in as PublicKey. What is signed is SignedPayload: the release
+// version followed by the file, so an older release's files can't be served
+// as a newer one.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/proxy"
+)
+
+// DEFAULT_URL is the GitHub releases API for the latest labwatch release
+const DEFAULT_URL = "https://api.github.com/repos/DRuggeri/labwatch/releases/latest"
+
+const SUMS_ASSET = "SHA256SUMS"
+const SIGNATURE_ASSET = "SHA256SUMS.sig"
+
+// PublicKey is the base64 ed25519 key releases are signed with, set at build
+// time with -ldflags "-X github.com/DRuggeri/labwatch/selfupdate.PublicKey=..."
+var PublicKey = ""
+
+var checkTimeout = time.Duration(30) * time.Second
+var downloadTimeout = time.Duration(10) * time.Minute
+var maxMetadataSize int64 = 1024 * 1024
+
+// ErrNoKey is returned when the build carries no key to verify releases with
+var ErrNoKey = errors.New("this build has no release signing key, so updates can't be verified")
+
+// Release is the part of a GitHub release labwatch reads. Other release
+// servers need only serve the same shape.
+type Release struct {
+	Version string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+}
+
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// AssetName is the binary for this platform, as built by make build-all
+func AssetName() string {
+	return "labwatch-" + runtime.GOOS + "-" + runtime.GOARCH
+}
+
+func (r Release) asset(name string) (Asset, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("release %s has no %s", r.Version, name)
+}
+
+// Check fetches the release description from url
+func Check(ctx context.Context, url string) (Release, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	b, err := fetch(ctx, url, maxMetadataSize)
+	if err != nil {
+		return Release{}, err
+	}
+	r := Release{}
+	if err = json.Unmarshal(b, &r); err != nil {
+		return Release{}, fmt.Errorf("invalid release description: %w", err)
+	}
+	if r.Version == "" {
+		return Release{}, fmt.Errorf("release description has no tag_name")
+	}
+	return r, nil
+}
+
+func fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	resp, err := get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%s is over %d bytes", url, limit)
+	}
+	return b, nil
+}
+
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json, application/json")
+	resp, err := proxy.HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// Newer reports whether latest is a later release than current. Versions
+// are compared as vMAJOR.MINOR.PATCH; a current version that isn't one, such
+// as a development build, is never considered out of date.
+func Newer(current string, latest string) bool {
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	for i := range c {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	ret := [3]int{}
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return ret, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return ret, false
+		}
+		ret[i] = n
+	}
+	return ret, true
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if s == "" {
+		return nil, ErrNoKey
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid release signing key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid release signing key: %d bytes, expected %d", len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// SignedPayload is what a release's SHA256SUMS.sig signs: a line naming the
+// version, without any leading v, then the SHA256SUMS file
+func SignedPayload(version string, sums []byte) []byte {
+	return append([]byte("labwatch "+strings.TrimPrefix(version, "v")+"\n"), sums...)
+}
+
+// VerifySums checks the signature of the SHA256SUMS file of release version
+// and returns the checksum it lists for name. The signature may be raw or
+// base64.
+func VerifySums(key ed25519.PublicKey, version string, sums []byte, sig []byte, name string) ([]byte, error) {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil {
+			return nil, fmt.Errorf("unreadable %s: %w", SIGNATURE_ASSET, err)
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(key, SignedPayload(version, sums), sig) {
+		return nil, fmt.Errorf("%s signature does not match the release signing key and version %s", SUMS_ASSET, version)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum for %s in %s", name, SUMS_ASSET)
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("%s lists no checksum for %s", SUMS_ASSET, name)
+}
+
+// Install downloads this platform's binary from the release, verifies it and
+// replaces target with it. The new binary is written beside target and only
+// renamed over it once every check passed, so a failure at any step leaves
+// target untouched.
+func Install(ctx context.Context, r Release, target string, key ed25519.PublicKey) error {
+	name := AssetName()
+	bin, err := r.asset(name)
+	if err != nil {
+		return err
+	}
+	sumsAsset, err := r.asset(SUMS_ASSET)
+	if err != nil {
+		return err
+	}
+	sigAsset, err := r.asset(SIGNATURE_ASSET)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+	sums, err := fetch(ctx, sumsAsset.URL, maxMetadataSize)
+	if err != nil {
+		return err
+	}
+	sig, err := fetch(ctx, sigAsset.URL, maxMetadataSize)
+	if err != nil {
+		return err
+	}
+	want, err := VerifySums(key, r.Version, sums, sig, name)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".update.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	resp, err := get(ctx, bin.URL)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	defer resp.Body.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("downloading %s: %w", name, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if got := hash.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("checksum mismatch for %s: got %x, expected %x", name, got, want)
+	}
+	if err = os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
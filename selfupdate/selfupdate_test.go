@@ -0,0 +1,334 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// release is what the fake release server publishes. Each test breaks one
+// part of an otherwise good release.
+type release struct {
+	version string
+	binary  []byte
+	sums    []byte
+	sig     []byte
+	// assets lists what the release description links to
+	assets []string
+	// missing assets are linked but 404
+	missing map[string]bool
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func sumsFor(name string, binary []byte) []byte {
+	return []byte(fmt.Sprintf("%x  %s\n%x  labwatch-plan9-386\n", sha256.Sum256(binary), name, sha256.Sum256([]byte("other"))))
+}
+
+func goodRelease(priv ed25519.PrivateKey) *release {
+	binary := []byte("#!/bin/sh\necho new labwatch\n")
+	sums := sumsFor(AssetName(), binary)
+	return &release{
+		version: "v1.3.0",
+		binary:  binary,
+		sums:    sums,
+		sig:     []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, SignedPayload("v1.3.0", sums)))),
+		assets:  []string{AssetName(), SUMS_ASSET, SIGNATURE_ASSET},
+	}
+}
+
+func serveRelease(t *testing.T, r *release) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, "/download/")
+		if r.missing[name] {
+			http.NotFound(w, req)
+			return
+		}
+		switch {
+		case req.URL.Path == "/latest":
+			desc := Release{Version: r.version}
+			for _, a := range r.assets {
+				desc.Assets = append(desc.Assets, Asset{Name: a, URL: srv.URL + "/download/" + a})
+			}
+			json.NewEncoder(w).Encode(desc)
+		case name == AssetName():
+			w.Write(r.binary)
+		case name == SUMS_ASSET:
+			w.Write(r.sums)
+		case name == SIGNATURE_ASSET:
+			w.Write(r.sig)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// installedTarget is the binary an update replaces
+func installedTarget(t *testing.T) string {
+	t.Helper()
+	target := filepath.Join(t.TempDir(), "labwatch")
+	if err := os.WriteFile(target, []byte("old labwatch"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestInstall(t *testing.T) {
+	pub, priv := newKey(t)
+	r := goodRelease(priv)
+	srv := serveRelease(t, r)
+	target := installedTarget(t)
+
+	release, err := Check(context.Background(), srv.URL+"/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.Version != "v1.3.0" {
+		t.Fatalf("found release %s", release.Version)
+	}
+	if err := Install(context.Background(), release, target, pub); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(target)
+	if !bytes.Equal(b, r.binary) {
+		t.Errorf("target holds %q", b)
+	}
+	if fi, _ := os.Stat(target); fi.Mode().Perm() != 0o750 {
+		t.Errorf("target mode %s, want the old binary's", fi.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(target)); len(entries) != 1 {
+		t.Errorf("left %d files beside the target", len(entries))
+	}
+}
+
+func TestInstallRawSignature(t *testing.T) {
+	pub, priv := newKey(t)
+	r := goodRelease(priv)
+	r.sig = ed25519.Sign(priv, SignedPayload(r.version, r.sums))
+	srv := serveRelease(t, r)
+	release, err := Check(context.Background(), srv.URL+"/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(context.Background(), release, installedTarget(t), pub); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallFailures(t *testing.T) {
+	pub, priv := newKey(t)
+	_, otherPriv := newKey(t)
+
+	tests := []struct {
+		name    string
+		corrupt func(r *release)
+		want    string
+	}{
+		{
+			name:    "no binary for this platform",
+			corrupt: func(r *release) { r.assets = []string{SUMS_ASSET, SIGNATURE_ASSET} },
+			want:    "has no " + AssetName(),
+		},
+		{
+			name:    "no checksums",
+			corrupt: func(r *release) { r.assets = []string{AssetName(), SIGNATURE_ASSET} },
+			want:    "has no " + SUMS_ASSET,
+		},
+		{
+			name:    "no signature",
+			corrupt: func(r *release) { r.assets = []string{AssetName(), SUMS_ASSET} },
+			want:    "has no " + SIGNATURE_ASSET,
+		},
+		{
+			name:    "checksums unreachable",
+			corrupt: func(r *release) { r.missing = map[string]bool{SUMS_ASSET: true} },
+			want:    "returned status 404",
+		},
+		{
+			name:    "signature unreachable",
+			corrupt: func(r *release) { r.missing = map[string]bool{SIGNATURE_ASSET: true} },
+			want:    "returned status 404",
+		},
+		{
+			name:    "binary unreachable",
+			corrupt: func(r *release) { r.missing = map[string]bool{AssetName(): true} },
+			want:    "returned status 404",
+		},
+		{
+			name:    "signed by another key",
+			corrupt: func(r *release) { r.sig = ed25519.Sign(otherPriv, SignedPayload(r.version, r.sums)) },
+			want:    "does not match the release signing key",
+		},
+		{
+			name:    "unreadable signature",
+			corrupt: func(r *release) { r.sig = []byte("not a signature") },
+			want:    "unreadable " + SIGNATURE_ASSET,
+		},
+		{
+			// An older release's files are validly signed, but for their own
+			// version
+			name: "older release served as newer",
+			corrupt: func(r *release) {
+				r.binary = []byte("old vulnerable labwatch")
+				r.sums = sumsFor(AssetName(), r.binary)
+				r.sig = ed25519.Sign(priv, SignedPayload("v1.2.0", r.sums))
+			},
+			want: "does not match the release signing key and version v1.3.0",
+		},
+		{
+			name: "checksums altered after signing",
+			corrupt: func(r *release) {
+				r.binary = []byte("tampered")
+				r.sums = sumsFor(AssetName(), r.binary)
+			},
+			want: "does not match the release signing key",
+		},
+		{
+			name: "no checksum for this platform",
+			corrupt: func(r *release) {
+				r.sums = sumsFor("labwatch-plan9-amd64", r.binary)
+				r.sig = ed25519.Sign(priv, SignedPayload(r.version, r.sums))
+			},
+			want: "lists no checksum for " + AssetName(),
+		},
+		{
+			name: "malformed checksum",
+			corrupt: func(r *release) {
+				r.sums = []byte("abc123  " + AssetName() + "\n")
+				r.sig = ed25519.Sign(priv, SignedPayload(r.version, r.sums))
+			},
+			want: "invalid checksum for " + AssetName(),
+		},
+		{
+			name:    "binary swapped",
+			corrupt: func(r *release) { r.binary = []byte("something else") },
+			want:    "checksum mismatch for " + AssetName(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := goodRelease(priv)
+			tt.corrupt(r)
+			srv := serveRelease(t, r)
+			target := installedTarget(t)
+
+			release, err := Check(context.Background(), srv.URL+"/latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Install(context.Background(), release, target, pub)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+			if b, _ := os.ReadFile(target); string(b) != "old labwatch" {
+				t.Errorf("target replaced with %q", b)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(target)); len(entries) != 1 {
+				t.Errorf("left %d files beside the target", len(entries))
+			}
+		})
+	}
+}
+
+func TestCheckFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+		want string
+	}{
+		{name: "server error", code: http.StatusBadGateway, want: "returned status 502"},
+		{name: "not json", body: "<html>", want: "invalid release description"},
+		{name: "no version", body: `{"assets": []}`, want: "has no tag_name"},
+		{name: "too large", body: `{"tag_name": "v1.0.0", "x": "` + strings.Repeat("a", int(maxMetadataSize)) + `"}`, want: "is over"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.code != 0 {
+					w.WriteHeader(tt.code)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			_, err := Check(context.Background(), srv.URL)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedPayload(t *testing.T) {
+	// The leading v is dropped so tags and goreleaser's .Version agree
+	if a, b := SignedPayload("v1.3.0", []byte("sums")), SignedPayload("1.3.0", []byte("sums")); !bytes.Equal(a, b) {
+		t.Errorf("%q != %q", a, b)
+	}
+	if got := string(SignedPayload("v1.3.0", []byte("sums\n"))); got != "labwatch 1.3.0\nsums\n" {
+		t.Errorf("payload %q", got)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"v1.2.3", "v1.2.4", true},
+		{"v1.2.3", "v1.10.0", true},
+		{"v1.2.3", "v2.0.0", true},
+		{"1.2.3", "v1.2.4", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.4", "v1.2.3", false},
+		{"v1.2.3-rc1", "v1.2.3", false},
+		{"testing", "v9.9.9", false},
+		{"v1.2.3", "latest", false},
+		{"v1.2", "v1.3.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.current, tt.latest); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %t, want %t", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _ := newKey(t)
+	if got, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub) + "\n"); err != nil || !got.Equal(pub) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	for name, s := range map[string]string{
+		"not base64": "!!!",
+		"too short":  base64.StdEncoding.EncodeToString(pub[:16]),
+	} {
+		if _, err := ParsePublicKey(s); err == nil || !strings.Contains(err.Error(), "invalid release signing key") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+	if _, err := ParsePublicKey(""); err != ErrNoKey {
+		t.Errorf("empty key: got %v", err)
+	}
+}
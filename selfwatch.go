@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+func init() {
+	registerSchemaSection("self", SCHEMA_WATCHER, "Watches the host and process labwatch itself runs on", "self")
+}
+
+// SelfConfig samples the host labwatch runs on every interval. Open file
+// descriptors above max-fd-percent of the limit, or a resident size above
+// max-rss, are announced as events since they predict labwatch itself
+// failing. Either set to 0 is not checked.
+type SelfConfig struct {
+	Interval     config.Duration `yaml:"interval"`
+	MaxFDPercent float64         `yaml:"max-fd-percent"`
+	MaxRSS       config.ByteSize `yaml:"max-rss"`
+}
+
+func validateSelf(cfg SelfConfig) error {
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if cfg.MaxFDPercent < 0 || cfg.MaxFDPercent > 100 {
+		return fmt.Errorf("max-fd-percent must be between 0 and 100")
+	}
+	if cfg.MaxRSS < 0 {
+		return fmt.Errorf("max-rss must not be negative")
+	}
+	return nil
+}
+
+type DiskUsage struct {
+	Path        string  `json:"path"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// SelfStatus is the latest sample of labwatch's own host and process. What
+// the platform can't report is left out.
+type SelfStatus struct {
+	Hostname        string      `json:"hostname"`
+	LoadAverage     []float64   `json:"load_average,omitempty"`
+	MemoryTotal     uint64      `json:"memory_total_bytes,omitempty"`
+	MemoryAvailable uint64      `json:"memory_available_bytes,omitempty"`
+	Disks           []DiskUsage `json:"disks,omitempty"`
+	OpenFDs         int         `json:"open_fds,omitempty"`
+	FDLimit         uint64      `json:"fd_limit,omitempty"`
+	Goroutines      int         `json:"goroutines"`
+	RSS             uint64      `json:"rss_bytes,omitempty"`
+	Sampled         time.Time   `json:"sampled"`
+}
+
+// selfPaths are the directories whose disk usage is reported: the working
+// directory, data-dir and wherever labwatch keeps its state, snapshot and
+// queue files
+func selfPaths(cfg LabwatchConfig) []string {
+	ret := []string{}
+	add := func(p string) {
+		if abs, err := filepath.Abs(p); err == nil && !slices.Contains(ret, abs) {
+			ret = append(ret, abs)
+		}
+	}
+	add(".")
+	if cfg.DataDir != "" {
+		add(cfg.DataDir)
+	}
+	for _, f := range []string{cfg.StateFile, cfg.SnapshotFile, cfg.NotifyQueueFile} {
+		if f != "" {
+			add(filepath.Dir(f))
+		}
+	}
+	return ret
+}
+
+func sampleSelf(paths []string, now time.Time) SelfStatus {
+	hostname, _ := os.Hostname()
+	s := SelfStatus{Hostname: hostname, Goroutines: runtime.NumGoroutine(), Sampled: now}
+	readHostStats(&s, paths)
+	return s
+}
+
+// watchSelf sends a sample at once and then every interval until ctx is done
+func watchSelf(ctx context.Context, cfg LabwatchConfig, out chan<- SelfStatus) {
+	paths := selfPaths(cfg)
+	ticker := clk.NewTicker(time.Duration(cfg.Self.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case out <- sampleSelf(paths, clk.Now()):
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// selfLimits remembers which limits the last sample was over so each
+// crossing is announced once. It is only used from the watch loop.
+type selfLimits struct {
+	cfg     SelfConfig
+	fdsHigh bool
+	rssHigh bool
+	log     *slog.Logger
+}
+
+func newSelfLimits(cfg SelfConfig, log *slog.Logger) *selfLimits {
+	return &selfLimits{cfg: cfg, log: log.With("operation", "selfLimits")}
+}
+
+// check announces the limits the sample crossed or came back under
+func (l *selfLimits) check(s SelfStatus) {
+	if l.cfg.MaxFDPercent > 0 && s.FDLimit > 0 {
+		used := float64(s.OpenFDs) * 100 / float64(s.FDLimit)
+		if high := used > l.cfg.MaxFDPercent; high != l.fdsHigh {
+			l.fdsHigh = high
+			if high {
+				l.announce(true, fmt.Sprintf("labwatch has %d of %d file descriptors open, %.0f%% is above %.0f%%", s.OpenFDs, s.FDLimit, used, l.cfg.MaxFDPercent), s.Sampled)
+			} else {
+				l.announce(false, fmt.Sprintf("labwatch file descriptors back to %d of %d", s.OpenFDs, s.FDLimit), s.Sampled)
+			}
+		}
+	}
+	if l.cfg.MaxRSS > 0 && s.RSS > 0 {
+		if high := s.RSS > uint64(l.cfg.MaxRSS); high != l.rssHigh {
+			l.rssHigh = high
+			if high {
+				l.announce(true, fmt.Sprintf("labwatch resident memory is %d bytes, above %d", s.RSS, l.cfg.MaxRSS), s.Sampled)
+			} else {
+				l.announce(false, fmt.Sprintf("labwatch resident memory back to %d bytes", s.RSS), s.Sampled)
+			}
+		}
+	}
+}
+
+func (l *selfLimits) announce(high bool, msg string, now time.Time) {
+	level := "info"
+	if high {
+		level = "warning"
+		l.log.Warn(msg)
+	} else {
+		l.log.Info(msg)
+	}
+	broadcastEvent(loki.LogEvent{Node: "labwatch", Service: "labwatch", Level: level, Message: msg, Timestamp: now}, l.log)
+}
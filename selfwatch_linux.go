@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readHostStats fills in what /proc and the kernel report. Anything that
+// can't be read is left out of the sample.
+func readHostStats(s *SelfStatus, paths []string) {
+	if b, err := os.ReadFile("/proc/loadavg"); err == nil {
+		fields := strings.Fields(string(b))
+		for i := 0; i < 3 && i < len(fields); i++ {
+			if v, err := strconv.ParseFloat(fields[i], 64); err == nil {
+				s.LoadAverage = append(s.LoadAverage, v)
+			}
+		}
+	}
+
+	meminfo := procKB("/proc/meminfo", "MemTotal:", "MemAvailable:")
+	s.MemoryTotal, s.MemoryAvailable = meminfo["MemTotal:"], meminfo["MemAvailable:"]
+	s.RSS = procKB("/proc/self/status", "VmRSS:")["VmRSS:"]
+
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		s.OpenFDs = len(fds)
+	}
+	limit := unix.Rlimit{}
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limit); err == nil {
+		s.FDLimit = limit.Cur
+	}
+
+	for _, p := range paths {
+		fs := unix.Statfs_t{}
+		if err := unix.Statfs(p, &fs); err != nil || fs.Blocks == 0 {
+			continue
+		}
+		total := fs.Blocks * uint64(fs.Bsize)
+		free := fs.Bavail * uint64(fs.Bsize)
+		used := (fs.Blocks - fs.Bfree) * uint64(fs.Bsize)
+		s.Disks = append(s.Disks, DiskUsage{
+			Path:        p,
+			TotalBytes:  total,
+			FreeBytes:   free,
+			UsedPercent: float64(used) * 100 / float64(used+free),
+		})
+	}
+}
+
+// procKB reads the named "Key: N kB" lines of a /proc file as bytes
+func procKB(file string, keys ...string) map[string]uint64 {
+	ret := map[string]uint64{}
+	f, err := os.Open(file)
+	if err != nil {
+		return ret
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !slices.Contains(keys, fields[0]) {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			ret[fields[0]] = v * 1024
+		}
+	}
+	return ret
+}
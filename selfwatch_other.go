@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "runtime"
+
+// readHostStats has no /proc to read outside Linux. The Go runtime's own
+// view of the memory it holds stands in for the resident size.
+func readHostStats(s *SelfStatus, paths []string) {
+	m := runtime.MemStats{}
+	runtime.ReadMemStats(&m)
+	s.RSS = m.Sys
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestProtocol(t *testing.T) {
+	for query, want := range map[string]int{
+		"":                      PROTOCOL_V1,
+		"?protocol=v1":          PROTOCOL_V1,
+		"?protocol=2":           PROTOCOL_V1,
+		"?protocol=V2":          PROTOCOL_V1,
+		"?protocol=v2":          PROTOCOL_V2,
+		"?host=cp1&protocol=v2": PROTOCOL_V2,
+	} {
+		if got := Protocol(httptest.NewRequest("GET", "/status"+query, nil)); got != want {
+			t.Errorf("%q asked for protocol %d, want %d", query, got, want)
+		}
+	}
+}
+
+func TestFramer(t *testing.T) {
+	f := &Framer{}
+	hello := f.Frame(TYPE_HELLO, Hello{Version: "1.2.3"})
+	status := f.Frame(TYPE_STATUS, map[string]any{"healthy": true})
+	if hello.Seq != 1 || status.Seq != 2 || hello.Type != TYPE_HELLO || status.Type != TYPE_STATUS {
+		t.Errorf("framed %+v then %+v", hello, status)
+	}
+	if hello.TS.IsZero() || status.TS.Before(hello.TS) {
+		t.Errorf("framed at %s then %s", hello.TS, status.TS)
+	}
+
+	// Events carry the number of the one before them, whatever was framed
+	// between them
+	first := f.FrameEvent("first", 40)
+	f.Frame(TYPE_CONTROL, Control{Action: CONTROL_SUBSCRIBED})
+	second := f.FrameEvent("second", 45)
+	if first.Prev != 0 || second.Prev != 40 || first.Type != TYPE_EVENT || second.Seq != 5 {
+		t.Errorf("framed events %+v then %+v", first, second)
+	}
+
+	// Connections number their messages separately
+	if other := (&Framer{}).Frame(TYPE_HELLO, nil); other.Seq != 1 {
+		t.Errorf("a new connection started at %d", other.Seq)
+	}
+}
+
+// Writes to a connection can come from more than one goroutine, which must
+// never share a number
+func TestFramerConcurrent(t *testing.T) {
+	f := &Framer{}
+	seqs := make(chan uint64, 1000)
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				seqs <- f.Frame(TYPE_STATUS, nil).Seq
+			}
+		}()
+	}
+	wg.Wait()
+	close(seqs)
+	seen := map[uint64]bool{}
+	for seq := range seqs {
+		if seen[seq] || seq == 0 || seq > 1000 {
+			t.Fatalf("seq %d framed twice or out of range", seq)
+		}
+		seen[seq] = true
+	}
+}
+
+func TestEnvelopeJSON(t *testing.T) {
+	f := &Framer{}
+	tests := []struct {
+		name string
+		env  Envelope
+		want map[string]any
+	}{
+		{
+			name: "hello",
+			env:  f.Frame(TYPE_HELLO, Hello{Version: "1.2.3", Instance: "lab", Encoding: "json", Features: []string{"delta"}}),
+			want: map[string]any{"type": "hello", "seq": 1.0, "data": map[string]any{"version": "1.2.3", "instance": "lab", "encoding": "json", "features": []any{"delta"}}},
+		},
+		{
+			// prev is left out until there is an event before
+			name: "first event",
+			env:  f.FrameEvent(map[string]any{"Message": "up"}, 7),
+			want: map[string]any{"type": "event", "seq": 2.0, "data": map[string]any{"Message": "up"}},
+		},
+		{
+			name: "event",
+			env:  f.FrameEvent(map[string]any{"Message": "down"}, 9),
+			want: map[string]any{"type": "event", "seq": 3.0, "prev": 7.0, "data": map[string]any{"Message": "down"}},
+		},
+		{
+			name: "control",
+			env:  f.Frame(TYPE_CONTROL, Control{Action: CONTROL_GAP_UNRECOVERABLE}),
+			want: map[string]any{"type": "control", "seq": 4.0, "data": map[string]any{"action": "gap_unrecoverable"}},
+		},
+		{
+			name: "backfill",
+			env:  f.Frame(TYPE_BACKFILL, Backfill{From: 3, To: 4, Events: []string{}}),
+			want: map[string]any{"type": "backfill", "seq": 5.0, "data": map[string]any{"from": 3.0, "to": 4.0, "events": []any{}}},
+		},
+		{
+			name: "error",
+			env:  f.Frame(TYPE_ERROR, Error{Message: "unknown action"}),
+			want: map[string]any{"type": "error", "seq": 6.0, "data": map[string]any{"message": "unknown action"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]any{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := got["ts"].(string); !ok {
+				t.Errorf("no ts in %s", b)
+			}
+			delete(got, "ts")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encoded %s", b)
+			}
+		})
+	}
+}
+
+// What clients send is decoded as documented
+func TestClientMessages(t *testing.T) {
+	sub := Subscribe{}
+	if err := json.Unmarshal([]byte(`{"subscribe":["status","events"],"event_filter":{"host":"cp1,cp2"}}`), &sub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sub, Subscribe{Subscribe: []string{SUBSCRIPTION_STATUS, SUBSCRIPTION_EVENTS}, EventFilter: map[string]string{"host": "cp1,cp2"}}) {
+		t.Errorf("decoded %+v", sub)
+	}
+
+	control := ClientControl{}
+	if err := json.Unmarshal([]byte(`{"action":"report_gap","from":41,"to":44}`), &control); err != nil {
+		t.Fatal(err)
+	}
+	if control != (ClientControl{Action: ACTION_REPORT_GAP, From: 41, To: 44}) {
+		t.Errorf("decoded %+v", control)
+	}
+}
@@ -0,0 +1,5 @@
+package server
+
+// labstatus.pb.go holds the types /status?encoding=protobuf is encoded
+// through. Regenerating it needs protoc and protoc-gen-go on the PATH.
+//go:generate protoc --go_out=. --go_opt=paths=source_relative labstatus.proto
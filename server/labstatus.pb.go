@@ -0,0 +1,2447 @@
+// Wire schema for /status?encoding=protobuf. Each message on the wire is a
+// LabStatus preceded by its length as a varint, the same framing as
+// protobuf's writeDelimitedTo. Times are unix milliseconds, 0 when unset.
+// labstatus.pb.go is generated from it with go generate.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.2
+// 	protoc        (unknown)
+// source: labstatus.proto
+
+package server
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LabStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Labwatch         *LabwatchStatus           `protobuf:"bytes,1,opt,name=labwatch,proto3" json:"labwatch,omitempty"`
+	Healthy          bool                      `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Summary          *Summary                  `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`
+	Maintenance      bool                      `protobuf:"varint,4,opt,name=maintenance,proto3" json:"maintenance,omitempty"`
+	MaintenanceUntil int64                     `protobuf:"varint,5,opt,name=maintenance_until,json=maintenanceUntil,proto3" json:"maintenance_until,omitempty"`
+	Sections         map[string]*SectionStatus `protobuf:"bytes,6,rep,name=sections,proto3" json:"sections,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Talos            map[string]*NodeStatus    `protobuf:"bytes,7,rep,name=talos,proto3" json:"talos,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Logs             *LogStats                 `protobuf:"bytes,8,opt,name=logs,proto3" json:"logs,omitempty"`
+	Outputs          map[string]*OutputHealth  `protobuf:"bytes,9,rep,name=outputs,proto3" json:"outputs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	TalosSummary     *TalosSummary             `protobuf:"bytes,10,opt,name=talos_summary,json=talosSummary,proto3" json:"talos_summary,omitempty"`
+	ConfigError      string                    `protobuf:"bytes,11,opt,name=config_error,json=configError,proto3" json:"config_error,omitempty"`
+	TalosEndpoints   []*EndpointStatus         `protobuf:"bytes,12,rep,name=talos_endpoints,json=talosEndpoints,proto3" json:"talos_endpoints,omitempty"`
+	ConfigHash       string                    `protobuf:"bytes,13,opt,name=config_hash,json=configHash,proto3" json:"config_hash,omitempty"`
+	Self             *SelfStatus               `protobuf:"bytes,14,opt,name=self,proto3" json:"self,omitempty"`
+	// served from the previous run's last status until warmup completes
+	FromCache bool  `protobuf:"varint,15,opt,name=from_cache,json=fromCache,proto3" json:"from_cache,omitempty"`
+	CachedAt  int64 `protobuf:"varint,16,opt,name=cached_at,json=cachedAt,proto3" json:"cached_at,omitempty"`
+	// only set when etcd-snapshot has a directory
+	EtcdSnapshot *EtcdSnapshotStatus `protobuf:"bytes,17,opt,name=etcd_snapshot,json=etcdSnapshot,proto3" json:"etcd_snapshot,omitempty"`
+	Churn        *ChurnStatus        `protobuf:"bytes,18,opt,name=churn,proto3" json:"churn,omitempty"`
+	Incidents    []*Incident         `protobuf:"bytes,19,rep,name=incidents,proto3" json:"incidents,omitempty"`
+}
+
+func (x *LabStatus) Reset() {
+	*x = LabStatus{}
+	mi := &file_labstatus_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LabStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LabStatus) ProtoMessage() {}
+
+func (x *LabStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LabStatus.ProtoReflect.Descriptor instead.
+func (*LabStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LabStatus) GetLabwatch() *LabwatchStatus {
+	if x != nil {
+		return x.Labwatch
+	}
+	return nil
+}
+
+func (x *LabStatus) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *LabStatus) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *LabStatus) GetMaintenance() bool {
+	if x != nil {
+		return x.Maintenance
+	}
+	return false
+}
+
+func (x *LabStatus) GetMaintenanceUntil() int64 {
+	if x != nil {
+		return x.MaintenanceUntil
+	}
+	return 0
+}
+
+func (x *LabStatus) GetSections() map[string]*SectionStatus {
+	if x != nil {
+		return x.Sections
+	}
+	return nil
+}
+
+func (x *LabStatus) GetTalos() map[string]*NodeStatus {
+	if x != nil {
+		return x.Talos
+	}
+	return nil
+}
+
+func (x *LabStatus) GetLogs() *LogStats {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+func (x *LabStatus) GetOutputs() map[string]*OutputHealth {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+func (x *LabStatus) GetTalosSummary() *TalosSummary {
+	if x != nil {
+		return x.TalosSummary
+	}
+	return nil
+}
+
+func (x *LabStatus) GetConfigError() string {
+	if x != nil {
+		return x.ConfigError
+	}
+	return ""
+}
+
+func (x *LabStatus) GetTalosEndpoints() []*EndpointStatus {
+	if x != nil {
+		return x.TalosEndpoints
+	}
+	return nil
+}
+
+func (x *LabStatus) GetConfigHash() string {
+	if x != nil {
+		return x.ConfigHash
+	}
+	return ""
+}
+
+func (x *LabStatus) GetSelf() *SelfStatus {
+	if x != nil {
+		return x.Self
+	}
+	return nil
+}
+
+func (x *LabStatus) GetFromCache() bool {
+	if x != nil {
+		return x.FromCache
+	}
+	return false
+}
+
+func (x *LabStatus) GetCachedAt() int64 {
+	if x != nil {
+		return x.CachedAt
+	}
+	return 0
+}
+
+func (x *LabStatus) GetEtcdSnapshot() *EtcdSnapshotStatus {
+	if x != nil {
+		return x.EtcdSnapshot
+	}
+	return nil
+}
+
+func (x *LabStatus) GetChurn() *ChurnStatus {
+	if x != nil {
+		return x.Churn
+	}
+	return nil
+}
+
+func (x *LabStatus) GetIncidents() []*Incident {
+	if x != nil {
+		return x.Incidents
+	}
+	return nil
+}
+
+// an open incident, without the transitions /incidents lists
+type Incident struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Start    int64    `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End      int64    `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	Entities []string `protobuf:"bytes,4,rep,name=entities,proto3" json:"entities,omitempty"`
+	// node/check of every check involved
+	Checks []string `protobuf:"bytes,5,rep,name=checks,proto3" json:"checks,omitempty"`
+	Peak   string   `protobuf:"bytes,6,opt,name=peak,proto3" json:"peak,omitempty"`
+}
+
+func (x *Incident) Reset() {
+	*x = Incident{}
+	mi := &file_labstatus_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Incident) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Incident) ProtoMessage() {}
+
+func (x *Incident) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Incident.ProtoReflect.Descriptor instead.
+func (*Incident) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Incident) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Incident) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Incident) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Incident) GetEntities() []string {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+func (x *Incident) GetChecks() []string {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+func (x *Incident) GetPeak() string {
+	if x != nil {
+		return x.Peak
+	}
+	return ""
+}
+
+type TalosSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Total               int64    `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Healthy             int64    `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	ControlPlane        int64    `protobuf:"varint,3,opt,name=control_plane,json=controlPlane,proto3" json:"control_plane,omitempty"`
+	ControlPlaneHealthy int64    `protobuf:"varint,4,opt,name=control_plane_healthy,json=controlPlaneHealthy,proto3" json:"control_plane_healthy,omitempty"`
+	EtcdHealthy         bool     `protobuf:"varint,5,opt,name=etcd_healthy,json=etcdHealthy,proto3" json:"etcd_healthy,omitempty"`
+	Unreachable         []string `protobuf:"bytes,6,rep,name=unreachable,proto3" json:"unreachable,omitempty"`
+	Versions            []string `protobuf:"bytes,7,rep,name=versions,proto3" json:"versions,omitempty"`
+	VersionsConsistent  bool     `protobuf:"varint,8,opt,name=versions_consistent,json=versionsConsistent,proto3" json:"versions_consistent,omitempty"`
+	Worst               string   `protobuf:"bytes,9,opt,name=worst,proto3" json:"worst,omitempty"`
+	EndpointsDown       bool     `protobuf:"varint,10,opt,name=endpoints_down,json=endpointsDown,proto3" json:"endpoints_down,omitempty"`
+}
+
+func (x *TalosSummary) Reset() {
+	*x = TalosSummary{}
+	mi := &file_labstatus_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TalosSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TalosSummary) ProtoMessage() {}
+
+func (x *TalosSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TalosSummary.ProtoReflect.Descriptor instead.
+func (*TalosSummary) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TalosSummary) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *TalosSummary) GetHealthy() int64 {
+	if x != nil {
+		return x.Healthy
+	}
+	return 0
+}
+
+func (x *TalosSummary) GetControlPlane() int64 {
+	if x != nil {
+		return x.ControlPlane
+	}
+	return 0
+}
+
+func (x *TalosSummary) GetControlPlaneHealthy() int64 {
+	if x != nil {
+		return x.ControlPlaneHealthy
+	}
+	return 0
+}
+
+func (x *TalosSummary) GetEtcdHealthy() bool {
+	if x != nil {
+		return x.EtcdHealthy
+	}
+	return false
+}
+
+func (x *TalosSummary) GetUnreachable() []string {
+	if x != nil {
+		return x.Unreachable
+	}
+	return nil
+}
+
+func (x *TalosSummary) GetVersions() []string {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+func (x *TalosSummary) GetVersionsConsistent() bool {
+	if x != nil {
+		return x.VersionsConsistent
+	}
+	return false
+}
+
+func (x *TalosSummary) GetWorst() string {
+	if x != nil {
+		return x.Worst
+	}
+	return ""
+}
+
+func (x *TalosSummary) GetEndpointsDown() bool {
+	if x != nil {
+		return x.EndpointsDown
+	}
+	return false
+}
+
+type EndpointStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Endpoint  string `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Healthy   bool   `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Active    bool   `protobuf:"varint,3,opt,name=active,proto3" json:"active,omitempty"`
+	LastUsed  int64  `protobuf:"varint,4,opt,name=last_used,json=lastUsed,proto3" json:"last_used,omitempty"`
+	LastError string `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+}
+
+func (x *EndpointStatus) Reset() {
+	*x = EndpointStatus{}
+	mi := &file_labstatus_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EndpointStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointStatus) ProtoMessage() {}
+
+func (x *EndpointStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointStatus.ProtoReflect.Descriptor instead.
+func (*EndpointStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EndpointStatus) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *EndpointStatus) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *EndpointStatus) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *EndpointStatus) GetLastUsed() int64 {
+	if x != nil {
+		return x.LastUsed
+	}
+	return 0
+}
+
+func (x *EndpointStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+// the host and process labwatch itself runs on
+type SelfStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hostname             string       `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	LoadAverage          []float64    `protobuf:"fixed64,2,rep,packed,name=load_average,json=loadAverage,proto3" json:"load_average,omitempty"`
+	MemoryTotalBytes     int64        `protobuf:"varint,3,opt,name=memory_total_bytes,json=memoryTotalBytes,proto3" json:"memory_total_bytes,omitempty"`
+	MemoryAvailableBytes int64        `protobuf:"varint,4,opt,name=memory_available_bytes,json=memoryAvailableBytes,proto3" json:"memory_available_bytes,omitempty"`
+	Disks                []*DiskUsage `protobuf:"bytes,5,rep,name=disks,proto3" json:"disks,omitempty"`
+	OpenFds              int64        `protobuf:"varint,6,opt,name=open_fds,json=openFds,proto3" json:"open_fds,omitempty"`
+	FdLimit              int64        `protobuf:"varint,7,opt,name=fd_limit,json=fdLimit,proto3" json:"fd_limit,omitempty"`
+	Goroutines           int64        `protobuf:"varint,8,opt,name=goroutines,proto3" json:"goroutines,omitempty"`
+	RssBytes             int64        `protobuf:"varint,9,opt,name=rss_bytes,json=rssBytes,proto3" json:"rss_bytes,omitempty"`
+	Sampled              int64        `protobuf:"varint,10,opt,name=sampled,proto3" json:"sampled,omitempty"`
+}
+
+func (x *SelfStatus) Reset() {
+	*x = SelfStatus{}
+	mi := &file_labstatus_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SelfStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SelfStatus) ProtoMessage() {}
+
+func (x *SelfStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SelfStatus.ProtoReflect.Descriptor instead.
+func (*SelfStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SelfStatus) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *SelfStatus) GetLoadAverage() []float64 {
+	if x != nil {
+		return x.LoadAverage
+	}
+	return nil
+}
+
+func (x *SelfStatus) GetMemoryTotalBytes() int64 {
+	if x != nil {
+		return x.MemoryTotalBytes
+	}
+	return 0
+}
+
+func (x *SelfStatus) GetMemoryAvailableBytes() int64 {
+	if x != nil {
+		return x.MemoryAvailableBytes
+	}
+	return 0
+}
+
+func (x *SelfStatus) GetDisks() []*DiskUsage {
+	if x != nil {
+		return x.Disks
+	}
+	return nil
+}
+
+func (x *SelfStatus) GetOpenFds() int64 {
+	if x != nil {
+		return x.OpenFds
+	}
+	return 0
+}
+
+func (x *SelfStatus) GetFdLimit() int64 {
+	if x != nil {
+		return x.FdLimit
+	}
+	return 0
+}
+
+func (x *SelfStatus) GetGoroutines() int64 {
+	if x != nil {
+		return x.Goroutines
+	}
+	return 0
+}
+
+func (x *SelfStatus) GetRssBytes() int64 {
+	if x != nil {
+		return x.RssBytes
+	}
+	return 0
+}
+
+func (x *SelfStatus) GetSampled() int64 {
+	if x != nil {
+		return x.Sampled
+	}
+	return 0
+}
+
+// state transitions counted over the sliding window
+type ChurnStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Window string `protobuf:"bytes,1,opt,name=window,proto3" json:"window,omitempty"`
+	// transitions per hour over which an entity is churning, 0 to only count
+	Threshold float64                 `protobuf:"fixed64,2,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	Watchers  map[string]int64        `protobuf:"bytes,3,rep,name=watchers,proto3" json:"watchers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Entities  map[string]*EntityChurn `protobuf:"bytes,4,rep,name=entities,proto3" json:"entities,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *ChurnStatus) Reset() {
+	*x = ChurnStatus{}
+	mi := &file_labstatus_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChurnStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChurnStatus) ProtoMessage() {}
+
+func (x *ChurnStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChurnStatus.ProtoReflect.Descriptor instead.
+func (*ChurnStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChurnStatus) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+func (x *ChurnStatus) GetThreshold() float64 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+func (x *ChurnStatus) GetWatchers() map[string]int64 {
+	if x != nil {
+		return x.Watchers
+	}
+	return nil
+}
+
+func (x *ChurnStatus) GetEntities() map[string]*EntityChurn {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+type EntityChurn struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Watcher     string  `protobuf:"bytes,1,opt,name=watcher,proto3" json:"watcher,omitempty"`
+	Transitions int64   `protobuf:"varint,2,opt,name=transitions,proto3" json:"transitions,omitempty"`
+	PerHour     float64 `protobuf:"fixed64,3,opt,name=per_hour,json=perHour,proto3" json:"per_hour,omitempty"`
+	Churning    bool    `protobuf:"varint,4,opt,name=churning,proto3" json:"churning,omitempty"`
+}
+
+func (x *EntityChurn) Reset() {
+	*x = EntityChurn{}
+	mi := &file_labstatus_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EntityChurn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EntityChurn) ProtoMessage() {}
+
+func (x *EntityChurn) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EntityChurn.ProtoReflect.Descriptor instead.
+func (*EntityChurn) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EntityChurn) GetWatcher() string {
+	if x != nil {
+		return x.Watcher
+	}
+	return ""
+}
+
+func (x *EntityChurn) GetTransitions() int64 {
+	if x != nil {
+		return x.Transitions
+	}
+	return 0
+}
+
+func (x *EntityChurn) GetPerHour() float64 {
+	if x != nil {
+		return x.PerHour
+	}
+	return 0
+}
+
+func (x *EntityChurn) GetChurning() bool {
+	if x != nil {
+		return x.Churning
+	}
+	return false
+}
+
+// the newest etcd snapshot in the etcd-snapshot directory
+type EtcdSnapshotStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Directory string `protobuf:"bytes,1,opt,name=directory,proto3" json:"directory,omitempty"`
+	Newest    string `protobuf:"bytes,2,opt,name=newest,proto3" json:"newest,omitempty"`
+	Taken     int64  `protobuf:"varint,3,opt,name=taken,proto3" json:"taken,omitempty"`
+	SizeBytes int64  `protobuf:"varint,4,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	Stale     bool   `protobuf:"varint,5,opt,name=stale,proto3" json:"stale,omitempty"`
+	Error     string `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	Checked   int64  `protobuf:"varint,7,opt,name=checked,proto3" json:"checked,omitempty"`
+}
+
+func (x *EtcdSnapshotStatus) Reset() {
+	*x = EtcdSnapshotStatus{}
+	mi := &file_labstatus_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EtcdSnapshotStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EtcdSnapshotStatus) ProtoMessage() {}
+
+func (x *EtcdSnapshotStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EtcdSnapshotStatus.ProtoReflect.Descriptor instead.
+func (*EtcdSnapshotStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *EtcdSnapshotStatus) GetDirectory() string {
+	if x != nil {
+		return x.Directory
+	}
+	return ""
+}
+
+func (x *EtcdSnapshotStatus) GetNewest() string {
+	if x != nil {
+		return x.Newest
+	}
+	return ""
+}
+
+func (x *EtcdSnapshotStatus) GetTaken() int64 {
+	if x != nil {
+		return x.Taken
+	}
+	return 0
+}
+
+func (x *EtcdSnapshotStatus) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *EtcdSnapshotStatus) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+func (x *EtcdSnapshotStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *EtcdSnapshotStatus) GetChecked() int64 {
+	if x != nil {
+		return x.Checked
+	}
+	return 0
+}
+
+type DiskUsage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Path        string  `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	TotalBytes  int64   `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	FreeBytes   int64   `protobuf:"varint,3,opt,name=free_bytes,json=freeBytes,proto3" json:"free_bytes,omitempty"`
+	UsedPercent float64 `protobuf:"fixed64,4,opt,name=used_percent,json=usedPercent,proto3" json:"used_percent,omitempty"`
+}
+
+func (x *DiskUsage) Reset() {
+	*x = DiskUsage{}
+	mi := &file_labstatus_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiskUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiskUsage) ProtoMessage() {}
+
+func (x *DiskUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiskUsage.ProtoReflect.Descriptor instead.
+func (*DiskUsage) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DiskUsage) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DiskUsage) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *DiskUsage) GetFreeBytes() int64 {
+	if x != nil {
+		return x.FreeBytes
+	}
+	return 0
+}
+
+func (x *DiskUsage) GetUsedPercent() float64 {
+	if x != nil {
+		return x.UsedPercent
+	}
+	return 0
+}
+
+type LabwatchStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	State             string `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	EstimatedDowntime string `protobuf:"bytes,2,opt,name=estimated_downtime,json=estimatedDowntime,proto3" json:"estimated_downtime,omitempty"`
+}
+
+func (x *LabwatchStatus) Reset() {
+	*x = LabwatchStatus{}
+	mi := &file_labstatus_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LabwatchStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LabwatchStatus) ProtoMessage() {}
+
+func (x *LabwatchStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LabwatchStatus.ProtoReflect.Descriptor instead.
+func (*LabwatchStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *LabwatchStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *LabwatchStatus) GetEstimatedDowntime() string {
+	if x != nil {
+		return x.EstimatedDowntime
+	}
+	return ""
+}
+
+type Summary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	State      string           `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Counts     map[string]int64 `protobuf:"bytes,2,rep,name=counts,proto3" json:"counts,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Suppressed int64            `protobuf:"varint,3,opt,name=suppressed,proto3" json:"suppressed,omitempty"`
+	Reasons    []string         `protobuf:"bytes,4,rep,name=reasons,proto3" json:"reasons,omitempty"`
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_labstatus_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Summary) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Summary) GetCounts() map[string]int64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *Summary) GetSuppressed() int64 {
+	if x != nil {
+		return x.Suppressed
+	}
+	return 0
+}
+
+func (x *Summary) GetReasons() []string {
+	if x != nil {
+		return x.Reasons
+	}
+	return nil
+}
+
+type SectionStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastUpdated int64  `protobuf:"varint,1,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	Stale       bool   `protobuf:"varint,2,opt,name=stale,proto3" json:"stale,omitempty"`
+	Degraded    string `protobuf:"bytes,3,opt,name=degraded,proto3" json:"degraded,omitempty"`
+	Cached      bool   `protobuf:"varint,4,opt,name=cached,proto3" json:"cached,omitempty"`
+}
+
+func (x *SectionStatus) Reset() {
+	*x = SectionStatus{}
+	mi := &file_labstatus_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SectionStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SectionStatus) ProtoMessage() {}
+
+func (x *SectionStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SectionStatus.ProtoReflect.Descriptor instead.
+func (*SectionStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SectionStatus) GetLastUpdated() int64 {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return 0
+}
+
+func (x *SectionStatus) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+func (x *SectionStatus) GetDegraded() string {
+	if x != nil {
+		return x.Degraded
+	}
+	return ""
+}
+
+func (x *SectionStatus) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+type OutputHealth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Healthy bool  `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Queued  int64 `protobuf:"varint,2,opt,name=queued,proto3" json:"queued,omitempty"`
+	Dropped int64 `protobuf:"varint,3,opt,name=dropped,proto3" json:"dropped,omitempty"`
+}
+
+func (x *OutputHealth) Reset() {
+	*x = OutputHealth{}
+	mi := &file_labstatus_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputHealth) ProtoMessage() {}
+
+func (x *OutputHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputHealth.ProtoReflect.Descriptor instead.
+func (*OutputHealth) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OutputHealth) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *OutputHealth) GetQueued() int64 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *OutputHealth) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+type NodeStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Node            string                    `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	WatcherState    string                    `protobuf:"bytes,2,opt,name=watcher_state,json=watcherState,proto3" json:"watcher_state,omitempty"`
+	Stage           string                    `protobuf:"bytes,3,opt,name=stage,proto3" json:"stage,omitempty"`
+	Ready           bool                      `protobuf:"varint,4,opt,name=ready,proto3" json:"ready,omitempty"`
+	Addresses       []string                  `protobuf:"bytes,5,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	Services        map[string]*ServiceStatus `protobuf:"bytes,6,rep,name=services,proto3" json:"services,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Error           string                    `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	PodCount        int64                     `protobuf:"varint,8,opt,name=pod_count,json=podCount,proto3" json:"pod_count,omitempty"`
+	PodCapacity     int64                     `protobuf:"varint,9,opt,name=pod_capacity,json=podCapacity,proto3" json:"pod_capacity,omitempty"`
+	SuppressedBy    string                    `protobuf:"bytes,10,opt,name=suppressed_by,json=suppressedBy,proto3" json:"suppressed_by,omitempty"`
+	UnmetConditions []string                  `protobuf:"bytes,11,rep,name=unmet_conditions,json=unmetConditions,proto3" json:"unmet_conditions,omitempty"`
+	LastUpdated     int64                     `protobuf:"varint,12,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	Injected        bool                      `protobuf:"varint,13,opt,name=injected,proto3" json:"injected,omitempty"`
+	Version         string                    `protobuf:"bytes,14,opt,name=version,proto3" json:"version,omitempty"`
+	Metrics         map[string]float64        `protobuf:"bytes,15,rep,name=metrics,proto3" json:"metrics,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+	// metric to the severity of the worst threshold crossed
+	Degraded map[string]string `protobuf:"bytes,16,rep,name=degraded,proto3" json:"degraded,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// kept for a grace period after the watcher stopped reporting the node
+	Departed   bool  `protobuf:"varint,17,opt,name=departed,proto3" json:"departed,omitempty"`
+	DepartedAt int64 `protobuf:"varint,18,opt,name=departed_at,json=departedAt,proto3" json:"departed_at,omitempty"`
+	// notifications about the node are held back by an admin silence
+	Silenced bool `protobuf:"varint,19,opt,name=silenced,proto3" json:"silenced,omitempty"`
+	// most recent machine stage transitions, oldest first
+	StageHistory []*StageTransition `protobuf:"bytes,20,rep,name=stage_history,json=stageHistory,proto3" json:"stage_history,omitempty"`
+	// name of the Kubernetes node the Talos node runs
+	KubernetesNode string `protobuf:"bytes,21,opt,name=kubernetes_node,json=kubernetesNode,proto3" json:"kubernetes_node,omitempty"`
+	// boot phases, tasks and sequences as Talos reports them, with their state
+	Phase     map[string]string `protobuf:"bytes,22,rep,name=phase,proto3" json:"phase,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Tasks     map[string]string `protobuf:"bytes,23,rep,name=tasks,proto3" json:"tasks,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Sequences map[string]string `protobuf:"bytes,24,rep,name=sequences,proto3" json:"sequences,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// the name the node was known by before identity mapping renamed it
+	SourceId string `protobuf:"bytes,25,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	// set while the node sits in maintenance mode waiting for a config
+	Maintenance *MaintenanceInfo `protobuf:"bytes,26,opt,name=maintenance,proto3" json:"maintenance,omitempty"`
+	// hasn't reported within the talos staleness threshold
+	Stale bool `protobuf:"varint,27,opt,name=stale,proto3" json:"stale,omitempty"`
+}
+
+func (x *NodeStatus) Reset() {
+	*x = NodeStatus{}
+	mi := &file_labstatus_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeStatus) ProtoMessage() {}
+
+func (x *NodeStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeStatus.ProtoReflect.Descriptor instead.
+func (*NodeStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *NodeStatus) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetWatcherState() string {
+	if x != nil {
+		return x.WatcherState
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *NodeStatus) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetServices() map[string]*ServiceStatus {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetPodCount() int64 {
+	if x != nil {
+		return x.PodCount
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetPodCapacity() int64 {
+	if x != nil {
+		return x.PodCapacity
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetSuppressedBy() string {
+	if x != nil {
+		return x.SuppressedBy
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetUnmetConditions() []string {
+	if x != nil {
+		return x.UnmetConditions
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetLastUpdated() int64 {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetInjected() bool {
+	if x != nil {
+		return x.Injected
+	}
+	return false
+}
+
+func (x *NodeStatus) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetMetrics() map[string]float64 {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetDegraded() map[string]string {
+	if x != nil {
+		return x.Degraded
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetDeparted() bool {
+	if x != nil {
+		return x.Departed
+	}
+	return false
+}
+
+func (x *NodeStatus) GetDepartedAt() int64 {
+	if x != nil {
+		return x.DepartedAt
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetSilenced() bool {
+	if x != nil {
+		return x.Silenced
+	}
+	return false
+}
+
+func (x *NodeStatus) GetStageHistory() []*StageTransition {
+	if x != nil {
+		return x.StageHistory
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetKubernetesNode() string {
+	if x != nil {
+		return x.KubernetesNode
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetPhase() map[string]string {
+	if x != nil {
+		return x.Phase
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetTasks() map[string]string {
+	if x != nil {
+		return x.Tasks
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetSequences() map[string]string {
+	if x != nil {
+		return x.Sequences
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetMaintenance() *MaintenanceInfo {
+	if x != nil {
+		return x.Maintenance
+	}
+	return nil
+}
+
+func (x *NodeStatus) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+type MaintenanceInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version  string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Arch     string `protobuf:"bytes,2,opt,name=arch,proto3" json:"arch,omitempty"`
+	Platform string `protobuf:"bytes,3,opt,name=platform,proto3" json:"platform,omitempty"`
+}
+
+func (x *MaintenanceInfo) Reset() {
+	*x = MaintenanceInfo{}
+	mi := &file_labstatus_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MaintenanceInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MaintenanceInfo) ProtoMessage() {}
+
+func (x *MaintenanceInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MaintenanceInfo.ProtoReflect.Descriptor instead.
+func (*MaintenanceInfo) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MaintenanceInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *MaintenanceInfo) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *MaintenanceInfo) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+type StageTransition struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To   string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Time int64  `protobuf:"varint,3,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *StageTransition) Reset() {
+	*x = StageTransition{}
+	mi := &file_labstatus_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StageTransition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StageTransition) ProtoMessage() {}
+
+func (x *StageTransition) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StageTransition.ProtoReflect.Descriptor instead.
+func (*StageTransition) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *StageTransition) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *StageTransition) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *StageTransition) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+type ServiceStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	State      string `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Message    string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Healthy    string `protobuf:"bytes,3,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	LastChange int64  `protobuf:"varint,4,opt,name=last_change,json=lastChange,proto3" json:"last_change,omitempty"`
+}
+
+func (x *ServiceStatus) Reset() {
+	*x = ServiceStatus{}
+	mi := &file_labstatus_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceStatus) ProtoMessage() {}
+
+func (x *ServiceStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceStatus.ProtoReflect.Descriptor instead.
+func (*ServiceStatus) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ServiceStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ServiceStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ServiceStatus) GetHealthy() string {
+	if x != nil {
+		return x.Healthy
+	}
+	return ""
+}
+
+func (x *ServiceStatus) GetLastChange() int64 {
+	if x != nil {
+		return x.LastChange
+	}
+	return 0
+}
+
+type LogStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NumMessages            int64 `protobuf:"varint,1,opt,name=num_messages,json=numMessages,proto3" json:"num_messages,omitempty"`
+	NumEmergencyMessages   int64 `protobuf:"varint,2,opt,name=num_emergency_messages,json=numEmergencyMessages,proto3" json:"num_emergency_messages,omitempty"`
+	NumAlertMessages       int64 `protobuf:"varint,3,opt,name=num_alert_messages,json=numAlertMessages,proto3" json:"num_alert_messages,omitempty"`
+	NumCriticalMessages    int64 `protobuf:"varint,4,opt,name=num_critical_messages,json=numCriticalMessages,proto3" json:"num_critical_messages,omitempty"`
+	NumErrorMessages       int64 `protobuf:"varint,5,opt,name=num_error_messages,json=numErrorMessages,proto3" json:"num_error_messages,omitempty"`
+	NumWarnMessages        int64 `protobuf:"varint,6,opt,name=num_warn_messages,json=numWarnMessages,proto3" json:"num_warn_messages,omitempty"`
+	NumNoticeMessages      int64 `protobuf:"varint,7,opt,name=num_notice_messages,json=numNoticeMessages,proto3" json:"num_notice_messages,omitempty"`
+	NumInfoMessages        int64 `protobuf:"varint,8,opt,name=num_info_messages,json=numInfoMessages,proto3" json:"num_info_messages,omitempty"`
+	NumDebugMessages       int64 `protobuf:"varint,9,opt,name=num_debug_messages,json=numDebugMessages,proto3" json:"num_debug_messages,omitempty"`
+	NumDnsQueries          int64 `protobuf:"varint,10,opt,name=num_dns_queries,json=numDnsQueries,proto3" json:"num_dns_queries,omitempty"`
+	NumDnsLocal            int64 `protobuf:"varint,11,opt,name=num_dns_local,json=numDnsLocal,proto3" json:"num_dns_local,omitempty"`
+	NumDnsRecursions       int64 `protobuf:"varint,12,opt,name=num_dns_recursions,json=numDnsRecursions,proto3" json:"num_dns_recursions,omitempty"`
+	NumDnsCached           int64 `protobuf:"varint,13,opt,name=num_dns_cached,json=numDnsCached,proto3" json:"num_dns_cached,omitempty"`
+	NumCertChecks          int64 `protobuf:"varint,14,opt,name=num_cert_checks,json=numCertChecks,proto3" json:"num_cert_checks,omitempty"`
+	NumCertOk              int64 `protobuf:"varint,15,opt,name=num_cert_ok,json=numCertOk,proto3" json:"num_cert_ok,omitempty"`
+	NumCertSigned          int64 `protobuf:"varint,16,opt,name=num_cert_signed,json=numCertSigned,proto3" json:"num_cert_signed,omitempty"`
+	NumFirewallWanInDrops  int64 `protobuf:"varint,17,opt,name=num_firewall_wan_in_drops,json=numFirewallWanInDrops,proto3" json:"num_firewall_wan_in_drops,omitempty"`
+	NumFirewallWanOutDrops int64 `protobuf:"varint,18,opt,name=num_firewall_wan_out_drops,json=numFirewallWanOutDrops,proto3" json:"num_firewall_wan_out_drops,omitempty"`
+	NumFirewallLanInDrops  int64 `protobuf:"varint,19,opt,name=num_firewall_lan_in_drops,json=numFirewallLanInDrops,proto3" json:"num_firewall_lan_in_drops,omitempty"`
+	NumFirewallLanOutDrops int64 `protobuf:"varint,20,opt,name=num_firewall_lan_out_drops,json=numFirewallLanOutDrops,proto3" json:"num_firewall_lan_out_drops,omitempty"`
+	RateLimited            bool  `protobuf:"varint,21,opt,name=rate_limited,json=rateLimited,proto3" json:"rate_limited,omitempty"`
+	PrimaryMatchedNothing  bool  `protobuf:"varint,22,opt,name=primary_matched_nothing,json=primaryMatchedNothing,proto3" json:"primary_matched_nothing,omitempty"`
+	// configured name of the query counted, or the LogQL itself
+	Query string `protobuf:"bytes,23,opt,name=query,proto3" json:"query,omitempty"`
+	// hosts, and host/service streams, tracked for log silence
+	Sources map[string]*LogSourceState `protobuf:"bytes,24,rep,name=sources,proto3" json:"sources,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// ok, unreachable, or unauthorized when Loki refuses the credentials
+	Connection string `protobuf:"bytes,25,opt,name=connection,proto3" json:"connection,omitempty"`
+}
+
+func (x *LogStats) Reset() {
+	*x = LogStats{}
+	mi := &file_labstatus_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogStats) ProtoMessage() {}
+
+func (x *LogStats) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogStats.ProtoReflect.Descriptor instead.
+func (*LogStats) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *LogStats) GetNumMessages() int64 {
+	if x != nil {
+		return x.NumMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumEmergencyMessages() int64 {
+	if x != nil {
+		return x.NumEmergencyMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumAlertMessages() int64 {
+	if x != nil {
+		return x.NumAlertMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumCriticalMessages() int64 {
+	if x != nil {
+		return x.NumCriticalMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumErrorMessages() int64 {
+	if x != nil {
+		return x.NumErrorMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumWarnMessages() int64 {
+	if x != nil {
+		return x.NumWarnMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumNoticeMessages() int64 {
+	if x != nil {
+		return x.NumNoticeMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumInfoMessages() int64 {
+	if x != nil {
+		return x.NumInfoMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumDebugMessages() int64 {
+	if x != nil {
+		return x.NumDebugMessages
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumDnsQueries() int64 {
+	if x != nil {
+		return x.NumDnsQueries
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumDnsLocal() int64 {
+	if x != nil {
+		return x.NumDnsLocal
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumDnsRecursions() int64 {
+	if x != nil {
+		return x.NumDnsRecursions
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumDnsCached() int64 {
+	if x != nil {
+		return x.NumDnsCached
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumCertChecks() int64 {
+	if x != nil {
+		return x.NumCertChecks
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumCertOk() int64 {
+	if x != nil {
+		return x.NumCertOk
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumCertSigned() int64 {
+	if x != nil {
+		return x.NumCertSigned
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumFirewallWanInDrops() int64 {
+	if x != nil {
+		return x.NumFirewallWanInDrops
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumFirewallWanOutDrops() int64 {
+	if x != nil {
+		return x.NumFirewallWanOutDrops
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumFirewallLanInDrops() int64 {
+	if x != nil {
+		return x.NumFirewallLanInDrops
+	}
+	return 0
+}
+
+func (x *LogStats) GetNumFirewallLanOutDrops() int64 {
+	if x != nil {
+		return x.NumFirewallLanOutDrops
+	}
+	return 0
+}
+
+func (x *LogStats) GetRateLimited() bool {
+	if x != nil {
+		return x.RateLimited
+	}
+	return false
+}
+
+func (x *LogStats) GetPrimaryMatchedNothing() bool {
+	if x != nil {
+		return x.PrimaryMatchedNothing
+	}
+	return false
+}
+
+func (x *LogStats) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *LogStats) GetSources() map[string]*LogSourceState {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *LogStats) GetConnection() string {
+	if x != nil {
+		return x.Connection
+	}
+	return ""
+}
+
+type LogSourceState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastSeen  int64 `protobuf:"varint,1,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	LogSilent bool  `protobuf:"varint,2,opt,name=log_silent,json=logSilent,proto3" json:"log_silent,omitempty"`
+}
+
+func (x *LogSourceState) Reset() {
+	*x = LogSourceState{}
+	mi := &file_labstatus_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogSourceState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogSourceState) ProtoMessage() {}
+
+func (x *LogSourceState) ProtoReflect() protoreflect.Message {
+	mi := &file_labstatus_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogSourceState.ProtoReflect.Descriptor instead.
+func (*LogSourceState) Descriptor() ([]byte, []int) {
+	return file_labstatus_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *LogSourceState) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+func (x *LogSourceState) GetLogSilent() bool {
+	if x != nil {
+		return x.LogSilent
+	}
+	return false
+}
+
+var File_labstatus_proto protoreflect.FileDescriptor
+
+var file_labstatus_proto_rawDesc = []byte{
+	0x0a, 0x0f, 0x6c, 0x61, 0x62, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x08, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x22, 0xf6, 0x08, 0x0a, 0x09,
+	0x4c, 0x61, 0x62, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x34, 0x0a, 0x08, 0x6c, 0x61, 0x62,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6c, 0x61,
+	0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x08, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x12,
+	0x18, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x2b, 0x0a, 0x07, 0x73, 0x75, 0x6d,
+	0x6d, 0x61, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6c, 0x61, 0x62,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x52, 0x07, 0x73,
+	0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x6d, 0x61, 0x69, 0x6e, 0x74, 0x65,
+	0x6e, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6d, 0x61, 0x69,
+	0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x2b, 0x0a, 0x11, 0x6d, 0x61, 0x69, 0x6e,
+	0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x10, 0x6d, 0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65,
+	0x55, 0x6e, 0x74, 0x69, 0x6c, 0x12, 0x3d, 0x0a, 0x08, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74,
+	0x63, 0x68, 0x2e, 0x4c, 0x61, 0x62, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x53, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x73, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x34, 0x0a, 0x05, 0x74, 0x61, 0x6c, 0x6f, 0x73, 0x18, 0x07, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4c,
+	0x61, 0x62, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x54, 0x61, 0x6c, 0x6f, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x05, 0x74, 0x61, 0x6c, 0x6f, 0x73, 0x12, 0x26, 0x0a, 0x04, 0x6c, 0x6f,
+	0x67, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61,
+	0x74, 0x63, 0x68, 0x2e, 0x4c, 0x6f, 0x67, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x04, 0x6c, 0x6f,
+	0x67, 0x73, 0x12, 0x3a, 0x0a, 0x07, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x18, 0x09, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4c,
+	0x61, 0x62, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x12, 0x3b,
+	0x0a, 0x0d, 0x74, 0x61, 0x6c, 0x6f, 0x73, 0x5f, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68,
+	0x2e, 0x54, 0x61, 0x6c, 0x6f, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x52, 0x0c, 0x74,
+	0x61, 0x6c, 0x6f, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x0b, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x41,
+	0x0a, 0x0f, 0x74, 0x61, 0x6c, 0x6f, 0x73, 0x5f, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74,
+	0x63, 0x68, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x52, 0x0e, 0x74, 0x61, 0x6c, 0x6f, 0x73, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x68, 0x61, 0x73, 0x68,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48, 0x61,
+	0x73, 0x68, 0x12, 0x28, 0x0a, 0x04, 0x73, 0x65, 0x6c, 0x66, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x53, 0x65, 0x6c, 0x66,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x04, 0x73, 0x65, 0x6c, 0x66, 0x12, 0x1d, 0x0a, 0x0a,
+	0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x63, 0x61, 0x63, 0x68, 0x65, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x09, 0x66, 0x72, 0x6f, 0x6d, 0x43, 0x61, 0x63, 0x68, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63,
+	0x61, 0x63, 0x68, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x63, 0x61, 0x63, 0x68, 0x65, 0x64, 0x41, 0x74, 0x12, 0x41, 0x0a, 0x0d, 0x65, 0x74, 0x63, 0x64,
+	0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1c, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x45, 0x74, 0x63, 0x64, 0x53,
+	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x0c, 0x65,
+	0x74, 0x63, 0x64, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x2b, 0x0a, 0x05, 0x63,
+	0x68, 0x75, 0x72, 0x6e, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6c, 0x61, 0x62,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x52, 0x05, 0x63, 0x68, 0x75, 0x72, 0x6e, 0x12, 0x30, 0x0a, 0x09, 0x69, 0x6e, 0x63, 0x69,
+	0x64, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6c, 0x61,
+	0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x49, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x52,
+	0x09, 0x69, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x73, 0x1a, 0x54, 0x0a, 0x0d, 0x53, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2d, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6c,
+	0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x53, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x1a, 0x4e, 0x0a, 0x0a, 0x54, 0x61, 0x6c, 0x6f, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x2a, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x14, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x1a, 0x52, 0x0a, 0x0c, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x2c, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x16, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0x8a, 0x01, 0x0a, 0x08, 0x49, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x74,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x74,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x18,
+	0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x12, 0x12, 0x0a,
+	0x04, 0x70, 0x65, 0x61, 0x6b, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x65, 0x61,
+	0x6b, 0x22, 0xe6, 0x02, 0x0a, 0x0c, 0x54, 0x61, 0x6c, 0x6f, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61,
+	0x72, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x68, 0x65, 0x61, 0x6c, 0x74,
+	0x68, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x5f, 0x70, 0x6c,
+	0x61, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x72,
+	0x6f, 0x6c, 0x50, 0x6c, 0x61, 0x6e, 0x65, 0x12, 0x32, 0x0a, 0x15, 0x63, 0x6f, 0x6e, 0x74, 0x72,
+	0x6f, 0x6c, 0x5f, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x5f, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x13, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x50,
+	0x6c, 0x61, 0x6e, 0x65, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x65,
+	0x74, 0x63, 0x64, 0x5f, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x0b, 0x65, 0x74, 0x63, 0x64, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x20,
+	0x0a, 0x0b, 0x75, 0x6e, 0x72, 0x65, 0x61, 0x63, 0x68, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x06, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x0b, 0x75, 0x6e, 0x72, 0x65, 0x61, 0x63, 0x68, 0x61, 0x62, 0x6c, 0x65,
+	0x12, 0x1a, 0x0a, 0x08, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x08, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x2f, 0x0a, 0x13,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x5f, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74,
+	0x65, 0x6e, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x12, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x73, 0x43, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a,
+	0x05, 0x77, 0x6f, 0x72, 0x73, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x77, 0x6f,
+	0x72, 0x73, 0x74, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73,
+	0x5f, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x65, 0x6e, 0x64,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x44, 0x6f, 0x77, 0x6e, 0x22, 0x9a, 0x01, 0x0a, 0x0e, 0x45,
+	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a,
+	0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x65, 0x61,
+	0x6c, 0x74, 0x68, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x68, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x6c,
+	0x61, 0x73, 0x74, 0x5f, 0x75, 0x73, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x6c, 0x61, 0x73, 0x74, 0x55, 0x73, 0x65, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x61, 0x73, 0x74,
+	0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6c, 0x61,
+	0x73, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x22, 0xe7, 0x02, 0x0a, 0x0a, 0x53, 0x65, 0x6c, 0x66,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6c, 0x6f, 0x61, 0x64, 0x5f, 0x61, 0x76, 0x65, 0x72, 0x61,
+	0x67, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0b, 0x6c, 0x6f, 0x61, 0x64, 0x41, 0x76,
+	0x65, 0x72, 0x61, 0x67, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x10, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x79,
+	0x74, 0x65, 0x73, 0x12, 0x34, 0x0a, 0x16, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x61, 0x76,
+	0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x14, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x41, 0x76, 0x61, 0x69, 0x6c,
+	0x61, 0x62, 0x6c, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x29, 0x0a, 0x05, 0x64, 0x69, 0x73,
+	0x6b, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61,
+	0x74, 0x63, 0x68, 0x2e, 0x44, 0x69, 0x73, 0x6b, 0x55, 0x73, 0x61, 0x67, 0x65, 0x52, 0x05, 0x64,
+	0x69, 0x73, 0x6b, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x6e, 0x5f, 0x66, 0x64, 0x73,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x6f, 0x70, 0x65, 0x6e, 0x46, 0x64, 0x73, 0x12,
+	0x19, 0x0a, 0x08, 0x66, 0x64, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x07, 0x66, 0x64, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x67, 0x6f,
+	0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a,
+	0x67, 0x6f, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x65, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x73,
+	0x73, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x72,
+	0x73, 0x73, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c,
+	0x65, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65,
+	0x64, 0x22, 0xd6, 0x02, 0x0a, 0x0b, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x68, 0x72,
+	0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x74, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x12, 0x3f, 0x0a, 0x08, 0x77, 0x61, 0x74, 0x63, 0x68,
+	0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6c, 0x61, 0x62, 0x77,
+	0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x65, 0x72, 0x73, 0x12, 0x3f, 0x0a, 0x08, 0x65, 0x6e, 0x74, 0x69,
+	0x74, 0x69, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6c, 0x61, 0x62,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x2e, 0x45, 0x6e, 0x74, 0x69, 0x74, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x08, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x69, 0x65, 0x73, 0x1a, 0x3b, 0x0a, 0x0d, 0x57, 0x61, 0x74,
+	0x63, 0x68, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x52, 0x0a, 0x0d, 0x45, 0x6e, 0x74, 0x69, 0x74, 0x69,
+	0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2b, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61,
+	0x74, 0x63, 0x68, 0x2e, 0x45, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x80, 0x01, 0x0a, 0x0b, 0x45,
+	0x6e, 0x74, 0x69, 0x74, 0x79, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x77, 0x61,
+	0x74, 0x63, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x77, 0x61, 0x74,
+	0x63, 0x68, 0x65, 0x72, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x65, 0x72, 0x5f, 0x68, 0x6f,
+	0x75, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x07, 0x70, 0x65, 0x72, 0x48, 0x6f, 0x75,
+	0x72, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x75, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x08, 0x63, 0x68, 0x75, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x22, 0xc5, 0x01,
+	0x0a, 0x12, 0x45, 0x74, 0x63, 0x64, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x6f, 0x72,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x6f,
+	0x72, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x65, 0x77, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x6e, 0x65, 0x77, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x61,
+	0x6b, 0x65, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x74, 0x61, 0x6b, 0x65, 0x6e,
+	0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x73, 0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12,
+	0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05,
+	0x73, 0x74, 0x61, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x63,
+	0x68, 0x65, 0x63, 0x6b, 0x65, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x65, 0x64, 0x22, 0x82, 0x01, 0x0a, 0x09, 0x44, 0x69, 0x73, 0x6b, 0x55, 0x73,
+	0x61, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x6f, 0x74, 0x61, 0x6c,
+	0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x74, 0x6f,
+	0x74, 0x61, 0x6c, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x66, 0x72, 0x65, 0x65,
+	0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x66, 0x72,
+	0x65, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x75, 0x73, 0x65, 0x64, 0x5f,
+	0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x75,
+	0x73, 0x65, 0x64, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x22, 0x55, 0x0a, 0x0e, 0x4c, 0x61,
+	0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x12, 0x2d, 0x0a, 0x12, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x5f,
+	0x64, 0x6f, 0x77, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11,
+	0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x44, 0x6f, 0x77, 0x6e, 0x74, 0x69, 0x6d,
+	0x65, 0x22, 0xcb, 0x01, 0x0a, 0x07, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x12, 0x35, 0x0a, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x53,
+	0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x2e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x73, 0x75,
+	0x70, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a,
+	0x73, 0x75, 0x70, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x61,
+	0x73, 0x6f, 0x6e, 0x73, 0x1a, 0x39, 0x0a, 0x0b, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22,
+	0x7c, 0x0a, 0x0d, 0x53, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x12, 0x21, 0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x67,
+	0x72, 0x61, 0x64, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x67,
+	0x72, 0x61, 0x64, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x61, 0x63, 0x68, 0x65, 0x64, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x63, 0x61, 0x63, 0x68, 0x65, 0x64, 0x22, 0x5a, 0x0a,
+	0x0c, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x18, 0x0a,
+	0x07, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x71, 0x75, 0x65, 0x75, 0x65,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x12,
+	0x18, 0x0a, 0x07, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x07, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x22, 0xaf, 0x0b, 0x0a, 0x0a, 0x4e, 0x6f,
+	0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x23, 0x0a, 0x0d,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x65, 0x72, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0c, 0x77, 0x61, 0x74, 0x63, 0x68, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x73, 0x74, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x65, 0x61, 0x64, 0x79,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x72, 0x65, 0x61, 0x64, 0x79, 0x12, 0x1c, 0x0a,
+	0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x3e, 0x0a, 0x08, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e,
+	0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x08, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f,
+	0x72, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x6f, 0x64, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x6f, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x21,
+	0x0a, 0x0c, 0x70, 0x6f, 0x64, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x70, 0x6f, 0x64, 0x43, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74,
+	0x79, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x75, 0x70, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x5f,
+	0x62, 0x79, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x75, 0x70, 0x70, 0x72, 0x65,
+	0x73, 0x73, 0x65, 0x64, 0x42, 0x79, 0x12, 0x29, 0x0a, 0x10, 0x75, 0x6e, 0x6d, 0x65, 0x74, 0x5f,
+	0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x0f, 0x75, 0x6e, 0x6d, 0x65, 0x74, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x21, 0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x64, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x0e, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x3b, 0x0a, 0x07, 0x6d, 0x65,
+	0x74, 0x72, 0x69, 0x63, 0x73, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6c, 0x61,
+	0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x2e, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07,
+	0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x12, 0x3e, 0x0a, 0x08, 0x64, 0x65, 0x67, 0x72, 0x61,
+	0x64, 0x65, 0x64, 0x18, 0x10, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x6c, 0x61, 0x62, 0x77,
+	0x61, 0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e,
+	0x44, 0x65, 0x67, 0x72, 0x61, 0x64, 0x65, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x64,
+	0x65, 0x67, 0x72, 0x61, 0x64, 0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x70, 0x61, 0x72,
+	0x74, 0x65, 0x64, 0x18, 0x11, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x64, 0x65, 0x70, 0x61, 0x72,
+	0x74, 0x65, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x64, 0x65, 0x70, 0x61, 0x72, 0x74, 0x65, 0x64, 0x5f,
+	0x61, 0x74, 0x18, 0x12, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x64, 0x65, 0x70, 0x61, 0x72, 0x74,
+	0x65, 0x64, 0x41, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x69, 0x6c, 0x65, 0x6e, 0x63, 0x65, 0x64,
+	0x18, 0x13, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x73, 0x69, 0x6c, 0x65, 0x6e, 0x63, 0x65, 0x64,
+	0x12, 0x3e, 0x0a, 0x0d, 0x73, 0x74, 0x61, 0x67, 0x65, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72,
+	0x79, 0x18, 0x14, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74,
+	0x63, 0x68, 0x2e, 0x53, 0x74, 0x61, 0x67, 0x65, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x0c, 0x73, 0x74, 0x61, 0x67, 0x65, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79,
+	0x12, 0x27, 0x0a, 0x0f, 0x6b, 0x75, 0x62, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x65, 0x73, 0x5f, 0x6e,
+	0x6f, 0x64, 0x65, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x6b, 0x75, 0x62, 0x65, 0x72,
+	0x6e, 0x65, 0x74, 0x65, 0x73, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x35, 0x0a, 0x05, 0x70, 0x68, 0x61,
+	0x73, 0x65, 0x18, 0x16, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61,
+	0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x50,
+	0x68, 0x61, 0x73, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65,
+	0x12, 0x35, 0x0a, 0x05, 0x74, 0x61, 0x73, 0x6b, 0x73, 0x18, 0x17, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1f, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x54, 0x61, 0x73, 0x6b, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x05, 0x74, 0x61, 0x73, 0x6b, 0x73, 0x12, 0x41, 0x0a, 0x09, 0x73, 0x65, 0x71, 0x75, 0x65,
+	0x6e, 0x63, 0x65, 0x73, 0x18, 0x18, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6c, 0x61, 0x62,
+	0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x2e, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x09, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x19, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x49, 0x64, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x61, 0x69, 0x6e, 0x74,
+	0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c,
+	0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61,
+	0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0b, 0x6d, 0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e,
+	0x61, 0x6e, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x18, 0x1b, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x1a, 0x54, 0x0a, 0x0d, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2d, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6c,
+	0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x1a, 0x3a, 0x0a, 0x0c, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3b, 0x0a, 0x0d,
+	0x44, 0x65, 0x67, 0x72, 0x61, 0x64, 0x65, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x38, 0x0a, 0x0a, 0x50, 0x68, 0x61,
+	0x73, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x1a, 0x38, 0x0a, 0x0a, 0x54, 0x61, 0x73, 0x6b, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3c, 0x0a,
+	0x0e, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x5b, 0x0a, 0x0f, 0x4d,
+	0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x18,
+	0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x63, 0x68,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x72, 0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08,
+	0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x22, 0x49, 0x0a, 0x0f, 0x53, 0x74, 0x61, 0x67,
+	0x65, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x66,
+	0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12,
+	0x0e, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x74, 0x6f, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x22, 0x7a, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x79, 0x12, 0x1f,
+	0x0a, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0a, 0x6c, 0x61, 0x73, 0x74, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x22,
+	0xc7, 0x09, 0x0a, 0x08, 0x4c, 0x6f, 0x67, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x21, 0x0a, 0x0c,
+	0x6e, 0x75, 0x6d, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12,
+	0x34, 0x0a, 0x16, 0x6e, 0x75, 0x6d, 0x5f, 0x65, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x6e, 0x63, 0x79,
+	0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x14, 0x6e, 0x75, 0x6d, 0x45, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x6e, 0x63, 0x79, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x6e, 0x75, 0x6d, 0x5f, 0x61, 0x6c, 0x65,
+	0x72, 0x74, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x73, 0x12, 0x32, 0x0a, 0x15, 0x6e, 0x75, 0x6d, 0x5f, 0x63, 0x72, 0x69, 0x74, 0x69,
+	0x63, 0x61, 0x6c, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x13, 0x6e, 0x75, 0x6d, 0x43, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x6e, 0x75, 0x6d, 0x5f, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x2a, 0x0a, 0x11, 0x6e, 0x75, 0x6d, 0x5f, 0x77, 0x61, 0x72,
+	0x6e, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0f, 0x6e, 0x75, 0x6d, 0x57, 0x61, 0x72, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x73, 0x12, 0x2e, 0x0a, 0x13, 0x6e, 0x75, 0x6d, 0x5f, 0x6e, 0x6f, 0x74, 0x69, 0x63, 0x65, 0x5f,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11,
+	0x6e, 0x75, 0x6d, 0x4e, 0x6f, 0x74, 0x69, 0x63, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x73, 0x12, 0x2a, 0x0a, 0x11, 0x6e, 0x75, 0x6d, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x5f, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x6e, 0x75,
+	0x6d, 0x49, 0x6e, 0x66, 0x6f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x2c, 0x0a,
+	0x12, 0x6e, 0x75, 0x6d, 0x5f, 0x64, 0x65, 0x62, 0x75, 0x67, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x44, 0x65,
+	0x62, 0x75, 0x67, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x6e,
+	0x75, 0x6d, 0x5f, 0x64, 0x6e, 0x73, 0x5f, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6e, 0x75, 0x6d, 0x44, 0x6e, 0x73, 0x51, 0x75, 0x65, 0x72,
+	0x69, 0x65, 0x73, 0x12, 0x22, 0x0a, 0x0d, 0x6e, 0x75, 0x6d, 0x5f, 0x64, 0x6e, 0x73, 0x5f, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x44,
+	0x6e, 0x73, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x12, 0x2c, 0x0a, 0x12, 0x6e, 0x75, 0x6d, 0x5f, 0x64,
+	0x6e, 0x73, 0x5f, 0x72, 0x65, 0x63, 0x75, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0c, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x44, 0x6e, 0x73, 0x52, 0x65, 0x63, 0x75, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x24, 0x0a, 0x0e, 0x6e, 0x75, 0x6d, 0x5f, 0x64, 0x6e, 0x73,
+	0x5f, 0x63, 0x61, 0x63, 0x68, 0x65, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6e,
+	0x75, 0x6d, 0x44, 0x6e, 0x73, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x12, 0x26, 0x0a, 0x0f, 0x6e,
+	0x75, 0x6d, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x18, 0x0e,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6e, 0x75, 0x6d, 0x43, 0x65, 0x72, 0x74, 0x43, 0x68, 0x65,
+	0x63, 0x6b, 0x73, 0x12, 0x1e, 0x0a, 0x0b, 0x6e, 0x75, 0x6d, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f,
+	0x6f, 0x6b, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x6e, 0x75, 0x6d, 0x43, 0x65, 0x72,
+	0x74, 0x4f, 0x6b, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x75, 0x6d, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f,
+	0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6e, 0x75,
+	0x6d, 0x43, 0x65, 0x72, 0x74, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x12, 0x38, 0x0a, 0x19, 0x6e,
+	0x75, 0x6d, 0x5f, 0x66, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6c, 0x6c, 0x5f, 0x77, 0x61, 0x6e, 0x5f,
+	0x69, 0x6e, 0x5f, 0x64, 0x72, 0x6f, 0x70, 0x73, 0x18, 0x11, 0x20, 0x01, 0x28, 0x03, 0x52, 0x15,
+	0x6e, 0x75, 0x6d, 0x46, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6c, 0x6c, 0x57, 0x61, 0x6e, 0x49, 0x6e,
+	0x44, 0x72, 0x6f, 0x70, 0x73, 0x12, 0x3a, 0x0a, 0x1a, 0x6e, 0x75, 0x6d, 0x5f, 0x66, 0x69, 0x72,
+	0x65, 0x77, 0x61, 0x6c, 0x6c, 0x5f, 0x77, 0x61, 0x6e, 0x5f, 0x6f, 0x75, 0x74, 0x5f, 0x64, 0x72,
+	0x6f, 0x70, 0x73, 0x18, 0x12, 0x20, 0x01, 0x28, 0x03, 0x52, 0x16, 0x6e, 0x75, 0x6d, 0x46, 0x69,
+	0x72, 0x65, 0x77, 0x61, 0x6c, 0x6c, 0x57, 0x61, 0x6e, 0x4f, 0x75, 0x74, 0x44, 0x72, 0x6f, 0x70,
+	0x73, 0x12, 0x38, 0x0a, 0x19, 0x6e, 0x75, 0x6d, 0x5f, 0x66, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6c,
+	0x6c, 0x5f, 0x6c, 0x61, 0x6e, 0x5f, 0x69, 0x6e, 0x5f, 0x64, 0x72, 0x6f, 0x70, 0x73, 0x18, 0x13,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x15, 0x6e, 0x75, 0x6d, 0x46, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6c,
+	0x6c, 0x4c, 0x61, 0x6e, 0x49, 0x6e, 0x44, 0x72, 0x6f, 0x70, 0x73, 0x12, 0x3a, 0x0a, 0x1a, 0x6e,
+	0x75, 0x6d, 0x5f, 0x66, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6c, 0x6c, 0x5f, 0x6c, 0x61, 0x6e, 0x5f,
+	0x6f, 0x75, 0x74, 0x5f, 0x64, 0x72, 0x6f, 0x70, 0x73, 0x18, 0x14, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x16, 0x6e, 0x75, 0x6d, 0x46, 0x69, 0x72, 0x65, 0x77, 0x61, 0x6c, 0x6c, 0x4c, 0x61, 0x6e, 0x4f,
+	0x75, 0x74, 0x44, 0x72, 0x6f, 0x70, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x61, 0x74, 0x65, 0x5f,
+	0x6c, 0x69, 0x6d, 0x69, 0x74, 0x65, 0x64, 0x18, 0x15, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x72,
+	0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x65, 0x64, 0x12, 0x36, 0x0a, 0x17, 0x70, 0x72,
+	0x69, 0x6d, 0x61, 0x72, 0x79, 0x5f, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x5f, 0x6e, 0x6f,
+	0x74, 0x68, 0x69, 0x6e, 0x67, 0x18, 0x16, 0x20, 0x01, 0x28, 0x08, 0x52, 0x15, 0x70, 0x72, 0x69,
+	0x6d, 0x61, 0x72, 0x79, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x4e, 0x6f, 0x74, 0x68, 0x69,
+	0x6e, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x17, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x39, 0x0a, 0x07, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x73, 0x18, 0x18, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6c, 0x61, 0x62, 0x77,
+	0x61, 0x74, 0x63, 0x68, 0x2e, 0x4c, 0x6f, 0x67, 0x53, 0x74, 0x61, 0x74, 0x73, 0x2e, 0x53, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x19, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x1a, 0x54, 0x0a, 0x0c, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2e, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6c, 0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2e,
+	0x4c, 0x6f, 0x67, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x4c, 0x0a, 0x0e, 0x4c, 0x6f, 0x67,
+	0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x6c,
+	0x61, 0x73, 0x74, 0x5f, 0x73, 0x65, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x6c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x65, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x6f, 0x67, 0x5f,
+	0x73, 0x69, 0x6c, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x6c, 0x6f,
+	0x67, 0x53, 0x69, 0x6c, 0x65, 0x6e, 0x74, 0x42, 0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x44, 0x52, 0x75, 0x67, 0x67, 0x65, 0x72, 0x69, 0x2f, 0x6c,
+	0x61, 0x62, 0x77, 0x61, 0x74, 0x63, 0x68, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_labstatus_proto_rawDescOnce sync.Once
+	file_labstatus_proto_rawDescData = file_labstatus_proto_rawDesc
+)
+
+func file_labstatus_proto_rawDescGZIP() []byte {
+	file_labstatus_proto_rawDescOnce.Do(func() {
+		file_labstatus_proto_rawDescData = protoimpl.X.CompressGZIP(file_labstatus_proto_rawDescData)
+	})
+	return file_labstatus_proto_rawDescData
+}
+
+var file_labstatus_proto_msgTypes = make([]protoimpl.MessageInfo, 32)
+var file_labstatus_proto_goTypes = []any{
+	(*LabStatus)(nil),          // 0: labwatch.LabStatus
+	(*Incident)(nil),           // 1: labwatch.Incident
+	(*TalosSummary)(nil),       // 2: labwatch.TalosSummary
+	(*EndpointStatus)(nil),     // 3: labwatch.EndpointStatus
+	(*SelfStatus)(nil),         // 4: labwatch.SelfStatus
+	(*ChurnStatus)(nil),        // 5: labwatch.ChurnStatus
+	(*EntityChurn)(nil),        // 6: labwatch.EntityChurn
+	(*EtcdSnapshotStatus)(nil), // 7: labwatch.EtcdSnapshotStatus
+	(*DiskUsage)(nil),          // 8: labwatch.DiskUsage
+	(*LabwatchStatus)(nil),     // 9: labwatch.LabwatchStatus
+	(*Summary)(nil),            // 10: labwatch.Summary
+	(*SectionStatus)(nil),      // 11: labwatch.SectionStatus
+	(*OutputHealth)(nil),       // 12: labwatch.OutputHealth
+	(*NodeStatus)(nil),         // 13: labwatch.NodeStatus
+	(*MaintenanceInfo)(nil),    // 14: labwatch.MaintenanceInfo
+	(*StageTransition)(nil),    // 15: labwatch.StageTransition
+	(*ServiceStatus)(nil),      // 16: labwatch.ServiceStatus
+	(*LogStats)(nil),           // 17: labwatch.LogStats
+	(*LogSourceState)(nil),     // 18: labwatch.LogSourceState
+	nil,                        // 19: labwatch.LabStatus.SectionsEntry
+	nil,                        // 20: labwatch.LabStatus.TalosEntry
+	nil,                        // 21: labwatch.LabStatus.OutputsEntry
+	nil,                        // 22: labwatch.ChurnStatus.WatchersEntry
+	nil,                        // 23: labwatch.ChurnStatus.EntitiesEntry
+	nil,                        // 24: labwatch.Summary.CountsEntry
+	nil,                        // 25: labwatch.NodeStatus.ServicesEntry
+	nil,                        // 26: labwatch.NodeStatus.MetricsEntry
+	nil,                        // 27: labwatch.NodeStatus.DegradedEntry
+	nil,                        // 28: labwatch.NodeStatus.PhaseEntry
+	nil,                        // 29: labwatch.NodeStatus.TasksEntry
+	nil,                        // 30: labwatch.NodeStatus.SequencesEntry
+	nil,                        // 31: labwatch.LogStats.SourcesEntry
+}
+var file_labstatus_proto_depIdxs = []int32{
+	9,  // 0: labwatch.LabStatus.labwatch:type_name -> labwatch.LabwatchStatus
+	10, // 1: labwatch.LabStatus.summary:type_name -> labwatch.Summary
+	19, // 2: labwatch.LabStatus.sections:type_name -> labwatch.LabStatus.SectionsEntry
+	20, // 3: labwatch.LabStatus.talos:type_name -> labwatch.LabStatus.TalosEntry
+	17, // 4: labwatch.LabStatus.logs:type_name -> labwatch.LogStats
+	21, // 5: labwatch.LabStatus.outputs:type_name -> labwatch.LabStatus.OutputsEntry
+	2,  // 6: labwatch.LabStatus.talos_summary:type_name -> labwatch.TalosSummary
+	3,  // 7: labwatch.LabStatus.talos_endpoints:type_name -> labwatch.EndpointStatus
+	4,  // 8: labwatch.LabStatus.self:type_name -> labwatch.SelfStatus
+	7,  // 9: labwatch.LabStatus.etcd_snapshot:type_name -> labwatch.EtcdSnapshotStatus
+	5,  // 10: labwatch.LabStatus.churn:type_name -> labwatch.ChurnStatus
+	1,  // 11: labwatch.LabStatus.incidents:type_name -> labwatch.Incident
+	8,  // 12: labwatch.SelfStatus.disks:type_name -> labwatch.DiskUsage
+	22, // 13: labwatch.ChurnStatus.watchers:type_name -> labwatch.ChurnStatus.WatchersEntry
+	23, // 14: labwatch.ChurnStatus.entities:type_name -> labwatch.ChurnStatus.EntitiesEntry
+	24, // 15: labwatch.Summary.counts:type_name -> labwatch.Summary.CountsEntry
+	25, // 16: labwatch.NodeStatus.services:type_name -> labwatch.NodeStatus.ServicesEntry
+	26, // 17: labwatch.NodeStatus.metrics:type_name -> labwatch.NodeStatus.MetricsEntry
+	27, // 18: labwatch.NodeStatus.degraded:type_name -> labwatch.NodeStatus.DegradedEntry
+	15, // 19: labwatch.NodeStatus.stage_history:type_name -> labwatch.StageTransition
+	28, // 20: labwatch.NodeStatus.phase:type_name -> labwatch.NodeStatus.PhaseEntry
+	29, // 21: labwatch.NodeStatus.tasks:type_name -> labwatch.NodeStatus.TasksEntry
+	30, // 22: labwatch.NodeStatus.sequences:type_name -> labwatch.NodeStatus.SequencesEntry
+	14, // 23: labwatch.NodeStatus.maintenance:type_name -> labwatch.MaintenanceInfo
+	31, // 24: labwatch.LogStats.sources:type_name -> labwatch.LogStats.SourcesEntry
+	11, // 25: labwatch.LabStatus.SectionsEntry.value:type_name -> labwatch.SectionStatus
+	13, // 26: labwatch.LabStatus.TalosEntry.value:type_name -> labwatch.NodeStatus
+	12, // 27: labwatch.LabStatus.OutputsEntry.value:type_name -> labwatch.OutputHealth
+	6,  // 28: labwatch.ChurnStatus.EntitiesEntry.value:type_name -> labwatch.EntityChurn
+	16, // 29: labwatch.NodeStatus.ServicesEntry.value:type_name -> labwatch.ServiceStatus
+	18, // 30: labwatch.LogStats.SourcesEntry.value:type_name -> labwatch.LogSourceState
+	31, // [31:31] is the sub-list for method output_type
+	31, // [31:31] is the sub-list for method input_type
+	31, // [31:31] is the sub-list for extension type_name
+	31, // [31:31] is the sub-list for extension extendee
+	0,  // [0:31] is the sub-list for field type_name
+}
+
+func init() { file_labstatus_proto_init() }
+func file_labstatus_proto_init() {
+	if File_labstatus_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_labstatus_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   32,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_labstatus_proto_goTypes,
+		DependencyIndexes: file_labstatus_proto_depIdxs,
+		MessageInfos:      file_labstatus_proto_msgTypes,
+	}.Build()
+	File_labstatus_proto = out.File
+	file_labstatus_proto_rawDesc = nil
+	file_labstatus_proto_goTypes = nil
+	file_labstatus_proto_depIdxs = nil
+}
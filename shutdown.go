@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/DRuggeri/labwatch/sdnotify"
+	"github.com/gorilla/websocket"
+)
+
+type ShutdownSnapshot struct {
+	LabStatus
+	Reason string `json:"reason"`
+}
+
+// shutdownForceGrace is how long past the shutdown timeout the steps that
+// don't take it, like saving state, may run before labwatch exits anyway
+var shutdownForceGrace = time.Duration(5) * time.Second
+
+// watcherStop asks the watch loop to cancel a watcher. The loop answers on
+// stopped with a channel closed once the watcher has returned.
+type watcherStop struct {
+	name    string
+	stopped chan (<-chan struct{})
+}
+
+var watcherStops = make(chan watcherStop)
+
+// shutdownTracker names the component shutdown is waiting on, for the log
+// when it hangs
+type shutdownTracker struct {
+	current atomic.Value
+	log     *slog.Logger
+}
+
+// stop logs a component stopping and how long it took. A component still
+// running once ctx expires is warned about and left behind.
+func (t *shutdownTracker) stop(ctx context.Context, component string, stop func(context.Context)) {
+	t.current.Store(component)
+	t.log.Info("stopping", "component", component)
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		stop(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.log.Info("stopped", "component", component, "duration", time.Since(start).String())
+	case <-ctx.Done():
+		t.log.Warn("did not stop within the shutdown timeout", "component", component, "waited", time.Since(start).String())
+	}
+}
+
+func stopWatcher(name string) func(context.Context) {
+	return func(ctx context.Context) {
+		req := watcherStop{name: name, stopped: make(chan (<-chan struct{}), 1)}
+		select {
+		case watcherStops <- req:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case done := <-req.stopped:
+			select {
+			case <-done:
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+	}
+}
+
+func stopServer(server *http.Server, log *slog.Logger) func(context.Context) {
+	return func(ctx context.Context) {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warn("server did not shut down cleanly", "address", server.Addr, "error", err.Error())
+		}
+	}
+}
+
+// shutdown announces the shutdown to connected clients, stops the HTTP
+// servers, the watchers and the broadcaster in turn and delivers the final
+// status snapshot. Each step is logged with how long it took and all of them
+// are bounded by the configured shutdown timeout; should shutdown still be
+// running shutdownForceGrace after it, labwatch exits naming the step it
+// was stuck on.
+func shutdown(cfg LabwatchConfig, server *http.Server, reason string, log *slog.Logger) {
+	log = log.With("operation", "shutdown")
+	notifySystemd(sdnotify.STOPPING, log)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+
+	steps := &shutdownTracker{log: log}
+	steps.current.Store("shutdown state broadcast")
+	force := time.AfterFunc(time.Duration(cfg.ShutdownTimeout)+shutdownForceGrace, func() {
+		log.Error("shutdown hung, forcing exit", "component", steps.current.Load())
+		os.Exit(1)
+	})
+	defer force.Stop()
+
+	if grpcHealthStatus != nil {
+		// Flips every service to NOT_SERVING so probes see us going away
+		grpcHealthStatus.Shutdown()
+	}
+
+	state := LabwatchStatus{State: LABWATCH_SHUTTING_DOWN}
+	if *restartHint > 0 {
+		state.EstimatedDowntime = restartHint.String()
+	}
+	change := labwatchStateChange{status: state, done: make(chan struct{})}
+	select {
+	case labwatchStateChan <- change:
+		select {
+		case <-change.done:
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+		log.Warn("timed out broadcasting shutdown state")
+	}
+
+	close(stopping)
+	steps.stop(ctx, "websocket clients", func(context.Context) { clientsWG.Wait() })
+	steps.stop(ctx, "http server", stopServer(server, log))
+	if adminServer != nil {
+		steps.stop(ctx, "admin server", stopServer(adminServer, log))
+	}
+	if grpcHealthServer != nil {
+		steps.stop(ctx, "grpc health server", func(context.Context) { grpcHealthServer.Stop() })
+	}
+	steps.stop(ctx, "talos watcher", stopWatcher(WATCHER_TALOS))
+	steps.stop(ctx, "loki watcher", stopWatcher(WATCHER_LOKI))
+	steps.stop(ctx, "broadcaster", func(ctx context.Context) {
+		select {
+		case <-broadcasts.stop():
+		case <-ctx.Done():
+		}
+	})
+	steps.stop(ctx, "outputs", func(context.Context) { stopOutputs() })
+
+	steps.current.Store("snapshot file")
+	if cfg.SnapshotFile != "" {
+		if err := writeSnapshotFile(cfg.SnapshotFile, loadStatus()); err != nil {
+			log.Error("failed to write snapshot file", "error", err.Error(), "file", cfg.SnapshotFile)
+		} else {
+			log.Info("wrote snapshot file", "file", cfg.SnapshotFile)
+		}
+	}
+
+	steps.current.Store("state file")
+	if cfg.StateFile != "" {
+		if err := saveRuntimeState(cfg.StateFile, captureRuntimeState(cfg.InstanceName, time.Now())); err != nil {
+			log.Error("failed to save state", "error", err.Error(), "file", cfg.StateFile)
+		} else {
+			log.Info("saved state", "file", cfg.StateFile)
+		}
+	}
+
+	steps.current.Store("shutdown webhook")
+	if cfg.ShutdownWebhook != "" && notificationsMuted() {
+		log.Info("skipping shutdown webhook during maintenance")
+	} else if cfg.ShutdownWebhook != "" {
+		if err := postJSON(ctx, cfg.ShutdownWebhook, ShutdownSnapshot{LabStatus: loadStatus(), Reason: reason}); err != nil {
+			log.Error("failed to deliver shutdown webhook", "error", err.Error())
+		} else {
+			log.Info("delivered shutdown webhook")
+		}
+	}
+}
+
+// closeClient sends a close frame so clients can tell a deliberate shutdown
+// apart from a dropped connection.
+func closeClient(conn *websocket.Conn) {
+	reason := "server shutting down"
+	if *restartHint > 0 {
+		reason = fmt.Sprintf("server shutting down, expected back in %s", restartHint.String())
+	}
+	closeWebsocket(conn, websocket.CloseServiceRestart, reason)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+	"github.com/google/uuid"
+)
+
+// Silence holds back notifications about one node, or every node in a role,
+// until it expires. Status keeps updating; the nodes are only marked silenced.
+type Silence struct {
+	ID      string    `json:"id"`
+	Node    string    `json:"node,omitempty"`
+	Role    string    `json:"role,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Created time.Time `json:"created"`
+	Until   time.Time `json:"until"`
+}
+
+type SilenceRequest struct {
+	Node     string `json:"node"`
+	Role     string `json:"role"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func (s Silence) matches(name string, n talos.NodeStatus) bool {
+	if s.Node != "" {
+		return s.Node == name
+	}
+	return s.Role == nodeRole(n)
+}
+
+// silenceList is written by the admin API and applied by the watch loop.
+// silenced is the set of nodes matched on the last apply, which is what
+// notifications are checked against.
+type silenceList struct {
+	lock     sync.Mutex
+	silences []Silence
+	silenced map[string]bool
+}
+
+var silences = &silenceList{silenced: map[string]bool{}}
+
+func (l *silenceList) add(s Silence) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.silences = append(l.silences, s)
+}
+
+// remove lifts a silence early, reporting whether it existed
+func (l *silenceList) remove(id string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i, s := range l.silences {
+		if s.ID == id {
+			l.silences = append(l.silences[:i], l.silences[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// dropExpired drops silences whose time is up, reporting whether any were
+// dropped. The caller holds the lock.
+func (l *silenceList) dropExpired(now time.Time) bool {
+	kept := []Silence{}
+	for _, s := range l.silences {
+		if now.Before(s.Until) {
+			kept = append(kept, s)
+		}
+	}
+	expired := len(kept) != len(l.silences)
+	l.silences = kept
+	return expired
+}
+
+// active lists the silences in force, soonest to expire first
+func (l *silenceList) active(now time.Time) []Silence {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.dropExpired(now)
+	ret := append([]Silence{}, l.silences...)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Until.Before(ret[j].Until) })
+	return ret
+}
+
+// expire reports whether any silence ran out, meaning the nodes need
+// marking again
+func (l *silenceList) expire(now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.dropExpired(now)
+}
+
+// apply marks the nodes covered by a silence
+func (l *silenceList) apply(nodes map[string]talos.NodeStatus, now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.dropExpired(now)
+	l.silenced = map[string]bool{}
+	for name, n := range nodes {
+		n.Silenced = false
+		for _, s := range l.silences {
+			if s.matches(name, n) {
+				n.Silenced = true
+				l.silenced[name] = true
+				break
+			}
+		}
+		nodes[name] = n
+	}
+}
+
+// covers reports whether notifications about the node are silenced
+func (l *silenceList) covers(node string) bool {
+	if node == "" {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.silenced[node]
+}
+
+// handleSilence lists the active silences on GET and adds one on POST
+func handleSilence(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		b, _ := encodeJSON(silences.active(clk.Now()))
+		w.Write(b)
+		return
+	case http.MethodPost:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	req := SilenceRequest{}
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (req.Node == "") == (req.Role == "") {
+		http.Error(w, "exactly one of node or role is required", http.StatusBadRequest)
+		return
+	}
+	if req.Role != "" && req.Role != NODE_ROLE_CONTROLPLANE && req.Role != NODE_ROLE_WORKER {
+		http.Error(w, fmt.Sprintf("invalid role %q, must be one of controlplane|worker", req.Role), http.StatusBadRequest)
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		http.Error(w, "duration must be a positive duration such as 2h", http.StatusBadRequest)
+		return
+	}
+
+	now := clk.Now()
+	s := Silence{ID: uuid.New().String(), Node: req.Node, Role: req.Role, Reason: req.Reason, Created: now, Until: now.Add(duration)}
+	silences.add(s)
+	injectionChan <- nil
+
+	b, _ := encodeJSON(s)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+// handleSilenceDelete lifts a silence before it expires
+func handleSilenceDelete(w http.ResponseWriter, r *http.Request) {
+	if !silences.remove(r.PathValue("id")) {
+		http.Error(w, "no active silence "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	injectionChan <- nil
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+func init() {
+	registerSchemaSection("snapshot-file", SCHEMA_OUTPUT, "Keeps a file holding the latest status", "snapshot-file")
+}
+
+// snapshotWriter is an output keeping snapshot-file holding the latest
+// status, so a crash leaves the last status behind as well as a clean
+// shutdown does. Shutdown writes the final status itself once the outputs
+// have stopped.
+type snapshotWriter struct {
+	file    string
+	healthy atomic.Bool
+	failing atomic.Bool
+	log     *slog.Logger
+}
+
+func newSnapshotWriter(file string, log *slog.Logger) *snapshotWriter {
+	w := &snapshotWriter{file: file, log: log.With("operation", "snapshotWriter", "file", file)}
+	w.healthy.Store(true)
+	return w
+}
+
+func (w *snapshotWriter) Name() string {
+	return "snapshot-file"
+}
+
+func (w *snapshotWriter) Start(ctx context.Context) error {
+	return nil
+}
+
+func (w *snapshotWriter) HandleStatus(s LabStatus) {
+	err := writeSnapshotFile(w.file, s)
+	w.healthy.Store(err == nil)
+	// Logged once per run of failures rather than on every status
+	if err != nil && !w.failing.Swap(true) {
+		w.log.Error("failed to write snapshot file", "error", err.Error())
+	} else if err == nil && w.failing.Swap(false) {
+		w.log.Info("writing snapshot file again")
+	}
+}
+
+func (w *snapshotWriter) HandleEvent(e loki.LogEvent) {}
+
+func (w *snapshotWriter) Healthy() bool {
+	return w.healthy.Load()
+}
+
+// writeSnapshotFile replaces the file in one step so readers never see a
+// partial status
+func writeSnapshotFile(file string, status LabStatus) error {
+	b, err := encodeJSON(status)
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(file, b); err != nil {
+		return err
+	}
+	return os.Chmod(file, 0644)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	w := newSnapshotWriter(file, discardLog)
+
+	for _, state := range []HealthLevel{HEALTH_LEVEL_WARN, HEALTH_LEVEL_OK} {
+		w.HandleStatus(LabStatus{Summary: Summary{State: state}})
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := LabStatus{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Summary.State != state {
+			t.Errorf("snapshot holds %s, want the latest status %s", got.Summary.State, state)
+		}
+	}
+	if !w.Healthy() {
+		t.Error("writer unhealthy after good writes")
+	}
+	if fi, _ := os.Stat(file); fi.Mode().Perm() != 0644 {
+		t.Errorf("snapshot mode %s", fi.Mode().Perm())
+	}
+
+	// A write that fails shows in the output's health
+	gone := newSnapshotWriter(filepath.Join(t.TempDir(), "missing", "snapshot.json"), discardLog)
+	gone.HandleStatus(LabStatus{})
+	if gone.Healthy() {
+		t.Error("writer healthy after a failed write")
+	}
+}
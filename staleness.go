@@ -0,0 +1,116 @@
+package main
+
+import (
+	"maps"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+var stalenessCheckInterval = time.Duration(1) * time.Second
+
+const SECTION_TALOS = "talos"
+const SECTION_LOGS = "logs"
+
+// SectionStatus records how fresh the data in a LabStatus section is
+type SectionStatus struct {
+	LastUpdated *time.Time `json:"last_updated,omitempty"`
+	Stale       bool       `json:"stale"`
+
+	// Degraded explains why the section's watcher is running on an outdated
+	// configuration, such as a rotated credential file that failed to load
+	Degraded string `json:"degraded,omitempty"`
+
+	// Cached marks a section still holding the previous run's data, which is
+	// stale until its watcher first reports
+	Cached bool `json:"cached,omitempty"`
+}
+
+// cloneSections copies the section map before it is modified since earlier
+// copies of the status may still be in the hands of clients
+func cloneSections(status *LabStatus) {
+	sections := map[string]SectionStatus{}
+	for k, v := range status.Sections {
+		sections[k] = v
+	}
+	status.Sections = sections
+}
+
+func markDegraded(status *LabStatus, section string, reason string) bool {
+	if status.Sections[section].Degraded == reason {
+		return false
+	}
+	cloneSections(status)
+	s := status.Sections[section]
+	s.Degraded = reason
+	status.Sections[section] = s
+	return true
+}
+
+func markUpdated(status *LabStatus, section string, now time.Time) {
+	cloneSections(status)
+	s := status.Sections[section]
+	s.LastUpdated = &now
+	s.Stale = false
+	s.Cached = false
+	status.Sections[section] = s
+}
+
+// updateStaleness flags sections that haven't been updated within their
+// threshold. Sections that have never been updated age from startTime so a
+// watcher that never delivers anything is caught too. Reports whether any
+// flag changed.
+func updateStaleness(status *LabStatus, thresholds map[string]config.Duration, startTime time.Time, now time.Time) bool {
+	cloneSections(status)
+
+	changed := false
+	for section, threshold := range thresholds {
+		if threshold <= 0 {
+			continue
+		}
+		s := status.Sections[section]
+		since := startTime
+		if s.LastUpdated != nil {
+			since = *s.LastUpdated
+		}
+		stale := s.Cached || now.Sub(since) > time.Duration(threshold)
+		if stale != s.Stale {
+			s.Stale = stale
+			status.Sections[section] = s
+			changed = true
+		}
+	}
+	if updateNodeStaleness(status, time.Duration(thresholds[SECTION_TALOS]), startTime, now) {
+		changed = true
+	}
+	return changed
+}
+
+// updateNodeStaleness flags the Talos nodes that haven't reported within the
+// talos threshold the same way as their section. Departed nodes are already
+// flagged as gone. The node map is only copied when a flag changes.
+func updateNodeStaleness(status *LabStatus, threshold time.Duration, startTime time.Time, now time.Time) bool {
+	cached := status.Sections[SECTION_TALOS].Cached
+	var nodes map[string]talos.NodeStatus
+	for name, n := range status.Talos {
+		since := startTime
+		if !n.LastUpdated.IsZero() {
+			since = n.LastUpdated
+		}
+		stale := threshold > 0 && !n.Departed && (cached || now.Sub(since) > threshold)
+		if stale == n.Stale {
+			continue
+		}
+		if nodes == nil {
+			nodes = maps.Clone(status.Talos)
+		}
+		n.Stale = stale
+		nodes[name] = n
+	}
+	if nodes == nil {
+		return false
+	}
+	status.Talos = nodes
+	return true
+}
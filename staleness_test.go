@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestStalenessWithFakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	start := fake.Now()
+	thresholds := map[string]config.Duration{
+		SECTION_TALOS: config.Duration(30 * time.Second),
+		SECTION_LOGS:  config.Duration(time.Minute),
+	}
+	status := LabStatus{}
+	stale := func() map[string]bool {
+		return map[string]bool{SECTION_TALOS: status.Sections[SECTION_TALOS].Stale, SECTION_LOGS: status.Sections[SECTION_LOGS].Stale}
+	}
+
+	// The watch loop's staleness ticker, stepped by hand. Like the loop it
+	// reads the clock on each tick, since ticks it fell behind on are dropped.
+	tick := fake.NewTicker(stalenessCheckInterval)
+	defer tick.Stop()
+	step := func(d time.Duration) bool {
+		t.Helper()
+		fake.Advance(d)
+		<-tick.C()
+		return updateStaleness(&status, thresholds, start, fake.Now())
+	}
+
+	markUpdated(&status, SECTION_TALOS, fake.Now())
+	if step(stalenessCheckInterval) || stale()[SECTION_TALOS] || stale()[SECTION_LOGS] {
+		t.Fatalf("stale right after start: %v", stale())
+	}
+
+	if !step(30*time.Second) || !stale()[SECTION_TALOS] || stale()[SECTION_LOGS] {
+		t.Fatalf("31s after the update: %v, want only talos stale", stale())
+	}
+	if step(stalenessCheckInterval) {
+		t.Error("unchanged flags reported as a change")
+	}
+	// A section that has never reported ages from startup
+	if !step(29*time.Second) || !stale()[SECTION_LOGS] {
+		t.Fatalf("61s after startup: %v, want logs stale", stale())
+	}
+
+	// An update clears the flag at the next check, and it holds until the
+	// threshold passes again
+	markUpdated(&status, SECTION_TALOS, fake.Now())
+	if stale()[SECTION_TALOS] {
+		t.Fatal("update didn't clear stale")
+	}
+	previous := status.Sections
+	step(30 * time.Second)
+	if stale()[SECTION_TALOS] {
+		t.Error("stale at exactly the threshold")
+	}
+	if !step(stalenessCheckInterval) || !stale()[SECTION_TALOS] {
+		t.Error("not stale past the threshold")
+	}
+	if previous[SECTION_TALOS].Stale {
+		t.Error("section map shared with an earlier status was modified")
+	}
+}
+
+func TestStalenessCachedSection(t *testing.T) {
+	now := time.Now()
+	status := LabStatus{Sections: map[string]SectionStatus{SECTION_TALOS: {LastUpdated: &now, Cached: true}}}
+	thresholds := map[string]config.Duration{SECTION_TALOS: config.Duration(time.Hour)}
+	if !updateStaleness(&status, thresholds, now, now) || !status.Sections[SECTION_TALOS].Stale {
+		t.Error("data from the previous run isn't stale")
+	}
+}
+
+func TestNodeStaleness(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := start.Add(time.Hour)
+	thresholds := map[string]config.Duration{SECTION_TALOS: config.Duration(time.Minute)}
+	fresh := map[string]talos.NodeStatus{
+		"cp1":     {Node: "cp1", LastUpdated: now.Add(-10 * time.Second)},
+		"worker1": {Node: "worker1", LastUpdated: now.Add(-5 * time.Minute)},
+		"worker2": {Node: "worker2"},
+		"worker3": {Node: "worker3", LastUpdated: now.Add(-time.Hour), Departed: true},
+	}
+	status := LabStatus{Talos: fresh}
+	if !updateStaleness(&status, thresholds, start, now) {
+		t.Fatal("stale nodes not reported as a change")
+	}
+	want := map[string]bool{"cp1": false, "worker1": true, "worker2": true, "worker3": false}
+	for name, stale := range want {
+		if status.Talos[name].Stale != stale {
+			t.Errorf("%s stale %t, want %t", name, status.Talos[name].Stale, stale)
+		}
+	}
+	if fresh["worker1"].Stale {
+		t.Error("node map shared with an earlier status was modified")
+	}
+	if updateStaleness(&status, thresholds, start, now) {
+		t.Error("unchanged node flags reported as a change")
+	}
+
+	// Nodes held over from the previous run are stale until the watcher
+	// reports them
+	cached := LabStatus{
+		Talos:    map[string]talos.NodeStatus{"cp1": {Node: "cp1", LastUpdated: now}},
+		Sections: map[string]SectionStatus{SECTION_TALOS: {LastUpdated: &now, Cached: true}},
+	}
+	updateStaleness(&cached, thresholds, now, now)
+	if !cached.Talos["cp1"].Stale {
+		t.Error("cached node isn't stale")
+	}
+
+	// Without a threshold nothing is flagged
+	status = LabStatus{Talos: map[string]talos.NodeStatus{"worker2": {Node: "worker2"}}}
+	if updateStaleness(&status, nil, start, now) || status.Talos["worker2"].Stale {
+		t.Error("node flagged without a threshold")
+	}
+}
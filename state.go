@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// STATE_VERSION is bumped whenever RuntimeState changes incompatibly. Files
+// with any other version are ignored. Version 2 added the silences, stats
+// history, firing alerts and last status.
+const STATE_VERSION = 2
+
+// RuntimeState is what labwatch carries across a restart so it picks up
+// where it left off instead of re-announcing everything
+type RuntimeState struct {
+	Version  int       `json:"version"`
+	Saved    time.Time `json:"saved"`
+	Instance string    `json:"instance"`
+
+	Maintenance MaintenanceState             `json:"maintenance"`
+	Silences    []Silence                    `json:"silences,omitempty"`
+	Summary     HealthLevel                  `json:"summary"`
+	Checks      map[string]map[string]string `json:"checks"`
+	History     []Transition                 `json:"history"`
+	Stats       *StatsHistoryState           `json:"stats,omitempty"`
+	Alerts      map[string]AlertState        `json:"alerts,omitempty"`
+
+	// Status is the last status, kept apart so one that no longer decodes
+	// can't cost the rest of the state
+	Status json.RawMessage `json:"status,omitempty"`
+}
+
+// captureRuntimeState gathers the current state for saving
+func captureRuntimeState(instance string, now time.Time) RuntimeState {
+	checks, history := transitions.export()
+	statsState := eventStats.export()
+	status := loadStatus()
+	return RuntimeState{
+		Version:     STATE_VERSION,
+		Saved:       now,
+		Instance:    instance,
+		Maintenance: maintenance.state(now),
+		Silences:    silences.active(now),
+		Summary:     status.Summary.State,
+		Checks:      checks,
+		History:     history,
+		Stats:       &statsState,
+		Alerts:      notifier.firingAlerts(),
+		Status:      captureStatusCache(status, now),
+	}
+}
+
+func saveRuntimeState(file string, state RuntimeState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, b)
+}
+
+// writeFileAtomic writes beside the target and renames into place so a crash
+// mid-write never leaves a truncated file
+func writeFileAtomic(file string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func readRuntimeState(file string) (*RuntimeState, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	state := RuntimeState{}
+	if err = json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	if state.Version != STATE_VERSION {
+		return nil, fmt.Errorf("unsupported state version %d, expected %d", state.Version, STATE_VERSION)
+	}
+	return &state, nil
+}
+
+// loadRuntimeState restores saved state. A missing, corrupt or incompatible
+// file is logged and ignored; it never blocks startup.
+func loadRuntimeState(file string, now time.Time, log *slog.Logger) *RuntimeState {
+	log = log.With("operation", "loadRuntimeState", "file", file)
+	state, err := readRuntimeState(file)
+	if os.IsNotExist(err) {
+		log.Info("no saved state to restore")
+		return nil
+	} else if err != nil {
+		log.Warn("ignoring unreadable saved state", "error", err.Error())
+		return nil
+	}
+
+	if m := state.Maintenance; m.Active && (m.Until == nil || m.Until.After(now)) {
+		var duration time.Duration
+		if m.Until != nil {
+			duration = m.Until.Sub(now)
+		}
+		maintenance.set(true, duration, m.Reason)
+	}
+	for _, s := range state.Silences {
+		if now.Before(s.Until) {
+			silences.add(s)
+		}
+	}
+	transitions.restore(state.Checks, state.History, Transition{
+		Time:  now,
+		Node:  "labwatch",
+		Check: "labwatch",
+		From:  state.Saved.Format(time.RFC3339),
+		To:    "labwatch restarted",
+	})
+	// Churn carries on from the restored history. Its notifications were
+	// restored with the rest, so the entities still churning aren't announced
+	// again.
+	churn.observe(state.History, now)
+	notifier.restoreAlerts(state.Alerts)
+	if state.Stats != nil && !eventStats.restore(*state.Stats, now) {
+		log.Info("discarding saved stats history recorded at another resolution")
+	}
+	log.Info("restored saved state", "saved", state.Saved, "summary", state.Summary, "history", len(state.History))
+	return state
+}
+
+// saveRuntimeStatePeriodically keeps the state file fresh so an unclean exit
+// loses at most one interval
+func saveRuntimeStatePeriodically(cfg LabwatchConfig, log *slog.Logger) {
+	log = log.With("operation", "saveRuntimeState", "file", cfg.StateFile)
+	for range time.Tick(time.Duration(cfg.StateInterval)) {
+		if err := saveRuntimeState(cfg.StateFile, captureRuntimeState(cfg.InstanceName, time.Now())); err != nil {
+			log.Error("failed to save state", "error", err.Error())
+		}
+	}
+}
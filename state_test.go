@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestRuntimeStateRoundTrip(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.NotifyDestinations = []NotifyDestination{{Name: "pager", URL: "http://127.0.0.1:1/hook"}}
+	fake := useTestGlobals(t, cfg)
+	now := fake.Now()
+
+	// Build up state the way a running instance does
+	maintenance.set(true, time.Hour, "rack move")
+	silences.add(Silence{ID: "s1", Node: "worker1", Reason: "disk swap", Created: now, Until: now.Add(2 * time.Hour)})
+	healthy := map[string]talos.NodeStatus{
+		"cp1":     {Node: "cp1", WatcherState: talos.CONNECTION_OK, Ready: true},
+		"worker1": {Node: "worker1", WatcherState: talos.CONNECTION_OK, Ready: true},
+	}
+	transitions.observe(healthy, now.Add(-time.Minute))
+	down := map[string]talos.NodeStatus{
+		"cp1":     healthy["cp1"],
+		"worker1": {Node: "worker1", WatcherState: talos.CONNECTION_OK},
+	}
+	transitions.observe(down, now)
+	notifier.restoreAlerts(map[string]AlertState{
+		"node/worker1": {
+			Note:     Notification{Title: "node down", Node: "worker1", Alert: "node/worker1"},
+			Notified: map[string]time.Time{"pager": now},
+		},
+	})
+	storeStatus(LabStatus{
+		Labwatch: LabwatchStatus{State: LABWATCH_RUNNING},
+		Summary:  Summary{State: HEALTH_LEVEL_WARN},
+		Talos:    down,
+		Sections: map[string]SectionStatus{SECTION_TALOS: {LastUpdated: &now}},
+	})
+
+	file := filepath.Join(t.TempDir(), "state.json")
+	saved := captureRuntimeState("lab", now)
+	if err := saveRuntimeState(file, saved); err != nil {
+		t.Fatal(err)
+	}
+	wantChecks, wantHistory := transitions.export()
+	wantAlerts := notifier.firingAlerts()
+
+	// A new process starts with empty trackers and reads the file back
+	useTestGlobals(t, cfg)
+	later := now.Add(5 * time.Minute)
+	restored := loadRuntimeState(file, later, discardLog)
+	if restored == nil {
+		t.Fatal("state not restored")
+	}
+	if restored.Version != STATE_VERSION || restored.Instance != "lab" || !restored.Saved.Equal(now) {
+		t.Errorf("header restored as %d %q %s", restored.Version, restored.Instance, restored.Saved)
+	}
+	if restored.Summary != HEALTH_LEVEL_WARN {
+		t.Errorf("summary restored as %s", restored.Summary)
+	}
+
+	if m := maintenance.state(later); !m.Active || m.Reason != "rack move" {
+		t.Errorf("maintenance restored as %+v", m)
+	}
+	if s := silences.active(later); len(s) != 1 || s[0].ID != "s1" || s[0].Reason != "disk swap" {
+		t.Errorf("silences restored as %+v", s)
+	}
+
+	checks, history := transitions.export()
+	if !reflect.DeepEqual(checks, wantChecks) {
+		t.Errorf("checks restored as %v, want %v", checks, wantChecks)
+	}
+	// The restart marker follows the history carried over
+	if len(history) != len(wantHistory)+1 || history[len(history)-1].Check != "labwatch" {
+		t.Fatalf("history restored as %+v", history)
+	}
+	for i, tr := range wantHistory {
+		if tr.Node != history[i].Node || tr.Check != history[i].Check || tr.To != history[i].To || !tr.Time.Equal(history[i].Time) {
+			t.Errorf("transition %d restored as %+v, want %+v", i, history[i], tr)
+		}
+	}
+
+	alerts := notifier.firingAlerts()
+	if len(alerts) != 1 || alerts["node/worker1"].Note.Title != wantAlerts["node/worker1"].Note.Title || !alerts["node/worker1"].Notified["pager"].Equal(now) {
+		t.Errorf("alerts restored as %+v, want %+v", alerts, wantAlerts)
+	}
+
+	status := cachedStatus(restored, discardLog)
+	if !status.FromCache || status.Summary.State != HEALTH_LEVEL_WARN || status.Talos["worker1"].Ready {
+		t.Errorf("status restored as %+v", status)
+	}
+}
+
+func TestRuntimeStateOtherVersionIgnored(t *testing.T) {
+	useTestGlobals(t, defaultConfig())
+	file := filepath.Join(t.TempDir(), "state.json")
+	b, _ := json.Marshal(RuntimeState{Version: STATE_VERSION - 1, Maintenance: MaintenanceState{Active: true, Reason: "old"}})
+	if err := os.WriteFile(file, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if restored := loadRuntimeState(file, time.Now(), discardLog); restored != nil {
+		t.Errorf("restored a version %d file", restored.Version)
+	}
+	if maintenance.state(time.Now()).Active {
+		t.Error("maintenance restored from an ignored file")
+	}
+}
+
+// The state saver runs on its own ticker while the watch loop replaces the
+// status. Run with -race.
+func TestCaptureRuntimeStateWhileStatusChanges(t *testing.T) {
+	useTestGlobals(t, defaultConfig())
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			storeStatus(LabStatus{Summary: Summary{State: HEALTH_LEVEL_OK, Suppressed: i}})
+		}
+	}()
+	for range 100 {
+		captureRuntimeState("lab", time.Now())
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DRuggeri/labwatch/config"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// Packets are kept under the common 1500 byte MTU once headers are counted
+var statsdMaxPacket = 1432
+var statsdNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func init() {
+	registerSchemaSection("statsd", SCHEMA_OUTPUT, "Pushes health, node and event metrics to StatsD", "statsd")
+}
+
+// StatsDConfig pushes metrics to a StatsD server every interval. Nothing is
+// sent unless address is set. With tags the node, level and endpoint are sent
+// as DogStatsD tags; without, they become part of the metric name.
+type StatsDConfig struct {
+	Address  string          `yaml:"address"`
+	Prefix   string          `yaml:"prefix"`
+	Interval config.Duration `yaml:"interval"`
+	Tags     bool            `yaml:"tags"`
+}
+
+func validateStatsD(cfg StatsDConfig) error {
+	if cfg.Address == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return err
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	return nil
+}
+
+// statsdPublisher is an output keeping the latest status and counting events
+// between flushes
+type statsdPublisher struct {
+	cfg     StatsDConfig
+	conn    net.Conn
+	status  *LabStatus
+	events  map[[2]string]int64
+	healthy atomic.Bool
+	log     *slog.Logger
+	lock    sync.Mutex
+}
+
+func newStatsDPublisher(cfg StatsDConfig, log *slog.Logger) *statsdPublisher {
+	return &statsdPublisher{
+		cfg:    cfg,
+		events: map[[2]string]int64{},
+		log:    log.With("operation", "statsdPublisher"),
+	}
+}
+
+func (p *statsdPublisher) Name() string {
+	return "statsd"
+}
+
+// Start opens the UDP socket, which succeeds as long as the address
+// resolves, and flushes every interval until ctx is done
+func (p *statsdPublisher) Start(ctx context.Context) error {
+	conn, err := net.Dial("udp", p.cfg.Address)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	p.healthy.Store(true)
+	go func() {
+		ticker := time.NewTicker(time.Duration(p.cfg.Interval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				p.flush()
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *statsdPublisher) HandleStatus(s LabStatus) {
+	p.lock.Lock()
+	p.status = &s
+	p.lock.Unlock()
+}
+
+func (p *statsdPublisher) HandleEvent(e loki.LogEvent) {
+	level := e.Level
+	if level == "" {
+		level = "info"
+	}
+	p.lock.Lock()
+	p.events[[2]string{level, e.Node}]++
+	p.lock.Unlock()
+}
+
+func (p *statsdPublisher) Healthy() bool {
+	return p.healthy.Load()
+}
+
+// line renders one metric. tags are name/value pairs.
+func (p *statsdPublisher) line(name string, value int64, kind string, tags ...string) string {
+	suffix := ""
+	for i := 0; i+1 < len(tags); i += 2 {
+		if p.cfg.Tags {
+			sep := "|#"
+			if suffix != "" {
+				sep = ","
+			}
+			suffix += sep + tags[i] + ":" + statsdNameInvalid.ReplaceAllString(tags[i+1], "_")
+		} else {
+			name += "." + statsdNameInvalid.ReplaceAllString(tags[i+1], "_")
+		}
+	}
+	if p.cfg.Prefix != "" {
+		name = p.cfg.Prefix + "." + name
+	}
+	return fmt.Sprintf("%s:%d|%s%s", name, value, kind, suffix)
+}
+
+func boolGauge(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// lines renders the metrics for one flush, resetting the event counters
+func (p *statsdPublisher) lines() []string {
+	p.lock.Lock()
+	status, events := p.status, p.events
+	p.events = map[[2]string]int64{}
+	p.lock.Unlock()
+
+	ret := []string{}
+	for key, n := range events {
+		ret = append(ret, p.line("events", n, "c", "level", key[0], "node", key[1]))
+	}
+
+	clients := map[string]int64{ENDPOINT_STATUS: 0, ENDPOINT_EVENTS: 0}
+	for _, c := range registry.list() {
+		clients[c.Endpoint]++
+	}
+	for endpoint, n := range clients {
+		ret = append(ret, p.line("clients", n, "g", "endpoint", strings.TrimPrefix(endpoint, "/")))
+	}
+
+	if status == nil {
+		return ret
+	}
+	ret = append(ret, p.line("lab.health", int64(status.Summary.State.rank()), "g"))
+	ret = append(ret, p.line("lab.healthy", boolGauge(status.Healthy), "g"))
+	for _, node := range sortedKeys(status.Talos) {
+		n := status.Talos[node]
+		if n.Departed {
+			continue
+		}
+		ret = append(ret, p.line("node.up", boolGauge(n.WatcherState == talos.CONNECTION_OK), "g", "node", node))
+		ret = append(ret, p.line("node.ready", boolGauge(n.Ready), "g", "node", node))
+	}
+	return ret
+}
+
+func (p *statsdPublisher) flush() {
+	packet := strings.Builder{}
+	ok := true
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, err := p.conn.Write([]byte(packet.String())); err != nil {
+			p.log.Warn("failed to send to statsd", "error", err.Error())
+			ok = false
+		}
+		packet.Reset()
+	}
+	for _, l := range p.lines() {
+		if packet.Len() > 0 && packet.Len()+1+len(l) > statsdMaxPacket {
+			send()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(l)
+	}
+	send()
+	p.healthy.Store(ok)
+}
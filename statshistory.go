@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+// Events are counted into buckets of the configured resolution, which are
+// rolled up into 10 minute and then hourly buckets as they age
+var statsRollups = []time.Duration{time.Duration(10) * time.Minute, time.Duration(1) * time.Hour}
+var statsRetention = []time.Duration{time.Duration(2) * time.Hour, time.Duration(48) * time.Hour, time.Duration(30*24) * time.Hour}
+var defaultStatsWindow = time.Duration(24) * time.Hour
+
+// StatsBucket counts the events seen in the bucket by level, and by host and
+// level
+type StatsBucket struct {
+	Start  time.Time                 `json:"start"`
+	Levels map[string]int            `json:"levels"`
+	Hosts  map[string]map[string]int `json:"hosts"`
+}
+
+func (b *StatsBucket) add(host string, level string, n int) {
+	if b.Levels == nil {
+		b.Levels = map[string]int{}
+		b.Hosts = map[string]map[string]int{}
+	}
+	b.Levels[level] += n
+	if b.Hosts[host] == nil {
+		b.Hosts[host] = map[string]int{}
+	}
+	b.Hosts[host][level] += n
+}
+
+func (b *StatsBucket) merge(o StatsBucket) {
+	for host, levels := range o.Hosts {
+		for level, n := range levels {
+			b.add(host, level, n)
+		}
+	}
+}
+
+// StatsHistoryState is the saved form of the history, one list of buckets per
+// tier from finest to coarsest
+type StatsHistoryState struct {
+	Resolution time.Duration   `json:"resolution"`
+	Tiers      [][]StatsBucket `json:"tiers"`
+}
+
+// statsHistory downsamples the event stream into time buckets. Buckets in
+// each tier are kept in order; an event stamped before the newest bucket,
+// as happens when the clock is stepped back, is counted in the newest bucket
+// rather than reopening an old one.
+type statsHistory struct {
+	resolutions []time.Duration
+	tiers       [][]StatsBucket
+	lock        sync.Mutex
+}
+
+var eventStats *statsHistory
+
+func newStatsHistory(resolution time.Duration) *statsHistory {
+	return &statsHistory{
+		resolutions: append([]time.Duration{resolution}, statsRollups...),
+		tiers:       make([][]StatsBucket, len(statsRollups)+1),
+	}
+}
+
+func validateStatsResolution(resolution time.Duration) error {
+	if resolution <= 0 || statsRollups[0]%resolution != 0 {
+		return fmt.Errorf("must evenly divide %s", statsRollups[0])
+	}
+	return nil
+}
+
+func (h *statsHistory) record(e loki.LogEvent, now time.Time) {
+	level := e.Level
+	if level == "" {
+		level = "info"
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.bucket(0, now.Truncate(h.resolutions[0])).add(e.Node, level, 1)
+	h.rollup(now)
+}
+
+// bucket returns the tier's bucket starting at start, opening it if needed
+func (h *statsHistory) bucket(tier int, start time.Time) *StatsBucket {
+	buckets := h.tiers[tier]
+	if n := len(buckets); n > 0 && !start.After(buckets[n-1].Start) {
+		return &buckets[n-1]
+	}
+	h.tiers[tier] = append(buckets, StatsBucket{Start: start})
+	return &h.tiers[tier][len(h.tiers[tier])-1]
+}
+
+// rollup moves buckets past their tier's retention into the next tier and
+// drops those past the last
+func (h *statsHistory) rollup(now time.Time) {
+	for tier := range h.tiers {
+		cutoff := now.Add(-statsRetention[tier])
+		i := 0
+		for ; i < len(h.tiers[tier]) && h.tiers[tier][i].Start.Before(cutoff); i++ {
+			if tier+1 < len(h.tiers) {
+				b := h.tiers[tier][i]
+				h.bucket(tier+1, b.Start.Truncate(h.resolutions[tier+1])).merge(b)
+			}
+		}
+		h.tiers[tier] = h.tiers[tier][i:]
+	}
+}
+
+func (h *statsHistory) export() StatsHistoryState {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	ret := StatsHistoryState{Resolution: h.resolutions[0], Tiers: make([][]StatsBucket, len(h.tiers))}
+	for i, t := range h.tiers {
+		ret.Tiers[i] = append([]StatsBucket{}, t...)
+	}
+	return ret
+}
+
+// restore loads saved buckets. History saved at another resolution or with
+// another set of tiers is discarded.
+func (h *statsHistory) restore(s StatsHistoryState, now time.Time) bool {
+	if s.Resolution != h.resolutions[0] || len(s.Tiers) != len(h.tiers) {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.tiers = s.Tiers
+	h.rollup(now)
+	return true
+}
+
+// query re-buckets the history covering the window at the resolution.
+// Resolutions finer than the data has been rolled up to return the coarser
+// buckets as they are.
+func (h *statsHistory) query(start time.Time, resolution time.Duration) []StatsBucket {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	merged := map[time.Time]*StatsBucket{}
+	for _, tier := range h.tiers {
+		for _, b := range tier {
+			if b.Start.Before(start.Truncate(resolution)) {
+				continue
+			}
+			t := b.Start.Truncate(resolution)
+			if merged[t] == nil {
+				merged[t] = &StatsBucket{Start: t}
+			}
+			merged[t].merge(b)
+		}
+	}
+
+	ret := make([]StatsBucket, 0, len(merged))
+	for _, b := range merged {
+		ret = append(ret, *b)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	return ret
+}
+
+// StatsSeries is one line of the history in the shape the Grafana JSON
+// datasource expects: [count, unix milliseconds] pairs
+type StatsSeries struct {
+	Target     string     `json:"target"`
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+// statsSeries turns buckets into one series per level, or per host and level
+// when byHost is set
+func statsSeries(buckets []StatsBucket, byHost bool) []StatsSeries {
+	series := map[string][][2]int64{}
+	for _, b := range buckets {
+		counts := map[string]int{}
+		if byHost {
+			for host, levels := range b.Hosts {
+				for level, n := range levels {
+					counts[host+"/"+level] = n
+				}
+			}
+		} else {
+			counts = b.Levels
+		}
+		for target, n := range counts {
+			series[target] = append(series[target], [2]int64{int64(n), b.Start.UnixMilli()})
+		}
+	}
+
+	ret := []StatsSeries{}
+	for _, target := range sortedKeys(series) {
+		ret = append(ret, StatsSeries{Target: target, Datapoints: series[target]})
+	}
+	return ret
+}
+
+// handleStatsHistory serves the event counts over ?window (default 24h) at
+// ?resolution (default the configured one), split by host with ?by=host
+func handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	var err error
+	window := defaultStatsWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		if window, err = time.ParseDuration(s); err != nil || window <= 0 {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+	}
+	resolution := eventStats.resolutions[0]
+	if s := r.URL.Query().Get("resolution"); s != "" {
+		if resolution, err = time.ParseDuration(s); err != nil || resolution < eventStats.resolutions[0] {
+			http.Error(w, fmt.Sprintf("invalid resolution, must be at least %s", eventStats.resolutions[0]), http.StatusBadRequest)
+			return
+		}
+	}
+
+	buckets := eventStats.query(time.Now().Add(-window), resolution)
+	b, _ := encodeJSON(statsSeries(buckets, r.URL.Query().Get("by") == "host"))
+	w.Write(b)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// captureStatusCache is the status saved with the runtime state for the
+// next run to serve while it warms up. A status still served from the cache
+// is saved as is, keeping when it was really captured.
+func captureStatusCache(status LabStatus, now time.Time) json.RawMessage {
+	if !status.FromCache {
+		status.CachedAt = &now
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// cachedStatus is the status a starting instance serves until its watchers
+// report: the previous run's last status when one was saved, flagged
+// from_cache with every section marked cached and stale so nothing in it
+// passes for fresh. Watchers replace their sections as they report and the
+// flag is cleared once warmup completes.
+func cachedStatus(restored *RuntimeState, log *slog.Logger) LabStatus {
+	status := LabStatus{Labwatch: LabwatchStatus{State: LABWATCH_STARTING}}
+	if restored == nil || len(restored.Status) == 0 {
+		return status
+	}
+
+	cached := LabStatus{}
+	// A field that doesn't round trip, like a node's error, is only skipped
+	typeErr := &json.UnmarshalTypeError{}
+	if err := json.Unmarshal(restored.Status, &cached); err != nil && !errors.As(err, &typeErr) {
+		log.Warn("ignoring unreadable cached status", "error", err.Error())
+		return status
+	}
+	cached.Labwatch = status.Labwatch
+	cached.FromCache = true
+	if cached.CachedAt == nil {
+		saved := restored.Saved
+		cached.CachedAt = &saved
+	}
+	sections := map[string]SectionStatus{}
+	for name, s := range cached.Sections {
+		s.Cached = true
+		s.Stale = true
+		sections[name] = s
+	}
+	cached.Sections = sections
+	log.Info("serving cached status until warmup completes", "cached_at", *cached.CachedAt)
+	return cached
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func TestStatusCacheRoundTrip(t *testing.T) {
+	captured := time.Date(2026, 3, 1, 11, 58, 0, 0, time.UTC)
+	updated := captured.Add(-10 * time.Second)
+	status := LabStatus{
+		Labwatch: LabwatchStatus{State: LABWATCH_RUNNING},
+		Summary:  Summary{State: HEALTH_LEVEL_WARN, Reasons: []string{"worker1 not ready"}},
+		Talos: map[string]talos.NodeStatus{
+			"cp1": {Node: "cp1", WatcherState: talos.CONNECTION_OK, Ready: true},
+			// Errors marshal as {} and can't be read back into an error
+			"worker1": {Node: "worker1", WatcherState: talos.CONNECTION_DISCONNECTED, Error: errors.New("connection refused")},
+		},
+		Sections: map[string]SectionStatus{
+			SECTION_TALOS: {LastUpdated: &updated},
+			SECTION_LOGS:  {LastUpdated: &updated, Degraded: "credential file failed to load"},
+		},
+	}
+
+	// Saved with the runtime state, then read back by the next run
+	cache := captureStatusCache(status, captured)
+	got := cachedStatus(&RuntimeState{Saved: captured.Add(time.Minute), Status: cache}, discardLog)
+
+	if !got.FromCache || got.CachedAt == nil || !got.CachedAt.Equal(captured) {
+		t.Errorf("served from cache %t captured at %v, want %s", got.FromCache, got.CachedAt, captured)
+	}
+	if got.Labwatch.State != LABWATCH_STARTING {
+		t.Errorf("labwatch state %s, want the new run's", got.Labwatch.State)
+	}
+	if got.Summary.State != HEALTH_LEVEL_WARN || len(got.Summary.Reasons) != 1 {
+		t.Errorf("summary %+v", got.Summary)
+	}
+	if len(got.Talos) != 2 || !got.Talos["cp1"].Ready || got.Talos["worker1"].WatcherState != talos.CONNECTION_DISCONNECTED {
+		t.Errorf("nodes %+v", got.Talos)
+	}
+	for name, s := range got.Sections {
+		if !s.Cached || !s.Stale || s.LastUpdated == nil || !s.LastUpdated.Equal(updated) {
+			t.Errorf("section %s restored as %+v", name, s)
+		}
+	}
+	if got.Sections[SECTION_LOGS].Degraded == "" {
+		t.Error("degraded reason lost")
+	}
+	if status.Sections[SECTION_TALOS].Cached {
+		t.Error("the saved status was modified")
+	}
+
+	// A run that restarts again before warming up saves what it is still
+	// serving, keeping when it was really captured
+	again := cachedStatus(&RuntimeState{Saved: captured.Add(2 * time.Minute), Status: captureStatusCache(got, captured.Add(2*time.Minute))}, discardLog)
+	if !again.CachedAt.Equal(captured) {
+		t.Errorf("recached status captured at %s, want %s", again.CachedAt, captured)
+	}
+}
+
+func TestStatusCacheMissing(t *testing.T) {
+	saved := time.Date(2026, 3, 1, 11, 58, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		restored *RuntimeState
+	}{
+		{name: "no state file", restored: loadRuntimeState(filepath.Join(t.TempDir(), "missing.json"), saved, discardLog)},
+		{name: "state without a status", restored: &RuntimeState{Saved: saved}},
+		{name: "unreadable status", restored: &RuntimeState{Saved: saved, Status: []byte(`{"talos": [`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cachedStatus(tt.restored, discardLog)
+			if got.FromCache || got.CachedAt != nil || got.Labwatch.State != LABWATCH_STARTING || len(got.Talos) != 0 || len(got.Sections) != 0 {
+				t.Errorf("got %+v, want an empty starting status", got)
+			}
+		})
+	}
+}
+
+func TestStatusCacheWithoutCaptureTime(t *testing.T) {
+	// Caches written before the capture time was saved fall back to when the
+	// state was saved
+	saved := time.Date(2026, 3, 1, 11, 58, 0, 0, time.UTC)
+	got := cachedStatus(&RuntimeState{Saved: saved, Status: []byte(`{"summary": {"state": "ok"}}`)}, discardLog)
+	if !got.FromCache || got.CachedAt == nil || !got.CachedAt.Equal(saved) {
+		t.Errorf("cached at %v, want %s", got.CachedAt, saved)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/gorilla/websocket"
+)
+
+// statusDeltaRequested reads ?delta. Deltas are only framed for v2 JSON
+// clients; anyone else asking for them is refused before the upgrade.
+func statusDeltaRequested(r *http.Request, encoding string) (bool, error) {
+	if r.URL.Query().Get("delta") != "true" {
+		return false, nil
+	}
+	if server.Protocol(r) != server.PROTOCOL_V2 || encoding != ENCODING_JSON {
+		return false, fmt.Errorf("delta updates require protocol=v2 and json encoding")
+	}
+	return true, nil
+}
+
+// statusStream writes the statuses of one /status websocket. In delta mode
+// an update only carries the top level fields that changed since the last
+// message, null for those that went away, as a status_delta. A full status
+// message is still sent every resyncEvery updates or, at the next update,
+// once resyncInterval has passed, so a client that mishandled a delta can't
+// drift for long. Clients treat every status message as a resync point.
+type statusStream struct {
+	delta          bool
+	resyncEvery    int
+	resyncInterval time.Duration
+	last           map[string]json.RawMessage
+	sinceFull      int
+	lastFull       time.Time
+}
+
+func newStatusStream(delta bool, cfg LabwatchConfig) *statusStream {
+	return &statusStream{
+		delta:          delta,
+		resyncEvery:    cfg.StatusResyncEvery,
+		resyncInterval: time.Duration(cfg.StatusResyncInterval),
+	}
+}
+
+func (s *statusStream) resyncDue(now time.Time) bool {
+	return s.last == nil ||
+		(s.resyncEvery > 0 && s.sinceFull >= s.resyncEvery) ||
+		(s.resyncInterval > 0 && now.Sub(s.lastFull) >= s.resyncInterval)
+}
+
+func (s *statusStream) write(conn *websocket.Conn, framer *server.Framer, encoding string, status LabStatus, now time.Time) error {
+	if !s.delta {
+		return writeStatus(conn, framer, encoding, status)
+	}
+
+	b, err := encodeJSON(status)
+	if err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	if s.resyncDue(now) {
+		s.last, s.sinceFull, s.lastFull = fields, 0, now
+		return writeMessage(conn, framer, server.TYPE_STATUS, json.RawMessage(b))
+	}
+
+	changed := map[string]json.RawMessage{}
+	for k, v := range fields {
+		if !bytes.Equal(s.last[k], v) {
+			changed[k] = v
+		}
+	}
+	for k := range s.last {
+		if _, ok := fields[k]; !ok {
+			changed[k] = json.RawMessage("null")
+		}
+	}
+	s.last = fields
+	s.sinceFull++
+	return writeMessage(conn, framer, server.TYPE_STATUS_DELTA, changed)
+}
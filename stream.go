@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/gorilla/websocket"
+)
+
+// streamFilterKeys are the /events filter parameters a /stream event_filter
+// may set. Label matchers need a Loki tail of their own and are left to
+// /events.
+var streamFilterKeys = []string{"host", "level", "service", "trace", "text", "stream"}
+
+// streamSubscriptions is what one /stream client receives. The queues are
+// the ones /status and /events clients get, keyed by the same client ID, so
+// the broadcast treats each subscription like a client of that endpoint.
+type streamSubscriptions struct {
+	id     string
+	delta  bool
+	view   statusView
+	cfg    LabwatchConfig
+	status *clientQueue[LabStatus]
+	events *clientQueue[loki.LogEvent]
+	stream *statusStream
+	filter eventFilter
+}
+
+// parseSubscribe checks a subscription request and builds its event filter
+func parseSubscribe(req server.Subscribe) (eventFilter, error) {
+	for _, s := range req.Subscribe {
+		if s != server.SUBSCRIPTION_STATUS && s != server.SUBSCRIPTION_EVENTS {
+			return eventFilter{}, fmt.Errorf("unknown subscription %q: must be one of status|events", s)
+		}
+	}
+	q := url.Values{}
+	for k, v := range req.EventFilter {
+		if !slices.Contains(streamFilterKeys, k) {
+			return eventFilter{}, fmt.Errorf("unknown event_filter key %q: must be one of %s", k, strings.Join(streamFilterKeys, "|"))
+		}
+		q.Set(k, v)
+	}
+	filter := parseEventFilter(q)
+	return filter, eventStreams.checkStreams(filter.streams)
+}
+
+// apply replaces the subscriptions. Dropping a subscription removes its queue
+// at once so nothing already queued for it is delivered. It reports whether
+// status was just subscribed to, which calls for a full status.
+func (s *streamSubscriptions) apply(req server.Subscribe, filter eventFilter) bool {
+	s.filter = filter
+	wantStatus := slices.Contains(req.Subscribe, server.SUBSCRIPTION_STATUS)
+	wantEvents := slices.Contains(req.Subscribe, server.SUBSCRIPTION_EVENTS)
+
+	started := false
+	if wantStatus && s.status == nil {
+		s.status = addStatusClient(s.id)
+		s.stream = newStatusStream(s.delta, s.cfg)
+		started = true
+	} else if !wantStatus && s.status != nil {
+		removeStatusClient(s.id)
+		s.status, s.stream = nil, nil
+	}
+	if wantEvents && s.events == nil {
+		s.events = addEventClient(s.id)
+	} else if !wantEvents && s.events != nil {
+		removeEventClient(s.id)
+		s.events = nil
+	}
+	registry.setSubscriptions(s.id, s.names())
+	return started
+}
+
+func (s *streamSubscriptions) names() []string {
+	ret := []string{}
+	if s.status != nil {
+		ret = append(ret, server.SUBSCRIPTION_STATUS)
+	}
+	if s.events != nil {
+		ret = append(ret, server.SUBSCRIPTION_EVENTS)
+	}
+	return ret
+}
+
+func (s *streamSubscriptions) close() {
+	s.apply(server.Subscribe{}, eventFilter{})
+}
+
+// handleStream multiplexes status and events over one v2 websocket. The
+// client subscribes with ?subscribe and the /events filter parameters, and
+// may replace its subscriptions at any time by sending a Subscribe message,
+// or report a gap in the events it received to have them sent again.
+// ?only, ?fields and ?exclude apply to its statuses as they do on /status.
+// It is one client to the registry, connection limit and quotas.
+func handleStream(cfg LabwatchConfig, auth *streamAuth, u websocket.Upgrader, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.allow(w, r) {
+			return
+		}
+		if server.Protocol(r) != server.PROTOCOL_V2 {
+			http.Error(w, "/stream requires protocol=v2", http.StatusBadRequest)
+			return
+		}
+		delta, err := statusDeltaRequested(r, ENCODING_JSON)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		view, err := requestView(r, cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		initial := server.Subscribe{EventFilter: map[string]string{}}
+		if v := r.URL.Query().Get("subscribe"); v != "" {
+			initial.Subscribe = strings.Split(v, ",")
+		}
+		for _, k := range streamFilterKeys {
+			if v := r.URL.Query().Get(k); v != "" {
+				initial.EventFilter[k] = v
+			}
+		}
+		filter, err := parseSubscribe(initial)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !connections.admit(w) {
+			return
+		}
+		defer connections.release()
+
+		client := registry.register(r, ENDPOINT_STREAM)
+		defer registry.unregister(client.ID)
+
+		conn, err := u.Upgrade(countingWriter{ResponseWriter: w, client: client}, r, nil)
+		if err != nil {
+			slog.Info("upgrade failed", "error", err.Error())
+			return
+		}
+
+		defer conn.Close()
+		clientsWG.Add(1)
+		defer clientsWG.Done()
+
+		log.Info("stream client connected", "client", client.ID, "remote", client.Remote)
+		defer log.Info("stream client disconnected", "client", client.ID)
+
+		framer := &server.Framer{}
+		if err := client.track(sendHello(conn, framer, cfg)); err != nil {
+			return
+		}
+
+		subs := &streamSubscriptions{id: client.ID, delta: delta, view: view, cfg: cfg}
+		defer subs.close()
+
+		done := make(chan struct{})
+		defer close(done)
+		conn.SetReadLimit(requestLimits.bodyLimit(r))
+		requests, readFailed := readClientMessages(conn, done)
+
+		subscribe := func(req server.Subscribe, filter eventFilter) error {
+			if subs.apply(req, filter) {
+				if err := client.track(subs.stream.write(conn, framer, ENCODING_JSON, subs.view.apply(loadStatus()), time.Now())); err != nil {
+					return err
+				}
+			}
+			log.Info("stream client subscribed", "client", client.ID, "subscriptions", subs.names())
+			control := server.Control{Action: server.CONTROL_SUBSCRIBED, Message: strings.Join(subs.names(), ",")}
+			return client.track(writeMessage(conn, framer, server.TYPE_CONTROL, control))
+		}
+		if err := subscribe(initial, filter); err != nil {
+			return
+		}
+
+		lifetime := connectionLifetime(cfg)
+		for {
+			// A nil channel never delivers, so dropped subscriptions go quiet
+			var statusCh <-chan LabStatus
+			var statusKicked, eventsKicked <-chan struct{}
+			var eventsCh <-chan loki.LogEvent
+			if subs.status != nil {
+				statusCh, statusKicked = subs.status.ch, subs.status.kicked
+			}
+			if subs.events != nil {
+				eventsCh, eventsKicked = subs.events.ch, subs.events.kicked
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-stopping:
+				closeClient(conn)
+				return
+			case <-lifetime:
+				log.Info("disconnecting stream client at max lifetime", "client", client.ID)
+				closeExpiredClient(conn)
+				return
+			case <-statusKicked:
+				log.Warn("disconnecting slow stream client", "client", client.ID, "subscription", server.SUBSCRIPTION_STATUS)
+				closeSlowClient(conn)
+				return
+			case <-eventsKicked:
+				log.Warn("disconnecting slow stream client", "client", client.ID, "subscription", server.SUBSCRIPTION_EVENTS)
+				closeSlowClient(conn)
+				return
+			case err := <-readFailed:
+				log.Debug("stream client read failed", "client", client.ID, "error", err.Error())
+				return
+			case msg := <-requests:
+				if msg.control != nil {
+					if err := backfill(conn, framer, client, subs.filter, *msg.control); err != nil {
+						return
+					}
+					continue
+				}
+				var filter eventFilter
+				err := msg.err
+				if err == nil {
+					filter, err = parseSubscribe(*msg.subscribe)
+				}
+				if err != nil {
+					if err := client.track(writeMessage(conn, framer, server.TYPE_ERROR, server.Error{Message: err.Error()})); err != nil {
+						return
+					}
+					continue
+				}
+				if err := subscribe(*msg.subscribe, filter); err != nil {
+					return
+				}
+			case status := <-statusCh:
+				if err := client.track(subs.stream.write(conn, framer, ENCODING_JSON, subs.view.apply(status), time.Now())); err != nil {
+					return
+				}
+			case e := <-eventsCh:
+				if !subs.filter.matches(e) {
+					continue
+				}
+				if err := writeEvent(conn, framer, client, e, log); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+type HealthLevel string
+
+const HEALTH_LEVEL_OK HealthLevel = "ok"
+const HEALTH_LEVEL_WARN HealthLevel = "warn"
+const HEALTH_LEVEL_CRITICAL HealthLevel = "critical"
+
+func (HealthLevel) JSONSchema() map[string]any {
+	return enumSchema(string(HEALTH_LEVEL_OK), string(HEALTH_LEVEL_WARN), string(HEALTH_LEVEL_CRITICAL))
+}
+
+func (l HealthLevel) rank() int {
+	switch l {
+	case HEALTH_LEVEL_CRITICAL:
+		return 2
+	case HEALTH_LEVEL_WARN:
+		return 1
+	}
+	return 0
+}
+
+func (l HealthLevel) color() string {
+	switch l {
+	case HEALTH_LEVEL_CRITICAL:
+		return "RED"
+	case HEALTH_LEVEL_WARN:
+		return "YELLOW"
+	}
+	return "GREEN"
+}
+
+func worst(a HealthLevel, b HealthLevel) HealthLevel {
+	if b.rank() > a.rank() {
+		return b
+	}
+	return a
+}
+
+// SummaryConfig sets the severity each kind of problem contributes to the
+// overall lab state
+type SummaryConfig struct {
+	Disconnected     HealthLevel            `yaml:"disconnected"`
+	NotReady         HealthLevel            `yaml:"not-ready"`
+	ServiceUnhealthy HealthLevel            `yaml:"service-unhealthy"`
+	Services         map[string]HealthLevel `yaml:"services"`
+}
+
+type Summary struct {
+	State      HealthLevel         `json:"state"`
+	Counts     map[HealthLevel]int `json:"counts"`
+	Suppressed int                 `json:"suppressed"`
+	Reasons    []string            `json:"reasons,omitempty"`
+}
+
+var healthMetric = expvar.NewInt("lab_health_state")
+
+// computeSummary derives the overall lab state from the worst state of each
+// entity in the status. Entities suppressed by a failed dependency are only
+// counted as suppressed so a single root cause doesn't dominate the rollup.
+func computeSummary(status LabStatus, cfg SummaryConfig) Summary {
+	ret := Summary{
+		State: HEALTH_LEVEL_OK,
+		Counts: map[HealthLevel]int{
+			HEALTH_LEVEL_OK:       0,
+			HEALTH_LEVEL_WARN:     0,
+			HEALTH_LEVEL_CRITICAL: 0,
+		},
+	}
+
+	nodes := []string{}
+	for node := range status.Talos {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	sections := []string{}
+	for section := range status.Sections {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		// Stale data can't vouch for a healthy lab
+		if status.Sections[section].Stale {
+			ret.State = worst(ret.State, HEALTH_LEVEL_WARN)
+			ret.Reasons = append(ret.Reasons, section+" data stale")
+		}
+	}
+
+	if endpointsDown(status.TalosEndpoints) {
+		ret.State = worst(ret.State, cfg.Disconnected)
+		ret.Reasons = append(ret.Reasons, "talos API endpoints unreachable")
+	}
+	if status.Logs.Connection == loki.CONNECTION_UNAUTHORIZED {
+		ret.State = worst(ret.State, HEALTH_LEVEL_WARN)
+		ret.Reasons = append(ret.Reasons, "loki credentials lack permission for the query")
+	}
+	if status.EtcdSnapshot != nil && status.EtcdSnapshot.Stale {
+		ret.State = worst(ret.State, HEALTH_LEVEL_WARN)
+		ret.Reasons = append(ret.Reasons, "etcd snapshot stale")
+	}
+
+	for _, node := range nodes {
+		if status.Talos[node].Departed {
+			continue
+		}
+		if status.Talos[node].SuppressedBy != "" {
+			ret.Suppressed++
+			continue
+		}
+		level, reasons := nodeHealth(status.Talos[node], cfg)
+		// A node can look fine at every update yet keep flapping between them
+		if c := status.Churn.Entities[node]; c.Churning {
+			level = worst(level, HEALTH_LEVEL_WARN)
+			reasons = append(reasons, fmt.Sprintf("churning, %d transitions in %s", c.Transitions, status.Churn.Window))
+		}
+		ret.Counts[level]++
+		ret.State = worst(ret.State, level)
+		for _, r := range reasons {
+			ret.Reasons = append(ret.Reasons, fmt.Sprintf("%s %s", node, r))
+		}
+	}
+	return ret
+}
+
+func nodeHealth(s talos.NodeStatus, cfg SummaryConfig) (HealthLevel, []string) {
+	level := HEALTH_LEVEL_OK
+	reasons := []string{}
+
+	if s.WatcherState == talos.CONNECTION_DISCONNECTED {
+		level = worst(level, cfg.Disconnected)
+		reasons = append(reasons, "unreachable")
+		// Nothing else the node reports can be trusted while disconnected
+		return level, reasons
+	}
+	if s.WatcherState == talos.CONNECTION_MAINTENANCE {
+		level = worst(level, cfg.NotReady)
+		reasons = append(reasons, "in maintenance mode")
+		return level, reasons
+	}
+
+	if !s.Ready {
+		level = worst(level, cfg.NotReady)
+		reasons = append(reasons, "not ready")
+	}
+
+	services := []string{}
+	for name, svc := range s.Services {
+		if svc.Healthy == talos.HEALTH_ERR {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	for _, name := range services {
+		svcLevel := cfg.ServiceUnhealthy
+		if l, ok := cfg.Services[name]; ok {
+			svcLevel = l
+		}
+		level = worst(level, svcLevel)
+		reasons = append(reasons, name+" degraded")
+	}
+
+	for _, metric := range sortedKeys(s.Degraded) {
+		level = worst(level, HealthLevel(s.Degraded[metric]))
+		reasons = append(reasons, fmt.Sprintf("%s %.1f over threshold", metric, s.Metrics[metric]))
+	}
+	return level, reasons
+}
+
+// summaryChangeMessage describes a change of the overall lab state, e.g.
+// "lab went RED: worker2 unreachable, worker1 etcd degraded"
+func summaryChangeMessage(s Summary) string {
+	msg := fmt.Sprintf("lab went %s", s.State.color())
+	if len(s.Reasons) > 0 {
+		msg += ": " + strings.Join(s.Reasons, ", ")
+	}
+	return msg
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/proxy"
+	"github.com/DRuggeri/labwatch/render"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+var tailMinBackoff = time.Duration(1) * time.Second
+var tailMaxBackoff = time.Duration(30) * time.Second
+
+// tailOptions selects what the tail command asks the server for. The filter
+// values are passed straight through as the /events query parameters.
+type tailOptions struct {
+	Server  string
+	Token   string
+	Replay  bool
+	Filters url.Values
+	Format  render.Format
+}
+
+// errTailRejected is returned when the server refuses the request outright,
+// in which case reconnecting would only fail the same way
+type errTailRejected struct {
+	status int
+	body   string
+}
+
+func (e errTailRejected) Error() string {
+	return fmt.Sprintf("server rejected the request with status %d: %s", e.status, e.body)
+}
+
+// tailEvents streams events from a running labwatch to out, reconnecting
+// with backoff until ctx is done or the server rejects the request
+func tailEvents(ctx context.Context, opts tailOptions, out io.Writer, log *slog.Logger) error {
+	log = log.With("operation", "tail", "server", opts.Server)
+	u, err := url.Parse(strings.TrimSuffix(opts.Server, "/") + ENDPOINT_EVENTS)
+	if err != nil {
+		return fmt.Errorf("invalid server URL: %w", err)
+	}
+
+	colorizer := render.NewColorizer(out)
+	backoff := tailMinBackoff
+	replay := opts.Replay
+	for {
+		connected, err := tailOnce(ctx, *u, opts, replay, out, colorizer)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, ok := err.(errTailRejected); ok {
+			return err
+		}
+		if connected {
+			// Only the first connection replays so reconnecting doesn't reprint
+			replay = false
+			backoff = tailMinBackoff
+		}
+
+		msg := "event stream closed, reconnecting"
+		args := []any{"in", backoff}
+		if err != nil {
+			msg = "event stream failed, reconnecting"
+			args = append(args, "error", err.Error())
+		}
+		log.Warn(msg, args...)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, tailMaxBackoff)
+	}
+}
+
+// tailOnce makes a single streaming request. connected reports whether the
+// server accepted it before the stream ended.
+func tailOnce(ctx context.Context, u url.URL, opts tailOptions, replay bool, out io.Writer, colorizer *render.Colorizer) (connected bool, err error) {
+	q := url.Values{}
+	for k, v := range opts.Filters {
+		q[k] = v
+	}
+	q.Set("stream", "ndjson")
+	if q.Get("client_name") == "" {
+		q.Set("client_name", "labwatch-tail")
+	}
+	if replay {
+		q.Set("replay", "true")
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	resp, err := proxy.HTTPClient(0).Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, errTailRejected{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		e := loki.LogEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return true, fmt.Errorf("failed to decode event: %w", err)
+		}
+		if opts.Format == render.FORMAT_JSON {
+			fmt.Fprintln(out, scanner.Text())
+			continue
+		}
+		fmt.Fprintln(out, render.EventLine(e, time.Now(), colorizer, opts.Format == render.FORMAT_WIDE))
+	}
+	return true, scanner.Err()
+}
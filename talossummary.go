@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func init() {
+	registerSchemaSection("talos", SCHEMA_WATCHER, "Watches the Talos API of every node in the cluster", "talos-")
+}
+
+// TalosSummary rolls the node map up into the cluster level figures a
+// dashboard header or health expression wants without iterating the nodes
+type TalosSummary struct {
+	Total               int         `json:"total"`
+	Healthy             int         `json:"healthy"`
+	ControlPlane        int         `json:"control_plane"`
+	ControlPlaneHealthy int         `json:"control_plane_healthy"`
+	EtcdHealthy         bool        `json:"etcd_healthy"`
+	Unreachable         []string    `json:"unreachable"`
+	Versions            []string    `json:"versions"`
+	VersionsConsistent  bool        `json:"versions_consistent"`
+	Worst               HealthLevel `json:"worst"`
+
+	// EndpointsDown is set when no Talos API endpoint answers cluster wide
+	// calls, which can happen with nodes still reachable one by one
+	EndpointsDown bool `json:"endpoints_down"`
+}
+
+// endpointsDown reports whether every endpoint has failed. An empty list
+// means the watcher hasn't polled yet.
+func endpointsDown(endpoints []talos.EndpointStatus) bool {
+	for _, e := range endpoints {
+		if e.Healthy {
+			return false
+		}
+	}
+	return len(endpoints) > 0
+}
+
+// computeTalosSummary is recomputed from scratch on every broadcast so
+// nodes coming and going are always reflected. Node health is judged the same
+// way as the overall summary, and departed nodes are left out.
+func computeTalosSummary(nodes map[string]talos.NodeStatus, cfg SummaryConfig) TalosSummary {
+	ret := TalosSummary{
+		Unreachable: []string{},
+		Versions:    []string{},
+		Worst:       HEALTH_LEVEL_OK,
+		EtcdHealthy: true,
+	}
+	versions := map[string]bool{}
+	for name, n := range nodes {
+		if n.Departed {
+			continue
+		}
+		ret.Total++
+		level, _ := nodeHealth(n, cfg)
+		ret.Worst = worst(ret.Worst, level)
+		if level == HEALTH_LEVEL_OK {
+			ret.Healthy++
+		}
+		if n.WatcherState == talos.CONNECTION_DISCONNECTED {
+			ret.Unreachable = append(ret.Unreachable, name)
+		}
+		if nodeRole(n) == NODE_ROLE_CONTROLPLANE {
+			ret.ControlPlane++
+			if level == HEALTH_LEVEL_OK {
+				ret.ControlPlaneHealthy++
+			}
+			if n.Services["etcd"].Healthy != talos.HEALTH_OK {
+				ret.EtcdHealthy = false
+			}
+		}
+		if n.Version != "" {
+			versions[n.Version] = true
+		}
+	}
+	for v := range versions {
+		ret.Versions = append(ret.Versions, v)
+	}
+	sort.Strings(ret.Unreachable)
+	sort.Strings(ret.Versions)
+	ret.VersionsConsistent = len(ret.Versions) <= 1
+	if ret.ControlPlane == 0 {
+		ret.EtcdHealthy = false
+	}
+	return ret
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+func controlPlane(version string, etcd talos.HealthState) talos.NodeStatus {
+	return talos.NodeStatus{
+		WatcherState: talos.CONNECTION_OK,
+		Ready:        true,
+		Version:      version,
+		Services: map[string]talos.ServiceStatus{
+			"etcd":    {Healthy: etcd},
+			"kubelet": {Healthy: talos.HEALTH_OK},
+		},
+	}
+}
+
+func worker(version string) talos.NodeStatus {
+	return talos.NodeStatus{
+		WatcherState: talos.CONNECTION_OK,
+		Ready:        true,
+		Version:      version,
+		Services:     map[string]talos.ServiceStatus{"kubelet": {Healthy: talos.HEALTH_OK}},
+	}
+}
+
+func with(n talos.NodeStatus, change func(n *talos.NodeStatus)) talos.NodeStatus {
+	change(&n)
+	return n
+}
+
+func TestComputeTalosSummary(t *testing.T) {
+	unreachable := func(n *talos.NodeStatus) { n.WatcherState = talos.CONNECTION_DISCONNECTED }
+	notReady := func(n *talos.NodeStatus) { n.Ready = false }
+	departed := func(n *talos.NodeStatus) { n.Departed = true }
+
+	tests := []struct {
+		name  string
+		nodes map[string]talos.NodeStatus
+		want  TalosSummary
+	}{
+		{
+			// Before discovery finds anything there is no etcd to vouch for
+			name:  "no nodes",
+			nodes: map[string]talos.NodeStatus{},
+			want:  TalosSummary{Unreachable: []string{}, Versions: []string{}, VersionsConsistent: true, Worst: HEALTH_LEVEL_OK},
+		},
+		{
+			name: "all healthy",
+			nodes: map[string]talos.NodeStatus{
+				"cp1":     controlPlane("v1.9.0", talos.HEALTH_OK),
+				"cp2":     controlPlane("v1.9.0", talos.HEALTH_OK),
+				"cp3":     controlPlane("v1.9.0", talos.HEALTH_OK),
+				"worker1": worker("v1.9.0"),
+				"worker2": worker("v1.9.0"),
+			},
+			want: TalosSummary{
+				Total: 5, Healthy: 5, ControlPlane: 3, ControlPlaneHealthy: 3, EtcdHealthy: true,
+				Unreachable: []string{}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_OK,
+			},
+		},
+		{
+			name: "worker not ready",
+			nodes: map[string]talos.NodeStatus{
+				"cp1":     controlPlane("v1.9.0", talos.HEALTH_OK),
+				"worker1": with(worker("v1.9.0"), notReady),
+			},
+			want: TalosSummary{
+				Total: 2, Healthy: 1, ControlPlane: 1, ControlPlaneHealthy: 1, EtcdHealthy: true,
+				Unreachable: []string{}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_WARN,
+			},
+		},
+		{
+			name: "etcd unhealthy",
+			nodes: map[string]talos.NodeStatus{
+				"cp1": controlPlane("v1.9.0", talos.HEALTH_OK),
+				"cp2": controlPlane("v1.9.0", talos.HEALTH_ERR),
+			},
+			want: TalosSummary{
+				Total: 2, Healthy: 1, ControlPlane: 2, ControlPlaneHealthy: 1,
+				Unreachable: []string{}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_CRITICAL,
+			},
+		},
+		{
+			// An unreachable node's services can't be trusted, but it still
+			// counts as a control plane node with etcd in doubt
+			name: "unreachable nodes sorted",
+			nodes: map[string]talos.NodeStatus{
+				"cp1":     controlPlane("v1.9.0", talos.HEALTH_OK),
+				"cp2":     with(controlPlane("v1.9.0", talos.HEALTH_UNKNOWN), unreachable),
+				"worker2": with(worker(""), unreachable),
+				"worker1": worker("v1.9.0"),
+			},
+			want: TalosSummary{
+				Total: 4, Healthy: 2, ControlPlane: 2, ControlPlaneHealthy: 1,
+				Unreachable: []string{"cp2", "worker2"}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_CRITICAL,
+			},
+		},
+		{
+			name: "mid upgrade",
+			nodes: map[string]talos.NodeStatus{
+				"cp1":     controlPlane("v1.9.1", talos.HEALTH_OK),
+				"worker1": worker("v1.9.0"),
+				"worker2": worker("v1.10.0"),
+				"worker3": worker(""),
+			},
+			want: TalosSummary{
+				Total: 4, Healthy: 4, ControlPlane: 1, ControlPlaneHealthy: 1, EtcdHealthy: true,
+				Unreachable: []string{}, Versions: []string{"v1.10.0", "v1.9.0", "v1.9.1"}, Worst: HEALTH_LEVEL_OK,
+			},
+		},
+		{
+			// Departed nodes are left out as the overall summary leaves them
+			// out, even when they went away broken
+			name: "departed nodes",
+			nodes: map[string]talos.NodeStatus{
+				"cp1":     controlPlane("v1.9.0", talos.HEALTH_OK),
+				"worker1": worker("v1.9.0"),
+				"worker9": with(with(worker("v1.8.0"), unreachable), departed),
+			},
+			want: TalosSummary{
+				Total: 2, Healthy: 2, ControlPlane: 1, ControlPlaneHealthy: 1, EtcdHealthy: true,
+				Unreachable: []string{}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_OK,
+			},
+		},
+		{
+			name: "only workers",
+			nodes: map[string]talos.NodeStatus{
+				"worker1": worker("v1.9.0"),
+			},
+			want: TalosSummary{
+				Total: 1, Healthy: 1,
+				Unreachable: []string{}, Versions: []string{"v1.9.0"}, VersionsConsistent: true, Worst: HEALTH_LEVEL_OK,
+			},
+		},
+	}
+
+	cfg := defaultConfig().Summary
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeTalosSummary(tt.nodes, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointsDown(t *testing.T) {
+	tests := []struct {
+		healthy []bool
+		want    bool
+	}{
+		{nil, false},
+		{[]bool{true}, false},
+		{[]bool{false, true}, false},
+		{[]bool{false}, true},
+		{[]bool{false, false}, true},
+	}
+	for _, tt := range tests {
+		endpoints := []talos.EndpointStatus{}
+		for _, h := range tt.healthy {
+			endpoints = append(endpoints, talos.EndpointStatus{Healthy: h})
+		}
+		if got := endpointsDown(endpoints); got != tt.want {
+			t.Errorf("endpointsDown(%v) = %t, want %t", tt.healthy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/DRuggeri/labwatch/watchers/talos"
+)
+
+// NodeThresholdConfig marks a node degraded while a metric is above the
+// threshold. Once crossed, the node stays degraded until the metric drops
+// under clear-below, 90% of above by default, so a value hovering at the
+// threshold doesn't flap. nodes and role narrow the rule to a group of
+// nodes; both empty applies it to every node.
+type NodeThresholdConfig struct {
+	Metric     string      `yaml:"metric"`
+	Above      float64     `yaml:"above"`
+	ClearBelow float64     `yaml:"clear-below"`
+	Severity   HealthLevel `yaml:"severity"`
+	Nodes      []string    `yaml:"nodes"`
+	Role       string      `yaml:"role"`
+}
+
+func (c NodeThresholdConfig) applies(name string, n talos.NodeStatus) bool {
+	if len(c.Nodes) > 0 && !slices.Contains(c.Nodes, name) {
+		return false
+	}
+	return c.Role == "" || c.Role == nodeRole(n)
+}
+
+// thresholdEvaluator is only used from the watch loop so it needs no locking
+type thresholdEvaluator struct {
+	rules    []NodeThresholdConfig
+	exceeded map[string]bool
+	log      *slog.Logger
+}
+
+func newThresholdEvaluator(cfgs []NodeThresholdConfig, log *slog.Logger) (*thresholdEvaluator, error) {
+	ret := &thresholdEvaluator{exceeded: map[string]bool{}, log: log.With("operation", "thresholdEvaluator")}
+	for i, c := range cfgs {
+		if !slices.Contains(talos.Metrics, c.Metric) {
+			return nil, fmt.Errorf("threshold %d: unknown metric %q, must be one of %v", i, c.Metric, talos.Metrics)
+		}
+		if c.Severity == "" {
+			c.Severity = HEALTH_LEVEL_WARN
+		}
+		if c.Severity != HEALTH_LEVEL_WARN && c.Severity != HEALTH_LEVEL_CRITICAL {
+			return nil, fmt.Errorf("threshold %d: invalid severity %q, must be one of warn|critical", i, c.Severity)
+		}
+		if c.ClearBelow == 0 {
+			c.ClearBelow = c.Above * 0.9
+		}
+		if c.ClearBelow > c.Above {
+			return nil, fmt.Errorf("threshold %d: clear-below must not be above the threshold", i)
+		}
+		if c.Role != "" && c.Role != NODE_ROLE_CONTROLPLANE && c.Role != NODE_ROLE_WORKER {
+			return nil, fmt.Errorf("threshold %d: invalid role %q, must be one of controlplane|worker", i, c.Role)
+		}
+		ret.rules = append(ret.rules, c)
+	}
+	return ret, nil
+}
+
+// apply evaluates every rule against the nodes' latest metrics, setting
+// Degraded on the nodes and announcing each threshold crossed or cleared.
+// Disconnected nodes keep their state until fresh metrics arrive.
+func (e *thresholdEvaluator) apply(nodes map[string]talos.NodeStatus) {
+	if e == nil || len(e.rules) == 0 {
+		return
+	}
+	for name, n := range nodes {
+		n.Degraded = nil
+		for i, rule := range e.rules {
+			if !rule.applies(name, n) {
+				continue
+			}
+			key := fmt.Sprintf("%s/%d", name, i)
+			was := e.exceeded[key]
+			if v, ok := n.Metrics[rule.Metric]; ok && n.WatcherState == talos.CONNECTION_OK {
+				is := v > rule.Above || (was && v >= rule.ClearBelow)
+				// An alert restored from the saved state that is no longer
+				// exceeded is cleared too, or level destinations would be
+				// reminded about it forever
+				if is != was || (!is && notifier.isFiring("threshold/"+key)) {
+					e.exceeded[key] = is
+					e.announce(key, name, n, rule, v, is)
+				}
+			}
+			if e.exceeded[key] {
+				if n.Degraded == nil {
+					n.Degraded = map[string]string{}
+				}
+				n.Degraded[rule.Metric] = string(worst(HealthLevel(n.Degraded[rule.Metric]), rule.Severity))
+			}
+		}
+		nodes[name] = n
+	}
+}
+
+func (e *thresholdEvaluator) announce(key string, name string, n talos.NodeStatus, rule NodeThresholdConfig, v float64, exceeded bool) {
+	now := clk.Now()
+	alert := "threshold/" + key
+	if !exceeded {
+		msg := fmt.Sprintf("%s %s back to %.1f, below %.1f", name, rule.Metric, v, rule.ClearBelow)
+		e.log.Info(msg)
+		broadcastEvent(loki.LogEvent{Node: name, Service: "labwatch", Level: "info", Message: msg, Timestamp: now, Injected: n.Injected}, e.log)
+		notifier.send(Notification{Title: "node threshold cleared", Node: name, Message: msg, Severity: string(HEALTH_LEVEL_OK), Time: now, Injected: n.Injected, Alert: alert, Resolved: true})
+		return
+	}
+
+	msg := fmt.Sprintf("%s %s is %.1f, above %.1f", name, rule.Metric, v, rule.Above)
+	e.log.Warn(msg)
+	event := loki.LogEvent{Node: name, Service: "labwatch", Level: "warning", Message: msg, Timestamp: now, Injected: n.Injected}
+	broadcastEvent(event, e.log)
+	notifier.send(Notification{Title: "node threshold exceeded", Node: name, Message: msg, Severity: string(rule.Severity), Time: now, Injected: n.Injected, Event: &event, Alert: alert})
+}
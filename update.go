@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DRuggeri/labwatch/selfupdate"
+)
+
+const RESTART_NONE = "none"
+const RESTART_SYSTEMD = "systemd"
+
+var systemctlTimeout = time.Duration(30) * time.Second
+
+type selfUpdateOptions struct {
+	URL       string
+	CheckOnly bool
+	Force     bool
+	Restart   string
+	Unit      string
+}
+
+// runSelfUpdate replaces the running binary with the latest release when it
+// is newer, or whenever forced. The release has to verify against the signing
+// key built into this binary; the binary on disk is only replaced once it has.
+func runSelfUpdate(ctx context.Context, opts selfUpdateOptions, out io.Writer) error {
+	release, err := selfupdate.Check(ctx, opts.URL)
+	if err != nil {
+		return fmt.Errorf("unable to check for updates: %w", err)
+	}
+	newer := selfupdate.Newer(Version, release.Version)
+	if !newer {
+		fmt.Fprintf(out, "labwatch %s is current, latest release is %s\n", Version, release.Version)
+	} else {
+		fmt.Fprintf(out, "labwatch %s is available, running %s\n", release.Version, Version)
+	}
+	if opts.CheckOnly || (!newer && !opts.Force) {
+		return nil
+	}
+
+	key, err := selfupdate.ParsePublicKey(selfupdate.PublicKey)
+	if err != nil {
+		return err
+	}
+	target, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if target, err = filepath.EvalSymlinks(target); err != nil {
+		return err
+	}
+	if err = selfupdate.Install(ctx, release, target, key); err != nil {
+		return fmt.Errorf("update aborted, %s is unchanged: %w", target, err)
+	}
+	fmt.Fprintf(out, "installed labwatch %s to %s\n", release.Version, target)
+
+	if opts.Restart == RESTART_SYSTEMD {
+		ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+		defer cancel()
+		// --no-block so the restart doesn't wait on this process when it is
+		// itself run from the unit
+		if b, err := exec.CommandContext(ctx, "systemctl", "restart", "--no-block", opts.Unit).CombinedOutput(); err != nil {
+			return fmt.Errorf("installed, but restarting %s failed: %w: %s", opts.Unit, err, b)
+		}
+		fmt.Fprintf(out, "restarting %s\n", opts.Unit)
+	}
+	return nil
+}
+
+// VersionInfo is served at /version. The update fields are only set when
+// update-check-interval enables the background check and it has succeeded.
+type VersionInfo struct {
+	Version         string     `json:"version"`
+	ConfigHash      string     `json:"config_hash"`
+	UpdateAvailable *bool      `json:"update_available,omitempty"`
+	Latest          string     `json:"latest,omitempty"`
+	Checked         *time.Time `json:"checked,omitempty"`
+}
+
+// updateChecker periodically looks for a newer release. It only reports; the
+// binary is replaced by running self-update.
+type updateChecker struct {
+	latest  string
+	checked time.Time
+	lock    sync.Mutex
+}
+
+var updates *updateChecker
+
+func newUpdateChecker() *updateChecker {
+	return &updateChecker{}
+}
+
+func (u *updateChecker) run(ctx context.Context, url string, interval time.Duration, log *slog.Logger) {
+	log = log.With("operation", "updateChecker")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if release, err := selfupdate.Check(ctx, url); err != nil {
+			log.Warn("unable to check for updates", "url", url, "error", err.Error())
+		} else {
+			u.lock.Lock()
+			if release.Version != u.latest && selfupdate.Newer(Version, release.Version) {
+				log.Info("labwatch update available", "version", Version, "latest", release.Version)
+			}
+			u.latest, u.checked = release.Version, time.Now()
+			u.lock.Unlock()
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (u *updateChecker) info() VersionInfo {
+	ret := VersionInfo{Version: Version, ConfigHash: currentConfigHash()}
+	if u == nil {
+		return ret
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.checked.IsZero() {
+		return ret
+	}
+	available := selfupdate.Newer(Version, u.latest)
+	checked := u.checked
+	ret.UpdateAvailable, ret.Latest, ret.Checked = &available, u.latest, &checked
+	return ret
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	b, _ := encodeJSON(updates.info())
+	w.Write(b)
+}
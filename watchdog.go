@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/DRuggeri/labwatch/sdnotify"
+)
+
+var selfCheckTimeout = time.Duration(2) * time.Second
+
+// loopHeartbeat is the unix nanosecond time the watch loop last went round.
+// The loop spins at least every stalenessCheckInterval, so a stale heartbeat
+// means it is wedged, typically on a blocked broadcast.
+var loopHeartbeat atomic.Int64
+
+func beatLoop(now time.Time) {
+	loopHeartbeat.Store(now.UnixNano())
+}
+
+// checkLiveness reports why labwatch should be considered wedged, or nil if
+// the watch loop is turning over and the HTTP listener answers
+func checkLiveness(ctx context.Context, selfURL string, maxAge time.Duration, now time.Time) error {
+	if age := now.Sub(time.Unix(0, loopHeartbeat.Load())); age > maxAge {
+		return fmt.Errorf("watch loop last ran %s ago", age.Round(time.Millisecond))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, selfCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, selfURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http listener not answering: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http listener returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runWatchdog pets the systemd watchdog at half its interval, but only while
+// labwatch is live. Withholding WATCHDOG=1 is what gets a wedged process
+// restarted.
+func runWatchdog(interval time.Duration, selfURL string, log *slog.Logger) {
+	log = log.With("operation", "watchdog")
+	log.Info("systemd watchdog enabled", "interval", interval)
+	for range time.Tick(interval / 2) {
+		if err := checkLiveness(context.Background(), selfURL, interval/2, time.Now()); err != nil {
+			log.Error("liveness check failed, not petting the watchdog", "error", err.Error())
+			continue
+		}
+		if _, err := sdnotify.Notify(sdnotify.WATCHDOG); err != nil {
+			log.Warn("failed to notify systemd", "error", err.Error())
+		}
+	}
+}
+
+// notifySystemd sends a state change to systemd, if labwatch runs under it
+func notifySystemd(state string, log *slog.Logger) {
+	if sent, err := sdnotify.Notify(state); err != nil {
+		log.Warn("failed to notify systemd", "state", state, "error", err.Error())
+	} else if sent {
+		log.Debug("notified systemd", "state", state)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckLiveness(t *testing.T) {
+	defer func(last int64) { loopHeartbeat.Store(last) }(loopHeartbeat.Load())
+	ok := httptest.NewServer(http.HandlerFunc(handleHealthz))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		beat    time.Duration
+		selfURL string
+		want    string
+	}{
+		{name: "live", beat: time.Second, selfURL: ok.URL},
+		// A loop wedged on a broadcast stops beating while the listener,
+		// running on its own goroutines, still answers
+		{name: "wedged loop", beat: time.Minute, selfURL: ok.URL, want: "watch loop last ran 1m0s ago"},
+		{name: "listener down", beat: time.Second, selfURL: gone.URL, want: "http listener not answering"},
+		{name: "listener failing", beat: time.Second, selfURL: failing.URL, want: "returned status 503"},
+	}
+	for _, tt := range tests {
+		beatLoop(now.Add(-tt.beat))
+		err := checkLiveness(context.Background(), tt.selfURL, 10*time.Second, now)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
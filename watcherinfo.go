@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/DRuggeri/labwatch/watchers"
+	"github.com/DRuggeri/labwatch/watchers/journald"
+)
+
+// WatcherInfo is a registered kind of watcher and what this config makes of
+// it. Ready is only set for configured watchers that can run.
+type WatcherInfo struct {
+	watchers.Info
+	Configured bool  `json:"configured"`
+	Ready      *bool `json:"ready,omitempty"`
+}
+
+// configuredWatchers names the registered watchers the config starts
+func configuredWatchers(cfg LabwatchConfig) []string {
+	ret := []string{WATCHER_TALOS}
+	if cfg.LogSource != LOG_SOURCE_JOURNALD {
+		return append(ret, WATCHER_LOKI)
+	}
+	ret = append(ret, string(LOG_SOURCE_JOURNALD))
+	if len(cfg.Journald.Hosts) == 0 || slices.Contains(cfg.Journald.Hosts, journald.LOCALHOST) {
+		ret = append(ret, journald.WATCHER_LOCAL)
+	}
+	return ret
+}
+
+// unsupportedWatchers names the configured watchers this build can't run
+func unsupportedWatchers(cfg LabwatchConfig) []string {
+	ret := []string{}
+	for _, name := range configuredWatchers(cfg) {
+		if info, ok := watchers.Lookup(name); ok && !info.Supported {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// checkConfigWarnings is what check-config warns about in a config that
+// loads. None of it makes the check fail.
+func checkConfigWarnings(cfg LabwatchConfig) []string {
+	ret := []string{}
+	for _, name := range unsupportedWatchers(cfg) {
+		ret = append(ret, fmt.Sprintf("watcher %s is %s and will not run", name, watchers.ErrUnsupported.Error()))
+	}
+	if warning := adminExposureWarning(cfg); warning != "" {
+		ret = append(ret, warning)
+	}
+	return ret
+}
+
+// handleWatchers lists every watcher this build knows of, whether it runs on
+// this platform and, for those configured, whether it is delivering data
+func handleWatchers(cfg LabwatchConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		configured := configuredWatchers(cfg)
+		states, _ := watchersHealth.snapshot()
+		ret := []WatcherInfo{}
+		for _, info := range watchers.All() {
+			wi := WatcherInfo{Info: info, Configured: slices.Contains(configured, info.Name)}
+			// Either log source reports its readiness as the loki watcher
+			health := info.Name
+			if health == string(LOG_SOURCE_JOURNALD) {
+				health = WATCHER_LOKI
+			}
+			if ready, ok := states[health]; ok && wi.Configured && info.Supported {
+				wi.Ready = &ready
+			}
+			ret = append(ret, wi)
+		}
+		b, _ := encodeJSON(ret)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/watchers"
+	"github.com/DRuggeri/labwatch/watchers/journald"
+)
+
+func TestUnsupportedWatchers(t *testing.T) {
+	// Stand in for a build where the local journal can't be read
+	info, _ := watchers.Lookup(journald.WATCHER_LOCAL)
+	watchers.Register(info.Name, info.Description, false)
+	t.Cleanup(func() { watchers.Register(info.Name, info.Description, info.Supported) })
+
+	cfg := defaultConfig()
+	if got := unsupportedWatchers(cfg); len(got) != 0 {
+		t.Errorf("loki config unsupported: %v", got)
+	}
+	cfg.LogSource = LOG_SOURCE_JOURNALD
+	cfg.Journald.Hosts = []string{"cp1"}
+	if got := unsupportedWatchers(cfg); len(got) != 0 {
+		t.Errorf("remote journald unsupported: %v", got)
+	}
+	cfg.Journald.Hosts = append(cfg.Journald.Hosts, journald.LOCALHOST)
+	if got := unsupportedWatchers(cfg); !slices.Equal(got, []string{journald.WATCHER_LOCAL}) {
+		t.Errorf("unsupported %v, want %s", got, journald.WATCHER_LOCAL)
+	}
+
+	// check-config warns that it won't run here, and why
+	want := "watcher " + journald.WATCHER_LOCAL + " is " + watchers.ErrUnsupported.Error() + " and will not run"
+	if got := checkConfigWarnings(cfg); !slices.Contains(got, want) {
+		t.Errorf("check-config warned %q, want %q", got, want)
+	}
+
+	// /watchers says so rather than reporting it not ready
+	rec := httptest.NewRecorder()
+	handleWatchers(cfg)(rec, httptest.NewRequest("GET", "/watchers", nil))
+	got := []WatcherInfo{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(got, func(w WatcherInfo) bool { return w.Name == journald.WATCHER_LOCAL })
+	if i < 0 {
+		t.Fatalf("%s not listed in %+v", journald.WATCHER_LOCAL, got)
+	}
+	if w := got[i]; w.Supported || !w.Configured || w.Ready != nil {
+		t.Errorf("listed as %+v", w)
+	}
+}
@@ -0,0 +1,369 @@
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/watchers"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+)
+
+var restartDelay = time.Duration(1) * time.Second
+var maxRestartDelay = time.Duration(1) * time.Minute
+var maxLineSize = 1024 * 1024
+var maxStderrSize = 4096
+
+// LOCALHOST reads the journal of the machine labwatch runs on rather than
+// connecting over SSH
+const LOCALHOST = "localhost"
+
+var defaultSSHCommand = []string{"ssh", "-o", "BatchMode=yes"}
+
+// WATCHER_LOCAL is the registry name of following localhost, which only
+// Linux builds can do
+const WATCHER_LOCAL = "journald-local"
+
+func init() {
+	watchers.Register("journald", "Follows the systemd journal of hosts reached over SSH", true)
+	watchers.Register(WATCHER_LOCAL, "Follows the systemd journal of the machine labwatch runs on", localJournal)
+}
+
+// priorities maps syslog priorities to the level names Loki reports
+var priorities = []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+
+type JournaldWatcherConfig struct {
+	// Hosts to follow. Anything but localhost is reached by running
+	// journalctl through SSHCommand. Empty follows localhost only.
+	Hosts      []string `yaml:"hosts"`
+	SSHCommand []string `yaml:"ssh-command"`
+
+	// Units limits the journal to these units. ExcludeUnits drops entries
+	// from units, or syslog identifiers for entries without one.
+	Units        []string `yaml:"units"`
+	ExcludeUnits []string `yaml:"exclude-units"`
+
+	// TraceIDField names the journal field holding a trace or correlation ID
+	// to copy into LogEvent.TraceID
+	TraceIDField string `yaml:"-"`
+}
+
+// JournaldWatcher follows the systemd journal of one or more hosts by running
+// journalctl, restarting it whenever it exits
+type JournaldWatcher struct {
+	cfg     JournaldWatcherConfig
+	exclude map[string]bool
+	log     *slog.Logger
+}
+
+func NewJournaldWatcher(cfg JournaldWatcherConfig, log *slog.Logger) (*JournaldWatcher, error) {
+	if log == nil {
+		log = slog.Default()
+	}
+	if len(cfg.Hosts) == 0 {
+		cfg.Hosts = []string{LOCALHOST}
+	}
+	if len(cfg.SSHCommand) == 0 {
+		cfg.SSHCommand = defaultSSHCommand
+	}
+	exclude := map[string]bool{}
+	for _, u := range cfg.ExcludeUnits {
+		exclude[u] = true
+	}
+	for _, h := range cfg.Hosts {
+		if h == "" || strings.HasPrefix(h, "-") {
+			return nil, fmt.Errorf("invalid host %q", h)
+		}
+	}
+	if !localJournal && slices.Contains(cfg.Hosts, LOCALHOST) {
+		log.Warn("not following localhost, reading the local journal is "+watchers.ErrUnsupported.Error(), "operation", "JournaldWatcher")
+		cfg.Hosts = slices.DeleteFunc(slices.Clone(cfg.Hosts), func(h string) bool { return h == LOCALHOST })
+	}
+
+	return &JournaldWatcher{
+		cfg:     cfg,
+		exclude: exclude,
+		log:     log.With("operation", "JournaldWatcher"),
+	}, nil
+}
+
+// command builds journalctl for the host. After a restart it resumes from the
+// cursor of the last entry seen so nothing is lost or repeated.
+func (w *JournaldWatcher) command(host string, cursor string, follow bool) []string {
+	args := []string{"journalctl", "--output=json"}
+	if follow {
+		args = append(args, "--follow")
+	}
+	if cursor != "" {
+		args = append(args, "--after-cursor="+cursor)
+	} else {
+		args = append(args, "--lines=0")
+	}
+	for _, u := range w.cfg.Units {
+		args = append(args, "--unit="+u)
+	}
+	if host == LOCALHOST {
+		return args
+	}
+
+	// ssh hands the command to the remote shell as one string
+	for i := range args {
+		args[i] = shellQuote(args[i])
+	}
+	ret := append([]string{}, w.cfg.SSHCommand...)
+	return append(append(ret, "--", host), args...)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (w *JournaldWatcher) Watch(controlContext context.Context, eventChan chan<- loki.LogEvent, statChan chan<- loki.LogStats) {
+	entries := make(chan loki.LogEvent)
+	for _, host := range w.cfg.Hosts {
+		go w.follow(controlContext, host, entries)
+	}
+
+	stats := loki.LogStats{}
+	for {
+		select {
+		case <-controlContext.Done():
+			return
+		case e := <-entries:
+			select {
+			case eventChan <- e:
+			case <-controlContext.Done():
+				return
+			}
+			stats.Count(e)
+			select {
+			case statChan <- stats:
+			case <-controlContext.Done():
+				return
+			}
+		}
+	}
+}
+
+// follow keeps journalctl running for the host, backing off while it keeps
+// exiting straight away
+func (w *JournaldWatcher) follow(ctx context.Context, host string, entries chan<- loki.LogEvent) {
+	log := w.log.With("host", host)
+	cursor := ""
+	delay := restartDelay
+	for ctx.Err() == nil {
+		started := time.Now()
+		log.Debug("starting journalctl")
+		err := w.run(ctx, host, &cursor, entries, log)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxRestartDelay {
+			delay = restartDelay
+		}
+		reason := "exited"
+		if err != nil {
+			reason = err.Error()
+		}
+		log.Warn("journalctl stopped, restarting", "reason", reason, "retry", delay.String())
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+func (w *JournaldWatcher) run(ctx context.Context, host string, cursor *string, entries chan<- loki.LogEvent, log *slog.Logger) error {
+	args := w.command(host, *cursor, true)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	stderr := &limitedBuffer{max: maxStderrSize}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := readLine(reader)
+		if errors.Is(err, errLineTooLong) {
+			log.Warn("skipping oversized journal entry", "limit", maxLineSize)
+			continue
+		} else if err != nil {
+			if len(line) > 0 {
+				log.Debug("discarding partial journal entry", "bytes", len(line))
+			}
+			break
+		}
+
+		e, c, err := parseEntry(line, w.cfg.TraceIDField)
+		if err != nil {
+			log.Error("error unmarshalling", "error", err, "received", string(line))
+			continue
+		}
+		*cursor = c
+		if w.exclude[e.Service] {
+			continue
+		}
+		if e.Node == "" && host != LOCALHOST {
+			e.Node = host
+		}
+		select {
+		case entries <- e:
+		case <-ctx.Done():
+		}
+	}
+
+	err = cmd.Wait()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+var errLineTooLong = errors.New("line too long")
+
+// readLine returns the next complete line without its newline. A line cut
+// short by the stream ending is returned along with the error so the caller
+// can tell it apart from a clean end.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(line) > maxLineSize {
+				tooLong, line = true, nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return line, err
+		}
+		if tooLong {
+			return nil, errLineTooLong
+		}
+		return bytes.TrimRight(line, "\r\n"), nil
+	}
+}
+
+// parseEntry converts a journalctl JSON entry to an event, returning the
+// entry's cursor alongside
+func parseEntry(line []byte, traceField string) (loki.LogEvent, string, error) {
+	raw := map[string]any{}
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return loki.LogEvent{}, "", err
+	}
+
+	fields := map[string]any{}
+	for k, v := range raw {
+		if !strings.HasPrefix(k, "__") {
+			fields[k] = fieldString(v)
+		}
+	}
+	get := func(k string) string {
+		s, _ := fields[k].(string)
+		return s
+	}
+
+	e := loki.LogEvent{
+		Node:    get("_HOSTNAME"),
+		Service: get("_SYSTEMD_UNIT"),
+		Message: get("MESSAGE"),
+		Level:   "info",
+		Fields:  fields,
+	}
+	if e.Service == "" {
+		e.Service = get("SYSLOG_IDENTIFIER")
+	}
+	if p, err := strconv.Atoi(get("PRIORITY")); err == nil && p >= 0 && p < len(priorities) {
+		e.Level = priorities[p]
+	}
+	if us, err := strconv.ParseInt(fieldString(raw["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		e.Timestamp = time.UnixMicro(us)
+	} else {
+		e.Timestamp = time.Now()
+	}
+	if traceField != "" {
+		e.TraceID = get(traceField)
+	}
+	return e, fieldString(raw["__CURSOR"]), nil
+}
+
+// fieldString flattens a journal field. journalctl writes binary values as an
+// array of bytes and repeated fields as an array of strings; the latter keep
+// their first value.
+func fieldString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case []any:
+		b := make([]byte, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case json.Number:
+				n, _ := item.Int64()
+				b = append(b, byte(n))
+			case string:
+				return item
+			}
+		}
+		return string(b)
+	}
+	return ""
+}
+
+// limitedBuffer keeps the start of a command's stderr for error messages
+// without growing over a long running command's lifetime
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// CheckHost runs journalctl once against the host to prove the journal can be
+// read with the configured access
+func (w *JournaldWatcher) CheckHost(ctx context.Context, host string) error {
+	args := w.command(host, "", false)
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// Hosts returns the hosts being followed
+func (w *JournaldWatcher) Hosts() []string {
+	return w.cfg.Hosts
+}
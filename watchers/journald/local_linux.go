@@ -0,0 +1,5 @@
+package journald
+
+// localJournal is whether the machine labwatch runs on has a journal of its
+// own to read
+const localJournal = true
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package journald
+
+// localJournal is whether the machine labwatch runs on has a journal of its
+// own to read. Outside Linux only hosts reached over SSH can be followed.
+const localJournal = false
@@ -0,0 +1,67 @@
+package journald
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/watchers"
+)
+
+func TestLocalJournalRegistered(t *testing.T) {
+	info, ok := watchers.Lookup(WATCHER_LOCAL)
+	if !ok {
+		t.Fatalf("%s not registered", WATCHER_LOCAL)
+	}
+	if want := runtime.GOOS == "linux"; info.Supported != want {
+		t.Errorf("%s supported %t on %s, want %t", WATCHER_LOCAL, info.Supported, runtime.GOOS, want)
+	}
+	// Hosts reached over SSH can be followed from anywhere
+	if info, _ := watchers.Lookup("journald"); !info.Supported {
+		t.Error("journald unsupported")
+	}
+}
+
+// The other side of the build tags is only compiled when building for
+// another platform, so build it rather than trust that it compiles
+func TestStubBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cross compiling is slow")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go toolchain to cross compile with")
+	}
+
+	for _, platform := range []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"freebsd", "amd64", "local_other.go"},
+		{"linux", "arm64", "local_linux.go"},
+	} {
+		t.Run(platform.goos+"/"+platform.goarch, func(t *testing.T) {
+			env := append(os.Environ(), "GOOS="+platform.goos, "GOARCH="+platform.goarch, "CGO_ENABLED=0")
+
+			list := exec.Command(goBin, "list", "-f", "{{join .GoFiles \" \"}}", ".")
+			list.Env = env
+			out, err := list.CombinedOutput()
+			if err != nil {
+				t.Fatalf("go list: %s: %s", err, out)
+			}
+			files := strings.Fields(string(out))
+			if !slices.Contains(files, platform.want) {
+				t.Errorf("built from %v, want %s", files, platform.want)
+			}
+
+			build := exec.Command(goBin, "build", "-o", os.DevNull, "github.com/DRuggeri/labwatch")
+			build.Env = env
+			if out, err := build.CombinedOutput(); err != nil {
+				t.Errorf("go build: %s: %s", err, out)
+			}
+		})
+	}
+}
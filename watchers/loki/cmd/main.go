@@ -16,7 +16,7 @@ func main() {
 	lvl.Set(slog.LevelDebug)
 	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	w, err := loki.NewLokiWatcher(context.Background(), "boss.local:3100", "", log)
+	w, err := loki.NewLokiWatcher(context.Background(), loki.LokiWatcherConfig{Address: "boss.local:3100"}, log)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,93 @@
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldType is the type a log field is coerced to so clients see it the same
+// whichever app logged it
+type FieldType string
+
+const FIELD_NUMBER FieldType = "to-number"
+const FIELD_STRING FieldType = "to-string"
+const FIELD_BOOL FieldType = "to-bool"
+
+func (FieldType) JSONSchema() map[string]any {
+	return map[string]any{"type": "string", "enum": []any{string(FIELD_NUMBER), string(FIELD_STRING), string(FIELD_BOOL)}}
+}
+
+func ValidateFieldTypes(types map[string]FieldType) error {
+	for field, t := range types {
+		switch t {
+		case FIELD_NUMBER, FIELD_STRING, FIELD_BOOL:
+		default:
+			return fmt.Errorf("invalid type %q for field %s: must be one of to-number|to-string|to-bool", t, field)
+		}
+	}
+	return nil
+}
+
+// CoerceFields converts the event's fields to their configured types. A
+// field that can't be converted keeps its value and is listed in
+// CoercionFailed. Absent and null fields are left alone. The watcher applies
+// it to what it tails; one-shot Query results are left to the caller.
+func CoerceFields(e *LogEvent, types map[string]FieldType) {
+	for field, t := range types {
+		v, ok := e.Fields[field]
+		if !ok || v == nil {
+			continue
+		}
+		if c, ok := coerce(v, t); ok {
+			e.Fields[field] = c
+		} else {
+			e.CoercionFailed = append(e.CoercionFailed, field)
+		}
+	}
+	sort.Strings(e.CoercionFailed)
+}
+
+func coerce(v any, t FieldType) (any, bool) {
+	switch t {
+	case FIELD_NUMBER:
+		switch x := v.(type) {
+		case json.Number:
+			return x, true
+		case string:
+			s := strings.TrimSpace(x)
+			if _, err := strconv.ParseFloat(s, 64); err != nil {
+				return v, false
+			}
+			return json.Number(s), true
+		}
+	case FIELD_STRING:
+		switch x := v.(type) {
+		case string:
+			return x, true
+		case json.Number:
+			return x.String(), true
+		case bool:
+			return strconv.FormatBool(x), true
+		}
+	case FIELD_BOOL:
+		switch x := v.(type) {
+		case bool:
+			return x, true
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(x)); err == nil {
+				return b, true
+			}
+		case json.Number:
+			switch x.String() {
+			case "0":
+				return false, true
+			case "1":
+				return true, true
+			}
+		}
+	}
+	return v, false
+}
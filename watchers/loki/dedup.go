@@ -0,0 +1,66 @@
+package loki
+
+import (
+	"strconv"
+	"time"
+)
+
+// eventDedup drops events Loki sends again, chiefly the backfill it replays
+// when the tail reconnects. An event at or before the newest timestamp seen
+// is a repeat, except that events up to window behind it are let through once
+// each so hosts whose clocks lag the rest aren't silently lost.
+type eventDedup struct {
+	window time.Duration
+	newest int64
+	pruned int64
+	seen   map[string]int64
+}
+
+func newEventDedup(window time.Duration) *eventDedup {
+	return &eventDedup{window: window, seen: map[string]int64{}}
+}
+
+// dedupKey identifies an event by its stream and log line so the same event
+// replayed is told from a different one sharing its timestamp
+func dedupKey(labels map[string]string, value []string) string {
+	key := value[0] + "\x00" + labels["host_name"] + "\x00" + labels["service_name"]
+	if len(value) > 1 {
+		key += "\x00" + value[1]
+	}
+	return key
+}
+
+// admit reports whether the event is new, remembering it if so
+func (d *eventDedup) admit(labels map[string]string, value []string) bool {
+	ts, _ := strconv.ParseInt(value[0], 10, 64)
+	if ts <= d.newest {
+		if d.window <= 0 || ts <= d.newest-int64(d.window) {
+			return false
+		}
+		if _, ok := d.seen[dedupKey(labels, value)]; ok {
+			return false
+		}
+	} else {
+		d.newest = ts
+	}
+
+	if d.window > 0 {
+		d.seen[dedupKey(labels, value)] = ts
+		d.prune()
+	}
+	return true
+}
+
+// prune forgets events that have fallen out of the window, at most once per
+// quarter window so busy streams don't rescan on every event
+func (d *eventDedup) prune() {
+	if d.newest-d.pruned < int64(d.window/4) {
+		return
+	}
+	d.pruned = d.newest
+	for key, ts := range d.seen {
+		if ts <= d.newest-int64(d.window) {
+			delete(d.seen, key)
+		}
+	}
+}
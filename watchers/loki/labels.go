@@ -0,0 +1,75 @@
+package loki
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DRuggeri/labwatch/proxy"
+)
+
+// StatusError is an answer from Loki other than 200, kept so callers can
+// pass the status on
+type StatusError struct {
+	Status int
+	Body   string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("loki returned status %d: %s", e.Status, e.Body)
+}
+
+type lokiLabelsResponse struct {
+	Status string   `json:"status"`
+	Data   []string `json:"data"`
+}
+
+// getStrings fetches one of Loki's label APIs, which answer with a list
+func getStrings(ctx context.Context, address string, path string) ([]string, error) {
+	u := url.URL{Scheme: "https", Host: address, Path: path}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := proxy.HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	msg := lokiLabelsResponse{}
+	if err = decodeJSON(body, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Data == nil {
+		msg.Data = []string{}
+	}
+	return msg.Data, nil
+}
+
+// Labels lists the label names Loki knows about
+func Labels(ctx context.Context, address string) ([]string, error) {
+	return getStrings(ctx, address, "/loki/api/v1/labels")
+}
+
+// LabelValues lists the values Loki has seen for the label
+func LabelValues(ctx context.Context, address string, name string) ([]string, error) {
+	return getStrings(ctx, address, "/loki/api/v1/label/"+url.PathEscape(name)+"/values")
+}
+
+// CheckLabels makes a single labels query to prove Loki is reachable
+func CheckLabels(ctx context.Context, address string) error {
+	_, err := Labels(ctx, address)
+	return err
+}
@@ -1,28 +1,99 @@
 package loki
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/DRuggeri/labwatch/clock"
+	"github.com/DRuggeri/labwatch/proxy"
+	"github.com/DRuggeri/labwatch/watchers"
 	"github.com/gorilla/websocket"
 )
 
+func init() {
+	watchers.Register("loki", "Tails a Loki query over its websocket API", true)
+}
+
 var reconnectDuration = time.Duration(250) * time.Millisecond
 var sleepDuration = time.Duration(250) * time.Millisecond
 var QUERY = `{ host_name =~ ".+" } | json`
 
+// Loki tails from an hour back by default. When a query is rejected for
+// being too large both the limit and the lookback shrink towards these floors.
+var tailLimit = 9999
+var tailLookback = time.Duration(1) * time.Hour
+var minTailLimit = 10
+var minTailLookback = time.Duration(1) * time.Minute
+var defaultNarrowFactor = 0.5
+var defaultFallbackWindow = time.Duration(5) * time.Minute
+
+var minRateLimitBackoff = time.Duration(1) * time.Second
+var maxRateLimitBackoff = time.Duration(5) * time.Minute
+
 type LogEvent struct {
 	Node    string
 	Service string
 	Level   string
 	Message string
+
+	// Fields holds the parsed JSON log line. Numbers are kept as json.Number
+	// so large integers such as IDs round-trip without losing precision.
+	Fields map[string]any `json:",omitempty"`
+
+	Timestamp time.Time `json:",omitempty"`
+	ClockSkew bool      `json:"clock_skew,omitempty"`
+
+	// CoercionFailed names the fields that couldn't be converted to their
+	// configured type and were left as logged
+	CoercionFailed []string `json:"coercion_failed,omitempty"`
+
+	SourceID string `json:"source_id,omitempty"`
+	Injected bool   `json:"injected,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+
+	// Hosts and Count are set on events aggregated across hosts
+	Hosts []string `json:"hosts,omitempty"`
+	Count int      `json:"count,omitempty"`
+
+	NodeContext *NodeContext `json:"node_context,omitempty"`
+
+	// Enrichment describes the MAC and IP addresses found in the event,
+	// keyed by the address as it was written
+	Enrichment map[string]Enrichment `json:"enrichment,omitempty"`
+
+	// Streams names the configured event streams the event was routed to
+	Streams []string `json:"streams,omitempty"`
+
+	// Seq numbers the events labwatch broadcasts, counting up from 1 in the
+	// order they were broadcast
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+// Enrichment is what labwatch could find out about one address
+type Enrichment struct {
+	Vendor   string `json:"vendor,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+// NodeContext is a compact view of the state of the node an event came from
+// at the time it was seen
+type NodeContext struct {
+	Up    bool   `json:"up"`
+	Ready bool   `json:"ready"`
+	Role  string `json:"role,omitempty"`
 }
 
 type LogStats struct {
@@ -50,64 +121,375 @@ type LogStats struct {
 	NumFirewallWanOutDrops int
 	NumFirewallLanInDrops  int
 	NumFirewallLanOutDrops int
+
+	// RateLimited is set while Loki is refusing the tail with HTTP 429
+	RateLimited bool `json:"rate_limited"`
+
+	// PrimaryMatchedNothing is set while the query has returned nothing for a
+	// whole fallback window but the fallback query found data
+	PrimaryMatchedNothing bool `json:"primary_matched_nothing"`
+
+	// Query names the query these stats were counted from: its configured
+	// name, or the LogQL itself. Empty unless the watcher reports it.
+	Query string `json:"query,omitempty"`
+
+	// Sources tracks each host seen, and the services named in
+	// SilenceServices per host, while silence detection is enabled
+	Sources map[string]SourceState `json:"sources,omitempty"`
+
+	// Connection is CONNECTION_OK while tailing, CONNECTION_UNREACHABLE when
+	// Loki can't be reached and CONNECTION_UNAUTHORIZED when it refuses the
+	// credentials for the query with 401 or 403
+	Connection string `json:"connection,omitempty"`
 }
 
 type LokiWatcherConfig struct {
 	ReconnectDuration time.Duration `yaml:"reconnect-duration"`
 	Address           string        `yaml:"address"`
+	Query             string        `yaml:"query"`
+
+	// MaxClockSkew flags events whose timestamp is further than this from
+	// the time they were received. Zero disables the check.
+	MaxClockSkew time.Duration `yaml:"max-clock-skew"`
+
+	// NarrowFactor scales the tail limit and lookback each time Loki rejects
+	// the query as too large. Must be between 0 and 1.
+	NarrowFactor float64 `yaml:"narrow-factor"`
+
+	// TraceIDField names the log field or stream label holding a trace or
+	// correlation ID to copy into LogEvent.TraceID
+	TraceIDField string `yaml:"trace-id-field"`
+
+	// FallbackQuery is a broader query run whenever Query has been silent for
+	// FallbackWindow, to tell a query matching nothing from an empty Loki.
+	// Empty disables the check.
+	FallbackQuery  string        `yaml:"fallback-query"`
+	FallbackWindow time.Duration `yaml:"fallback-window"`
+
+	// DedupWindow is how far behind the newest event an event may be and
+	// still be delivered, provided it wasn't already. Events further back are
+	// treated as replayed backfill. Zero drops anything not newer than the
+	// newest event, which loses events from hosts with lagging clocks.
+	DedupWindow time.Duration `yaml:"dedup-window"`
+
+	// Clock drives reconnect waits, the fallback window and skew checks. Nil
+	// uses the wall clock.
+	Clock clock.Clock `yaml:"-"`
+
+	// ReportQuery labels the stats with Name, or the query when Name is
+	// empty, so clients can tell which query they belong to
+	ReportQuery bool   `yaml:"report-query"`
+	Name        string `yaml:"name"`
+
+	// SilenceThreshold flags a host that logged before but has been quiet for
+	// longer, with a warning event when it goes silent and a notice when it
+	// resumes. SilenceServices are tracked per host in the same way and
+	// SilenceExempt hosts are never tracked. Zero disables the check.
+	SilenceThreshold time.Duration `yaml:"silence-threshold"`
+	SilenceExempt    []string      `yaml:"silence-exempt"`
+	SilenceServices  []string      `yaml:"silence-services"`
+
+	// FieldTypes coerces the named log fields to a consistent type
+	FieldTypes map[string]FieldType `yaml:"field-types"`
+
+	// PermissionCheck runs PermissionQuery, or Query when it is empty, as a
+	// one line range query before connecting and after each disconnect, so
+	// credentials lacking permission are reported as such instead of as a
+	// tail that keeps failing
+	PermissionCheck bool   `yaml:"permission-check"`
+	PermissionQuery string `yaml:"permission-query"`
 }
 type LokiWatcher struct {
+	cfg              LokiWatcherConfig
 	url              url.URL
-	lastTs           int
+	dedup            *eventDedup
 	internalLogChan  chan LogEvent
 	internalStatChan chan LogStats
 	stats            LogStats
 	log              *slog.Logger
+	skewWarnings     map[string]time.Time
+	lastEvent        atomic.Int64
+	fallbackChan     chan bool
+	silence          *silenceTracker
+	silenceChan      chan []silenceChange
+
+	// lock guards the url, the tail bounds and the live connection so the
+	// query can be swapped while tailing
+	lock     sync.Mutex
+	conn     *websocket.Conn
+	limit    int
+	lookback time.Duration
+	narrowed bool
 }
 
-func NewLokiWatcher(ctx context.Context, addr string, query string, log *slog.Logger) (*LokiWatcher, error) {
+func NewLokiWatcher(ctx context.Context, cfg LokiWatcherConfig, log *slog.Logger) (*LokiWatcher, error) {
 	if log == nil {
 		log = slog.Default()
 	}
+	query := cfg.Query
 	if query == "" {
 		query = QUERY
 	}
+	if cfg.ReconnectDuration == 0 {
+		cfg.ReconnectDuration = reconnectDuration
+	}
+	if cfg.NarrowFactor == 0 {
+		cfg.NarrowFactor = defaultNarrowFactor
+	}
+	if cfg.NarrowFactor <= 0 || cfg.NarrowFactor >= 1 {
+		return nil, fmt.Errorf("narrow factor must be between 0 and 1, got %v", cfg.NarrowFactor)
+	}
+	cfg.Query = query
+	cfg.Clock = clock.OrReal(cfg.Clock)
+	if cfg.FallbackWindow == 0 {
+		cfg.FallbackWindow = defaultFallbackWindow
+	}
 
-	q := url.Values{}
-	q.Set("limit", "9999")
-	q.Set("query", query)
-
-	return &LokiWatcher{
+	w := &LokiWatcher{
+		cfg: cfg,
 		url: url.URL{
-			Scheme:   "wss",
-			Host:     addr,
-			Path:     "/loki/api/v1/tail",
-			RawQuery: q.Encode(),
+			Scheme: "wss",
+			Host:   cfg.Address,
+			Path:   "/loki/api/v1/tail",
 		},
+		limit:            tailLimit,
+		lookback:         tailLookback,
 		internalLogChan:  make(chan LogEvent),
 		internalStatChan: make(chan LogStats),
-		lastTs:           int(time.Now().UnixMicro()) * 1000,
+		dedup:            newEventDedup(cfg.DedupWindow),
 		log:              log.With("operation", "LokiWatcher"),
-	}, nil
+		skewWarnings:     map[string]time.Time{},
+		fallbackChan:     make(chan bool),
+		silence:          newSilenceTracker(cfg.SilenceThreshold, cfg.SilenceExempt, cfg.SilenceServices),
+		silenceChan:      make(chan []silenceChange),
+	}
+	// Nothing older than the watcher is wanted, so the initial backfill is
+	// dropped as if it had already been delivered
+	w.dedup.newest = cfg.Clock.Now().UnixNano()
+	w.lastEvent.Store(cfg.Clock.Now().UnixNano())
+	return w, nil
+}
+
+// tailURL renders the tail endpoint for the current query and bounds. The
+// start is only sent once the query has been narrowed so Loki's own default
+// applies otherwise.
+func (w *LokiWatcher) tailURL(now time.Time) string {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(w.limit))
+	q.Set("query", w.cfg.Query)
+	if w.narrowed {
+		q.Set("start", strconv.FormatInt(now.Add(-w.lookback).UnixNano(), 10))
+	}
+	u := w.url
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// sendStats hands the current stats to Watch, returning false if the watcher
+// is stopping
+func (w *LokiWatcher) sendStats(controlContext context.Context) bool {
+	if w.cfg.ReportQuery {
+		w.lock.Lock()
+		w.stats.Query = w.cfg.Name
+		if w.stats.Query == "" {
+			w.stats.Query = w.cfg.Query
+		}
+		w.lock.Unlock()
+	}
+	select {
+	case w.internalStatChan <- w.stats:
+		return true
+	case <-controlContext.Done():
+		return false
+	}
+}
+
+// nextRateLimitBackoff doubles the wait between rate limited attempts up to
+// maxRateLimitBackoff
+func nextRateLimitBackoff(current time.Duration) time.Duration {
+	if current == 0 {
+		return minRateLimitBackoff
+	}
+	if current *= 2; current > maxRateLimitBackoff {
+		return maxRateLimitBackoff
+	}
+	return current
+}
+
+// retryAfter honors a Retry-After header given either in seconds or as an
+// HTTP date, falling back to the computed backoff when absent or invalid
+func retryAfter(header string, now time.Time, fallback time.Duration) time.Duration {
+	if header == "" {
+		return fallback
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return fallback
+}
+
+// isQueryTooLarge reports whether Loki refused the query for exceeding one
+// of its configured limits
+func isQueryTooLarge(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "query exceeds maximum") ||
+		strings.Contains(msg, "max entries limit")
+}
+
+// narrow shrinks the tail limit and lookback after Loki rejected the query
+func (w *LokiWatcher) narrow(reason string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	limit := int(float64(w.limit) * w.cfg.NarrowFactor)
+	if limit < minTailLimit {
+		limit = minTailLimit
+	}
+	lookback := time.Duration(float64(w.lookback) * w.cfg.NarrowFactor)
+	if lookback < minTailLookback {
+		lookback = minTailLookback
+	}
+
+	if limit == w.limit && lookback == w.lookback && w.narrowed {
+		w.log.Error("Loki still rejects the query at the narrowest bounds, use a tighter query", "query", w.cfg.Query, "reason", reason)
+		return
+	}
+	w.limit, w.lookback, w.narrowed = limit, lookback, true
+	w.log.Warn("Loki rejected the query as too large, narrowing and retrying; consider a tighter query",
+		"query", w.cfg.Query, "reason", reason, "limit", limit, "lookback", lookback.String())
+}
+
+// SetQuery replaces the LogQL query, re-establishing the tail if one is open
+func (w *LokiWatcher) SetQuery(query string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if query == w.cfg.Query {
+		return
+	}
+	w.cfg.Query = query
+	w.limit, w.lookback, w.narrowed = tailLimit, tailLookback, false
+	w.log.Info("query changed", "query", query)
+	if w.conn != nil {
+		w.conn.Close()
+	}
 }
 
 func (w *LokiWatcher) Watch(controlContext context.Context, eventChan chan<- LogEvent, statChan chan<- LogStats) {
 	go func() {
-		for {
-			c, _, err := websocket.DefaultDialer.Dial(w.url.String(), nil)
+		<-controlContext.Done()
+		w.lock.Lock()
+		if w.conn != nil {
+			w.conn.Close()
+		}
+		w.lock.Unlock()
+	}()
+
+	if w.cfg.FallbackQuery != "" {
+		go w.checkFallback(controlContext)
+	}
+	if w.silence != nil {
+		go w.watchSilence(controlContext)
+	}
+
+	go func() {
+		var rateLimitBackoff, authBackoff time.Duration
+		permitted := !w.cfg.PermissionCheck
+		for controlContext.Err() == nil {
+			if !permitted {
+				if permitted = w.checkPermission(controlContext); !permitted {
+					if !w.setConnection(controlContext, CONNECTION_UNAUTHORIZED) {
+						return
+					}
+					authBackoff = nextRateLimitBackoff(authBackoff)
+					select {
+					case <-w.cfg.Clock.After(authBackoff):
+					case <-controlContext.Done():
+						return
+					}
+					continue
+				}
+			}
+
+			w.lock.Lock()
+			tailURL := w.tailURL(w.cfg.Clock.Now())
+			w.log.Debug("connecting to Loki", "query", w.cfg.Query)
+			w.lock.Unlock()
+
+			c, resp, err := proxy.Dialer().Dial(tailURL, nil)
 			if err != nil {
-				w.log.Error("error connecting to Loki", "error", err)
-				time.Sleep(reconnectDuration)
+				reason := err.Error()
+				delay := w.cfg.ReconnectDuration
+				if resp != nil {
+					body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+					resp.Body.Close()
+					reason = strings.TrimSpace(string(body))
+				}
+				if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+					rateLimitBackoff = nextRateLimitBackoff(rateLimitBackoff)
+					delay = retryAfter(resp.Header.Get("Retry-After"), w.cfg.Clock.Now(), rateLimitBackoff)
+					w.log.Warn("rate limited by Loki, backing off", "retry", delay.String(), "reason", reason)
+					if !w.stats.RateLimited {
+						w.stats.RateLimited = true
+						if !w.sendStats(controlContext) {
+							return
+						}
+					}
+				} else if isQueryTooLarge(reason) {
+					w.narrow(reason)
+				} else if resp != nil && isUnauthorized(resp.StatusCode) {
+					w.log.Error("insufficient permissions for query "+w.cfg.Query, "status", resp.StatusCode, "reason", reason)
+					authBackoff = nextRateLimitBackoff(authBackoff)
+					delay = authBackoff
+					if !w.setConnection(controlContext, CONNECTION_UNAUTHORIZED) {
+						return
+					}
+				} else {
+					w.log.Error("error connecting to Loki", "error", err, "reason", reason)
+					if !w.setConnection(controlContext, CONNECTION_UNREACHABLE) {
+						return
+					}
+				}
+				select {
+				case <-w.cfg.Clock.After(delay):
+				case <-controlContext.Done():
+					return
+				}
 				continue
 			}
+			rateLimitBackoff, authBackoff = 0, 0
+
+			w.lock.Lock()
+			w.conn = c
+			w.lock.Unlock()
+			if controlContext.Err() != nil {
+				c.Close()
+				return
+			}
 
 			w.log.Info("connected to Loki")
+			if w.stats.RateLimited {
+				w.stats.RateLimited = false
+				if !w.sendStats(controlContext) {
+					return
+				}
+			}
+			if !w.setConnection(controlContext, CONNECTION_OK) {
+				return
+			}
 			for {
 				w.log.Debug("attempting to read...")
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					log.Println("read:", err)
-					continue
+					if isQueryTooLarge(err.Error()) {
+						w.narrow(err.Error())
+					} else {
+						w.log.Error("error reading from Loki, reconnecting", "error", err)
+					}
+					permitted = !w.cfg.PermissionCheck
+					break
 				}
 
 				w.log.Debug(fmt.Sprintf("read %d bytes", len(message)))
@@ -115,16 +497,42 @@ func (w *LokiWatcher) Watch(controlContext context.Context, eventChan chan<- Log
 				w.log.Debug(fmt.Sprintf("Got %d events back after normalization", len(events)))
 
 				if len(events) > 0 {
+					now := w.cfg.Clock.Now()
+					w.lastEvent.Store(now.UnixNano())
+					for _, c := range w.silence.seen(events, now) {
+						w.log.Info("log source resumed", "source", c.source, "quiet", c.quiet.String())
+						select {
+						case w.internalLogChan <- c.event(now):
+						case <-controlContext.Done():
+							return
+						}
+					}
 					for _, e := range events {
-						w.internalLogChan <- e
+						select {
+						case w.internalLogChan <- e:
+						case <-controlContext.Done():
+							return
+						}
 					}
 					w.updateStats(events)
-					w.internalStatChan <- w.stats
+					if !w.sendStats(controlContext) {
+						return
+					}
 				}
 			}
+
+			w.lock.Lock()
+			w.conn = nil
+			w.lock.Unlock()
+			c.Close()
+			<-w.cfg.Clock.After(w.cfg.ReconnectDuration)
 		}
 	}()
 
+	// The fallback flag and log sources are laid over the stats here since
+	// their checks run alongside the connection that owns them
+	matchedNothing := false
+	last := LogStats{}
 	for {
 		select {
 		case <-controlContext.Done():
@@ -138,9 +546,36 @@ func (w *LokiWatcher) Watch(controlContext context.Context, eventChan chan<- Log
 		for {
 			select {
 			case event := <-w.internalLogChan:
-				eventChan <- event
+				if !send(controlContext, eventChan, event) {
+					return
+				}
 			case stats := <-w.internalStatChan:
-				statChan <- stats
+				stats.PrimaryMatchedNothing = matchedNothing
+				stats.Sources = w.silence.snapshot()
+				last = stats
+				if !send(controlContext, statChan, stats) {
+					return
+				}
+			case changes := <-w.silenceChan:
+				now := w.cfg.Clock.Now()
+				for _, c := range changes {
+					w.log.Warn("log source went silent", "source", c.source, "quiet", c.quiet.String())
+					if !send(controlContext, eventChan, c.event(now)) {
+						return
+					}
+				}
+				last.Sources = w.silence.snapshot()
+				if !send(controlContext, statChan, last) {
+					return
+				}
+			case m := <-w.fallbackChan:
+				if m != matchedNothing {
+					matchedNothing = m
+					last.PrimaryMatchedNothing = m
+					if !send(controlContext, statChan, last) {
+						return
+					}
+				}
 			default:
 				break OUTER
 			}
@@ -150,6 +585,48 @@ func (w *LokiWatcher) Watch(controlContext context.Context, eventChan chan<- Log
 	}
 }
 
+// send hands v to the consumer unless the watcher is stopped first, so a
+// consumer that went away can't wedge the watch loop
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// checkFallback runs the fallback query each window the main query stays
+// silent, reporting whether the main query is missing data Loki has
+func (w *LokiWatcher) checkFallback(controlContext context.Context) {
+	for {
+		select {
+		case <-w.cfg.Clock.After(w.cfg.FallbackWindow):
+		case <-controlContext.Done():
+			return
+		}
+
+		now := w.cfg.Clock.Now()
+		matchedNothing := false
+		if now.Sub(time.Unix(0, w.lastEvent.Load())) >= w.cfg.FallbackWindow {
+			events, err := Query(controlContext, w.cfg.Address, w.cfg.FallbackQuery, now.Add(-w.cfg.FallbackWindow), 1, "")
+			if err != nil {
+				w.log.Warn("failed to run the fallback query", "error", err.Error())
+				continue
+			}
+			if matchedNothing = len(events) > 0; matchedNothing {
+				w.log.Warn("query matched nothing but the fallback query found logs, check the query", "window", w.cfg.FallbackWindow.String(), "query", w.cfg.Query)
+			}
+		}
+
+		select {
+		case w.fallbackChan <- matchedNothing:
+		case <-controlContext.Done():
+			return
+		}
+	}
+}
+
 /*
 	{
 	  "streams": [
@@ -187,85 +664,191 @@ type lokiStream struct {
 func (w *LokiWatcher) normalizeEvents(m []byte) []LogEvent {
 	ret := []LogEvent{}
 	msg := lokiMsg{}
-	err := json.Unmarshal(m, &msg)
+	err := decodeJSON(m, &msg)
 	if err != nil {
 		w.log.Error("error unmarshalling", "error", err, "received", string(m))
 		return ret
 	}
 
+	now := w.cfg.Clock.Now()
 	for _, stream := range msg.Streams {
-		thisTs, _ := strconv.Atoi(stream.Values[0][0])
-		if thisTs > w.lastTs {
-			w.lastTs = thisTs
-		} else {
+		if !w.dedup.admit(stream.Stream, stream.Values[0]) {
 			continue
 		}
 
-		// This message is newer than the last batch of messages
-		e := LogEvent{
-			Node:    stream.Stream["host_name"],
-			Service: stream.Stream["service_name"],
-			Message: stream.Stream["MESSAGE"],
-			Level:   stream.Stream["level"],
-		}
+		e := newEvent(stream.Stream, stream.Values[0], w.cfg.TraceIDField)
+		CoerceFields(&e, w.cfg.FieldTypes)
+		w.checkClockSkew(&e, now)
 		ret = append(ret, e)
 	}
 	return ret
 }
 
+// newEvent builds an event from a stream's labels and one [timestamp, line]
+// value
+func newEvent(labels map[string]string, value []string, traceField string) LogEvent {
+	ts, _ := strconv.Atoi(value[0])
+	e := LogEvent{
+		Node:    labels["host_name"],
+		Service: labels["service_name"],
+		Message: labels["MESSAGE"],
+		Level:   labels["level"],
+
+		Timestamp: time.Unix(0, int64(ts)),
+	}
+	if len(value) > 1 {
+		fields := map[string]any{}
+		if err := decodeJSON([]byte(value[1]), &fields); err == nil {
+			e.Fields = fields
+		}
+	}
+	if traceField != "" {
+		if v, ok := e.Fields[traceField]; ok {
+			e.TraceID = fmt.Sprint(v)
+		} else {
+			e.TraceID = labels[traceField]
+		}
+	}
+	return e
+}
+
+var skewWarningInterval = time.Duration(5) * time.Minute
+
+// checkClockSkew annotates events whose timestamp is too far from the time
+// they were received, warning about the offending host at most once per
+// skewWarningInterval
+func (w *LokiWatcher) checkClockSkew(e *LogEvent, now time.Time) {
+	if w.cfg.MaxClockSkew <= 0 {
+		return
+	}
+	skew := now.Sub(e.Timestamp)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew <= w.cfg.MaxClockSkew {
+		return
+	}
+
+	e.ClockSkew = true
+	if last, ok := w.skewWarnings[e.Node]; !ok || now.Sub(last) > skewWarningInterval {
+		w.skewWarnings[e.Node] = now
+		w.log.Warn("event timestamp skewed from receive time, check the host clock", "host", e.Node, "skew", now.Sub(e.Timestamp).String())
+	}
+}
+
+func decodeJSON(b []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
 func (w *LokiWatcher) updateStats(events []LogEvent) {
 	for _, e := range events {
-		w.stats.NumMessages++
-
-		switch e.Level {
-		case "emergency":
-			w.stats.NumEmergencyMessages++
-		case "alert":
-			w.stats.NumAlertMessages++
-		case "critical":
-			w.stats.NumCriticalMessages++
-		case "error":
-			w.stats.NumErrorMessages++
-		case "warning":
-			w.stats.NumWarnMessages++
-		case "notice":
-			w.stats.NumNoticeMessages++
-		case "info":
-			w.stats.NumInfoMessages++
-		case "debug":
-			w.stats.NumDebugMessages++
-		default:
-			w.stats.NumInfoMessages++
+		w.stats.Count(e)
+	}
+}
+
+// Count adds the event to the message and per service tallies. Every log
+// source counts through here so the stats mean the same whatever the source.
+func (s *LogStats) Count(e LogEvent) {
+	s.NumMessages++
+
+	switch e.Level {
+	case "emergency":
+		s.NumEmergencyMessages++
+	case "alert":
+		s.NumAlertMessages++
+	case "critical":
+		s.NumCriticalMessages++
+	case "error":
+		s.NumErrorMessages++
+	case "warning":
+		s.NumWarnMessages++
+	case "notice":
+		s.NumNoticeMessages++
+	case "info":
+		s.NumInfoMessages++
+	case "debug":
+		s.NumDebugMessages++
+	default:
+		s.NumInfoMessages++
+	}
+
+	if e.Service == "dnsmasq.service" {
+		if strings.HasPrefix(e.Message, "query") {
+			s.NumDNSQueries++
+		} else if strings.HasPrefix(e.Message, "dnsmasq: config") {
+			s.NumDNSLocal++
+		} else if strings.HasPrefix(e.Message, "forwarded") {
+			s.NumDNSRecursions++
+		} else if strings.HasPrefix(e.Message, "cached") {
+			s.NumDNSCached++
 		}
 
-		if e.Service == "dnsmasq.service" {
-			if strings.HasPrefix(e.Message, "query") {
-				w.stats.NumDNSQueries++
-			} else if strings.HasPrefix(e.Message, "dnsmasq: config") {
-				w.stats.NumDNSLocal++
-			} else if strings.HasPrefix(e.Message, "forwarded") {
-				w.stats.NumDNSRecursions++
-			} else if strings.HasPrefix(e.Message, "cached") {
-				w.stats.NumDNSCached++
-			}
+	} else if e.Node == "wally" && e.Service == "kernel" {
+		if strings.Contains(e.Message, "drop wan in") {
+			s.NumFirewallWanInDrops++
+		} else if strings.Contains(e.Message, "drop wan out") {
+			s.NumFirewallWanOutDrops++
+		} else if strings.Contains(e.Message, "drop lan in") {
+			s.NumFirewallLanInDrops++
+		} else if strings.Contains(e.Message, "drop lan out") {
+			s.NumFirewallLanOutDrops++
+		}
 
-		} else if e.Node == "wally" && e.Service == "kernel" {
-			if strings.Contains(e.Message, "drop wan in") {
-				w.stats.NumFirewallWanInDrops++
-			} else if strings.Contains(e.Message, "drop wan out") {
-				w.stats.NumFirewallWanOutDrops++
-			} else if strings.Contains(e.Message, "drop lan in") {
-				w.stats.NumFirewallLanInDrops++
-			} else if strings.Contains(e.Message, "drop lan out") {
-				w.stats.NumFirewallLanOutDrops++
-			}
+	} else if strings.HasPrefix(e.Message, "Starting cert-renewer") {
+		s.NumCertChecks++
+	} else if e.Message == "certificate does not need renewal" {
+		s.NumCertOK++
+	} else if e.Service == "step-ca.service" && strings.Contains(e.Message, "path=/sign") && strings.Contains(e.Message, "status=201") {
+		s.NumCertSigned++
+	}
+}
+
+type lokiQueryResponse struct {
+	Data struct {
+		Result []lokiStream `json:"result"`
+	} `json:"data"`
+}
+
+// Query runs a one-shot range query from start until now, returning at most
+// limit events oldest first
+func Query(ctx context.Context, address string, query string, start time.Time, limit int, traceField string) ([]LogEvent, error) {
+	q := url.Values{}
+	q.Set("query", query)
+	q.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("direction", "forward")
+	u := url.URL{Scheme: "https", Host: address, Path: "/loki/api/v1/query_range", RawQuery: q.Encode()}
 
-		} else if strings.HasPrefix(e.Message, "Starting cert-renewer") {
-			w.stats.NumCertChecks++
-		} else if e.Message == "certificate does not need renewal" {
-			w.stats.NumCertOK++
-		} else if e.Service == "step-ca.service" && strings.Contains(e.Message, "path=/sign") && strings.Contains(e.Message, "status=201") {
-			w.stats.NumCertSigned++
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := proxy.HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	msg := lokiQueryResponse{}
+	if err = decodeJSON(body, &msg); err != nil {
+		return nil, err
+	}
+	ret := []LogEvent{}
+	for _, stream := range msg.Data.Result {
+		for _, value := range stream.Values {
+			ret = append(ret, newEvent(stream.Stream, value, traceField))
 		}
 	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Timestamp.Before(ret[j].Timestamp) })
+	return ret, nil
 }
@@ -0,0 +1,53 @@
+package loki
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+func TestLargeNumbersSurvive(t *testing.T) {
+	const id = "1234567890123456789"
+	w := &LokiWatcher{
+		cfg: LokiWatcherConfig{
+			Clock:      clock.NewFake(epoch),
+			FieldTypes: map[string]FieldType{"span": FIELD_STRING, "count": FIELD_NUMBER},
+		},
+		dedup: &eventDedup{seen: map[string]int64{}},
+		log:   slog.New(slog.DiscardHandler),
+	}
+	line := `{"request_id":` + id + `,"span":` + id + `,"count":"` + id + `","ratio":0.000001,"nested":{"id":` + id + `}}`
+	msg, _ := json.Marshal(lokiMsg{Streams: []lokiStream{{
+		Stream: map[string]string{"host_name": "worker1", "service_name": "api"},
+		Values: [][]string{{"1772366400000000000", line}},
+	}}})
+
+	events := w.normalizeEvents(msg)
+	if len(events) != 1 {
+		t.Fatalf("normalized %d events", len(events))
+	}
+	f := events[0].Fields
+	if f["request_id"] != json.Number(id) || f["nested"].(map[string]any)["id"] != json.Number(id) {
+		t.Errorf("decoded %v", f)
+	}
+	if f["span"] != id || f["count"] != json.Number(id) {
+		t.Errorf("coerced %v", f)
+	}
+
+	// Marshaling writes the digits back as they were logged
+	b, err := json.Marshal(events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"request_id":` + id, `"span":"` + id + `"`, `"count":` + id, `"ratio":0.000001`, `"id":` + id} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("%s missing from %s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "e+") || strings.Contains(string(b), "e-") {
+		t.Errorf("scientific notation in %s", b)
+	}
+}
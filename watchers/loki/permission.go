@@ -0,0 +1,58 @@
+package loki
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// LogStats.Connection tells an unreachable Loki from one refusing the
+// credentials
+const CONNECTION_OK = "ok"
+const CONNECTION_UNREACHABLE = "unreachable"
+const CONNECTION_UNAUTHORIZED = "unauthorized"
+
+// The permission check asks for a single line from the last minute, which is
+// as little as Loki can be asked for
+var permissionCheckTimeout = time.Duration(10) * time.Second
+var permissionCheckWindow = time.Duration(1) * time.Minute
+
+func isUnauthorized(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// checkPermission runs the permission query, the tail query unless
+// PermissionQuery is set. It only fails when Loki refuses it with 401 or
+// 403; connectivity problems are left for the tail to run into and report.
+func (w *LokiWatcher) checkPermission(controlContext context.Context) bool {
+	w.lock.Lock()
+	query := w.cfg.PermissionQuery
+	if query == "" {
+		query = w.cfg.Query
+	}
+	w.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(controlContext, permissionCheckTimeout)
+	defer cancel()
+	_, err := Query(ctx, w.cfg.Address, query, w.cfg.Clock.Now().Add(-permissionCheckWindow), 1, "")
+	statusErr := StatusError{}
+	if errors.As(err, &statusErr) && isUnauthorized(statusErr.Status) {
+		w.log.Error("insufficient permissions for query "+query, "status", statusErr.Status, "reason", statusErr.Body)
+		return false
+	}
+	if err != nil {
+		w.log.Warn("permission check failed, trying the tail anyway", "error", err.Error())
+	}
+	return true
+}
+
+// setConnection records the connection state, sending the stats when it
+// changed. It returns false if the watcher is stopping.
+func (w *LokiWatcher) setConnection(controlContext context.Context, state string) bool {
+	if w.stats.Connection == state {
+		return true
+	}
+	w.stats.Connection = state
+	return w.sendStats(controlContext)
+}
@@ -0,0 +1,157 @@
+package loki
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Silence is only evaluated this often, or twice per threshold when that is
+// shorter, however quiet the tail is
+var silenceCheckInterval = time.Duration(30) * time.Second
+
+// SourceState is what the watcher knows about one host, or one service of a
+// host keyed host/service, that has logged since it started
+type SourceState struct {
+	LastSeen  time.Time `json:"last_seen"`
+	LogSilent bool      `json:"log_silent"`
+}
+
+// silenceChange is a source going quiet or resuming
+type silenceChange struct {
+	source string
+	host   string
+	silent bool
+	quiet  time.Duration
+}
+
+// silenceTracker remembers when each host, and each host's configured
+// services, last logged. Sources only count once they have logged, so a host
+// that never did can't alarm.
+type silenceTracker struct {
+	threshold time.Duration
+	exempt    []string
+	services  []string
+	sources   map[string]*SourceState
+	lock      sync.Mutex
+}
+
+func newSilenceTracker(threshold time.Duration, exempt []string, services []string) *silenceTracker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &silenceTracker{
+		threshold: threshold,
+		exempt:    exempt,
+		services:  services,
+		sources:   map[string]*SourceState{},
+	}
+}
+
+// seen records events, returning the sources that had been silent
+func (t *silenceTracker) seen(events []LogEvent, now time.Time) []silenceChange {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := []silenceChange{}
+	mark := func(source string, host string) {
+		s, ok := t.sources[source]
+		if !ok {
+			s = &SourceState{}
+			t.sources[source] = s
+		}
+		if s.LogSilent {
+			s.LogSilent = false
+			ret = append(ret, silenceChange{source: source, host: host, quiet: now.Sub(s.LastSeen)})
+		}
+		s.LastSeen = now
+	}
+	for _, e := range events {
+		if e.Node == "" || e.Injected || slices.Contains(t.exempt, e.Node) {
+			continue
+		}
+		mark(e.Node, e.Node)
+		if slices.Contains(t.services, e.Service) {
+			mark(e.Node+"/"+e.Service, e.Node)
+		}
+	}
+	return ret
+}
+
+// check flags the sources quiet for longer than the threshold
+func (t *silenceTracker) check(now time.Time) []silenceChange {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := []silenceChange{}
+	for source, s := range t.sources {
+		if quiet := now.Sub(s.LastSeen); !s.LogSilent && quiet > t.threshold {
+			s.LogSilent = true
+			host, _, _ := strings.Cut(source, "/")
+			ret = append(ret, silenceChange{source: source, host: host, silent: true, quiet: quiet})
+		}
+	}
+	return ret
+}
+
+func (t *silenceTracker) snapshot() map[string]SourceState {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := make(map[string]SourceState, len(t.sources))
+	for source, s := range t.sources {
+		ret[source] = *s
+	}
+	return ret
+}
+
+// watchSilence evaluates the tracker on its own ticker so a silence is
+// noticed even when nothing at all arrives
+func (w *LokiWatcher) watchSilence(controlContext context.Context) {
+	interval := min(silenceCheckInterval, w.silence.threshold/2)
+	for {
+		select {
+		case <-w.cfg.Clock.After(interval):
+		case <-controlContext.Done():
+			return
+		}
+		changes := w.silence.check(w.cfg.Clock.Now())
+		if len(changes) == 0 {
+			continue
+		}
+		select {
+		case w.silenceChan <- changes:
+		case <-controlContext.Done():
+			return
+		}
+	}
+}
+
+// event describes the change for clients. Onset is a warning and recovery a
+// notice so alerting on warnings resolves on its own.
+func (c silenceChange) event(now time.Time) LogEvent {
+	e := LogEvent{
+		Node:      c.host,
+		Service:   "labwatch",
+		Level:     "notice",
+		Timestamp: now,
+		Fields:    map[string]any{"log_source": c.source, "log_silent": c.silent},
+	}
+	what := "host " + c.host
+	if _, service, ok := strings.Cut(c.source, "/"); ok {
+		what = fmt.Sprintf("%s on host %s", service, c.host)
+	}
+	if c.silent {
+		e.Level = "warning"
+		e.Message = fmt.Sprintf("%s stopped logging, nothing for %s", what, c.quiet.Round(time.Second))
+	} else {
+		e.Message = fmt.Sprintf("%s resumed logging after %s", what, c.quiet.Round(time.Second))
+	}
+	return e
+}
@@ -0,0 +1,170 @@
+package loki
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+var epoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func sources(changes []silenceChange) []string {
+	ret := []string{}
+	for _, c := range changes {
+		ret = append(ret, c.source)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestSilenceOnsetAndRecovery(t *testing.T) {
+	f := clock.NewFake(epoch)
+	tracker := newSilenceTracker(5*time.Minute, nil, []string{"kubelet"})
+
+	tracker.seen([]LogEvent{
+		{Node: "worker1", Service: "kubelet"},
+		{Node: "cp1", Service: "etcd"},
+	}, f.Now())
+	f.Advance(4 * time.Minute)
+	tracker.seen([]LogEvent{{Node: "cp1", Service: "etcd"}}, f.Now())
+
+	f.Advance(time.Minute)
+	if got := tracker.check(f.Now()); len(got) != 0 {
+		t.Fatalf("silent at exactly the threshold: %v", sources(got))
+	}
+
+	// worker1 and its kubelet went quiet, cp1 kept logging
+	f.Advance(time.Second)
+	onset := tracker.check(f.Now())
+	if got := sources(onset); !slices.Equal(got, []string{"worker1", "worker1/kubelet"}) {
+		t.Fatalf("silent sources %v", got)
+	}
+	for _, c := range onset {
+		if !c.silent || c.host != "worker1" || c.quiet != 5*time.Minute+time.Second {
+			t.Errorf("onset %+v", c)
+		}
+	}
+	if got := tracker.check(f.Now().Add(time.Minute)); len(got) != 0 {
+		t.Errorf("silence reported again: %v", sources(got))
+	}
+	snap := tracker.snapshot()
+	if !snap["worker1"].LogSilent || !snap["worker1/kubelet"].LogSilent || snap["cp1"].LogSilent {
+		t.Errorf("snapshot %+v", snap)
+	}
+
+	// Another service logging brings the host back but not its kubelet
+	f.Advance(2 * time.Minute)
+	recovered := tracker.seen([]LogEvent{{Node: "worker1", Service: "containerd"}}, f.Now())
+	if got := sources(recovered); !slices.Equal(got, []string{"worker1"}) {
+		t.Fatalf("recovered %v", got)
+	}
+	if recovered[0].silent || recovered[0].quiet != 7*time.Minute+time.Second {
+		t.Errorf("recovery %+v", recovered[0])
+	}
+	recovered = tracker.seen([]LogEvent{{Node: "worker1", Service: "kubelet"}}, f.Now())
+	if got := sources(recovered); !slices.Equal(got, []string{"worker1/kubelet"}) {
+		t.Fatalf("recovered %v", got)
+	}
+	if snap := tracker.snapshot(); snap["worker1"].LogSilent || snap["worker1/kubelet"].LogSilent || !snap["worker1"].LastSeen.Equal(f.Now()) {
+		t.Errorf("snapshot after recovery %+v", snap)
+	}
+}
+
+func TestSilenceUntrackedSources(t *testing.T) {
+	f := clock.NewFake(epoch)
+	tracker := newSilenceTracker(time.Minute, []string{"nas"}, []string{"kubelet"})
+	tracker.seen([]LogEvent{
+		// Legitimately logs rarely
+		{Node: "nas", Service: "kubelet"},
+		// Generated by labwatch rather than logged by the host
+		{Node: "worker2", Service: "kubelet", Injected: true},
+		{Service: "dnsmasq"},
+	}, f.Now())
+	f.Advance(time.Hour)
+	if got := tracker.check(f.Now()); len(got) != 0 {
+		t.Errorf("untracked sources alarmed: %v", sources(got))
+	}
+	// A host never seen before can't be silent
+	if snap := tracker.snapshot(); len(snap) != 0 {
+		t.Errorf("tracking %v", snap)
+	}
+}
+
+func TestSilenceDisabled(t *testing.T) {
+	tracker := newSilenceTracker(0, nil, nil)
+	if tracker != nil {
+		t.Fatal("tracker built without a threshold")
+	}
+	if tracker.seen([]LogEvent{{Node: "worker1"}}, epoch) != nil || tracker.snapshot() != nil {
+		t.Error("a disabled tracker reported something")
+	}
+}
+
+func TestSilenceEvent(t *testing.T) {
+	tests := []struct {
+		change silenceChange
+		level  string
+		msg    string
+	}{
+		{silenceChange{source: "worker1", host: "worker1", silent: true, quiet: 5*time.Minute + 1400*time.Millisecond}, "warning", "host worker1 stopped logging, nothing for 5m1s"},
+		{silenceChange{source: "worker1/kubelet", host: "worker1", quiet: 7 * time.Minute}, "notice", "kubelet on host worker1 resumed logging after 7m0s"},
+	}
+	for _, tt := range tests {
+		e := tt.change.event(epoch)
+		if e.Level != tt.level || e.Message != tt.msg || e.Node != "worker1" || !e.Timestamp.Equal(epoch) {
+			t.Errorf("event %+v", e)
+		}
+		if e.Fields["log_source"] != tt.change.source || e.Fields["log_silent"] != tt.change.silent {
+			t.Errorf("fields %v", e.Fields)
+		}
+	}
+}
+
+// The ticker notices a silence without any event arriving to prompt it
+func TestWatchSilence(t *testing.T) {
+	f := clock.NewFake(epoch)
+	w := &LokiWatcher{
+		cfg:         LokiWatcherConfig{Clock: f},
+		silence:     newSilenceTracker(time.Minute, nil, nil),
+		silenceChan: make(chan []silenceChange, 1),
+	}
+	w.silence.seen([]LogEvent{{Node: "worker1"}}, f.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.watchSilence(ctx)
+
+	waiting := func() {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for f.Waiters() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("silence check never waited on the clock")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// Checked twice per threshold: quiet for 30s, then exactly the threshold
+	for range 2 {
+		waiting()
+		f.Advance(30 * time.Second)
+	}
+	waiting()
+	select {
+	case changes := <-w.silenceChan:
+		t.Fatalf("silent before the threshold: %v", sources(changes))
+	default:
+	}
+
+	f.Advance(30 * time.Second)
+	select {
+	case changes := <-w.silenceChan:
+		if got := sources(changes); !slices.Equal(got, []string{"worker1"}) || changes[0].quiet != 90*time.Second {
+			t.Errorf("changes %+v", changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("silence never reported")
+	}
+}
@@ -0,0 +1,52 @@
+package watchers
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// ErrUnsupported is returned, or logged, in place of a watcher that can't run
+// on the platform labwatch was built for
+var ErrUnsupported = errors.New("unsupported on this platform (" + runtime.GOOS + "/" + runtime.GOARCH + ")")
+
+// Info describes a kind of watcher. Watchers that depend on one platform
+// register from a file built for it and from a stub built everywhere else,
+// so the registry says why a configured watcher isn't running instead of the
+// build or the startup failing.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Supported   bool   `json:"supported"`
+}
+
+var registry = map[string]Info{}
+var registryLock sync.Mutex
+
+// Register records a kind of watcher, typically from an init function
+func Register(name string, description string, supported bool) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[name] = Info{Name: name, Description: description, Supported: supported}
+}
+
+func Lookup(name string) (Info, bool) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	info, ok := registry[name]
+	return info, ok
+}
+
+// All lists every registered watcher sorted by name
+func All() []Info {
+	registryLock.Lock()
+	ret := make([]Info, 0, len(registry))
+	for _, info := range registry {
+		ret = append(ret, info)
+	}
+	registryLock.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
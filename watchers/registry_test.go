@@ -0,0 +1,36 @@
+package watchers
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	Register("test-b", "runs everywhere", true)
+	Register("test-a", "runs nowhere", false)
+	t.Cleanup(func() {
+		registryLock.Lock()
+		delete(registry, "test-a")
+		delete(registry, "test-b")
+		registryLock.Unlock()
+	})
+
+	if info, ok := Lookup("test-a"); !ok || info.Supported || info.Description != "runs nowhere" {
+		t.Errorf("looked up %+v, %t", info, ok)
+	}
+	if _, ok := Lookup("test-c"); ok {
+		t.Error("found a watcher never registered")
+	}
+
+	all := All()
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Name >= all[i].Name {
+			t.Errorf("listed %s before %s", all[i-1].Name, all[i].Name)
+		}
+	}
+
+	if !strings.Contains(ErrUnsupported.Error(), runtime.GOOS+"/"+runtime.GOARCH) {
+		t.Errorf("unsupported error %q doesn't name the platform", ErrUnsupported)
+	}
+}
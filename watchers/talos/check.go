@@ -0,0 +1,75 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	tclient "github.com/siderolabs/talos/pkg/machinery/client"
+	tcconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+)
+
+// ResolveContext picks the talosconfig context to watch: contextName when
+// set, otherwise the file's current context, falling back to a context named
+// after the cluster when the file sets none. A context that records its
+// cluster must record clusterName.
+func ResolveContext(cfg *tcconfig.Config, configFile string, contextName string, clusterName string) (string, *tcconfig.Context, error) {
+	name := contextName
+	if name == "" {
+		name = cfg.Context
+	}
+	if name == "" {
+		name = clusterName
+	}
+
+	tctx, ok := cfg.Contexts[name]
+	if !ok {
+		known := slices.Sorted(maps.Keys(cfg.Contexts))
+		return "", nil, fmt.Errorf("the %s context does not exist in the config file %s (contexts: %s)", name, configFile, strings.Join(known, ", "))
+	}
+	if tctx.Cluster != "" && tctx.Cluster != clusterName {
+		return "", nil, fmt.Errorf("the %s context in the config file %s is for cluster %s, not %s", name, configFile, tctx.Cluster, clusterName)
+	}
+	return name, tctx, nil
+}
+
+// ConfiguredNodes returns the node names in the talosconfig context without
+// connecting to any of them
+func ConfiguredNodes(configFile string, contextName string, clusterName string) ([]string, error) {
+	cfg, err := tcconfig.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+	_, tctx, err := ResolveContext(cfg, configFile, contextName, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, tctx.Nodes...), nil
+}
+
+// CheckNode makes a single version call against the node to prove it is
+// reachable with the configured credentials
+func CheckNode(ctx context.Context, configFile string, contextName string, clusterName string, node string) error {
+	cfg, err := tcconfig.Open(configFile)
+	if err != nil {
+		return err
+	}
+	name, _, err := ResolveContext(cfg, configFile, contextName, clusterName)
+	if err != nil {
+		return err
+	}
+	client, err := tclient.New(ctx,
+		tclient.WithConfig(cfg),
+		tclient.WithContextName(name),
+		tclient.WithEndpoints(node),
+	)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err = client.Version(ctx)
+	return err
+}
@@ -17,7 +17,7 @@ func main() {
 	lvl.Set(slog.LevelDebug)
 	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	w, err := talos.NewTalosWatcher(context.Background(), "/home/boss/talos/talosconfig", "koobs", log)
+	w, err := talos.NewTalosWatcher(context.Background(), "/home/boss/talos/talosconfig", "", "koobs", log)
 	if err != nil {
 		panic(err)
 	}
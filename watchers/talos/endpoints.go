@@ -0,0 +1,202 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/api/machine"
+	tclient "github.com/siderolabs/talos/pkg/machinery/client"
+	tcconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var endpointTimeout = time.Duration(5) * time.Second
+
+// EndpointStatus is the health of one Talos API endpoint as last seen by the
+// cluster wide calls
+type EndpointStatus struct {
+	Endpoint  string    `json:"endpoint"`
+	Healthy   bool      `json:"healthy"`
+	Active    bool      `json:"active,omitempty"`
+	LastUsed  time.Time `json:"last_used,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// endpointClient is the part of the Talos client the cluster wide calls use
+type endpointClient interface {
+	Kubeconfig(ctx context.Context) ([]byte, error)
+	Version(ctx context.Context, callOptions ...grpc.CallOption) (*machine.VersionResponse, error)
+	EtcdSnapshot(ctx context.Context, req *machine.EtcdSnapshotRequest, callOptions ...grpc.CallOption) (io.ReadCloser, error)
+	Close() error
+}
+
+// endpointPool spreads cluster wide calls over every endpoint that can serve
+// them: the talosconfig endpoints, typically a VIP, followed by the nodes
+// themselves. The endpoint that last worked is tried first and those that
+// failed last, so losing the VIP holder only costs one failed attempt. The
+// lock only guards the pool's own state, never a call in flight.
+type endpointPool struct {
+	endpoints []string
+	dial      func(ctx context.Context, endpoint string) (endpointClient, error)
+	clients   map[string]endpointClient
+	status    map[string]EndpointStatus
+	preferred string
+	lock      sync.Mutex
+}
+
+func newEndpointPool(cfg *tcconfig.Config, contextName string, tctx *tcconfig.Context) *endpointPool {
+	endpoints := []string{}
+	for _, e := range append(append([]string{}, tctx.Endpoints...), tctx.Nodes...) {
+		if !slices.Contains(endpoints, e) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return newPool(endpoints, func(ctx context.Context, endpoint string) (endpointClient, error) {
+		return tclient.New(ctx,
+			tclient.WithConfig(cfg),
+			tclient.WithContextName(contextName),
+			tclient.WithEndpoints(endpoint),
+		)
+	})
+}
+
+func newPool(endpoints []string, dial func(ctx context.Context, endpoint string) (endpointClient, error)) *endpointPool {
+	p := &endpointPool{
+		endpoints: endpoints,
+		dial:      dial,
+		clients:   map[string]endpointClient{},
+		status:    map[string]EndpointStatus{},
+	}
+	for _, e := range endpoints {
+		p.status[e] = EndpointStatus{Endpoint: e, Healthy: true}
+	}
+	return p
+}
+
+// order lists the endpoints to try: the preferred one, then the healthy ones
+// in configured order, then the ones that failed. Call it with the lock held.
+func (p *endpointPool) order() []string {
+	ret := []string{}
+	if p.preferred != "" {
+		ret = append(ret, p.preferred)
+	}
+	for _, healthy := range []bool{true, false} {
+		for _, e := range p.endpoints {
+			if e != p.preferred && p.status[e].Healthy == healthy {
+				ret = append(ret, e)
+			}
+		}
+	}
+	return ret
+}
+
+// client returns the endpoint's client, dialing it outside the lock the
+// first time. When two calls race to dial, the first one stored wins.
+func (p *endpointPool) client(ctx context.Context, endpoint string) (endpointClient, error) {
+	p.lock.Lock()
+	c, ok := p.clients[endpoint]
+	p.lock.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	c, err := p.dial(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if existing, ok := p.clients[endpoint]; ok {
+		c.Close()
+		return existing, nil
+	}
+	p.clients[endpoint] = c
+	return c, nil
+}
+
+// isConnectionError reports whether err means the endpoint couldn't be
+// reached, as opposed to the call itself failing
+func isConnectionError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// do runs fn against each endpoint in turn until one succeeds, returning the
+// endpoint that served the call. Only connection errors mark an endpoint
+// unhealthy; other failures move on in case another endpoint can serve the
+// call, as only control plane nodes can hand out a kubeconfig. Calls run
+// concurrently, each walking the order as it stood when it started.
+func (p *endpointPool) do(ctx context.Context, fn func(context.Context, endpointClient) error) (string, error) {
+	return p.doTimeout(ctx, endpointTimeout, fn)
+}
+
+// doTimeout is do for calls that need longer than endpointTimeout
+func (p *endpointPool) doTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context, endpointClient) error) (string, error) {
+	p.lock.Lock()
+	order := p.order()
+	p.lock.Unlock()
+
+	var err error
+	for _, endpoint := range order {
+		var c endpointClient
+		if c, err = p.client(ctx, endpoint); err == nil {
+			callCtx, cancel := context.WithTimeout(ctx, timeout)
+			err = fn(callCtx, c)
+			cancel()
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		p.record(endpoint, err)
+		if err == nil {
+			return endpoint, nil
+		}
+	}
+	return "", err
+}
+
+// record notes how a call to the endpoint went, preferring it when it worked
+func (p *endpointPool) record(endpoint string, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := p.status[endpoint]
+	s.LastUsed = time.Now()
+	if err != nil {
+		s.Healthy, s.LastError = !isConnectionError(err), err.Error()
+		p.status[endpoint] = s
+		return
+	}
+	s.Healthy, s.LastError = true, ""
+	p.status[endpoint] = s
+	p.preferred = endpoint
+}
+
+// snapshot returns the endpoints in configured order
+func (p *endpointPool) snapshot() []EndpointStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ret := []EndpointStatus{}
+	for _, e := range p.endpoints {
+		s := p.status[e]
+		s.Active = e == p.preferred
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func (p *endpointPool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, c := range p.clients {
+		c.Close()
+	}
+}
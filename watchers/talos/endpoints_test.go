@@ -0,0 +1,237 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/api/machine"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeCluster hands out fake clients whose calls fail with the endpoint's
+// configured error and records which endpoints were called
+type fakeCluster struct {
+	errs     map[string]error
+	snapshot map[string]string
+	block    chan struct{}
+	called   []string
+	lock     sync.Mutex
+}
+
+type fakeClient struct {
+	cluster  *fakeCluster
+	endpoint string
+}
+
+func (c *fakeCluster) dial(ctx context.Context, endpoint string) (endpointClient, error) {
+	return &fakeClient{cluster: c, endpoint: endpoint}, nil
+}
+
+func (c *fakeCluster) calls() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.called...)
+}
+
+func (c *fakeClient) call() error {
+	c.cluster.lock.Lock()
+	c.cluster.called = append(c.cluster.called, c.endpoint)
+	err := c.cluster.errs[c.endpoint]
+	c.cluster.lock.Unlock()
+	return err
+}
+
+func (c *fakeClient) Kubeconfig(ctx context.Context) ([]byte, error) {
+	if err := c.call(); err != nil {
+		return nil, err
+	}
+	return []byte("kubeconfig from " + c.endpoint), nil
+}
+
+func (c *fakeClient) Version(ctx context.Context, callOptions ...grpc.CallOption) (*machine.VersionResponse, error) {
+	if c.cluster.block != nil {
+		select {
+		case <-c.cluster.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &machine.VersionResponse{}, c.call()
+}
+
+func (c *fakeClient) EtcdSnapshot(ctx context.Context, req *machine.EtcdSnapshotRequest, callOptions ...grpc.CallOption) (io.ReadCloser, error) {
+	err := c.call()
+	data := c.cluster.snapshot[c.endpoint]
+	if data == "" && err != nil {
+		return nil, err
+	}
+	// A snapshot with data and an error is cut short after the data. With
+	// block set the stream stalls after the data until it is closed.
+	return io.NopCloser(io.MultiReader(strings.NewReader(data), blockReader{c.cluster.block}, errReader{err})), nil
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+type blockReader struct{ block chan struct{} }
+
+func (r blockReader) Read([]byte) (int, error) {
+	if r.block != nil {
+		<-r.block
+	}
+	return 0, io.EOF
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err == nil {
+		return 0, io.EOF
+	}
+	return 0, r.err
+}
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+func TestEndpointFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		errs map[string]error
+		// calls made by the first and second call through the pool
+		first, second []string
+		served        string
+		unhealthy     []string
+	}{
+		{
+			name:   "all healthy",
+			first:  []string{"vip"},
+			second: []string{"vip"},
+			served: "vip",
+		},
+		{
+			name:      "vip dead, nodes alive",
+			errs:      map[string]error{"vip": errUnavailable},
+			first:     []string{"vip", "cp1"},
+			second:    []string{"cp1"},
+			served:    "cp1",
+			unhealthy: []string{"vip"},
+		},
+		{
+			name:      "vip and first node dead",
+			errs:      map[string]error{"vip": errUnavailable, "cp1": errUnavailable},
+			first:     []string{"vip", "cp1", "worker1"},
+			second:    []string{"worker1"},
+			served:    "worker1",
+			unhealthy: []string{"cp1", "vip"},
+		},
+		{
+			// Workers can't hand out a kubeconfig but are reachable, so they
+			// stay healthy and keep their place
+			name:   "call refused",
+			errs:   map[string]error{"vip": status.Error(codes.PermissionDenied, "not a control plane node")},
+			first:  []string{"vip", "cp1"},
+			second: []string{"cp1"},
+			served: "cp1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &fakeCluster{errs: tt.errs}
+			p := newPool([]string{"vip", "cp1", "worker1"}, cluster.dial)
+			kubeconfig := func(ctx context.Context, c endpointClient) error {
+				_, err := c.Kubeconfig(ctx)
+				return err
+			}
+
+			for i, want := range [][]string{tt.first, tt.second} {
+				before := len(cluster.calls())
+				served, err := p.do(context.Background(), kubeconfig)
+				if err != nil {
+					t.Fatalf("call %d failed: %v", i+1, err)
+				}
+				if served != tt.served {
+					t.Errorf("call %d served by %s, want %s", i+1, served, tt.served)
+				}
+				if got := cluster.calls()[before:]; !slices.Equal(got, want) {
+					t.Errorf("call %d tried %v, want %v", i+1, got, want)
+				}
+			}
+
+			unhealthy := []string{}
+			for _, s := range p.snapshot() {
+				if !s.Healthy {
+					unhealthy = append(unhealthy, s.Endpoint)
+				}
+				if s.Active != (s.Endpoint == tt.served) {
+					t.Errorf("%s active is %t", s.Endpoint, s.Active)
+				}
+			}
+			slices.Sort(unhealthy)
+			if !slices.Equal(unhealthy, tt.unhealthy) {
+				t.Errorf("unhealthy endpoints %v, want %v", unhealthy, tt.unhealthy)
+			}
+		})
+	}
+}
+
+func TestEndpointAllDead(t *testing.T) {
+	cluster := &fakeCluster{errs: map[string]error{"vip": errUnavailable, "cp1": errUnavailable}}
+	p := newPool([]string{"vip", "cp1"}, cluster.dial)
+	_, err := p.do(context.Background(), func(ctx context.Context, c endpointClient) error {
+		_, err := c.Version(ctx)
+		return err
+	})
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("got %v, want the last endpoint's error", err)
+	}
+	for _, s := range p.snapshot() {
+		if s.Healthy || s.LastError == "" {
+			t.Errorf("%s should be unhealthy with its error recorded: %+v", s.Endpoint, s)
+		}
+	}
+
+	// With every endpoint failed, the next call tries them all again in order
+	cluster.errs = map[string]error{"cp1": errUnavailable}
+	served, err := p.do(context.Background(), func(ctx context.Context, c endpointClient) error {
+		_, err := c.Version(ctx)
+		return err
+	})
+	if err != nil || served != "vip" {
+		t.Fatalf("served by %q with %v, want vip", served, err)
+	}
+}
+
+// A slow call must not hold up the status snapshot the watch loop takes
+func TestEndpointSnapshotDuringCall(t *testing.T) {
+	cluster := &fakeCluster{block: make(chan struct{})}
+	p := newPool([]string{"vip"}, cluster.dial)
+
+	done := make(chan struct{})
+	go func() {
+		p.do(context.Background(), func(ctx context.Context, c endpointClient) error {
+			_, err := c.Version(ctx)
+			return err
+		})
+		close(done)
+	}()
+
+	snapped := make(chan []EndpointStatus)
+	go func() { snapped <- p.snapshot() }()
+	select {
+	case <-snapped:
+	case <-time.After(time.Second):
+		t.Fatal("snapshot blocked while a call was in flight")
+	}
+	close(cluster.block)
+	<-done
+}
@@ -0,0 +1,36 @@
+package talos
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/api/machine"
+)
+
+// EtcdSnapshot streams an etcd snapshot from the first endpoint able to
+// serve one into dst, returning the endpoint used and the bytes written.
+// Only control plane nodes run etcd, so other endpoints fail and the next is
+// tried. dst is truncated before each attempt so a snapshot cut short by a
+// failing endpoint isn't kept. Other cluster wide calls and the endpoint
+// status carry on while it streams.
+func (w *TalosWatcher) EtcdSnapshot(ctx context.Context, timeout time.Duration, dst *os.File) (string, int64, error) {
+	var written int64
+	endpoint, err := w.conn.Load().endpoints.doTimeout(ctx, timeout, func(ctx context.Context, c endpointClient) error {
+		if err := dst.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := dst.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r, err := c.EtcdSnapshot(ctx, &machine.EtcdSnapshotRequest{})
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		written, err = io.Copy(dst, r)
+		return err
+	})
+	return endpoint, written, err
+}
@@ -0,0 +1,73 @@
+package talos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEtcdSnapshotFailover(t *testing.T) {
+	cluster := &fakeCluster{
+		errs: map[string]error{"vip": errUnavailable, "worker1": errUnavailable},
+		// The VIP dies partway through, so its bytes must not be kept
+		snapshot: map[string]string{"vip": "partial", "cp1": "complete snapshot"},
+	}
+	w := &TalosWatcher{}
+	w.conn.Store(&talosConn{endpoints: newPool([]string{"vip", "cp1", "worker1"}, cluster.dial)})
+
+	dst, err := os.Create(filepath.Join(t.TempDir(), "etcd.snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	endpoint, written, err := w.EtcdSnapshot(context.Background(), time.Minute, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint != "cp1" || written != int64(len("complete snapshot")) {
+		t.Errorf("got %d bytes from %s, want the complete snapshot from cp1", written, endpoint)
+	}
+	if b, _ := os.ReadFile(dst.Name()); string(b) != "complete snapshot" {
+		t.Errorf("snapshot file holds %q", b)
+	}
+}
+
+// The download holds no lock the watch loop needs for the endpoint status
+func TestEtcdSnapshotDoesNotBlockEndpoints(t *testing.T) {
+	cluster := &fakeCluster{snapshot: map[string]string{"cp1": "snapshot"}, block: make(chan struct{})}
+	w := &TalosWatcher{}
+	w.conn.Store(&talosConn{endpoints: newPool([]string{"cp1"}, cluster.dial)})
+
+	dst, err := os.Create(filepath.Join(t.TempDir(), "etcd.snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	finished := make(chan error)
+	go func() {
+		_, _, err := w.EtcdSnapshot(context.Background(), time.Minute, dst)
+		finished <- err
+	}()
+	for len(cluster.calls()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.Endpoints()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Endpoints blocked behind the snapshot")
+	}
+	close(cluster.block)
+	if err := <-finished; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/DRuggeri/labwatch/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+var podPollDuration = time.Duration(30) * time.Second
+
+// podInfo is what the Kubernetes API knows about a node: the name it is
+// registered under and its pods
+type podInfo struct {
+	Name     string
+	Running  int
+	Capacity int
+}
+
+// kubeClient is a deliberately small Kubernetes API client built from the
+// kubeconfig Talos hands out. It only knows how to list nodes and pods.
+type kubeClient struct {
+	server string
+	http   *http.Client
+}
+
+type kubeconfigFile struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server string `yaml:"server"`
+			CAData string `yaml:"certificate-authority-data"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			CertData string `yaml:"client-certificate-data"`
+			KeyData  string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+func newKubeClient(kubeconfig []byte) (*kubeClient, error) {
+	kc := kubeconfigFile{}
+	if err := yaml.Unmarshal(kubeconfig, &kc); err != nil {
+		return nil, err
+	}
+
+	clusterName, userName := "", ""
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+
+	tlsConfig := &tls.Config{}
+	server := ""
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		server = c.Cluster.Server
+		ca, err := base64.StdEncoding.DecodeString(c.Cluster.CAData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding cluster CA: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+	}
+	if server == "" {
+		return nil, fmt.Errorf("no cluster found for kubeconfig context %s", kc.CurrentContext)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cert, err := base64.StdEncoding.DecodeString(u.User.CertData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding client certificate: %w", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(u.User.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding client key: %w", err)
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	return &kubeClient{
+		server: server,
+		http: &http.Client{
+			Timeout:   time.Duration(10) * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: proxy.ForRequest},
+		},
+	}, nil
+}
+
+func (k *kubeClient) get(ctx context.Context, path string, query url.Values, out any) error {
+	u := k.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kubernetes API returned status %d for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type kubeNodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Addresses []struct {
+				Address string `json:"address"`
+			} `json:"addresses"`
+			Allocatable map[string]string `json:"allocatable"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type kubePodList struct {
+	Items []struct {
+		Spec struct {
+			NodeName string `json:"nodeName"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// podCounts returns each Kubernetes node's name, running pods and pod
+// capacity keyed by every name and address the node is known by, so it can
+// be matched against the node names used in the talosconfig and the
+// addresses Talos reports.
+func (k *kubeClient) podCounts(ctx context.Context) (map[string]podInfo, error) {
+	nodes := kubeNodeList{}
+	if err := k.get(ctx, "/api/v1/nodes", nil, &nodes); err != nil {
+		return nil, err
+	}
+
+	pods := kubePodList{}
+	if err := k.get(ctx, "/api/v1/pods", url.Values{"fieldSelector": []string{"status.phase=Running"}}, &pods); err != nil {
+		return nil, err
+	}
+
+	running := map[string]int{}
+	for _, p := range pods.Items {
+		running[p.Spec.NodeName]++
+	}
+
+	ret := map[string]podInfo{}
+	for _, n := range nodes.Items {
+		capacity, _ := strconv.Atoi(n.Status.Allocatable["pods"])
+		info := podInfo{Name: n.Metadata.Name, Running: running[n.Metadata.Name], Capacity: capacity}
+		ret[n.Metadata.Name] = info
+		for _, a := range n.Status.Addresses {
+			ret[a.Address] = info
+		}
+	}
+	return ret, nil
+}
+
+func (w *TalosWatcher) watchPods(controlContext context.Context) {
+	log := w.log.With("operation", "TalosWatcher.watchPods")
+	var kube *kubeClient
+	var kubeSource *talosConn
+
+	for {
+		var counts map[string]podInfo
+
+		// Rebuild the kube client whenever the talosconfig was reloaded
+		if source := w.conn.Load(); source != kubeSource {
+			kube, kubeSource = nil, source
+		}
+		// Every poll goes through the endpoint pool, even once the kubeconfig
+		// is cached, so the endpoint health stays current
+		var kubeconfig []byte
+		endpoint, err := kubeSource.endpoints.do(controlContext, func(ctx context.Context, c endpointClient) error {
+			var err error
+			if kube == nil {
+				kubeconfig, err = c.Kubeconfig(ctx)
+			} else {
+				_, err = c.Version(ctx)
+			}
+			return err
+		})
+		if err != nil {
+			log.Warn("no talos endpoint could serve the poll", "error", err.Error())
+		} else {
+			log.Debug("polled talos", "endpoint", endpoint)
+		}
+		if kube == nil && kubeconfig != nil {
+			if kube, err = newKubeClient(kubeconfig); err != nil {
+				log.Warn("unable to build kubernetes client", "error", err.Error())
+			}
+		}
+
+		if kube != nil {
+			c, err := kube.podCounts(controlContext)
+			if err != nil {
+				// Leave the pod fields unset rather than reporting zero pods
+				log.Warn("unable to fetch pod counts", "error", err.Error())
+				kube = nil
+			} else {
+				counts = c
+			}
+		}
+
+		if counts == nil {
+			counts = map[string]podInfo{}
+		}
+		select {
+		case w.internalPodChan <- counts:
+		case <-controlContext.Done():
+			return
+		}
+
+		select {
+		case <-time.After(podPollDuration):
+		case <-controlContext.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package talos
+
+import "testing"
+
+func TestSnapshotKubernetesNode(t *testing.T) {
+	counts := podInfo{Name: "k8s-worker1", Running: 12, Capacity: 110}
+	tests := []struct {
+		name      string
+		status    NodeStatus
+		overrides map[string]string
+		counts    map[string]podInfo
+		want      string
+		pods      int
+	}{
+		{
+			name:   "matched by name",
+			status: NodeStatus{Node: "k8s-worker1"},
+			counts: map[string]podInfo{"k8s-worker1": counts},
+			want:   "k8s-worker1",
+			pods:   12,
+		},
+		{
+			name:   "matched by address with its prefix length",
+			status: NodeStatus{Node: "worker1", Addresses: []string{"10.0.0.21/24"}},
+			counts: map[string]podInfo{"k8s-worker1": counts, "10.0.0.21": counts},
+			want:   "k8s-worker1",
+			pods:   12,
+		},
+		{
+			name:      "overridden and counted",
+			status:    NodeStatus{Node: "worker1", Addresses: []string{"10.0.0.21"}},
+			overrides: map[string]string{"worker1": "k8s-worker1"},
+			counts:    map[string]podInfo{"k8s-worker1": counts},
+			want:      "k8s-worker1",
+			pods:      12,
+		},
+		{
+			// The configured name is known before the first pod poll, or
+			// while the Kubernetes API is unreachable
+			name:      "overridden before pods are counted",
+			status:    NodeStatus{Node: "worker1"},
+			overrides: map[string]string{"worker1": "k8s-worker1"},
+			want:      "k8s-worker1",
+		},
+		{
+			// The configured name wins over one found by address
+			name:      "overridden with another node at its address",
+			status:    NodeStatus{Node: "worker1", Addresses: []string{"10.0.0.21"}},
+			overrides: map[string]string{"worker1": "k8s-worker1"},
+			counts:    map[string]podInfo{"10.0.0.21": {Name: "k8s-other", Running: 3}},
+			want:      "k8s-worker1",
+		},
+		{
+			name:   "unmatched",
+			status: NodeStatus{Node: "worker1", Addresses: []string{"10.0.0.21"}},
+			counts: map[string]podInfo{"k8s-worker2": counts},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &TalosWatcher{
+				Status:    map[string]NodeStatus{tt.status.Node: tt.status},
+				kubeNodes: tt.overrides,
+				podCounts: tt.counts,
+			}
+			got := w.snapshot()[tt.status.Node]
+			if got.KubernetesNode != tt.want {
+				t.Errorf("kubernetes node %q, want %q", got.KubernetesNode, tt.want)
+			}
+			if tt.pods == 0 {
+				if got.PodCount != nil || got.PodCapacity != nil {
+					t.Errorf("pod counts set without any counted: %v/%v", *got.PodCount, *got.PodCapacity)
+				}
+				return
+			}
+			if got.PodCount == nil || *got.PodCount != tt.pods || got.PodCapacity == nil || *got.PodCapacity != 110 {
+				t.Errorf("pod counts %v/%v, want %d/110", got.PodCount, got.PodCapacity, tt.pods)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package talos
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"time"
+
+	tclient "github.com/siderolabs/talos/pkg/machinery/client"
+)
+
+var maintenanceProbeTimeout = time.Duration(2) * time.Second
+
+// MaintenanceInfo is what a node in maintenance mode reveals about itself to
+// an unauthenticated caller
+type MaintenanceInfo struct {
+	Version  string `json:"version,omitempty"`
+	Arch     string `json:"arch,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// probeMaintenance asks the node for its version without client
+// credentials. Only a node in maintenance mode, which has no PKI yet,
+// answers; a configured node rejects the handshake.
+func probeMaintenance(ctx context.Context, node string) (*MaintenanceInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, maintenanceProbeTimeout)
+	defer cancel()
+
+	client, err := tclient.New(ctx,
+		tclient.WithEndpoints(node),
+		tclient.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	resp, err := client.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.GetMessages()) == 0 {
+		return nil, fmt.Errorf("empty version response")
+	}
+	msg := resp.GetMessages()[0]
+	return &MaintenanceInfo{
+		Version:  msg.GetVersion().GetTag(),
+		Arch:     msg.GetVersion().GetArch(),
+		Platform: msg.GetPlatform().GetName(),
+	}, nil
+}
+
+// checkMaintenance runs after the secure API could not be reached and
+// reports the node as in maintenance mode if it answers insecurely, or no
+// longer in maintenance mode if it has stopped answering
+func (w *NodeWatcher) checkMaintenance(ctx context.Context, resultChan chan<- NodeStatus, log *slog.Logger) {
+	info, err := probeMaintenance(ctx, w.CurrentStatus.Node)
+	if err != nil {
+		if w.CurrentStatus.WatcherState == CONNECTION_MAINTENANCE {
+			log.Info("node no longer answering in maintenance mode", "error", err.Error())
+			w.CurrentStatus.WatcherState = CONNECTION_DISCONNECTED
+			w.CurrentStatus.Maintenance = nil
+			w.send(ctx, resultChan)
+		}
+		return
+	}
+
+	if w.CurrentStatus.WatcherState != CONNECTION_MAINTENANCE || w.CurrentStatus.Maintenance == nil || *w.CurrentStatus.Maintenance != *info {
+		log.Info("node is in maintenance mode", "version", info.Version, "arch", info.Arch, "platform", info.Platform)
+		w.CurrentStatus.WatcherState = CONNECTION_MAINTENANCE
+		w.CurrentStatus.Maintenance = info
+		w.send(ctx, resultChan)
+	}
+}
@@ -0,0 +1,182 @@
+package talos
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/siderolabs/talos/pkg/machinery/api/machine"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeMaintenanceAPI answers Version the way a node in maintenance mode
+// does, to anyone
+type fakeMaintenanceAPI struct {
+	machine.UnimplementedMachineServiceServer
+}
+
+func (fakeMaintenanceAPI) Version(context.Context, *emptypb.Empty) (*machine.VersionResponse, error) {
+	return &machine.VersionResponse{Messages: []*machine.Version{{
+		Version:  &machine.VersionInfo{Tag: "v1.9.0", Arch: "arm64"},
+		Platform: &machine.PlatformInfo{Name: "metal"},
+	}}}, nil
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "talos"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// fakeNode serves the Talos API on a local port and returns its address
+// and a function taking it down. A configured node insists on a client
+// certificate, which is what turns the maintenance probe away.
+func fakeNode(t *testing.T, configured bool) (string, func()) {
+	t.Helper()
+	cfg := &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}
+	if configured {
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(cfg)))
+	machine.RegisterMachineServiceServer(srv, fakeMaintenanceAPI{})
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	return l.Addr().String(), srv.Stop
+}
+
+// shortProbes keeps probes of a node that's down from waiting out the full
+// timeout
+func shortProbes(t *testing.T) {
+	timeout := maintenanceProbeTimeout
+	maintenanceProbeTimeout = 500 * time.Millisecond
+	t.Cleanup(func() { maintenanceProbeTimeout = timeout })
+}
+
+func TestProbeMaintenance(t *testing.T) {
+	shortProbes(t)
+	ctx := context.Background()
+
+	fresh, _ := fakeNode(t, false)
+	info, err := probeMaintenance(ctx, fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info != (MaintenanceInfo{Version: "v1.9.0", Arch: "arm64", Platform: "metal"}) {
+		t.Errorf("probed %+v", info)
+	}
+
+	configured, _ := fakeNode(t, true)
+	if info, err := probeMaintenance(ctx, configured); err == nil {
+		t.Errorf("a configured node answered the probe with %+v", info)
+	}
+
+	gone, stop := fakeNode(t, false)
+	stop()
+	if info, err := probeMaintenance(ctx, gone); err == nil {
+		t.Errorf("a node that's down answered the probe with %+v", info)
+	}
+}
+
+// sent is the status checkMaintenance sent, if any
+func sent(results chan NodeStatus) *NodeStatus {
+	select {
+	case s := <-results:
+		return &s
+	default:
+		return nil
+	}
+}
+
+func TestCheckMaintenance(t *testing.T) {
+	shortProbes(t)
+	ctx := context.Background()
+	log := slog.New(slog.DiscardHandler)
+	results := make(chan NodeStatus, 1)
+	want := MaintenanceInfo{Version: "v1.9.0", Arch: "arm64", Platform: "metal"}
+
+	t.Run("outage then maintenance", func(t *testing.T) {
+		// The secure API failed and nothing answers insecurely either: the
+		// node stays unreachable without a new status
+		addr, stop := fakeNode(t, false)
+		stop()
+		w := &NodeWatcher{CurrentStatus: NodeStatus{Node: addr, WatcherState: CONNECTION_DISCONNECTED}}
+		w.checkMaintenance(ctx, results, log)
+		if s := sent(results); s != nil {
+			t.Fatalf("an unreachable node sent %+v", s)
+		}
+
+		// The node is reinstalled and comes up waiting for its config
+		addr, _ = fakeNode(t, false)
+		w.CurrentStatus.Node = addr
+		w.checkMaintenance(ctx, results, log)
+		s := sent(results)
+		if s == nil || s.WatcherState != CONNECTION_MAINTENANCE || s.Maintenance == nil || *s.Maintenance != want {
+			t.Fatalf("a node in maintenance mode sent %+v", s)
+		}
+
+		// Nothing is sent while it stays that way
+		w.checkMaintenance(ctx, results, log)
+		if s := sent(results); s != nil {
+			t.Errorf("an unchanged node sent %+v", s)
+		}
+	})
+
+	t.Run("maintenance then outage", func(t *testing.T) {
+		addr, stop := fakeNode(t, false)
+		w := &NodeWatcher{CurrentStatus: NodeStatus{Node: addr, WatcherState: CONNECTION_MAINTENANCE, Maintenance: &want}}
+		w.checkMaintenance(ctx, results, log)
+		if s := sent(results); s != nil {
+			t.Fatalf("an unchanged node sent %+v", s)
+		}
+
+		// It stops answering without ever answering the secure API
+		stop()
+		w.checkMaintenance(ctx, results, log)
+		s := sent(results)
+		if s == nil || s.WatcherState != CONNECTION_DISCONNECTED || s.Maintenance != nil {
+			t.Fatalf("a node leaving maintenance mode sent %+v", s)
+		}
+	})
+
+	t.Run("configured node", func(t *testing.T) {
+		// A configured node whose secure API failed isn't mistaken for one
+		// in maintenance mode
+		addr, _ := fakeNode(t, true)
+		w := &NodeWatcher{CurrentStatus: NodeStatus{Node: addr, WatcherState: CONNECTION_DISCONNECTED}}
+		w.checkMaintenance(ctx, results, log)
+		if s := sent(results); s != nil {
+			t.Errorf("a configured node sent %+v", s)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package talos
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/DRuggeri/labwatch/poll"
+	"github.com/siderolabs/talos/pkg/machinery/api/machine"
+	tclient "github.com/siderolabs/talos/pkg/machinery/client"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// defaultPollConfig paces the metrics poll of each node. The interval
+// stretches from Min towards Max while the node stays healthy and its metrics
+// hold within metricsTolerance.
+var defaultPollConfig = poll.Config{
+	Min:    time.Duration(30) * time.Second,
+	Max:    time.Duration(5) * time.Minute,
+	Steady: 3,
+}
+
+// metricsTolerance is how far a metric may drift between polls, in its own
+// units, before the node counts as changing
+var metricsTolerance = 5.0
+
+const METRIC_CPU_PERCENT = "cpu_percent"
+const METRIC_MEMORY_PERCENT = "memory_percent"
+const METRIC_DISK_PERCENT = "disk_percent"
+const METRIC_LOAD1 = "load1"
+
+// Metrics lists every metric reported in NodeStatus.Metrics
+var Metrics = []string{METRIC_CPU_PERCENT, METRIC_MEMORY_PERCENT, METRIC_DISK_PERCENT, METRIC_LOAD1}
+
+// cpuSample holds the cumulative CPU counters from the previous collection.
+// CPU usage is the share of busy time between two samples.
+type cpuSample struct {
+	busy, total float64
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// collectMetrics gathers resource usage from the node. Each metric is left
+// out when its call fails rather than failing the lot; disk_percent is the
+// fullest block device mount.
+func collectMetrics(ctx context.Context, client *tclient.Client, prev *cpuSample, log *slog.Logger) map[string]float64 {
+	ret := map[string]float64{}
+
+	if resp, err := client.Memory(ctx); err != nil {
+		log.Debug("unable to fetch memory", "error", err.Error())
+	} else if len(resp.GetMessages()) > 0 {
+		m := resp.GetMessages()[0].GetMeminfo()
+		if m.GetMemtotal() > 0 {
+			ret[METRIC_MEMORY_PERCENT] = round1(100 * float64(m.GetMemtotal()-m.GetMemavailable()) / float64(m.GetMemtotal()))
+		}
+	}
+
+	if resp, err := client.Mounts(ctx); err != nil {
+		log.Debug("unable to fetch mounts", "error", err.Error())
+	} else if len(resp.GetMessages()) > 0 {
+		fullest := -1.0
+		for _, s := range resp.GetMessages()[0].GetStats() {
+			if s.GetSize() == 0 || !strings.HasPrefix(s.GetFilesystem(), "/dev/") {
+				continue
+			}
+			fullest = max(fullest, 100*float64(s.GetSize()-s.GetAvailable())/float64(s.GetSize()))
+		}
+		if fullest >= 0 {
+			ret[METRIC_DISK_PERCENT] = round1(fullest)
+		}
+	}
+
+	if resp, err := client.MachineClient.LoadAvg(ctx, &emptypb.Empty{}); err != nil {
+		log.Debug("unable to fetch load average", "error", err.Error())
+	} else if len(resp.GetMessages()) > 0 {
+		ret[METRIC_LOAD1] = round1(resp.GetMessages()[0].GetLoad1())
+	}
+
+	if resp, err := client.MachineClient.SystemStat(ctx, &emptypb.Empty{}); err != nil {
+		log.Debug("unable to fetch cpu stats", "error", err.Error())
+	} else if len(resp.GetMessages()) > 0 {
+		c := resp.GetMessages()[0].GetCpuTotal()
+		cur := cpuSample{total: cpuTotal(c)}
+		cur.busy = cur.total - c.GetIdle() - c.GetIowait()
+		if prev.total > 0 && cur.total > prev.total {
+			ret[METRIC_CPU_PERCENT] = round1(100 * (cur.busy - prev.busy) / (cur.total - prev.total))
+		}
+		*prev = cur
+	}
+	return ret
+}
+
+func cpuTotal(c *machine.CPUStat) float64 {
+	return c.GetUser() + c.GetNice() + c.GetSystem() + c.GetIdle() + c.GetIowait() +
+		c.GetIrq() + c.GetSoftIrq() + c.GetSteal()
+}
+
+// metricsChanged reports whether a metric appeared, vanished or drifted
+// beyond metricsTolerance
+func metricsChanged(prev, cur map[string]float64) bool {
+	if len(prev) != len(cur) {
+		return true
+	}
+	for name, v := range cur {
+		p, ok := prev[name]
+		if !ok || math.Abs(v-p) > metricsTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// watchMetrics polls resource usage for as long as the watch runs, on the
+// node's adaptive schedule. The metrics map is replaced, never modified, as
+// earlier snapshots may still be in use.
+func (w *NodeWatcher) watchMetrics(ctx context.Context, client *tclient.Client, resultChan chan<- NodeStatus, log *slog.Logger) {
+	prev := &cpuSample{}
+	w.schedule.Reset()
+	for {
+		metrics := collectMetrics(ctx, client, prev, log)
+		if ctx.Err() != nil {
+			return
+		}
+		// A failed call drops its metric, so errors count as a change too
+		changed := metricsChanged(w.CurrentStatus.Metrics, metrics)
+		if len(metrics) > 0 {
+			w.CurrentStatus.Metrics = metrics
+			w.send(ctx, resultChan)
+		}
+
+		healthy := w.CurrentStatus.WatcherState == CONNECTION_OK && w.CurrentStatus.Ready && w.CurrentStatus.Error == nil
+		before := w.schedule.Interval()
+		if interval := w.schedule.Observe(changed || !healthy); interval != before {
+			log.Debug("metrics poll interval changed", "interval", interval.String())
+		}
+		if !w.schedule.Wait(ctx) {
+			return
+		}
+	}
+}
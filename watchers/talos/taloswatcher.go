@@ -2,11 +2,18 @@ package talos
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/DRuggeri/labwatch/clock"
+	"github.com/DRuggeri/labwatch/poll"
+	"github.com/DRuggeri/labwatch/watchers"
 	"github.com/siderolabs/gen/xslices"
 	"github.com/siderolabs/talos/pkg/machinery/api/machine"
 	tclient "github.com/siderolabs/talos/pkg/machinery/client"
@@ -16,24 +23,67 @@ import (
 	"google.golang.org/grpc/connectivity"
 )
 
+func init() {
+	watchers.Register("talos", "Streams the events and metrics of every node through the Talos API", true)
+}
+
 // SEE: https://github.com/siderolabs/talos/blob/main/pkg/machinery/client/client.go
 // SEE: https://github.com/siderolabs/talos/blob/main/cmd/talosctl/cmd/talos/events.go
 var reconnectDuration = time.Duration(250) * time.Millisecond
 var sleepDuration = time.Duration(250) * time.Millisecond
+var defaultMaxSilence = time.Duration(60) * time.Second
+
+// stageHistoryLimit caps how many stage transitions a node keeps
+var stageHistoryLimit = 20
 
 type TalosWatcher struct {
-	config       *tcconfig.Config
-	client       *tclient.Client
+	configFile   string
+	contextName  string
+	clusterName  string
+	conn         atomic.Pointer[talosConn]
 	Status       map[string]NodeStatus
 	talosContext *tcconfig.Context
 	watchers     map[string]NodeWatcher
 	internalChan chan NodeStatus
 	log          *slog.Logger
+
+	internalPodChan chan map[string]podInfo
+	podCounts       map[string]podInfo
+	kubeNodes       map[string]string
+
+	// Unchanged snapshots are only resent once maxSilence has passed so
+	// consumers can still tell the watcher is alive
+	maxSilence      time.Duration
+	clock           clock.Clock
+	lastFingerprint [sha256.Size]byte
+	lastSent        time.Time
+	suppressed      int
 }
 
+// talosConn is one generation of the talosconfig and the client built from
+// it. contextName is the context resolved from it. reloaded is closed when a
+// newer generation replaces it.
+type talosConn struct {
+	config      *tcconfig.Config
+	contextName string
+	client      *tclient.Client
+	endpoints   *endpointPool
+	reloaded    chan struct{}
+}
+
+func (c *talosConn) close() {
+	c.client.Close()
+	c.endpoints.close()
+}
+
+// reloadGrace gives in-flight calls on a replaced client time to finish
+var reloadGrace = time.Duration(30) * time.Second
+
 type NodeWatcher struct {
 	CurrentStatus NodeStatus
-	configOpts    []tclient.OptionFunc
+	source        *atomic.Pointer[talosConn]
+	backoff       backoff.Config
+	schedule      *poll.Adaptive
 	log           *slog.Logger
 }
 
@@ -47,8 +97,63 @@ type NodeStatus struct {
 	Error           error
 	Addresses       []string
 	Stage           string
+	StageHistory    []StageTransition `json:"stage_history,omitempty"`
 	Ready           bool
 	UnmetConditions []string
+	PodCount        *int             `json:",omitempty"`
+	KubernetesNode  string           `json:"kubernetes_node,omitempty"`
+	SourceID        string           `json:"source_id,omitempty"`
+	SuppressedBy    string           `json:"suppressed_by,omitempty"`
+	Injected        bool             `json:"injected,omitempty"`
+	Maintenance     *MaintenanceInfo `json:"maintenance,omitempty"`
+	Version         string           `json:"version,omitempty"`
+
+	// Metrics holds the latest resource usage, keyed by the METRIC_ names
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	// Degraded maps each metric over a configured threshold to the severity
+	// of the worst threshold crossed. Set by the consumer, never the watcher.
+	Degraded map[string]string `json:"degraded,omitempty"`
+
+	// Departed marks a node the watcher no longer reports, kept in the status
+	// since DepartedAt for a grace period. Set by the consumer, never the
+	// watcher.
+	Departed   bool       `json:"departed,omitempty"`
+	DepartedAt *time.Time `json:"departed_at,omitempty"`
+
+	// Silenced is set while an admin silence holds back notifications about
+	// the node. Set by the consumer, never the watcher.
+	Silenced bool `json:"silenced,omitempty"`
+
+	// Stale marks a node that hasn't reported within the talos staleness
+	// threshold. Set by the consumer, never the watcher.
+	Stale bool `json:"stale,omitempty"`
+
+	LastUpdated time.Time `json:"last_updated"`
+	PodCapacity *int      `json:",omitempty"`
+}
+
+// StageTransition is a change of the machine stage Talos reports, such as
+// installing to booting to running
+type StageTransition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// recordStage returns history with the move from one stage to another
+// appended, keeping the most recent stageHistoryLimit. A fresh slice is
+// returned as earlier snapshots may still share the old one. The first stage
+// seen after starting isn't a transition the watcher saw, so it is skipped.
+func recordStage(history []StageTransition, from string, to string, now time.Time) []StageTransition {
+	if from == to || from == "unknown" {
+		return history
+	}
+	ret := append(slices.Clone(history), StageTransition{From: from, To: to, Time: now})
+	if len(ret) > stageHistoryLimit {
+		ret = ret[len(ret)-stageHistoryLimit:]
+	}
+	return ret
 }
 
 type ServiceStatus struct {
@@ -69,37 +174,42 @@ type ConnectionState string
 const CONNECTION_OK ConnectionState = "connected"
 const CONNECTION_DISCONNECTED ConnectionState = "disconnected"
 
+// CONNECTION_MAINTENANCE is a node that only answers the unauthenticated
+// maintenance API, typically a fresh install waiting for its machine config
+const CONNECTION_MAINTENANCE ConnectionState = "maintenance"
+
 // func NewTalosWatcher(configFile string, clusterName string) (watchers.Watcher, error) {
-func NewTalosWatcher(ctx context.Context, configFile string, clusterName string, log *slog.Logger) (*TalosWatcher, error) {
+// NewTalosWatcher watches every node of the talosconfig context, see
+// ResolveContext for how contextName is resolved
+func NewTalosWatcher(ctx context.Context, configFile string, contextName string, clusterName string, log *slog.Logger) (*TalosWatcher, error) {
 	w := &TalosWatcher{
 		Status:       map[string]NodeStatus{},
 		watchers:     map[string]NodeWatcher{},
 		internalChan: make(chan NodeStatus),
 		log:          log.With("operation", "TalosWatcher"),
-	}
 
-	cfg, err := tcconfig.Open(configFile)
-	if err != nil {
-		return nil, err
-	}
-	w.config = cfg
-
-	var tctx *tcconfig.Context
-	var ok bool
-	if tctx, ok = cfg.Contexts[clusterName]; !ok {
-		return nil, fmt.Errorf("the %s context name does not exist in the config file %s", clusterName, configFile)
-	}
-	w.talosContext = tctx
-
-	if len(tctx.Nodes) == 0 {
-		return nil, fmt.Errorf("there are no nodes defined in the %s config file", configFile)
+		internalPodChan: make(chan map[string]podInfo),
+		podCounts:       map[string]podInfo{},
+		kubeNodes:       map[string]string{},
+		maxSilence:      defaultMaxSilence,
+		clock:           clock.Real{},
+		configFile:      configFile,
+		contextName:     contextName,
+		clusterName:     clusterName,
 	}
 
-	client, err := tclient.New(context.Background(), tclient.WithConfig(cfg))
+	conn, tctx, err := openTalosConfig(configFile, contextName, clusterName)
 	if err != nil {
 		return nil, err
 	}
-	w.client = client
+	w.log.Info("using talos context", "context", conn.contextName, "cluster", clusterName, "endpoints", tctx.Endpoints, "nodes", tctx.Nodes)
+	w.conn.Store(conn)
+	w.talosContext = tctx
+	go w.watchPods(ctx)
+	go func() {
+		<-ctx.Done()
+		w.conn.Load().close()
+	}()
 
 	//Create a standalone client that can suffer connects/disconnects without affecting the overall client
 	for _, nodeName := range tctx.Nodes {
@@ -118,17 +228,10 @@ func NewTalosWatcher(ctx context.Context, configFile string, clusterName string,
 				Stage:           "unknown",
 				UnmetConditions: []string{},
 			},
-			configOpts: []tclient.OptionFunc{
-				tclient.WithConfig(cfg),
-				tclient.WithContextName(clusterName),
-				tclient.WithEndpoints(nodeName),
-				tclient.WithGRPCDialOptions(grpc.WithConnectParams(
-					grpc.ConnectParams{
-						Backoff: backoffConfig,
-					},
-				)),
-			},
-			log: log.With("operation", "NodeWatcher", "node", nodeName),
+			source:   &w.conn,
+			backoff:  backoffConfig,
+			schedule: poll.NewAdaptive(defaultPollConfig, nil),
+			log:      log.With("operation", "NodeWatcher", "node", nodeName),
 		}
 		go nodeWatcher.Watch(ctx, w.internalChan)
 		w.watchers[nodeName] = nodeWatcher
@@ -137,7 +240,107 @@ func NewTalosWatcher(ctx context.Context, configFile string, clusterName string,
 	return w, err
 }
 
+// openTalosConfig loads the talosconfig and builds a client from it
+func openTalosConfig(configFile string, contextName string, clusterName string) (*talosConn, *tcconfig.Context, error) {
+	cfg, err := tcconfig.Open(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, tctx, err := ResolveContext(cfg, configFile, contextName, clusterName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tctx.Nodes) == 0 {
+		return nil, nil, fmt.Errorf("there are no nodes defined in the %s context of the %s config file", name, configFile)
+	}
+
+	client, err := tclient.New(context.Background(), tclient.WithConfig(cfg), tclient.WithContextName(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &talosConn{
+		config:      cfg,
+		contextName: name,
+		client:      client,
+		endpoints:   newEndpointPool(cfg, name, tctx),
+		reloaded:    make(chan struct{}),
+	}, tctx, nil
+}
+
+// ReloadConfig re-reads the talosconfig, for instance after credentials were
+// rotated. Node watchers reconnect using the new config while the old client
+// stays open for reloadGrace so in-flight calls can finish. An invalid config
+// is reported and the current one kept. The set of watched nodes does not
+// change until restart.
+func (w *TalosWatcher) ReloadConfig() error {
+	conn, tctx, err := openTalosConfig(w.configFile, w.contextName, w.clusterName)
+	if err != nil {
+		return err
+	}
+	if prev := w.conn.Load().contextName; conn.contextName != prev {
+		w.log.Warn("talosconfig now resolves to another context", "old", prev, "new", conn.contextName)
+	}
+	if strings.Join(tctx.Nodes, ",") != strings.Join(w.talosContext.Nodes, ",") {
+		w.log.Warn("talosconfig node list changed, restart to watch the new nodes", "nodes", tctx.Nodes)
+	}
+
+	old := w.conn.Swap(conn)
+	close(old.reloaded)
+	go func() {
+		time.Sleep(reloadGrace)
+		old.close()
+	}()
+	w.log.Info("reloaded talosconfig", "file", w.configFile, "context", conn.contextName, "endpoints", tctx.Endpoints)
+	return nil
+}
+
+// Endpoints reports the health of the endpoints used for cluster wide calls
+func (w *TalosWatcher) Endpoints() []EndpointStatus {
+	return w.conn.Load().endpoints.snapshot()
+}
+
+// Nodes returns the node names configured in the talosconfig context
+func (w *TalosWatcher) Nodes() []string {
+	return append([]string{}, w.talosContext.Nodes...)
+}
+
+// SetMaxSilence sets how long an unchanged status is held back before it is
+// sent again anyway. Must be called before Watch.
+func (w *TalosWatcher) SetMaxSilence(d time.Duration) {
+	w.maxSilence = d
+}
+
+// SetPollConfig bounds the adaptive metrics poll of every node
+func (w *TalosWatcher) SetPollConfig(cfg poll.Config) {
+	for _, n := range w.watchers {
+		n.schedule.Configure(cfg)
+	}
+}
+
+// PollSchedules reports the current metrics poll schedule of each node
+func (w *TalosWatcher) PollSchedules() map[string]poll.Status {
+	ret := make(map[string]poll.Status, len(w.watchers))
+	for name, n := range w.watchers {
+		ret[name] = n.schedule.Status()
+	}
+	return ret
+}
+
+// SetKubernetesNodes names the Kubernetes node of talosconfig nodes that
+// can't be matched by name or address. Call it before Watch.
+func (w *TalosWatcher) SetKubernetesNodes(names map[string]string) {
+	w.kubeNodes = names
+}
+
+// SetClock replaces the wall clock used to stamp and pace updates. Call it
+// before Watch.
+func (w *TalosWatcher) SetClock(c clock.Clock) {
+	w.clock = clock.OrReal(c)
+}
+
 func (w *TalosWatcher) Watch(controlContext context.Context, resultChan chan<- map[string]NodeStatus) {
+	log := w.log.With("operation", "TalosWatcher.Watch")
 	for {
 		select {
 		case <-controlContext.Done():
@@ -151,27 +354,116 @@ func (w *TalosWatcher) Watch(controlContext context.Context, resultChan chan<- m
 		for {
 			select {
 			case nodeStatus := <-w.internalChan:
+				nodeStatus.LastUpdated = w.clock.Now()
 				w.Status[nodeStatus.Node] = nodeStatus
-
-				// Make a copy of all data to send to the chan
-				og, _ := json.Marshal(w.Status)
-				cpy := map[string]NodeStatus{}
-				json.Unmarshal(og, &cpy)
-
-				resultChan <- cpy
+				w.sendIfChanged(controlContext, resultChan, w.clock.Now(), log)
+			case counts := <-w.internalPodChan:
+				w.podCounts = counts
+				w.sendIfChanged(controlContext, resultChan, w.clock.Now(), log)
 			default:
 				break OUTER
 			}
 		}
 
+		if now := w.clock.Now(); !w.lastSent.IsZero() && now.Sub(w.lastSent) >= w.maxSilence {
+			w.sendIfChanged(controlContext, resultChan, now, log)
+		}
+
 		time.Sleep(sleepDuration)
 	}
 }
 
+// sendIfChanged sends a snapshot unless it matches the last one sent and the
+// max silence interval hasn't passed yet
+func (w *TalosWatcher) sendIfChanged(ctx context.Context, resultChan chan<- map[string]NodeStatus, now time.Time, log *slog.Logger) {
+	snap := w.snapshot()
+	fp := fingerprint(snap)
+	if fp == w.lastFingerprint && now.Sub(w.lastSent) < w.maxSilence {
+		w.suppressed++
+		log.Debug("status unchanged, suppressing update", "suppressed", w.suppressed)
+		return
+	}
+
+	if w.suppressed > 0 {
+		log.Debug("sending status after suppressed updates", "suppressed", w.suppressed, "changed", fp != w.lastFingerprint)
+	}
+	w.lastFingerprint = fp
+	w.lastSent = now
+	w.suppressed = 0
+	select {
+	case resultChan <- snap:
+	case <-ctx.Done():
+	}
+}
+
+// fingerprint hashes the statuses, ignoring when each was last touched.
+// Errors marshal as {} so their text is hashed alongside.
+func fingerprint(nodes map[string]NodeStatus) [sha256.Size]byte {
+	type hashed struct {
+		NodeStatus
+		ErrorText string
+	}
+	cpy := make(map[string]hashed, len(nodes))
+	for name, n := range nodes {
+		n.LastUpdated = time.Time{}
+		h := hashed{NodeStatus: n}
+		if n.Error != nil {
+			h.ErrorText = n.Error.Error()
+		}
+		cpy[name] = h
+	}
+	b, _ := json.Marshal(cpy)
+	return sha256.Sum256(b)
+}
+
+// snapshot makes a copy of all node data, decorated with the Kubernetes node
+// name and latest pod counts, that is safe to hand to consumers
+func (w *TalosWatcher) snapshot() map[string]NodeStatus {
+	og, _ := json.Marshal(w.Status)
+	cpy := map[string]NodeStatus{}
+	json.Unmarshal(og, &cpy)
+
+	for node, status := range cpy {
+		// Errors don't survive the JSON copy, and are never changed once made
+		status.Error = w.Status[node].Error
+		if name, info, counted := w.kubeNode(status); name != "" {
+			status.KubernetesNode = name
+			if counted {
+				status.PodCount = &info.Running
+				status.PodCapacity = &info.Capacity
+			}
+		}
+		cpy[node] = status
+	}
+	return cpy
+}
+
+// kubeNode finds the Kubernetes node a Talos node runs: the one configured
+// for it, else the one known by its talosconfig name, else by any address
+// Talos reports for it, with or without its prefix length. A configured name
+// is returned even before its pods have been counted; counted reports
+// whether info holds its counts.
+func (w *TalosWatcher) kubeNode(status NodeStatus) (name string, info podInfo, counted bool) {
+	if name, ok := w.kubeNodes[status.Node]; ok {
+		info, counted := w.podCounts[name]
+		return name, info, counted
+	}
+	if info, ok := w.podCounts[status.Node]; ok {
+		return info.Name, info, true
+	}
+	for _, a := range status.Addresses {
+		ip, _, _ := strings.Cut(a, "/")
+		if info, ok := w.podCounts[ip]; ok {
+			return info.Name, info, true
+		}
+	}
+	return "", podInfo{}, false
+}
+
 func (w NodeWatcher) Watch(controlContext context.Context, resultChan chan<- NodeStatus) {
 	log := w.log.With("operation", "TalosWatcher.Watch")
 	log.Debug("watching")
-	resultChan <- w.CurrentStatus
+	w.send(controlContext, resultChan)
 
 	// Modelled from https://github.com/siderolabs/talos/blob/main/cmd/talosctl/cmd/talos/events.go
 	fxn := func(c <-chan tclient.Event) {
@@ -201,20 +493,26 @@ func (w NodeWatcher) Watch(controlContext context.Context, resultChan chan<- Nod
 		case *machine.ConfigValidationErrorEvent:
 			w.CurrentStatus.Error = fmt.Errorf("config validation: %s", msg.GetError())
 		case *machine.AddressEvent:
-			w.CurrentStatus.Addresses = msg.GetAddresses()
+			// Sorted so the status doesn't change with the order Talos reports in
+			w.CurrentStatus.Addresses = slices.Sorted(slices.Values(msg.GetAddresses()))
 		case *machine.MachineStatusEvent:
-			w.CurrentStatus.Stage = msg.GetStage().String()
+			stage := msg.GetStage().String()
+			w.CurrentStatus.StageHistory = recordStage(w.CurrentStatus.StageHistory, w.CurrentStatus.Stage, stage, time.Now())
+			w.CurrentStatus.Stage = stage
 			w.CurrentStatus.Ready = msg.GetStatus().Ready
 			unmet := xslices.Map(msg.GetStatus().GetUnmetConditions(),
 				func(c *machine.MachineStatusEvent_MachineStatus_UnmetCondition) string {
 					return c.Name
 				},
 			)
+			slices.Sort(unmet)
 			w.CurrentStatus.UnmetConditions = unmet
 		}
 
-		// Send status after every event
-		resultChan <- w.CurrentStatus
+		// Send status after every event. A node with something to report is
+		// likely mid change, so its metrics are polled at the fastest rate.
+		w.schedule.Reset()
+		w.send(controlContext, resultChan)
 	}
 
 	for {
@@ -227,13 +525,33 @@ func (w NodeWatcher) Watch(controlContext context.Context, resultChan chan<- Nod
 
 		connectTimeout := time.Duration(1) * time.Second
 		watchContext, killWatch := context.WithCancel(controlContext)
+		connected := atomic.Bool{}
 		connectCtx, closeCtx := context.WithTimeout(watchContext, connectTimeout)
 
 		log.Debug("creating new client")
-		nodeClient, err := tclient.New(connectCtx, w.configOpts...)
+		source := w.source.Load()
+		nodeClient, err := tclient.New(connectCtx,
+			tclient.WithConfig(source.config),
+			tclient.WithContextName(source.contextName),
+			tclient.WithEndpoints(w.CurrentStatus.Node),
+			tclient.WithGRPCDialOptions(grpc.WithConnectParams(
+				grpc.ConnectParams{
+					Backoff: w.backoff,
+				},
+			)),
+		)
 		if err != nil {
 			fmt.Printf("client error: %s\n", err.Error())
 		} else {
+			go func() {
+				select {
+				case <-source.reloaded:
+					log.Info("talosconfig reloaded, reconnecting")
+					killWatch()
+				case <-watchContext.Done():
+				}
+			}()
+
 			go func() {
 				conn := nodeClient.Conn()
 				newState := CONNECTION_DISCONNECTED
@@ -244,7 +562,9 @@ func (w NodeWatcher) Watch(controlContext context.Context, resultChan chan<- Nod
 					switch connState {
 					case connectivity.Connecting:
 					case connectivity.Ready:
+						connected.Store(true)
 						w.CurrentStatus.WatcherState = CONNECTION_OK
+						w.CurrentStatus.Maintenance = nil
 					case connectivity.Idle:
 						bail = true
 					case connectivity.TransientFailure:
@@ -258,7 +578,7 @@ func (w NodeWatcher) Watch(controlContext context.Context, resultChan chan<- Nod
 					if w.CurrentStatus.WatcherState != newState {
 						w.log.Debug("detected state change", "old", w.CurrentStatus.WatcherState, "new", newState)
 						w.CurrentStatus.WatcherState = newState
-						resultChan <- w.CurrentStatus
+						w.send(controlContext, resultChan)
 					}
 
 					if bail {
@@ -294,15 +614,37 @@ func (w NodeWatcher) Watch(controlContext context.Context, resultChan chan<- Nod
 				}
 			*/
 
+			versionCtx, closeVersion := context.WithTimeout(watchContext, connectTimeout)
+			if resp, err := nodeClient.Version(versionCtx); err == nil && len(resp.GetMessages()) > 0 {
+				w.CurrentStatus.Version = resp.GetMessages()[0].GetVersion().GetTag()
+			}
+			closeVersion()
+
+			go w.watchMetrics(watchContext, nodeClient, resultChan, log)
 			nodeClient.EventsWatch(watchContext, fxn)
 		}
 		closeCtx()
 		killWatch()
 
+		// The secure API comes first; only a node that never answered it is
+		// checked for maintenance mode
+		if !connected.Load() {
+			w.checkMaintenance(controlContext, resultChan, log)
+		}
+
 		time.Sleep(reconnectDuration)
 	}
 }
 
+// send hands the current status to the TalosWatcher, giving up once ctx is
+// done so a stopped watcher doesn't leave node watchers blocked
+func (w *NodeWatcher) send(ctx context.Context, resultChan chan<- NodeStatus) {
+	select {
+	case resultChan <- w.CurrentStatus:
+	case <-ctx.Done():
+	}
+}
+
 func getHealthInfo(h *machine.ServiceHealth) (HealthState, time.Time) {
 	health := HEALTH_UNKNOWN
 	if !h.Unknown {
@@ -0,0 +1,143 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/DRuggeri/labwatch/clock"
+)
+
+func newTestWatcher(f *clock.Fake) *TalosWatcher {
+	return &TalosWatcher{
+		Status:          map[string]NodeStatus{},
+		internalChan:    make(chan NodeStatus),
+		internalPodChan: make(chan map[string]podInfo),
+		podCounts:       map[string]podInfo{},
+		kubeNodes:       map[string]string{},
+		maxSilence:      defaultMaxSilence,
+		clock:           f,
+		log:             slog.New(slog.DiscardHandler),
+	}
+}
+
+// broadcasts counts what arrives on results until nothing has for a while
+func broadcasts(results <-chan map[string]NodeStatus) []map[string]NodeStatus {
+	ret := []map[string]NodeStatus{}
+	for {
+		select {
+		case snap := <-results:
+			ret = append(ret, snap)
+		case <-time.After(50 * time.Millisecond):
+			return ret
+		}
+	}
+}
+
+func TestIdenticalPollsNotBroadcast(t *testing.T) {
+	defer func(d time.Duration) { sleepDuration = d }(sleepDuration)
+	sleepDuration = time.Millisecond
+
+	f := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	w := newTestWatcher(f)
+	results := make(chan map[string]NodeStatus)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Watch(ctx, results)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	// poll hands the watcher node statuses as node watchers do, returning
+	// what it broadcast
+	poll := func(statuses ...NodeStatus) []map[string]NodeStatus {
+		t.Helper()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, n := range statuses {
+				w.internalChan <- n
+				f.Advance(time.Second)
+			}
+		}()
+		got := broadcasts(results)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("watcher stopped reading polls")
+		}
+		return got
+	}
+
+	// Only the first of many identical polls goes out, however the time
+	// between them moves on
+	worker := NodeStatus{Node: "worker1", WatcherState: CONNECTION_OK, Ready: true, Services: map[string]ServiceStatus{"kubelet": {State: "Running", Healthy: HEALTH_OK}}}
+	same := []NodeStatus{}
+	for range 20 {
+		same = append(same, worker)
+	}
+	if got := poll(same...); len(got) != 1 || !got[0]["worker1"].Ready {
+		t.Fatalf("%d broadcasts across 20 identical polls, want 1", len(got))
+	}
+
+	// A refresh goes out once the lab has been quiet for the max silence so
+	// staleness tracking still sees the node as current
+	f.Advance(defaultMaxSilence)
+	if got := broadcasts(results); len(got) != 1 || !got[0]["worker1"].Ready {
+		t.Fatalf("%d broadcasts after the max silence, want 1", len(got))
+	}
+
+	// Any change goes out right away, an error's text included
+	failing := worker
+	failing.Error = errors.New("kubelet restarting")
+	if got := poll(failing); len(got) != 1 || got[0]["worker1"].Error != failing.Error {
+		t.Fatalf("%d broadcasts for a new error, want 1", len(got))
+	}
+	failing.Error = errors.New("kubelet crashed")
+	if got := poll(failing, failing, failing); len(got) != 1 {
+		t.Fatalf("%d broadcasts for a changed error polled 3 times, want 1", len(got))
+	}
+}
+
+func TestSendIfChanged(t *testing.T) {
+	f := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	w := newTestWatcher(f)
+	w.Status["cp1"] = NodeStatus{Node: "cp1", Ready: true}
+	results := make(chan map[string]NodeStatus, 10)
+	ctx := context.Background()
+	log := slog.New(slog.DiscardHandler)
+
+	start := f.Now()
+	for i := range 5 {
+		w.sendIfChanged(ctx, results, start.Add(time.Duration(i)*time.Second), log)
+	}
+	if len(results) != 1 || w.suppressed != 4 {
+		t.Fatalf("%d sent and %d suppressed, want 1 and 4", len(results), w.suppressed)
+	}
+
+	// New pod counts change the snapshot even with the node unchanged
+	w.podCounts = map[string]podInfo{"cp1": {Name: "cp1", Running: 3}}
+	w.sendIfChanged(ctx, results, start.Add(5*time.Second), log)
+	if len(results) != 2 || w.suppressed != 0 {
+		t.Fatalf("%d sent and %d suppressed after the pods changed", len(results), w.suppressed)
+	}
+
+	// Touching a node without changing it isn't a change
+	n := w.Status["cp1"]
+	n.LastUpdated = start.Add(6 * time.Second)
+	w.Status["cp1"] = n
+	w.sendIfChanged(ctx, results, start.Add(6*time.Second), log)
+	if len(results) != 2 {
+		t.Errorf("sent a snapshot differing only in when it was polled")
+	}
+	w.sendIfChanged(ctx, results, start.Add(5*time.Second+defaultMaxSilence), log)
+	if len(results) != 3 {
+		t.Errorf("no refresh after the max silence")
+	}
+}
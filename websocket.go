@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/gorilla/websocket"
+)
+
+var closeFrameTimeout = time.Duration(1) * time.Second
+
+// websocketFeatures lists the optional features v2 clients can use
+var websocketFeatures = []string{"replay", "filters", "protobuf", "delta", "stream", "exclude", "fields", "backfill"}
+
+// newClientFramer returns a framer for v2 clients and nil for v1 clients,
+// which keep receiving bare payloads
+func newClientFramer(r *http.Request) *server.Framer {
+	if server.Protocol(r) == server.PROTOCOL_V2 {
+		return &server.Framer{}
+	}
+	return nil
+}
+
+// eventV2Params are the /events parameters only v2 websocket clients may
+// pass. Filters are v2 too but v1 clients have always been able to send
+// them, so they are ignored rather than rejected.
+var eventV2Params = []string{"replay", "stream", "label"}
+
+// statusV2Params are the /status parameters only v2 websocket clients may
+// pass
+var statusV2Params = []string{"only", "exclude", "fields"}
+
+// checkV1Params rejects the parameters of v2 features on a v1 websocket
+func checkV1Params(r *http.Request, params []string) error {
+	if server.Protocol(r) == server.PROTOCOL_V2 {
+		return nil
+	}
+	for _, p := range params {
+		if r.URL.Query().Has(p) {
+			return fmt.Errorf("%s requires protocol=v2", p)
+		}
+	}
+	return nil
+}
+
+// websocketView is the view a /status websocket gets. Views and protobuf
+// frames are v2 features, so v1 clients get the whole JSON status as they
+// always have, default-excludes included.
+func websocketView(r *http.Request, view statusView, encoding string) (statusView, error) {
+	if server.Protocol(r) == server.PROTOCOL_V2 {
+		return view, nil
+	}
+	if encoding == ENCODING_PROTOBUF {
+		return view, fmt.Errorf("protobuf websocket frames require protocol=v2")
+	}
+	if err := checkV1Params(r, statusV2Params); err != nil {
+		return view, err
+	}
+	return statusView{}, nil
+}
+
+func sendHello(conn *websocket.Conn, framer *server.Framer, cfg LabwatchConfig) error {
+	if framer == nil {
+		return nil
+	}
+	return writeMessage(conn, framer, server.TYPE_HELLO, server.Hello{
+		Version:  Version,
+		Instance: cfg.InstanceName,
+		Encoding: "json",
+		Features: websocketFeatures,
+	})
+}
+
+// writeEvent sends an event under the client's outbound quota, telling v2
+// clients when sampling starts and stops
+func writeEvent(conn *websocket.Conn, framer *server.Framer, client ClientInfo, e loki.LogEvent, log *slog.Logger) error {
+	send, changed := client.sampleEvent(time.Now())
+	if changed {
+		log.Info("event client sampling changed", "client", client.ID, "sampling", client.stats.sampling)
+		if framer != nil {
+			control := server.Control{Action: server.CONTROL_SAMPLING_STOPPED, Message: "outbound quota reset, sending every event"}
+			if client.stats.sampling {
+				control = server.Control{Action: server.CONTROL_SAMPLING_STARTED, Message: client.samplingMessage()}
+			}
+			if err := client.track(writeMessage(conn, framer, server.TYPE_CONTROL, control)); err != nil {
+				return err
+			}
+		}
+	}
+	if !send {
+		return nil
+	}
+	return client.track(writeMessage(conn, framer, server.TYPE_EVENT, e))
+}
+
+// writeMessage sends a payload to a websocket client, framed in an envelope
+// when the client speaks v2
+func writeMessage(conn *websocket.Conn, framer *server.Framer, t server.MessageType, payload any) error {
+	var msg any = payload
+	if e, ok := payload.(loki.LogEvent); ok && framer != nil && t == server.TYPE_EVENT {
+		msg = framer.FrameEvent(e, e.Seq)
+	} else if framer != nil {
+		msg = framer.Frame(t, payload)
+	}
+	data, err := encodeJSON(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// closeWebsocket sends a close frame with the code and reason before closing
+// so the client can tell why it was disconnected and how soon to come back.
+// Every disconnect the server starts goes through here.
+func closeWebsocket(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeFrameTimeout))
+	conn.Close()
+}
+
+// closeSlowClient tells a client it was disconnected for falling behind so
+// it can reconnect and start fresh
+func closeSlowClient(conn *websocket.Conn) {
+	closeWebsocket(conn, websocket.CloseTryAgainLater, "client too slow, queue full")
+}
+
+// closeExpiredClient tells a client it reached max-connection-lifetime. It
+// may reconnect straight away.
+func closeExpiredClient(conn *websocket.Conn) {
+	closeWebsocket(conn, websocket.CloseNormalClosure, "max lifetime reached, reconnect")
+}
+
+// connectionLifetime fires once a streaming client has been connected for
+// max-connection-lifetime, and never when that is unset
+func connectionLifetime(cfg LabwatchConfig) <-chan time.Time {
+	if cfg.MaxConnectionLifetime <= 0 {
+		return nil
+	}
+	return time.After(time.Duration(cfg.MaxConnectionLifetime))
+}
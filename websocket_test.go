@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DRuggeri/labwatch/server"
+	"github.com/DRuggeri/labwatch/watchers/loki"
+	"github.com/gorilla/websocket"
+)
+
+// TestProtocolHandshake has a v1 and a v2 client connect to an endpoint that
+// greets them and sends a status and two events as the status and event
+// endpoints do
+func TestProtocolHandshake(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.InstanceName = "homelab"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		framer := newClientFramer(r)
+		sendHello(conn, framer, cfg)
+		writeMessage(conn, framer, server.TYPE_STATUS, LabStatus{Labwatch: LabwatchStatus{State: LABWATCH_RUNNING}})
+		writeMessage(conn, framer, server.TYPE_EVENT, loki.LogEvent{Seq: 7, Node: "cp1", Message: "etcd started"})
+		writeMessage(conn, framer, server.TYPE_EVENT, loki.LogEvent{Seq: 9, Node: "cp1", Message: "etcd ready"})
+	}))
+	defer srv.Close()
+
+	read := func(query string) [][]byte {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv)+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		ret := [][]byte{}
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				return ret
+			}
+			ret = append(ret, b)
+		}
+	}
+
+	// v1 clients get the bare payloads and no hello
+	v1 := read("")
+	if len(v1) != 3 {
+		t.Fatalf("v1 client got %d messages, want 3", len(v1))
+	}
+	status := LabStatus{}
+	if err := json.Unmarshal(v1[0], &status); err != nil || status.Labwatch.State != LABWATCH_RUNNING {
+		t.Errorf("v1 status %s", v1[0])
+	}
+	event := loki.LogEvent{}
+	if err := json.Unmarshal(v1[1], &event); err != nil || event.Message != "etcd started" {
+		t.Errorf("v1 event %s", v1[1])
+	}
+
+	// v2 clients are greeted first and get everything framed
+	v2 := read("?protocol=v2")
+	if len(v2) != 4 {
+		t.Fatalf("v2 client got %d messages, want 4", len(v2))
+	}
+	msgs := []streamMessage{}
+	for i, b := range v2 {
+		msg := streamMessage{}
+		if err := json.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Seq != uint64(i+1) || msg.TS.IsZero() {
+			t.Errorf("message %d framed as seq %d at %s", i, msg.Seq, msg.TS)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	hello := server.Hello{}
+	if err := json.Unmarshal(msgs[0].Data, &hello); err != nil || msgs[0].Type != server.TYPE_HELLO {
+		t.Fatalf("greeted with %s", v2[0])
+	}
+	if hello.Version != Version || hello.Instance != "homelab" || hello.Encoding != "json" || len(hello.Features) != len(websocketFeatures) {
+		t.Errorf("hello %+v", hello)
+	}
+	status = LabStatus{}
+	if err := json.Unmarshal(msgs[1].Data, &status); err != nil || msgs[1].Type != server.TYPE_STATUS || status.Labwatch.State != LABWATCH_RUNNING {
+		t.Errorf("v2 status %s", v2[1])
+	}
+	if msgs[2].Type != server.TYPE_EVENT || msgs[2].Prev != 0 || msgs[3].Type != server.TYPE_EVENT || msgs[3].Prev != 7 {
+		t.Errorf("v2 events %s then %s", v2[2], v2[3])
+	}
+}
+
+// v2 features asked for on a v1 websocket are rejected before the upgrade,
+// except filters which v1 clients have always been able to send unheeded
+func TestV1WebsocketFeatures(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DefaultExcludes = []string{"logs"}
+	for _, tt := range []struct {
+		query string
+		err   string
+	}{
+		{query: ""},
+		{query: "?host=cp1&level=error"},
+		{query: "?replay=true", err: "replay requires protocol=v2"},
+		{query: "?stream=auth", err: "stream requires protocol=v2"},
+		{query: `?label=app="etcd"`, err: "label requires protocol=v2"},
+		{query: "?protocol=v2&replay=true&stream=auth&label=app%3D%22etcd%22"},
+	} {
+		err := checkV1Params(httptest.NewRequest("GET", "/events"+tt.query, nil), eventV2Params)
+		if (err == nil) != (tt.err == "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("/events%s got %v, want %q", tt.query, err, tt.err)
+		}
+	}
+
+	for _, tt := range []struct {
+		query    string
+		encoding string
+		err      string
+		// logs says whether the view leaves the logs in
+		logs bool
+	}{
+		// v1 clients get the whole status, not even default-excludes applied
+		{query: "", logs: true},
+		{query: "?only=unhealthy", err: "only requires protocol=v2"},
+		{query: "?exclude=talos", err: "exclude requires protocol=v2"},
+		{query: "?fields=summary", err: "fields requires protocol=v2"},
+		{query: "?encoding=protobuf", encoding: ENCODING_PROTOBUF, err: "protobuf websocket frames require protocol=v2"},
+		{query: "?protocol=v2", logs: false},
+		{query: "?protocol=v2&exclude=", logs: true},
+		{query: "?protocol=v2&encoding=protobuf", encoding: ENCODING_PROTOBUF, logs: false},
+	} {
+		r := httptest.NewRequest("GET", "/status"+tt.query, nil)
+		view, err := requestView(r, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.encoding == "" {
+			tt.encoding = ENCODING_JSON
+		}
+		view, err = websocketView(r, view, tt.encoding)
+		if (err == nil) != (tt.err == "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("/status%s got %v, want %q", tt.query, err, tt.err)
+			continue
+		}
+		if err == nil {
+			if logs := view.apply(LabStatus{Logs: loki.LogStats{NumMessages: 3}}).Logs.NumMessages != 0; logs != tt.logs {
+				t.Errorf("/status%s left the logs in: %t", tt.query, logs)
+			}
+		}
+	}
+}
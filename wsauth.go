@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// originPolicy decides which pages may open a stream. Requests without an
+// Origin header come from non-browser clients and are always allowed; the
+// rest must be same-origin or on the allowlist. "*" allows any origin.
+type originPolicy struct {
+	allowed map[string]bool
+	any     bool
+}
+
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return "", fmt.Errorf("%q is not an origin such as https://dash.lan:3000", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+func newOriginPolicy(origins []string) (*originPolicy, error) {
+	p := &originPolicy{allowed: map[string]bool{}}
+	for _, o := range origins {
+		if o == "*" {
+			p.any = true
+			continue
+		}
+		n, err := normalizeOrigin(o)
+		if err != nil {
+			return nil, err
+		}
+		p.allowed[n] = true
+	}
+	return p, nil
+}
+
+func (p *originPolicy) check(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.any {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return p.allowed[strings.ToLower(u.Scheme+"://"+u.Host)]
+}
+
+// ticketIssuer hands out short-lived, single use tickets that stand in for
+// the stream token on a websocket URL. Browsers can't set headers on a
+// websocket, and a ticket that dies within seconds is harmless when the URL
+// ends up in history or a proxy log. Tickets are signed with a key that only
+// lives as long as the process.
+type ticketIssuer struct {
+	key  []byte
+	ttl  time.Duration
+	used map[string]time.Time
+	lock sync.Mutex
+}
+
+type Ticket struct {
+	Ticket  string    `json:"ticket"`
+	Expires time.Time `json:"expires"`
+}
+
+func newTicketIssuer(ttl time.Duration) *ticketIssuer {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &ticketIssuer{key: key, ttl: ttl, used: map[string]time.Time{}}
+}
+
+func (t *ticketIssuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (t *ticketIssuer) issue(now time.Time) Ticket {
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	expires := now.Add(t.ttl)
+	payload := strconv.FormatInt(expires.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(nonce)
+	return Ticket{Ticket: payload + "." + t.sign(payload), Expires: expires.Truncate(time.Second)}
+}
+
+// redeem reports whether ticket is genuine, unexpired and not used before,
+// marking it used
+func (t *ticketIssuer) redeem(ticket string, now time.Time) bool {
+	i := strings.LastIndex(ticket, ".")
+	if i < 0 {
+		return false
+	}
+	payload, sig := ticket[:i], ticket[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(t.sign(payload))) {
+		return false
+	}
+	expiry, _, _ := strings.Cut(payload, ".")
+	secs, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	expires := time.Unix(secs, 0)
+	if !now.Before(expires) {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for k, exp := range t.used {
+		if !now.Before(exp) {
+			delete(t.used, k)
+		}
+	}
+	if _, ok := t.used[payload]; ok {
+		return false
+	}
+	t.used[payload] = expires
+	return true
+}
+
+// streamAuth guards the streaming endpoints. The origin check applies
+// whether or not a stream token is configured.
+type streamAuth struct {
+	origins *originPolicy
+	token   string
+	tickets *ticketIssuer
+}
+
+func (a *streamAuth) allow(w http.ResponseWriter, r *http.Request) bool {
+	if !a.origins.check(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return false
+	}
+	if a.token == "" {
+		return true
+	}
+	if bearerMatches(r, a.token) {
+		return true
+	}
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" && a.tickets.redeem(ticket, time.Now()) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// guard serves next only to callers allow accepts
+func (a *streamAuth) guard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.allow(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// handleTicket issues a connection ticket to a caller holding the stream
+// token. It only takes the token in a header and only answers POST, so a
+// hostile page can't mint tickets with the user's ambient credentials.
+func handleTicket(a *streamAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if a.token == "" {
+			http.Error(w, "tickets disabled: no stream-token configured", http.StatusNotFound)
+			return
+		}
+		if !bearerMatches(r, a.token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		b, _ := json.Marshal(a.tickets.issue(time.Now()))
+		w.Write(b)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newAuthServer serves a websocket endpoint guarded the way /events and
+// /stream are
+func newAuthServer(t *testing.T, token string, origins ...string) (*httptest.Server, *streamAuth) {
+	t.Helper()
+	policy, err := newOriginPolicy(origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &streamAuth{origins: policy, token: token, tickets: newTicketIssuer(time.Minute)}
+	u := websocket.Upgrader{CheckOrigin: policy.check}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.allow(w, r) {
+			return
+		}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		conn.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, auth
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestUpgradeOrigins(t *testing.T) {
+	srv, _ := newAuthServer(t, "", "https://dash.lan:3000")
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"hostile origin", "https://evil.example", http.StatusForbidden},
+		{"hostile origin posing as a subdomain", "https://dash.lan.evil.example:3000", http.StatusForbidden},
+		{"allowlisted origin", "https://dash.lan:3000", http.StatusSwitchingProtocols},
+		{"allowlisted host on another port", "https://dash.lan:4000", http.StatusForbidden},
+		{"same origin", srv.URL, http.StatusSwitchingProtocols},
+		{"no origin, not a browser", "", http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(wsURL(srv), header)
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("no response: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgradeAnyOrigin(t *testing.T) {
+	srv, _ := newAuthServer(t, "", "*")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), http.Header{"Origin": {"https://anywhere.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestUpgradeTickets(t *testing.T) {
+	srv, auth := newAuthServer(t, "stream-secret")
+	dial := func(query string, header http.Header) int {
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL(srv)+query, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if resp == nil {
+			t.Fatalf("no response: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := dial("", nil); got != http.StatusUnauthorized {
+		t.Errorf("no credentials: got %d", got)
+	}
+	if got := dial("", http.Header{"Authorization": {"Bearer stream-secret"}}); got != http.StatusSwitchingProtocols {
+		t.Errorf("bearer token: got %d", got)
+	}
+
+	ticket := auth.tickets.issue(time.Now()).Ticket
+	if got := dial("?ticket="+ticket, nil); got != http.StatusSwitchingProtocols {
+		t.Errorf("fresh ticket: got %d", got)
+	}
+	if got := dial("?ticket="+ticket, nil); got != http.StatusUnauthorized {
+		t.Errorf("reused ticket: got %d", got)
+	}
+
+	expired := auth.tickets.issue(time.Now().Add(-2 * time.Minute)).Ticket
+	if got := dial("?ticket="+expired, nil); got != http.StatusUnauthorized {
+		t.Errorf("expired ticket: got %d", got)
+	}
+
+	// A hostile page holding a ticket is still turned away by its origin
+	hostile := auth.tickets.issue(time.Now()).Ticket
+	if got := dial("?ticket="+hostile, http.Header{"Origin": {"https://evil.example"}}); got != http.StatusForbidden {
+		t.Errorf("ticket from a hostile origin: got %d", got)
+	}
+}
+
+func TestTicketExpiry(t *testing.T) {
+	issuer := newTicketIssuer(30 * time.Second)
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		at     time.Duration
+		reuse  bool
+		tamper bool
+		want   bool
+	}{
+		{name: "within the ttl", at: 10 * time.Second, want: true},
+		{name: "just before expiry", at: 29 * time.Second, want: true},
+		{name: "at expiry", at: 30 * time.Second, want: false},
+		{name: "long expired", at: time.Hour, want: false},
+		{name: "used twice", at: time.Second, reuse: true, want: false},
+		{name: "tampered expiry", at: time.Second, tamper: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := issuer.issue(now).Ticket
+			if tt.tamper {
+				expiry, rest, _ := strings.Cut(ticket, ".")
+				ticket = expiry + "9." + rest
+			}
+			if tt.reuse && !issuer.redeem(ticket, now.Add(tt.at)) {
+				t.Fatal("first use refused")
+			}
+			if got := issuer.redeem(ticket, now.Add(tt.at)); got != tt.want {
+				t.Errorf("redeemed %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTicketEndpoint(t *testing.T) {
+	policy, _ := newOriginPolicy(nil)
+	auth := &streamAuth{origins: policy, token: "stream-secret", tickets: newTicketIssuer(time.Minute)}
+	h := handleTicket(auth)
+
+	for name, tt := range map[string]struct {
+		method string
+		token  string
+		want   int
+	}{
+		"get refused":   {http.MethodGet, "stream-secret", http.StatusMethodNotAllowed},
+		"no token":      {http.MethodPost, "", http.StatusUnauthorized},
+		"wrong token":   {http.MethodPost, "guess", http.StatusUnauthorized},
+		"token in hand": {http.MethodPost, "stream-secret", http.StatusOK},
+	} {
+		r := httptest.NewRequest(tt.method, "/auth/ticket", nil)
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", name, w.Code, tt.want)
+		}
+	}
+}
+
+// The REST endpoints showing lab state and log lines take the stream token
+// the same way the streams do
+func TestGuardedEndpoints(t *testing.T) {
+	policy, _ := newOriginPolicy(nil)
+	auth := &streamAuth{origins: policy, token: "stream-secret", tickets: newTicketIssuer(time.Minute)}
+	h := auth.guard(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("lab state")) })
+
+	for name, tt := range map[string]struct {
+		header string
+		query  string
+		want   int
+	}{
+		"anonymous": {want: http.StatusUnauthorized},
+		"bearer":    {header: "Bearer stream-secret", want: http.StatusOK},
+		"ticket":    {query: "?ticket=" + auth.tickets.issue(time.Now()).Ticket, want: http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/events/query"+tt.query, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", name, w.Code, tt.want)
+		}
+	}
+}